@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"old-school/internal/config"
+	"old-school/internal/models"
+	"old-school/internal/repository"
+	"old-school/internal/services"
+	"old-school/internal/utils"
+)
+
+// Console commands working on the identity stored in the data directory, run instead of the node
+const (
+	exportIdentityCommand = "export-identity"
+	importIdentityCommand = "import-identity"
+	rotateKeyCommand      = "rotate-key"
+)
+
+// isIdentityCommand reports whether the first argument asks for an identity command
+func isIdentityCommand(command string) bool {
+	return command == exportIdentityCommand || command == importIdentityCommand || command == rotateKeyCommand
+}
+
+// runIdentityCommand exports the identity to, or imports it from, the backup file named after
+// the configuration flags, or rotates the node key. The passphrase comes from IDENTITY_PASSPHRASE
+// or is asked for.
+func runIdentityCommand(command string, args []string) error {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+
+	backupPath := ""
+	switch {
+	case command == rotateKeyCommand && len(cfg.Args) != 0:
+		return fmt.Errorf("usage: distributed-app %s [-data-dir DIR]", command)
+	case command != rotateKeyCommand && len(cfg.Args) != 1:
+		return fmt.Errorf("usage: distributed-app %s [-data-dir DIR] FILE", command)
+	case command != rotateKeyCommand:
+		backupPath = cfg.Args[0]
+	}
+
+	pathManager := utils.NewPathManagerWithRoot(cfg.DataDir)
+	database, err := repository.NewSQLiteRepository(pathManager.GetDatabasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	identityService := services.NewIdentityService(database, pathManager, nil)
+	reader := bufio.NewReader(os.Stdin)
+
+	switch command {
+	case exportIdentityCommand:
+		passphrase, err := readPassphrase(reader, "Backup passphrase: ")
+		if err != nil {
+			return err
+		}
+		if os.Getenv("IDENTITY_PASSPHRASE") == "" {
+			confirmation, err := readPassphrase(reader, "Repeat passphrase: ")
+			if err != nil {
+				return err
+			}
+			if confirmation != passphrase {
+				return fmt.Errorf("passphrases do not match")
+			}
+		}
+
+		backup, err := identityService.ExportIdentity(passphrase)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(backupPath, backup, 0600); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		fmt.Printf("🔐 Identity exported to %s\n", backupPath)
+
+	case importIdentityCommand:
+		data, err := os.ReadFile(backupPath)
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		var backup models.EncryptedIdentityBackup
+		if err := json.Unmarshal(data, &backup); err != nil {
+			return fmt.Errorf("%s is not an identity backup: %w", backupPath, err)
+		}
+
+		passphrase, err := readPassphrase(reader, "Backup passphrase: ")
+		if err != nil {
+			return err
+		}
+
+		result, err := identityService.ImportIdentity(&backup, passphrase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔐 Restored identity %s with %d friend(s) and %d friend group(s) into %s\n",
+			result.PeerID, result.Friends, result.Groups, cfg.DataDir)
+
+	case rotateKeyCommand:
+		result, err := identityService.RotateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔑 Rotated node key from %s to %s\n", result.Rotation.OldPeerID, result.Rotation.NewPeerID)
+		fmt.Println("   Friends follow to the new peer ID on the next identify exchange with them.")
+	}
+
+	return nil
+}
+
+// readPassphrase returns IDENTITY_PASSPHRASE if set, otherwise it prompts for a passphrase,
+// hiding it while it is typed on a terminal
+func readPassphrase(reader *bufio.Reader, prompt string) (string, error) {
+	if passphrase := os.Getenv("IDENTITY_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Print(prompt)
+
+	// Typed passphrases are not echoed, piped ones are read as a line
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		return string(passphrase), nil
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
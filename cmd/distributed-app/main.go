@@ -6,73 +6,77 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"old-school/internal/config"
+	"old-school/internal/models"
 	"old-school/internal/services"
 	"old-school/internal/ui"
 )
 
-// showConnectionString displays the connection string for sharing
-func showConnectionString(appService *services.AppService) {
+// showInvite creates an invite code and displays it for sharing
+func showInvite(appService *services.AppService) {
 	connectionInfo := appService.GetP2PService().GetConnectionInfo()
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Println("📋 CONNECTION STRING FOR SHARING")
+	fmt.Println("🎟️  INVITE CODE FOR SHARING")
 	fmt.Println(strings.Repeat("=", 60))
 
-	// Show P2P addresses (extracted from local addresses)
-	fmt.Printf("🔗 P2P Listening Addresses:\n")
-	var p2pPort int
-	var localIP string
-
-	for _, addr := range connectionInfo.LocalAddresses {
-		fmt.Printf("   %s\n", addr)
-		// Extract the first non-localhost IP and port for manual connection
-		if strings.Contains(addr, "/ip4/") && !strings.Contains(addr, "127.0.0.1") && localIP == "" {
-			parts := strings.Split(addr, "/")
-			if len(parts) >= 5 {
-				localIP = parts[2] // IP address
-				if port, err := strconv.Atoi(parts[4]); err == nil {
-					p2pPort = port // TCP port
-				}
-			}
+	invite, err := appService.GetInviteService().CreateInvite(models.CreateInviteRequest{})
+	if err != nil {
+		fmt.Printf("❌ Failed to create invite: %v\n", err)
+	} else {
+		fmt.Println(invite.Code)
+		fmt.Println(strings.Repeat("-", 60))
+		fmt.Printf("📋 Share this code, it is valid until %s and can be redeemed once\n",
+			invite.Invite.ExpiresAt.Local().Format(time.RFC1123))
+		fmt.Printf("🤝 Whoever redeems it becomes your friend without a friend request\n")
+		fmt.Printf("🔗 Addresses in the invite:\n")
+		for _, addr := range invite.Invite.Addresses {
+			fmt.Printf("   %s\n", addr)
 		}
 	}
 
-	fmt.Println(strings.Repeat("-", 60))
-
-	if connectionInfo.PublicAddress != "" && connectionInfo.Port != 0 {
-		connectionString := fmt.Sprintf("%s:%d:%s",
-			connectionInfo.PublicAddress,
-			connectionInfo.Port,
-			connectionInfo.PeerID)
-		fmt.Printf("🌐 Auto-detected Connection String: %s\n", connectionString)
-		fmt.Printf("📋 Share this with others to connect to your node\n")
-		fmt.Printf("🔗 Public Address: %s:%d\n", connectionInfo.PublicAddress, connectionInfo.Port)
-	} else {
-		fmt.Printf("⚠️  Auto-detection failed - Node appears behind NAT\n")
-		if localIP != "" && p2pPort != 0 {
-			fmt.Printf("🔧 Manual Connection String (if server has public IP):\n")
-			fmt.Printf("   YOUR_PUBLIC_IP:%d:%s\n", p2pPort, connectionInfo.PeerID)
-			fmt.Printf("   Example: 93.183.82.171:%d:%s\n", p2pPort, connectionInfo.PeerID)
-			fmt.Printf("   ⚠️  Replace YOUR_PUBLIC_IP with your actual public IP address\n")
+	fmt.Printf("🆔 Peer ID: %s\n", connectionInfo.PeerID)
+	fmt.Printf("📊 NAT Status: %s\n", map[string]string{
+		models.ReachabilityPublic:  "Public (can accept connections)",
+		models.ReachabilityPrivate: "Behind NAT (needs relay)",
+		models.ReachabilityUnknown: "Unknown (AutoNAT has not heard back from peers yet)",
+	}[connectionInfo.Reachability])
+	if len(connectionInfo.ObservedAddresses) > 0 {
+		fmt.Printf("👀 Public addresses peers see us at:\n")
+		for _, addr := range connectionInfo.ObservedAddresses {
+			fmt.Printf("   %s\n", addr)
 		}
 	}
 
-	fmt.Printf("🆔 Peer ID: %s\n", connectionInfo.PeerID)
-	fmt.Printf("🔌 P2P Port: %d (NOT the web port!)\n", p2pPort)
-	fmt.Printf("📊 NAT Status: %s\n",
-		map[bool]string{true: "Public (can accept connections)", false: "Behind NAT (needs relay)"}[connectionInfo.IsPublicNode])
+	fmt.Println(strings.Repeat("=", 60) + "\n")
+}
 
-	fmt.Printf("\n💡 IMPORTANT:\n")
-	fmt.Printf("   - Use P2P port (%d) for peer connections, NOT web port\n", p2pPort)
-	fmt.Printf("   - Make sure port %d is open in your firewall\n", p2pPort)
-	fmt.Printf("   - Web interface is on a different port (for browser access only)\n")
+// redeemInvite connects to the node of an invite code and becomes its friend
+func redeemInvite(appService *services.AppService, code string) {
+	if code == "" {
+		fmt.Println("Usage: R <invite code>")
+		return
+	}
 
-	fmt.Println(strings.Repeat("=", 60) + "\n")
+	fmt.Println("🎟️  Redeeming invite...")
+	result, err := appService.GetInviteService().RedeemInvite(models.RedeemInviteRequest{Code: code})
+	if err != nil {
+		fmt.Printf("❌ Failed to redeem invite: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Connected to %s (%s)\n", result.PeerName, result.PeerID)
+	if result.FriendRequest != nil {
+		if result.FriendRequest.Status == models.FriendRequestAccepted {
+			fmt.Printf("🤝 You are now friends\n")
+		} else {
+			fmt.Printf("📨 Friend request sent, waiting for %s to accept\n", result.PeerName)
+		}
+	}
 }
 
 // showNodeInfo displays current node information
@@ -138,7 +142,8 @@ func showHelp() {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("🎮 CONSOLE COMMANDS")
 	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println("Q - Show Connection String (for sharing with others)")
+	fmt.Println("Q - Create an Invite Code (for sharing with others)")
+	fmt.Println("R <code> - Redeem an Invite Code and become friends")
 	fmt.Println("W - Show Current Node Info (status, peers, files)")
 	fmt.Println("H - Show this help message")
 	fmt.Println("Ctrl+C - Quit application")
@@ -155,11 +160,17 @@ func startConsoleInput(appService *services.AppService) {
 			continue
 		}
 
-		command := strings.ToUpper(strings.TrimSpace(input))
+		fields := strings.Fields(input)
+		command := ""
+		if len(fields) > 0 {
+			command = strings.ToUpper(fields[0])
+		}
 
 		switch command {
 		case "Q":
-			showConnectionString(appService)
+			showInvite(appService)
+		case "R":
+			redeemInvite(appService, strings.Join(fields[1:], ""))
 		case "W":
 			showNodeInfo(appService)
 		case "H":
@@ -174,16 +185,23 @@ func startConsoleInput(appService *services.AppService) {
 }
 
 func main() {
-	// Get configuration from environment variables
-	webPort := 6996
-	if envPort := os.Getenv("WEB_PORT"); envPort != "" {
-		if port, err := strconv.Atoi(envPort); err == nil {
-			webPort = port
+	// Identity backup commands work on the stored identity without starting the node
+	if len(os.Args) > 1 && isIdentityCommand(os.Args[1]) {
+		if err := runIdentityCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("❌ %s failed: %v", os.Args[1], err)
 		}
+		return
+	}
+
+	// Load configuration from flags, environment and the data directory config file
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	cfg.LogEffective()
 
 	// Initialize application services
-	appService := services.NewAppService()
+	appService := services.NewAppService(cfg)
 	defer func() {
 		if err := appService.Close(); err != nil {
 			log.Printf("Error closing app service: %v", err)
@@ -191,7 +209,7 @@ func main() {
 	}()
 
 	// Initialize WebView UI with configured port
-	webUI, err := ui.NewWebViewUI(appService, webPort)
+	webUI, err := ui.NewWebViewUI(appService, cfg.WebHost, cfg.WebPort)
 	if err != nil {
 		log.Fatalf("Failed to create WebView UI: %v", err)
 	}
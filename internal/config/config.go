@@ -0,0 +1,308 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ConfigFileName is the name of the optional config file inside the data directory
+	ConfigFileName = "config.json"
+
+	// DefaultWebPort is the preferred port for the web interface
+	DefaultWebPort = 6996
+
+	// DefaultP2PStartPort is where automatic P2P port discovery starts when no port is configured
+	DefaultP2PStartPort = 9000
+)
+
+// Sources of a configuration value, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Config holds the effective node configuration
+type Config struct {
+	DataDir  string `json:"data_dir"`
+	NodeName string `json:"node_name"`
+	WebHost  string `json:"web_host"`
+	WebPort  int    `json:"web_port"`
+	P2PPort  int    `json:"p2p_port"`  // 0 means pick the first free port from DefaultP2PStartPort
+	QUICPort int    `json:"quic_port"` // 0 means reuse P2PPort over UDP, or pick a free port
+
+	// Args holds the command line arguments left after the flags
+	Args []string `json:"-"`
+
+	sources map[string]string
+}
+
+// fileConfig mirrors Config for the config file, where every field is optional
+type fileConfig struct {
+	NodeName *string `json:"node_name"`
+	WebHost  *string `json:"web_host"`
+	WebPort  *int    `json:"web_port"`
+	P2PPort  *int    `json:"p2p_port"`
+	QUICPort *int    `json:"quic_port"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	dataDir := "space184"
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		dataDir = filepath.Join(homeDir, "space184")
+	}
+
+	return &Config{
+		DataDir: dataDir,
+		WebPort: DefaultWebPort,
+		sources: map[string]string{
+			"data_dir":  SourceDefault,
+			"node_name": SourceDefault,
+			"web_host":  SourceDefault,
+			"web_port":  SourceDefault,
+			"p2p_port":  SourceDefault,
+			"quic_port": SourceDefault,
+		},
+	}
+}
+
+// Load builds the effective configuration from defaults, the config file in the
+// data directory, environment variables and command line flags (in that order of precedence)
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("distributed-app", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", "", "data directory (env DATA_DIR, default ~/space184)")
+	nodeName := fs.String("name", "", "node display name (env NODE_NAME)")
+	webHost := fs.String("web-host", "", "web interface bind address (env WEB_HOST, default all interfaces)")
+	webPort := fs.Int("web-port", 0, "web interface port (env WEB_PORT, default 6996)")
+	p2pPort := fs.Int("p2p-port", 0, "P2P TCP listen port (env P2P_PORT, default first free port from 9000)")
+	quicPort := fs.Int("quic-port", 0, "P2P QUIC listen port (env QUIC_PORT, default same as P2P port)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	// The data directory has to be resolved first since it holds the config file
+	if value := os.Getenv("DATA_DIR"); value != "" {
+		cfg.set("data_dir", SourceEnv)
+		cfg.DataDir = value
+	}
+	if setFlags["data-dir"] {
+		cfg.set("data_dir", SourceFlag)
+		cfg.DataDir = *dataDir
+	}
+
+	absDataDir, err := expandPath(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data directory %q: %w", cfg.DataDir, err)
+	}
+	cfg.DataDir = absDataDir
+
+	if err := cfg.applyFile(filepath.Join(cfg.DataDir, ConfigFileName)); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if setFlags["name"] {
+		cfg.set("node_name", SourceFlag)
+		cfg.NodeName = *nodeName
+	}
+	if setFlags["web-host"] {
+		cfg.set("web_host", SourceFlag)
+		cfg.WebHost = *webHost
+	}
+	if setFlags["web-port"] {
+		cfg.set("web_port", SourceFlag)
+		cfg.WebPort = *webPort
+	}
+	if setFlags["p2p-port"] {
+		cfg.set("p2p_port", SourceFlag)
+		cfg.P2PPort = *p2pPort
+	}
+	if setFlags["quic-port"] {
+		cfg.set("quic_port", SourceFlag)
+		cfg.QUICPort = *quicPort
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// applyFile overlays values from the JSON config file if it exists
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var file fileConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if file.NodeName != nil {
+		c.set("node_name", SourceFile)
+		c.NodeName = *file.NodeName
+	}
+	if file.WebHost != nil {
+		c.set("web_host", SourceFile)
+		c.WebHost = *file.WebHost
+	}
+	if file.WebPort != nil {
+		c.set("web_port", SourceFile)
+		c.WebPort = *file.WebPort
+	}
+	if file.P2PPort != nil {
+		c.set("p2p_port", SourceFile)
+		c.P2PPort = *file.P2PPort
+	}
+	if file.QUICPort != nil {
+		c.set("quic_port", SourceFile)
+		c.QUICPort = *file.QUICPort
+	}
+
+	log.Printf("📄 Loaded config file: %s", path)
+	return nil
+}
+
+// applyEnv overlays values from environment variables
+func (c *Config) applyEnv() error {
+	if value := os.Getenv("NODE_NAME"); value != "" {
+		c.set("node_name", SourceEnv)
+		c.NodeName = value
+	}
+	if value := os.Getenv("WEB_HOST"); value != "" {
+		c.set("web_host", SourceEnv)
+		c.WebHost = value
+	}
+
+	ports := []struct {
+		env    string
+		key    string
+		target *int
+	}{
+		{"WEB_PORT", "web_port", &c.WebPort},
+		{"P2P_PORT", "p2p_port", &c.P2PPort},
+		{"QUIC_PORT", "quic_port", &c.QUICPort},
+	}
+
+	for _, port := range ports {
+		value := os.Getenv(port.env)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q: %w", port.env, value, err)
+		}
+		c.set(port.key, SourceEnv)
+		*port.target = parsed
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration values are usable
+func (c *Config) Validate() error {
+	if c.DataDir == "" {
+		return fmt.Errorf("data directory must not be empty")
+	}
+	if c.WebPort < 1 || c.WebPort > 65535 {
+		return fmt.Errorf("web port %d out of range", c.WebPort)
+	}
+	if c.P2PPort < 0 || c.P2PPort > 65535 {
+		return fmt.Errorf("P2P port %d out of range", c.P2PPort)
+	}
+	if c.QUICPort < 0 || c.QUICPort > 65535 {
+		return fmt.Errorf("QUIC port %d out of range", c.QUICPort)
+	}
+	if len(c.NodeName) > 64 {
+		return fmt.Errorf("node name must be at most 64 characters")
+	}
+	return nil
+}
+
+// WebAddress returns the host:port the web server should bind to
+func (c *Config) WebAddress() string {
+	return fmt.Sprintf("%s:%d", c.WebHost, c.WebPort)
+}
+
+// Source reports where the value for the given config key came from
+func (c *Config) Source(key string) string {
+	if source, exists := c.sources[key]; exists {
+		return source
+	}
+	return SourceDefault
+}
+
+// set records the source of a config key
+func (c *Config) set(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// LogEffective prints the effective configuration and where each value came from
+func (c *Config) LogEffective() {
+	nodeName := c.NodeName
+	if nodeName == "" {
+		nodeName = "(stored in database)"
+	}
+	webHost := c.WebHost
+	if webHost == "" {
+		webHost = "(all interfaces)"
+	}
+	p2pPort := "auto"
+	if c.P2PPort != 0 {
+		p2pPort = strconv.Itoa(c.P2PPort)
+	}
+	quicPort := "auto"
+	if c.QUICPort != 0 {
+		quicPort = strconv.Itoa(c.QUICPort)
+	}
+
+	log.Printf("⚙️ Effective configuration:")
+	log.Printf("   data_dir:  %s [%s]", c.DataDir, c.Source("data_dir"))
+	log.Printf("   node_name: %s [%s]", nodeName, c.Source("node_name"))
+	log.Printf("   web_host:  %s [%s]", webHost, c.Source("web_host"))
+	log.Printf("   web_port:  %d [%s]", c.WebPort, c.Source("web_port"))
+	log.Printf("   p2p_port:  %s [%s]", p2pPort, c.Source("p2p_port"))
+	log.Printf("   quic_port: %s [%s]", quicPort, c.Source("quic_port"))
+}
+
+// expandPath resolves a leading ~ and makes the path absolute
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+	}
+	return filepath.Abs(path)
+}
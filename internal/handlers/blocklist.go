@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandleBlockedPeers handles GET /api/blocked-peers requests listing the block list
+// and POST /api/blocked-peers requests blocking a peer
+func (h *Handler) HandleBlockedPeers(w http.ResponseWriter, r *http.Request) {
+	blockService := h.appService.GetBlockService()
+	if blockService == nil {
+		http.Error(w, "Block service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		blocked, err := blockService.GetBlockedPeers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.BlockedPeersResponse{
+			Blocked: blocked,
+			Count:   len(blocked),
+		})
+
+	case http.MethodPost:
+		var req models.BlockPeerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		blocked, err := blockService.BlockPeer(req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(blocked)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleBlockedPeer handles DELETE /api/blocked-peers/{peerID} requests unblocking a peer
+func (h *Handler) HandleBlockedPeer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peerID := strings.Trim(r.URL.Path[len("/api/blocked-peers/"):], "/")
+	if peerID == "" || strings.Contains(peerID, "/") {
+		http.Error(w, "Expected /api/blocked-peers/{peerID}", http.StatusBadRequest)
+		return
+	}
+
+	blockService := h.appService.GetBlockService()
+	if blockService == nil {
+		http.Error(w, "Block service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := blockService.UnblockPeer(peerID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandlePeerComments handles the comments we write on a peer's content:
+// POST /api/peer-comments/{peerID} comments on or reacts to one of the peer's files and
+// DELETE /api/peer-comments/{peerID}/{commentID} removes one of our comments
+func (h *Handler) HandlePeerComments(w http.ResponseWriter, r *http.Request) {
+	// Extract peer ID and optional comment ID from URL path
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/peer-comments/"):], "/"), "/")
+	if len(parts) == 0 || parts[0] == "" || len(parts) > 2 {
+		http.Error(w, "Expected /api/peer-comments/{peerID}[/{commentID}]", http.StatusBadRequest)
+		return
+	}
+	peerID := parts[0]
+
+	commentService := h.appService.GetCommentService()
+	if commentService == nil {
+		http.Error(w, "Comment service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		var req models.AddCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		comment, err := commentService.AddComment(peerID, req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		if err := commentService.DeleteOwnComment(peerID, parts[1]); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleComments handles GET /api/comments?path={file path} requests, listing every comment
+// on one of our files including the ones we hid
+func (h *Handler) HandleComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		http.Error(w, "path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	commentService := h.appService.GetCommentService()
+	if commentService == nil {
+		http.Error(w, "Comment service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	comments, err := commentService.GetComments(filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CommentsResponse{
+		Comments: comments,
+		Count:    len(comments),
+	})
+}
+
+// HandleComment handles moderation of comments on our content:
+// POST /api/comments/{commentID}/{hide|unhide} and DELETE /api/comments/{commentID}
+func (h *Handler) HandleComment(w http.ResponseWriter, r *http.Request) {
+	// Extract comment ID and optional action from URL path
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/comments/"):], "/"), "/")
+	if len(parts) == 0 || parts[0] == "" || len(parts) > 2 {
+		http.Error(w, "Expected /api/comments/{commentID}[/{hide|unhide}]", http.StatusBadRequest)
+		return
+	}
+	commentID := parts[0]
+
+	commentService := h.appService.GetCommentService()
+	if commentService == nil {
+		http.Error(w, "Comment service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var err error
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		err = commentService.DeleteComment(commentID)
+	case len(parts) == 2 && r.Method == http.MethodPost && (parts[1] == "hide" || parts[1] == "unhide"):
+		err = commentService.HideComment(commentID, parts[1] == "hide")
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		http.Error(w, "Unknown action: "+parts[1], http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
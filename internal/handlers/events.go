@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"old-school/internal/models"
+	"old-school/internal/services"
+)
+
+const (
+	// eventStreamHeartbeat keeps idle event streams open through proxies
+	eventStreamHeartbeat = 15 * time.Second
+
+	// eventStreamRetry is the reconnection delay suggested to the browser, in milliseconds
+	eventStreamRetry = 3000
+
+	// eventTypeResync tells a client it missed events and must reload its state
+	eventTypeResync = "resync"
+)
+
+// HandleEvents handles GET /api/events, streaming bus events to the web UI as Server-Sent Events.
+// Clients reconnecting with Last-Event-ID (or ?lastEventId=) get the events they missed replayed,
+// or a resync event if they fell too far behind. ?types= limits the stream to a comma separated list of event types.
+func (h *Handler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	eventBus := h.appService.GetServiceContainer().GetEventBus()
+	if eventBus == nil {
+		http.Error(w, "Event bus not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Parse the event type filter
+	var eventTypes map[string]bool
+	if typesParam := r.URL.Query().Get("types"); typesParam != "" {
+		eventTypes = make(map[string]bool)
+		for _, eventType := range strings.Split(typesParam, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				eventTypes[eventType] = true
+			}
+		}
+	}
+
+	// Resume after the last event the client saw, or start with new events
+	lastEventIDParam := r.Header.Get("Last-Event-ID")
+	if lastEventIDParam == "" {
+		lastEventIDParam = r.URL.Query().Get("lastEventId")
+	}
+
+	lastEventID := eventBus.LastEventID()
+	resumed := false
+	if lastEventIDParam != "" {
+		parsedID, err := strconv.ParseUint(lastEventIDParam, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventID = parsedID
+		resumed = true
+	}
+
+	events := make(chan models.Event, 64)
+	done := make(chan struct{})
+	unsubscribe, complete := eventBus.SubscribeEventsAfter(services.EventTypeAll, lastEventID, func(event models.Event) {
+		if eventTypes != nil && !eventTypes[event.Type] {
+			return
+		}
+		select {
+		case events <- event:
+		case <-done:
+		case <-r.Context().Done():
+		}
+	})
+	if unsubscribe == nil {
+		http.Error(w, "Event bus is closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer unsubscribe()
+	defer close(done)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry)
+
+	if resumed && !complete {
+		log.Printf("📡 Event stream client missed events after %d, asking it to resync", lastEventID)
+		h.writeServerSentEvent(w, models.Event{
+			ID:        eventBus.LastEventID(),
+			Type:      eventTypeResync,
+			Timestamp: time.Now(),
+		})
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event := <-events:
+			if err := h.writeServerSentEvent(w, event); err != nil {
+				log.Printf("Failed to write event %d to stream: %v", event.ID, err)
+				return
+			}
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeServerSentEvent writes one event in text/event-stream format
+func (h *Handler) writeServerSentEvent(w http.ResponseWriter, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandleFriendGroups handles GET /api/friend-groups requests listing the friend groups
+// and POST /api/friend-groups requests creating one
+func (h *Handler) HandleFriendGroups(w http.ResponseWriter, r *http.Request) {
+	friendService := h.appService.GetFriendService()
+	if friendService == nil {
+		http.Error(w, "Friend service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		groups, err := friendService.GetGroups()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.FriendGroupsResponse{
+			Groups: groups,
+			Count:  len(groups),
+		})
+
+	case http.MethodPost:
+		var req models.FriendGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		group, err := friendService.CreateGroup(req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(group)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleFriendGroup handles the /api/friend-groups/{name}/... routes:
+// GET, PUT (rename) and DELETE .../{name}, POST .../{name}/members adds a friend,
+// DELETE .../{name}/members/{peerID} removes one and POST .../{name}/reconnect dials the group's friends
+func (h *Handler) HandleFriendGroup(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/friend-groups/"):], "/"), "/")
+	if len(parts) == 0 || parts[0] == "" || len(parts) > 3 {
+		http.Error(w, "Expected /api/friend-groups/{name}[/members[/{peerID}]|/reconnect]", http.StatusBadRequest)
+		return
+	}
+	name := parts[0]
+
+	friendService := h.appService.GetFriendService()
+	if friendService == nil {
+		http.Error(w, "Friend service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var group *models.FriendGroup
+	var err error
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		group, err = friendService.GetGroup(name)
+
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		var req models.FriendGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		group, err = friendService.RenameGroup(name, req.Name)
+
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err := friendService.DeleteGroup(name); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		return
+
+	case len(parts) == 2 && parts[1] == "members" && r.Method == http.MethodPost:
+		var req models.FriendGroupMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		group, err = friendService.AddGroupMember(name, req.PeerID)
+
+	case len(parts) == 3 && parts[1] == "members" && r.Method == http.MethodDelete:
+		group, err = friendService.RemoveGroupMember(name, parts[2])
+
+	case len(parts) == 2 && parts[1] == "reconnect" && r.Method == http.MethodPost:
+		if err := friendService.AttemptReconnectToGroup(name); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Reconnection to friend group " + name + " completed",
+		})
+		return
+
+	case len(parts) >= 2 && parts[1] != "members" && parts[1] != "reconnect":
+		http.Error(w, "Unknown action: "+parts[1], http.StatusNotFound)
+		return
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
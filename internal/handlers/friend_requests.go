@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+// HandleFriendRequests handles GET and POST /api/friend-requests requests.
+// GET accepts optional ?direction=incoming|outgoing and ?status= filters.
+func (h *Handler) HandleFriendRequests(w http.ResponseWriter, r *http.Request) {
+	friendService := h.appService.GetFriendService()
+	if friendService == nil {
+		http.Error(w, "Friend service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		direction := r.URL.Query().Get("direction")
+		if direction != "" && direction != models.FriendRequestIncoming && direction != models.FriendRequestOutgoing {
+			http.Error(w, "direction must be incoming or outgoing", http.StatusBadRequest)
+			return
+		}
+
+		requests, err := friendService.GetFriendRequests(direction, r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.FriendRequestsResponse{
+			Requests: requests,
+			Count:    len(requests),
+		})
+
+	case http.MethodPost:
+		var req models.SendFriendRequestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.PeerID == "" {
+			http.Error(w, "peer_id is required", http.StatusBadRequest)
+			return
+		}
+
+		request, err := friendService.SendFriendRequest(req.PeerID, req.PeerName, req.Message)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(request)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleFriendRequest handles POST /api/friend-requests/{peerID}/{accept|decline|cancel} requests
+func (h *Handler) HandleFriendRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract peer ID and action from URL path
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/friend-requests/"):], "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "Expected /api/friend-requests/{peerID}/{accept|decline|cancel}", http.StatusBadRequest)
+		return
+	}
+	peerID, action := parts[0], parts[1]
+
+	friendService := h.appService.GetFriendService()
+	if friendService == nil {
+		http.Error(w, "Friend service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var request *models.FriendRequest
+	var err error
+	switch action {
+	case models.FriendUpdateAccept:
+		request, err = friendService.AcceptFriendRequest(peerID)
+	case models.FriendUpdateDecline:
+		request, err = friendService.DeclineFriendRequest(peerID)
+	case models.FriendUpdateCancel:
+		request, err = friendService.CancelFriendRequest(peerID)
+	default:
+		http.Error(w, "Unknown action: "+action, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// writeServiceError maps validation and not found errors from the services to HTTP status codes
+func writeServiceError(w http.ResponseWriter, err error) {
+	var notFoundErr utils.NotFoundError
+	var validationErr utils.ValidationError
+
+	switch {
+	case errors.As(err, &notFoundErr):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &validationErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
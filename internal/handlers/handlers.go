@@ -244,15 +244,29 @@ func (h *Handler) HandlePeerAvatar(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Invalid request path", http.StatusBadRequest)
 }
 
-// HandleFriends handles GET /api/friends requests
+// HandleFriends handles GET /api/friends requests, optionally filtered with ?group={name}
 func (h *Handler) HandleFriends(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		friends, err := h.appService.GetDatabaseService().GetFriends()
+		var friends []models.Friend
+		var err error
+		if group := r.URL.Query().Get("group"); group != "" {
+			friendService := h.appService.GetFriendService()
+			if friendService == nil {
+				http.Error(w, "Friend service not available", http.StatusServiceUnavailable)
+				return
+			}
+			friends, err = friendService.GetGroupFriends(group)
+		} else {
+			friends, err = h.appService.GetDatabaseService().GetFriends()
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeServiceError(w, err)
 			return
 		}
+		if friendService := h.appService.GetFriendService(); friendService != nil {
+			friendService.AttachReconnectStatus(friends)
+		}
 
 		response := models.FriendsResponse{
 			Friends: friends,
@@ -274,14 +288,14 @@ func (h *Handler) HandleFriends(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err := h.appService.GetDatabaseService().AddFriend(req.PeerID, req.PeerName)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		// The peer only becomes a friend once they accept the request
+		if _, err := h.appService.GetFriendService().SendFriendRequest(req.PeerID, req.PeerName, ""); err != nil {
+			writeServiceError(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(models.StatusResponse{Status: "success", Message: "Friend added successfully"})
+		json.NewEncoder(w).Encode(models.StatusResponse{Status: "success", Message: "Friend request sent"})
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -309,6 +323,9 @@ func (h *Handler) HandleFriend(w http.ResponseWriter, r *http.Request) {
 		// Find the friend with matching peer ID
 		for _, friend := range friends {
 			if friend.PeerID == peerID {
+				if friendService := h.appService.GetFriendService(); friendService != nil {
+					friend.Reconnect = friendService.GetReconnectStatus(peerID)
+				}
 				w.Header().Set("Content-Type", "application/json")
 				json.NewEncoder(w).Encode(friend)
 				return
@@ -319,7 +336,7 @@ func (h *Handler) HandleFriend(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		// Remove friend
-		err := h.appService.GetDatabaseService().RemoveFriend(peerID)
+		err := h.appService.GetFriendService().RemoveFriend(peerID)
 		if err != nil {
 			if err.Error() == "friend not found" {
 				http.Error(w, err.Error(), http.StatusNotFound)
@@ -384,6 +401,34 @@ func (h *Handler) HandlePeerFriends(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandlePeerAddresses handles GET /api/peer-addresses/{peerID} requests
+func (h *Handler) HandlePeerAddresses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peerID := r.URL.Path[len("/api/peer-addresses/"):]
+	if peerID == "" {
+		http.Error(w, "Peer ID is required", http.StatusBadRequest)
+		return
+	}
+
+	addresses, err := h.appService.GetDatabaseService().GetPeerAddresses(peerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := models.PeerAddressesResponse{
+		PeerID:    peerID,
+		Addresses: addresses,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // HandlePeerDocs handles GET/POST /api/peer-docs/{peerID} and /api/peer-docs/{peerID}/{filename} requests
 func (h *Handler) HandlePeerDocs(w http.ResponseWriter, r *http.Request) {
 	// Handle POST requests for downloads
@@ -636,7 +681,8 @@ func (h *Handler) isValidImageFile(filename string) bool {
 	return false
 }
 
-// HandleSyncFriendFiles handles POST /api/sync-friend-files requests
+// HandleSyncFriendFiles handles POST /api/sync-friend-files requests, syncing every friend,
+// one friend with ?peer_id= or the friends of a group with ?group=
 func (h *Handler) HandleSyncFriendFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -649,20 +695,24 @@ func (h *Handler) HandleSyncFriendFiles(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check if specific peer ID is provided
+	// Check if specific peer ID or group is provided
 	peerID := r.URL.Query().Get("peer_id")
+	group := r.URL.Query().Get("group")
 
 	var err error
 	if peerID != "" {
 		// Sync specific friend
 		err = friendService.SyncSpecificFriendFiles(peerID)
+	} else if group != "" {
+		// Sync the friends of a group
+		err = friendService.SyncGroupFilesMetadata(group)
 	} else {
 		// Sync all friends
 		err = friendService.SyncFriendFilesMetadata()
 	}
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -691,6 +741,37 @@ func (h *Handler) HandlePeerGalleries(w http.ResponseWriter, r *http.Request) {
 
 	peerID := pathParts[0]
 
+	// GET /api/peer-galleries/{peerID}[/...]?type={image|audio|video|docs} uses the unified media protocol
+	if mediaTypeStr := r.URL.Query().Get("type"); mediaTypeStr != "" {
+		var mediaType models.MediaType
+
+		switch mediaTypeStr {
+		case "images", "image":
+			mediaType = models.MediaTypeImage
+		case "audio":
+			mediaType = models.MediaTypeAudio
+		case "video":
+			mediaType = models.MediaTypeVideo
+		case "docs":
+			mediaType = models.MediaTypeDocs
+		default:
+			http.Error(w, "Invalid media type. Use 'image', 'audio', 'video', or 'docs'", http.StatusBadRequest)
+			return
+		}
+
+		switch len(pathParts) {
+		case 1:
+			h.handlePeerMediaGalleriesList(w, r, peerID, mediaType)
+		case 2:
+			h.handlePeerMediaGalleryDetails(w, r, peerID, mediaType, pathParts[1])
+		case 3:
+			h.handlePeerMediaFile(w, r, peerID, mediaType, pathParts[1], pathParts[2])
+		default:
+			http.Error(w, "Invalid request path", http.StatusBadRequest)
+		}
+		return
+	}
+
 	// Route based on path length
 	switch len(pathParts) {
 	case 1:
@@ -751,7 +832,21 @@ func (h *Handler) handlePeerGalleryImage(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// Request image from peer via P2P and cache it
+	// Stream the image from the peer over the blob protocol into the cache
+	pathValidator := &utils.PathValidator{}
+	if pathValidator.ValidateGalleryName(galleryName) == nil && pathValidator.ValidateFilename(imageName) == nil {
+		if pathManager := h.appService.GetServiceContainer().GetPathManager(); pathManager != nil {
+			imagePath := filepath.Join(pathManager.GetPeerGalleryPath(peerID, galleryName), imageName)
+			err := h.appService.GetP2PService().DownloadPeerBlob(peerID, models.MediaTypeImage, galleryName, imageName, imagePath)
+			if err == nil {
+				h.serveCachedImage(w, r, imagePath, imageName)
+				return
+			}
+			log.Printf("⚠️ Blob download of image %s from %s failed, falling back to JSON transfer: %v", imageName, peerID, err)
+		}
+	}
+
+	// Peers without the blob protocol still answer the JSON image request
 	imageResponse, err := h.appService.GetP2PService().RequestPeerGalleryImage(peerID, galleryName, imageName)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get image from peer: %v", err), http.StatusInternalServerError)
@@ -867,6 +962,154 @@ func (h *Handler) downloadImage(peerID, galleryName, imageName string, imageData
 	return nil
 }
 
+// handlePeerMediaGalleriesList handles requests for a peer's galleries of a given media type
+func (h *Handler) handlePeerMediaGalleriesList(w http.ResponseWriter, r *http.Request, peerID string, mediaType models.MediaType) {
+	// Request media galleries list from peer via P2P
+	galleriesResponse, err := h.appService.GetP2PService().RequestPeerMediaGalleries(peerID, mediaType)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get %s galleries from peer: %v", mediaType, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(galleriesResponse)
+}
+
+// handlePeerMediaGalleryDetails handles requests for a specific media gallery of a peer
+func (h *Handler) handlePeerMediaGalleryDetails(w http.ResponseWriter, r *http.Request, peerID string, mediaType models.MediaType, galleryName string) {
+	// Request specific media gallery from peer via P2P
+	galleryResponse, err := h.appService.GetP2PService().RequestPeerMediaGallery(peerID, mediaType, galleryName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get %s gallery from peer: %v", mediaType, err), http.StatusInternalServerError)
+		return
+	}
+
+	if galleryResponse.Gallery == nil {
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(galleryResponse.Gallery)
+}
+
+// handlePeerMediaFile handles requests for a specific file from a peer's media gallery
+func (h *Handler) handlePeerMediaFile(w http.ResponseWriter, r *http.Request, peerID string, mediaType models.MediaType, galleryName, fileName string) {
+	pathValidator := &utils.PathValidator{}
+	if err := pathValidator.ValidateGalleryName(galleryName); err != nil {
+		http.Error(w, "Invalid gallery name", http.StatusBadRequest)
+		return
+	}
+	if err := pathValidator.ValidateFilename(fileName); err != nil {
+		http.Error(w, "Invalid file name", http.StatusBadRequest)
+		return
+	}
+
+	galleryDir := h.getPeerMediaGalleryPath(peerID, mediaType, galleryName)
+	if galleryDir == "" {
+		http.Error(w, "Path manager not available", http.StatusInternalServerError)
+		return
+	}
+
+	// Serve the cached copy if the file was downloaded before
+	filePath := filepath.Join(galleryDir, fileName)
+	if _, err := os.Stat(filePath); err == nil {
+		h.servePeerMediaFile(w, r, filePath, fileName, mediaType, nil)
+		return
+	}
+
+	// Stream the file from the peer over the blob protocol into the cache
+	err := h.appService.GetP2PService().DownloadPeerBlob(peerID, mediaType, galleryName, fileName, filePath)
+	if err == nil {
+		h.servePeerMediaFile(w, r, filePath, fileName, mediaType, nil)
+		return
+	}
+	log.Printf("⚠️ Blob download of %s file %s from %s failed, falling back to JSON transfer: %v", mediaType, fileName, peerID, err)
+
+	// Peers without the blob protocol still answer the JSON media file request
+	fileResponse, err := h.appService.GetP2PService().RequestPeerMediaFile(peerID, mediaType, galleryName, fileName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get %s file from peer: %v", mediaType, err), http.StatusInternalServerError)
+		return
+	}
+
+	if fileResponse.FileData == "" {
+		http.Error(w, fmt.Sprintf("%s file not found", strings.Title(string(mediaType))), http.StatusNotFound)
+		return
+	}
+
+	fileData, err := base64.StdEncoding.DecodeString(fileResponse.FileData)
+	if err != nil {
+		http.Error(w, "Failed to decode file data", http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.MkdirAll(galleryDir, 0755); err != nil {
+		log.Printf("Warning: Failed to create peer %s gallery directory: %v", mediaType, err)
+	} else if err := os.WriteFile(filePath, fileData, 0644); err != nil {
+		log.Printf("Warning: Failed to save downloaded %s file: %v", mediaType, err)
+	} else {
+		log.Printf("🗂️ Downloaded %s file %s for peer %s in gallery %s", mediaType, fileName, peerID, galleryName)
+		// Serve from disk so range requests work for audio and video seeking
+		h.servePeerMediaFile(w, r, filePath, fileName, mediaType, nil)
+		return
+	}
+
+	h.servePeerMediaFile(w, r, "", fileName, mediaType, fileData)
+}
+
+// servePeerMediaFile serves a peer media file from disk, or from memory when filePath is empty.
+// HTML docs from peers are sanitized the same way as local ones.
+func (h *Handler) servePeerMediaFile(w http.ResponseWriter, r *http.Request, filePath, fileName string, mediaType models.MediaType, fileData []byte) {
+	h.setMediaContentType(w, fileName, mediaType)
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if mediaType == models.MediaTypeDocs && (ext == ".html" || ext == ".htm") {
+		if filePath != "" {
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				http.Error(w, "Failed to read file", http.StatusInternalServerError)
+				return
+			}
+			fileData = content
+		}
+
+		sanitizedContent := h.sanitizeHTML(string(fileData))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(sanitizedContent)))
+		w.Write([]byte(sanitizedContent))
+		return
+	}
+
+	if filePath != "" {
+		http.ServeFile(w, r, filePath)
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileData)))
+	w.Write(fileData)
+}
+
+// getPeerMediaGalleryPath returns the local download directory for a peer's media gallery
+func (h *Handler) getPeerMediaGalleryPath(peerID string, mediaType models.MediaType, galleryName string) string {
+	pathManager := h.appService.GetServiceContainer().GetPathManager()
+	if pathManager == nil {
+		return ""
+	}
+
+	switch mediaType {
+	case models.MediaTypeImage:
+		return pathManager.GetPeerGalleryPath(peerID, galleryName)
+	case models.MediaTypeAudio:
+		return pathManager.GetPeerAudioGalleryPath(peerID, galleryName)
+	case models.MediaTypeVideo:
+		return pathManager.GetPeerVideoGalleryPath(peerID, galleryName)
+	case models.MediaTypeDocs:
+		return pathManager.GetPeerDocsGalleryPath(peerID, galleryName)
+	default:
+		return ""
+	}
+}
+
 // HandleDownloadedContent handles serving downloaded peer content
 func (h *Handler) HandleDownloadedContent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
@@ -1809,17 +2052,46 @@ func (h *Handler) RegisterRoutes() {
 
 	// API routes
 	http.HandleFunc("/api/info", h.HandleGetInfo)
+	http.HandleFunc("/api/profile", h.HandleProfile)
+	http.HandleFunc("/api/peer-profiles/", h.HandlePeerProfile)
 	http.HandleFunc("/api/create", h.HandleCreate)
 	http.HandleFunc("/api/discover", h.HandleDiscover)
 	http.HandleFunc("/api/peers", h.HandlePeers)
 	http.HandleFunc("/api/monitor", h.HandleMonitorStatus)
 	http.HandleFunc("/api/connect-ip", h.HandleConnectByIP)
+	http.HandleFunc("/api/invites", h.HandleInvites)
+	http.HandleFunc("/api/invites/redeem", h.HandleRedeemInvite)
+	http.HandleFunc("/api/invites/", h.HandleInvite)
 	http.HandleFunc("/api/peer-avatar/", h.HandlePeerAvatar)
 	http.HandleFunc("/api/friends", h.HandleFriends)
 	http.HandleFunc("/api/friends/", h.HandleFriend)
+	http.HandleFunc("/api/friend-requests", h.HandleFriendRequests)
+	http.HandleFunc("/api/friend-requests/", h.HandleFriendRequest)
+	http.HandleFunc("/api/friend-groups", h.HandleFriendGroups)
+	http.HandleFunc("/api/friend-groups/", h.HandleFriendGroup)
+	http.HandleFunc("/api/blocked-peers", h.HandleBlockedPeers)
+	http.HandleFunc("/api/blocked-peers/", h.HandleBlockedPeer)
 	http.HandleFunc("/api/peer-friends/", h.HandlePeerFriends)
+	http.HandleFunc("/api/peer-addresses/", h.HandlePeerAddresses)
+	http.HandleFunc("/api/conversations", h.HandleConversations)
+	http.HandleFunc("/api/conversations/", h.HandleConversation)
+	http.HandleFunc("/api/posts", h.HandlePosts)
+	http.HandleFunc("/api/timeline", h.HandleTimeline)
+	http.HandleFunc("/api/comments", h.HandleComments)
+	http.HandleFunc("/api/comments/", h.HandleComment)
+	http.HandleFunc("/api/peer-comments/", h.HandlePeerComments)
+	http.HandleFunc("/api/notifications", h.HandleNotifications)
+	http.HandleFunc("/api/notifications/", h.HandleNotification)
+	http.HandleFunc("/api/visibility", h.HandleVisibility)
+	http.HandleFunc("/api/privacy", h.HandlePrivacy)
+	http.HandleFunc("/api/identity/export", h.HandleIdentityExport)
+	http.HandleFunc("/api/identity/import", h.HandleIdentityImport)
+	http.HandleFunc("/api/identity/rotations", h.HandleKeyRotations)
 	http.HandleFunc("/api/peer-docs/", h.HandlePeerDocs)
 
+	// Live updates
+	http.HandleFunc("/api/events", h.HandleEvents)
+
 	// Files sync routes
 	http.HandleFunc("/api/sync-friend-files", h.HandleSyncFriendFiles)
 
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"old-school/internal/models"
+)
+
+// maxIdentityBackupSize bounds the request body of an identity import
+const maxIdentityBackupSize = 10 << 20
+
+// HandleIdentityExport handles POST /api/identity/export requests returning our identity
+// encrypted with the passphrase in the body as a downloadable backup file
+func (h *Handler) HandleIdentityExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireLoopback(w, r) {
+		return
+	}
+
+	identityService := h.appService.GetIdentityService()
+	if identityService == nil {
+		http.Error(w, "Identity service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req models.ExportIdentityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	backup, err := identityService.ExportIdentity(req.Passphrase)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("old-school-identity-%s.json", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(backup)
+}
+
+// HandleIdentityImport handles POST /api/identity/import requests restoring an identity backup
+func (h *Handler) HandleIdentityImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireLoopback(w, r) {
+		return
+	}
+
+	identityService := h.appService.GetIdentityService()
+	if identityService == nil {
+		http.Error(w, "Identity service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req models.ImportIdentityRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIdentityBackupSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := identityService.ImportIdentity(req.Backup, req.Passphrase)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// HandleKeyRotations handles GET /api/identity/rotations requests listing our key type and key
+// rotations and POST /api/identity/rotations requests rotating to a new Ed25519 key
+func (h *Handler) HandleKeyRotations(w http.ResponseWriter, r *http.Request) {
+	identityService := h.appService.GetIdentityService()
+	if identityService == nil {
+		http.Error(w, "Identity service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rotations, err := identityService.GetKeyRotations()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rotations)
+
+	case http.MethodPost:
+		if !requireLoopback(w, r) {
+			return
+		}
+
+		result, err := identityService.RotateKey()
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// requireLoopback refuses requests that don't come from this machine. The web server has no
+// authentication and may listen on every interface, so handing out or replacing the node key is
+// only allowed to local clients; the backup passphrase is no protection since the caller picks it.
+func requireLoopback(w http.ResponseWriter, r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	http.Error(w, "Only available from the machine the node runs on", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandleInvites handles GET /api/invites requests listing the invites we created
+// and POST /api/invites requests creating an invite code
+func (h *Handler) HandleInvites(w http.ResponseWriter, r *http.Request) {
+	inviteService := h.appService.GetInviteService()
+	if inviteService == nil {
+		http.Error(w, "Invite service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		invites, err := inviteService.GetInvites()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.InvitesResponse{
+			Invites: invites,
+			Count:   len(invites),
+		})
+
+	case http.MethodPost:
+		var req models.CreateInviteRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		invite, err := inviteService.CreateInvite(req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(invite)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleInvite handles DELETE /api/invites/{token} requests revoking an invite
+func (h *Handler) HandleInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.Trim(r.URL.Path[len("/api/invites/"):], "/")
+	if token == "" || strings.Contains(token, "/") {
+		http.Error(w, "Expected /api/invites/{token}", http.StatusBadRequest)
+		return
+	}
+
+	inviteService := h.appService.GetInviteService()
+	if inviteService == nil {
+		http.Error(w, "Invite service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := inviteService.RevokeInvite(token); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// HandleRedeemInvite handles POST /api/invites/redeem requests connecting to the node of an
+// invite code and, unless turned off, becoming its friend
+func (h *Handler) HandleRedeemInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	inviteService := h.appService.GetInviteService()
+	if inviteService == nil {
+		http.Error(w, "Invite service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req models.RedeemInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := inviteService.RedeemInvite(req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
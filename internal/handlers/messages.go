@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandleConversations handles GET /api/conversations requests
+func (h *Handler) HandleConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatService := h.appService.GetChatService()
+	if chatService == nil {
+		http.Error(w, "Chat service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	conversations, err := chatService.GetConversations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ConversationsResponse{
+		Conversations: conversations,
+		Count:         len(conversations),
+	})
+}
+
+// HandleConversation handles the /api/conversations/{peerID}/... routes:
+// GET .../messages?before={id}&limit={n} pages through the history,
+// POST .../messages sends a message and POST .../read marks the conversation read
+func (h *Handler) HandleConversation(w http.ResponseWriter, r *http.Request) {
+	// Extract peer ID and action from URL path
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/conversations/"):], "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "Expected /api/conversations/{peerID}/{messages|read}", http.StatusBadRequest)
+		return
+	}
+	peerID, action := parts[0], parts[1]
+
+	chatService := h.appService.GetChatService()
+	if chatService == nil {
+		http.Error(w, "Chat service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case action == "messages" && r.Method == http.MethodGet:
+		beforeID, limit := 0, 0
+		if before := r.URL.Query().Get("before"); before != "" {
+			parsed, err := strconv.Atoi(before)
+			if err != nil || parsed < 0 {
+				http.Error(w, "Invalid before parameter", http.StatusBadRequest)
+				return
+			}
+			beforeID = parsed
+		}
+		if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+			parsed, err := strconv.Atoi(limitParam)
+			if err != nil || parsed < 0 {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		response, err := chatService.GetMessages(peerID, beforeID, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case action == "messages" && r.Method == http.MethodPost:
+		var req models.SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		message, err := chatService.SendMessage(peerID, req.Body)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(message)
+
+	case action == "read" && r.Method == http.MethodPost:
+		count, err := chatService.MarkConversationRead(peerID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"marked_read": count})
+
+	case action == "messages" || action == "read":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.Error(w, "Unknown action: "+action, http.StatusNotFound)
+	}
+}
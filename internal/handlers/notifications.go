@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandleNotifications handles GET /api/notifications?unread=true&before={id}&limit={n} requests,
+// returning notifications newest first along with the unread count for the badge
+func (h *Handler) HandleNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	notificationService := h.appService.GetNotificationService()
+	if notificationService == nil {
+		http.Error(w, "Notification service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	beforeID, limit := 0, 0
+	if before := r.URL.Query().Get("before"); before != "" {
+		parsed, err := strconv.Atoi(before)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid before parameter", http.StatusBadRequest)
+			return
+		}
+		beforeID = parsed
+	}
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	response, err := notificationService.GetNotifications(unreadOnly, beforeID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleNotification handles the /api/notifications/... routes:
+// POST .../read marks every notification read, GET and PUT .../settings read and change the muted types,
+// POST .../{id}/read marks one notification read and DELETE .../{id} removes it
+func (h *Handler) HandleNotification(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/notifications/"):], "/"), "/")
+	if len(parts) == 0 || parts[0] == "" || len(parts) > 2 {
+		http.Error(w, "Expected /api/notifications/{read|settings|{id}[/read]}", http.StatusBadRequest)
+		return
+	}
+
+	notificationService := h.appService.GetNotificationService()
+	if notificationService == nil {
+		http.Error(w, "Notification service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(parts) == 1 && parts[0] == "read" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		count, err := notificationService.MarkAllRead()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"marked_read": count})
+		return
+	}
+
+	if len(parts) == 1 && parts[0] == "settings" {
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(notificationService.GetSettings())
+
+		case http.MethodPut:
+			var req models.NotificationSettings
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			settings, err := notificationService.UpdateSettings(req)
+			if err != nil {
+				writeServiceError(w, err)
+				return
+			}
+			json.NewEncoder(w).Encode(settings)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "read" && r.Method == http.MethodPost:
+		err = notificationService.MarkRead(id)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		err = notificationService.DeleteNotification(id)
+	case len(parts) == 2 && parts[1] != "read":
+		http.Error(w, "Unknown action: "+parts[1], http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"old-school/internal/models"
+)
+
+// HandlePosts handles GET and POST /api/posts requests.
+// GET lists our own posts, newest first, paged with ?before={created_at}&limit={n}.
+func (h *Handler) HandlePosts(w http.ResponseWriter, r *http.Request) {
+	postService := h.appService.GetPostService()
+	if postService == nil {
+		http.Error(w, "Post service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		before, limit, ok := parsePostPaging(w, r)
+		if !ok {
+			return
+		}
+
+		response, err := postService.GetOwnPosts(before, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPost:
+		var req models.CreatePostRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		post, err := postService.CreatePost(req.Body, req.Media)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(post)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleTimeline handles GET /api/timeline requests, returning our posts merged with our friends' posts,
+// newest first, paged with ?before={created_at}&limit={n}
+func (h *Handler) HandleTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postService := h.appService.GetPostService()
+	if postService == nil {
+		http.Error(w, "Post service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	before, limit, ok := parsePostPaging(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := postService.GetTimeline(before, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parsePostPaging reads the before (RFC 3339 timestamp, usually the created_at of the last post shown)
+// and limit query parameters, writing a 400 response if either is invalid
+func parsePostPaging(w http.ResponseWriter, r *http.Request) (time.Time, int, bool) {
+	var before time.Time
+	if beforeParam := r.URL.Query().Get("before"); beforeParam != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, beforeParam)
+		if err != nil {
+			http.Error(w, "Invalid before parameter, expected an RFC 3339 timestamp", http.StatusBadRequest)
+			return time.Time{}, 0, false
+		}
+		before = parsed
+	}
+
+	limit := 0
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return time.Time{}, 0, false
+		}
+		limit = parsed
+	}
+
+	return before, limit, true
+}
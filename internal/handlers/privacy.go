@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"old-school/internal/models"
+)
+
+// HandlePrivacy handles GET and PUT /api/privacy requests reading and changing who may learn
+// our friends list, avatar, name and files table
+func (h *Handler) HandlePrivacy(w http.ResponseWriter, r *http.Request) {
+	accessService := h.appService.GetAccessService()
+	if accessService == nil {
+		http.Error(w, "Access service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(accessService.GetPrivacySettings())
+
+	case http.MethodPut:
+		var req models.PrivacySettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		settings, err := accessService.UpdatePrivacySettings(req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settings)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
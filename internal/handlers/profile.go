@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"old-school/internal/models"
+)
+
+// HandleProfile handles GET /api/profile requests returning our profile with its signed record
+// and PUT /api/profile requests changing it
+func (h *Handler) HandleProfile(w http.ResponseWriter, r *http.Request) {
+	profileService := h.appService.GetProfileService()
+	if profileService == nil {
+		http.Error(w, "Profile service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := profileService.GetProfile()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+
+	case http.MethodPut:
+		var req models.UpdateProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		profile, err := profileService.UpdateProfile(req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandlePeerProfile handles GET /api/peer-profiles/{peerID} requests returning the newest
+// verified profile record we have of a peer
+func (h *Handler) HandlePeerProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peerID := strings.Trim(r.URL.Path[len("/api/peer-profiles/"):], "/")
+	if peerID == "" || strings.Contains(peerID, "/") {
+		http.Error(w, "Expected /api/peer-profiles/{peerID}", http.StatusBadRequest)
+		return
+	}
+
+	profileService := h.appService.GetProfileService()
+	if profileService == nil {
+		http.Error(w, "Profile service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	profile, err := profileService.GetPeerProfile(peerID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
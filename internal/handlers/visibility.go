@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"old-school/internal/models"
+)
+
+// HandleVisibility handles the /api/visibility requests controlling what peers may see of our content.
+// GET lists the rules and the default, or with ?path= returns the rule that applies to that path.
+// PUT sets the visibility of a path, or the default when no path is given.
+// DELETE ?path= removes a rule so the path inherits from its directory again.
+func (h *Handler) HandleVisibility(w http.ResponseWriter, r *http.Request) {
+	accessService := h.appService.GetAccessService()
+	if accessService == nil {
+		http.Error(w, "Access service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	contentPath := r.URL.Query().Get("path")
+
+	switch r.Method {
+	case http.MethodGet:
+		var response interface{}
+		var err error
+		if contentPath != "" {
+			response, err = accessService.GetEffectiveVisibility(contentPath)
+		} else {
+			response, err = accessService.GetRules()
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPut:
+		var req models.SetVisibilityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		rule, err := accessService.SetVisibility(req)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodDelete:
+		if err := accessService.RemoveVisibility(contentPath); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
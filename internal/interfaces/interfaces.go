@@ -1,6 +1,8 @@
 package interfaces
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"old-school/internal/models"
@@ -30,11 +32,109 @@ type FriendsRepository interface {
 	SavePeerFriends(peerID string, friends []models.Friend) error
 	IsFriend(peerID string) (bool, error)
 	UpdateFriendStatus(peerID string, isOnline bool) error
+	ConfirmFriend(peerID, peerName string) error
+}
+
+type FriendGroupsRepository interface {
+	CreateFriendGroup(name string) (*models.FriendGroup, error)
+	GetFriendGroup(name string, id int) (*models.FriendGroup, error)
+	GetFriendGroups() ([]models.FriendGroup, error)
+	RenameFriendGroup(name, newName string) error
+	DeleteFriendGroup(name string) error
+	AddFriendGroupMember(name, peerID string) error
+	RemoveFriendGroupMember(name, peerID string) error
+	IsFriendGroupMember(name, peerID string) (bool, error)
+}
+
+type BlockedPeersRepository interface {
+	BlockPeer(blocked *models.BlockedPeer) error
+	UnblockPeer(peerID string) error
+	GetBlockedPeers() ([]models.BlockedPeer, error)
+	IsPeerBlocked(peerID string) (bool, error)
+	PurgePeerData(peerID string) error
+}
+
+type PeerProfilesRepository interface {
+	SavePeerProfile(record *models.ProfileRecord) (bool, error)
+	GetPeerProfile(peerID string) (*models.ProfileRecord, error)
+}
+
+type KeyRotationRepository interface {
+	MigratePeerID(oldPeerID, newPeerID string) (bool, error)
+}
+
+type InvitesRepository interface {
+	SaveInvite(invite *models.InviteRecord) error
+	GetInvites() ([]models.InviteRecord, error)
+	RedeemInvite(token, peerID string, redeemedAt time.Time) (*models.InviteRecord, error)
+	DeleteInvite(token string) error
+}
+
+type PeerAddressesRepository interface {
+	ObservePeerAddress(peerID, address, transport string, connected bool) error
+	RecordPeerAddressFailures(peerID string, addresses []string) error
+	GetPeerAddresses(peerID string) ([]models.PeerAddress, error)
+}
+
+type FriendRequestsRepository interface {
+	SaveFriendRequest(request *models.FriendRequest) error
+	GetFriendRequest(peerID string) (*models.FriendRequest, error)
+	GetFriendRequests(direction, status string) ([]models.FriendRequest, error)
+	UpdateFriendRequestStatus(peerID, status string, delivered bool) error
+	SetFriendRequestDelivered(peerID string, delivered bool) error
+}
+
+type MessagesRepository interface {
+	SaveMessage(message *models.DirectMessage) (bool, error)
+	GetMessage(messageID string) (*models.DirectMessage, error)
+	GetMessages(peerID string, beforeID, limit int) ([]models.DirectMessage, error)
+	GetPendingMessages(peerID string) ([]models.DirectMessage, error)
+	GetConversations() ([]models.Conversation, error)
+	MarkMessageDelivered(messageID string, deliveredAt time.Time) error
+	UpdateMessageSignature(messageID string, signature []byte) error
+	MarkMessagesRead(peerID string, messageIDs []string, readAt time.Time) ([]string, error)
+	MarkConversationRead(peerID string, readAt time.Time) ([]string, error)
+	GetPendingReadReceipts(peerID string) ([]string, error)
+	ClearPendingReadReceipts(messageIDs []string) error
+}
+
+type PostsRepository interface {
+	SavePost(post *models.Post) (bool, error)
+	GetPostsSince(authorID string, since time.Time, afterPostID string, limit int) ([]models.Post, error)
+	GetPosts(authorID string, before time.Time, limit int) ([]models.Post, error)
+	GetTimeline(selfID string, before time.Time, limit int) ([]models.Post, error)
+	GetLatestPostTime(authorID string) (time.Time, error)
+}
+
+type CommentsRepository interface {
+	SaveComment(comment *models.Comment) (bool, error)
+	GetComment(commentID string) (*models.Comment, error)
+	GetComments(filePath, fileHash string, includeHidden bool) ([]models.Comment, error)
+	SetCommentHidden(commentID string, hidden bool) error
+	DeleteComment(commentID string) error
+	IsCommentDeleted(commentID string) (bool, error)
+}
+
+type NotificationsRepository interface {
+	SaveNotification(notification *models.Notification) error
+	GetNotifications(unreadOnly bool, beforeID, limit int) ([]models.Notification, error)
+	CountUnreadNotifications() (int, error)
+	MarkNotificationRead(id int) error
+	MarkAllNotificationsRead() (int, error)
+	DeleteNotification(id int) error
+}
+
+type ContentVisibilityRepository interface {
+	SetContentVisibility(rule *models.ContentVisibility) error
+	GetContentVisibilities() ([]models.ContentVisibility, error)
+	DeleteContentVisibility(path string) error
 }
 
 type FilesRepository interface {
 	FileExists(filePath string) (bool, string, error)
+	GetFileRecord(filePath, peerID string) (*models.FileRecord, error)
 	UpsertFileRecord(filePath, hash string, size int64, extension, fileType, peerID string) error
+	CountPeerFiles(peerID string) (int, error)
 	GetFiles() ([]models.FileRecord, error)
 	DeleteFileRecord(fileID int) error
 	DeleteFileRecordByPath(filePath string) error
@@ -45,6 +145,18 @@ type DatabaseService interface {
 	SettingsRepository
 	ConnectionRepository
 	FriendsRepository
+	FriendGroupsRepository
+	BlockedPeersRepository
+	PeerProfilesRepository
+	KeyRotationRepository
+	InvitesRepository
+	PeerAddressesRepository
+	FriendRequestsRepository
+	MessagesRepository
+	PostsRepository
+	CommentsRepository
+	NotificationsRepository
+	ContentVisibilityRepository
 	FilesRepository
 	Close() error
 }
@@ -65,14 +177,20 @@ type AvatarService interface {
 	SavePeerAvatar(peerID peer.ID, filename string, data []byte) error
 }
 
+// MessageHandler serves one request type of the P2P message protocol
 type MessageHandler interface {
-	Handle(payload interface{}, peerID peer.ID) error
 	MessageType() string
+	ResponseType() string
+	Timeout() time.Duration
+	NewRequest() interface{}
+	Handle(request interface{}, peerID peer.ID) (interface{}, error)
 }
 
+// MessageRouter dispatches P2P requests to the handler registered for their message type
 type MessageRouter interface {
 	RegisterHandler(messageType string, handler MessageHandler)
-	RouteMessage(messageType string, payload interface{}, peerID peer.ID) error
+	GetHandler(messageType string) (MessageHandler, bool)
+	RouteMessage(messageType string, decode func(v interface{}) error, peerID peer.ID) (string, interface{}, error)
 }
 
 type NetworkService interface {
@@ -23,6 +23,9 @@ type Doc struct {
 	ModifiedAt  time.Time `json:"modified_at"`
 	Size        int64     `json:"size"`
 	ContentType string    `json:"content_type"` // "text" for .txt files, "html" for .md files (converted to HTML)
+
+	// Comments and reactions, filled in when the doc is served to a peer
+	Comments *ContentComments `json:"comments,omitempty"`
 }
 
 // MediaType represents the type of media
@@ -41,6 +44,9 @@ type MediaGallery struct {
 	MediaType MediaType `json:"media_type"`
 	FileCount int       `json:"file_count"`
 	Files     []string  `json:"files"`
+
+	// Comments and reactions keyed by file name, filled in when the gallery is served to a peer
+	Comments map[string]*ContentComments `json:"comments,omitempty"`
 }
 
 
@@ -80,7 +86,8 @@ type DiscoveryRequest struct {
 	PeerID string `json:"peerId"`
 }
 
-// IPConnectionRequest represents a request to connect to a node by IP address
+// IPConnectionRequest represents a request to connect to a node by IP address.
+// The IP may be IPv4, IPv6, a DNS name or a multiaddr, which makes Port unused.
 type IPConnectionRequest struct {
 	IP     string `json:"ip"`
 	Port   int    `json:"port"`
@@ -89,13 +96,22 @@ type IPConnectionRequest struct {
 
 // ConnectionInfo represents the connection information for sharing
 type ConnectionInfo struct {
-	PeerID         string   `json:"peerId"`
-	PublicAddress  string   `json:"publicAddress,omitempty"`
-	Port           int      `json:"port,omitempty"`
-	LocalAddresses []string `json:"localAddresses"`
-	IsPublicNode   bool     `json:"isPublicNode"`
+	PeerID            string   `json:"peerId"`
+	PublicAddress     string   `json:"publicAddress,omitempty"`
+	Port              int      `json:"port,omitempty"`
+	LocalAddresses    []string `json:"localAddresses"`
+	ObservedAddresses []string `json:"observedAddresses,omitempty"` // public addresses peers see us at
+	Reachability      string   `json:"reachability"`
+	IsPublicNode      bool     `json:"isPublicNode"`
 }
 
+// Reachability of this node as determined by AutoNAT
+const (
+	ReachabilityUnknown = "unknown"
+	ReachabilityPublic  = "public"
+	ReachabilityPrivate = "private"
+)
+
 // PeerInfo stores information about connected peers for JSON serialization
 type PeerInfoJSON struct {
 	ID             string    `json:"id"`
@@ -142,12 +158,556 @@ type PeerListResponse struct {
 
 // Friend represents a friend in the friends list
 type Friend struct {
-	ID       int        `json:"id"`
-	PeerID   string     `json:"peer_id"`
-	PeerName string     `json:"peer_name"`
-	AddedAt  time.Time  `json:"added_at"`
-	LastSeen *time.Time `json:"last_seen"`
-	IsOnline bool       `json:"is_online"`
+	ID       int            `json:"id"`
+	PeerID   string         `json:"peer_id"`
+	PeerName string         `json:"peer_name"`
+	AddedAt  time.Time      `json:"added_at"`
+	LastSeen *time.Time     `json:"last_seen"`
+	IsOnline bool           `json:"is_online"`
+	Mutual   bool           `json:"mutual"`            // both sides accepted a friend request
+	Groups   []string       `json:"groups,omitempty"`  // names of the friend groups the friend belongs to
+	Profile  *ProfileRecord `json:"profile,omitempty"` // signed profile, relayed by peers sharing their friends
+
+	// Reconnect supervisor state, filled in for the friends API
+	Reconnect *ReconnectStatus `json:"reconnect,omitempty"`
+}
+
+// Reconnect states of a friend
+const (
+	ReconnectConnected = "connected"
+	ReconnectWaiting   = "waiting" // offline, the next attempt is scheduled
+	ReconnectDialing   = "dialing"
+)
+
+// Ways a reconnect reached a friend
+const (
+	ReconnectViaAddresses = "addresses" // an address we observed for the friend
+	ReconnectViaDHT       = "dht"       // addresses found by a DHT lookup
+	ReconnectViaRelay     = "relay"     // a circuit through a connected relay
+)
+
+// ReconnectStatus is the reconnect supervisor's view of a friend
+type ReconnectStatus struct {
+	State        string     `json:"state"`
+	Attempts     int        `json:"attempts"` // failed attempts since the friend was last connected
+	LastAttempt  *time.Time `json:"last_attempt,omitempty"`
+	NextAttempt  *time.Time `json:"next_attempt,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	ConnectedVia string     `json:"connected_via,omitempty"`
+}
+
+// Transports of an observed peer address
+const (
+	TransportTCP   = "tcp"
+	TransportQUIC  = "quic"
+	TransportRelay = "relay"
+	TransportOther = "other"
+)
+
+// MaxPeerAddresses is the number of addresses kept per peer, the least recently seen are dropped
+const MaxPeerAddresses = 32
+
+// PeerAddress is a multiaddr observed for a peer, either announced by it or connected to
+type PeerAddress struct {
+	PeerID        string     `json:"peer_id"`
+	Address       string     `json:"address"`
+	Transport     string     `json:"transport"`
+	FirstSeen     time.Time  `json:"first_seen"`
+	LastSeen      time.Time  `json:"last_seen"`
+	LastConnected *time.Time `json:"last_connected,omitempty"`
+	Successes     int        `json:"successes"`
+	Failures      int        `json:"failures"` // failed dials since the address was added
+}
+
+// PeerAddressesResponse represents the response for the address book of a peer
+type PeerAddressesResponse struct {
+	PeerID    string        `json:"peer_id"`
+	Addresses []PeerAddress `json:"addresses"`
+}
+
+// MaxFriendGroupNameLength is the longest friend group name accepted
+const MaxFriendGroupNameLength = 64
+
+// FriendGroup is a named set of friends, such as "family" or "work", used as an audience
+type FriendGroup struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Members   []string  `json:"members"` // peer IDs
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FriendGroupsResponse represents the response for the friend groups list
+type FriendGroupsResponse struct {
+	Groups []FriendGroup `json:"groups"`
+	Count  int           `json:"count"`
+}
+
+// FriendGroupRequest represents a request to create or rename a friend group
+type FriendGroupRequest struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members,omitempty"` // initial members when creating
+}
+
+// FriendGroupMemberRequest represents a request to add a friend to a group
+type FriendGroupMemberRequest struct {
+	PeerID string `json:"peer_id"`
+}
+
+// BlockedPeer is a peer refused at the network layer, before any stream opens
+type BlockedPeer struct {
+	PeerID    string    `json:"peer_id"`
+	PeerName  string    `json:"peer_name,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockedPeersResponse represents the response for the block list
+type BlockedPeersResponse struct {
+	Blocked []BlockedPeer `json:"blocked"`
+	Count   int           `json:"count"`
+}
+
+// BlockPeerRequest represents a request to block a peer. With Purge set everything stored about
+// the peer is deleted as well: downloads, its friends list, connection history and file records.
+type BlockPeerRequest struct {
+	PeerID   string `json:"peer_id"`
+	PeerName string `json:"peer_name,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Purge    bool   `json:"purge,omitempty"`
+}
+
+// Friend request directions
+const (
+	FriendRequestIncoming = "incoming"
+	FriendRequestOutgoing = "outgoing"
+)
+
+// Friend request statuses
+const (
+	FriendRequestPending   = "pending"
+	FriendRequestAccepted  = "accepted"
+	FriendRequestDeclined  = "declined"
+	FriendRequestCancelled = "cancelled"
+	FriendRequestRemoved   = "removed" // the friendship was ended after being accepted
+)
+
+// Actions carried by a FriendUpdateMessage
+const (
+	FriendUpdateAccept   = "accept"
+	FriendUpdateDecline  = "decline"
+	FriendUpdateCancel   = "cancel"
+	FriendUpdateUnfriend = "unfriend"
+)
+
+// FriendRequest is the latest friend request exchanged with a peer
+type FriendRequest struct {
+	ID        int       `json:"id"`
+	PeerID    string    `json:"peer_id"`
+	PeerName  string    `json:"peer_name"`
+	Direction string    `json:"direction"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Delivered bool      `json:"delivered"` // the peer has been told about the current status
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FriendRequestsResponse represents the response for the friend requests list
+type FriendRequestsResponse struct {
+	Requests []FriendRequest `json:"requests"`
+	Count    int             `json:"count"`
+}
+
+// SendFriendRequestRequest represents a request to send a friend request to a peer
+type SendFriendRequestRequest struct {
+	PeerID   string `json:"peer_id"`
+	PeerName string `json:"peer_name"`
+	Message  string `json:"message"`
+}
+
+// FriendRequestMessage asks a peer to become friends
+type FriendRequestMessage struct {
+	PeerName string `json:"peer_name"`
+	Message  string `json:"message,omitempty"`
+}
+
+// FriendRequestMessageResponse reports whether a friend request is pending or was accepted right away
+type FriendRequestMessageResponse struct {
+	Status string `json:"status"`
+}
+
+// FriendUpdateMessage tells a peer a friend request was answered or cancelled, or the friendship ended
+type FriendUpdateMessage struct {
+	Action   string `json:"action"`
+	PeerName string `json:"peer_name"`
+}
+
+// FriendUpdateMessageResponse acknowledges a FriendUpdateMessage
+type FriendUpdateMessageResponse struct {
+	Success bool `json:"success"`
+}
+
+// Direct message directions
+const (
+	MessageIncoming = "incoming"
+	MessageOutgoing = "outgoing"
+)
+
+// Direct message statuses. Outgoing messages stay pending in the outbox until the friend acknowledges them.
+const (
+	MessageStatusPending   = "pending"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
+// MaxDirectMessageLength is the largest message body accepted, in bytes
+const MaxDirectMessageLength = 16 * 1024
+
+// DirectMessage is a chat message exchanged with a friend
+type DirectMessage struct {
+	ID          int        `json:"id"`
+	MessageID   string     `json:"message_id"`
+	PeerID      string     `json:"peer_id"` // the friend the conversation is with
+	Direction   string     `json:"direction"`
+	Body        string     `json:"body"`
+	Status      string     `json:"status"`
+	SentAt      time.Time  `json:"sent_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	Signature   []byte     `json:"signature"`
+}
+
+// Conversation summarizes the messages exchanged with one friend
+type Conversation struct {
+	PeerID        string    `json:"peer_id"`
+	PeerName      string    `json:"peer_name"`
+	LastMessage   string    `json:"last_message"`
+	LastDirection string    `json:"last_direction"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	UnreadCount   int       `json:"unread_count"`
+	PendingCount  int       `json:"pending_count"` // messages waiting in the outbox
+}
+
+// ConversationsResponse represents the response for the conversations list
+type ConversationsResponse struct {
+	Conversations []Conversation `json:"conversations"`
+	Count         int            `json:"count"`
+}
+
+// MessagesResponse represents a page of a conversation's history, oldest first
+type MessagesResponse struct {
+	PeerID   string          `json:"peer_id"`
+	Messages []DirectMessage `json:"messages"`
+	Count    int             `json:"count"`
+	HasMore  bool            `json:"has_more"` // older messages exist before the first one
+}
+
+// SendMessageRequest represents a request to send a direct message
+type SendMessageRequest struct {
+	Body string `json:"body"`
+}
+
+// DirectMessagePayload carries a signed direct message between peers.
+// The signature covers every other field and is made with the sender's node key.
+type DirectMessagePayload struct {
+	MessageID string `json:"message_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Body      string `json:"body"`
+	SentAt    int64  `json:"sent_at"` // unix milliseconds
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"`
+}
+
+// DirectMessageAck is the delivery receipt for a DirectMessagePayload
+type DirectMessageAck struct {
+	MessageID   string `json:"message_id"`
+	DeliveredAt int64  `json:"delivered_at"` // unix milliseconds
+}
+
+// MessageReceiptPayload tells the sender that messages were read
+type MessageReceiptPayload struct {
+	MessageIDs []string `json:"message_ids"`
+	ReadAt     int64    `json:"read_at"` // unix milliseconds
+}
+
+// MessageReceiptAck acknowledges a MessageReceiptPayload
+type MessageReceiptAck struct {
+	Success bool `json:"success"`
+}
+
+// MessageEvent describes a direct message being received, delivered or read
+type MessageEvent struct {
+	PeerID     string   `json:"peer_id"`
+	MessageIDs []string `json:"message_ids"`
+	Body       string   `json:"body,omitempty"`
+}
+
+// MaxPostLength is the largest post body accepted, in bytes
+const MaxPostLength = 64 * 1024
+
+// MaxPostMedia is the largest number of media references attached to a post
+const MaxPostMedia = 20
+
+// PostMedia references a media file in the author's galleries.
+// Friends fetch it with the media file request of the P2P protocol.
+type PostMedia struct {
+	MediaType MediaType `json:"media_type"`
+	Gallery   string    `json:"gallery"`
+	File      string    `json:"file"`
+}
+
+// Post is a text post with optional media, written by us or a friend
+type Post struct {
+	ID         int         `json:"id"`
+	PostID     string      `json:"post_id"`
+	AuthorID   string      `json:"author_id"`
+	AuthorName string      `json:"author_name"`
+	Body       string      `json:"body"`
+	Media      []PostMedia `json:"media"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Own        bool        `json:"own"` // written by this node
+}
+
+// CreatePostRequest represents a request to publish a post
+type CreatePostRequest struct {
+	Body  string      `json:"body"`
+	Media []PostMedia `json:"media"`
+}
+
+// PostsResponse represents a list of posts, newest first
+type PostsResponse struct {
+	Posts   []Post `json:"posts"`
+	Count   int    `json:"count"`
+	HasMore bool   `json:"has_more"` // older posts exist after the last one
+}
+
+// PostPayload carries a post between peers
+type PostPayload struct {
+	PostID     string      `json:"post_id"`
+	AuthorName string      `json:"author_name"`
+	Body       string      `json:"body"`
+	Media      []PostMedia `json:"media,omitempty"`
+	CreatedAt  int64       `json:"created_at"` // unix milliseconds
+}
+
+// PostAnnounceAck acknowledges an announced post
+type PostAnnounceAck struct {
+	Success bool `json:"success"`
+}
+
+// GetPostsRequest asks a friend for its posts created after Since, oldest first
+type GetPostsRequest struct {
+	Since       int64  `json:"since"`                   // unix milliseconds, 0 for the beginning
+	SincePostID string `json:"since_post_id,omitempty"` // with Since, the last post already received
+	Limit       int    `json:"limit"`
+}
+
+// GetPostsResponse returns a friend's posts, oldest first
+type GetPostsResponse struct {
+	Posts   []PostPayload `json:"posts"`
+	HasMore bool          `json:"has_more"` // more posts follow the last one
+}
+
+// PostEvent describes a post being created or received
+type PostEvent struct {
+	PostID     string `json:"post_id"`
+	AuthorID   string `json:"author_id"`
+	AuthorName string `json:"author_name"`
+}
+
+// Comment kinds
+const (
+	CommentKindComment  = "comment"
+	CommentKindReaction = "reaction" // the body is a single emoji
+)
+
+// MaxCommentLength is the largest comment body accepted, in bytes
+const MaxCommentLength = 4 * 1024
+
+// MaxReactionLength is the largest reaction accepted, in bytes
+const MaxReactionLength = 32
+
+// Comment is a comment or reaction on a doc or media file, stored on the node that owns the file.
+// It is keyed by the file's path and hash in the files table and signed by its author.
+type Comment struct {
+	ID         int       `json:"id"`
+	CommentID  string    `json:"comment_id"`
+	OwnerID    string    `json:"owner_id"`
+	FilePath   string    `json:"file_path"`
+	FileHash   string    `json:"file_hash"`
+	AuthorID   string    `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Kind       string    `json:"kind"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	Hidden     bool      `json:"hidden,omitempty"` // hidden by the owner, only the owner sees it
+	PublicKey  []byte    `json:"public_key"`
+	Signature  []byte    `json:"signature"`
+}
+
+// ContentComments are the visible comments and reaction counts of one file
+type ContentComments struct {
+	FilePath  string         `json:"file_path"`
+	FileHash  string         `json:"file_hash"`
+	Comments  []Comment      `json:"comments"`
+	Reactions map[string]int `json:"reactions"`
+}
+
+// CommentsResponse represents the response for a list of comments
+type CommentsResponse struct {
+	Comments []Comment `json:"comments"`
+	Count    int       `json:"count"`
+}
+
+// AddCommentRequest represents a request to comment on or react to a peer's file
+type AddCommentRequest struct {
+	FilePath string `json:"file_path"`
+	FileHash string `json:"file_hash"`
+	Kind     string `json:"kind"`
+	Body     string `json:"body"`
+}
+
+// CommentAck acknowledges a comment sent to the content owner
+type CommentAck struct {
+	CommentID string `json:"comment_id"`
+	Stored    bool   `json:"stored"` // false when the same comment or reaction was already stored
+}
+
+// DeleteCommentMessage asks the content owner to remove one of our comments
+type DeleteCommentMessage struct {
+	CommentID string `json:"comment_id"`
+}
+
+// DeleteCommentAck acknowledges a DeleteCommentMessage
+type DeleteCommentAck struct {
+	Success bool `json:"success"`
+}
+
+// CommentEvent describes a comment being added or removed on our content
+type CommentEvent struct {
+	CommentID string `json:"comment_id"`
+	FilePath  string `json:"file_path"`
+	AuthorID  string `json:"author_id"`
+	Kind      string `json:"kind"`
+}
+
+// Notification types
+const (
+	NotificationFriendOnline   = "friend_online"
+	NotificationFriendRequest  = "friend_request"
+	NotificationFriendAccepted = "friend_accepted"
+	NotificationFriendFiles    = "friend_files"
+	NotificationMessage        = "message"
+	NotificationPost           = "post"
+	NotificationComment        = "comment"
+)
+
+// NotificationTypes lists every notification type, in the order the settings show them
+var NotificationTypes = []string{
+	NotificationFriendOnline,
+	NotificationFriendRequest,
+	NotificationFriendAccepted,
+	NotificationFriendFiles,
+	NotificationMessage,
+	NotificationPost,
+	NotificationComment,
+}
+
+// Notification records something a friend did while we may not have been looking.
+// Repeated notifications of the same type from the same peer are merged while unread and Count says how many.
+type Notification struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"`
+	PeerID    string    `json:"peer_id"`
+	PeerName  string    `json:"peer_name"`
+	Body      string    `json:"body"`
+	Ref       string    `json:"ref,omitempty"` // ID of the message, post, comment or file it is about
+	Count     int       `json:"count"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationsResponse represents a page of notifications, newest first
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	Count         int            `json:"count"`
+	Unread        int            `json:"unread"` // unread notifications in total, for the badge
+	HasMore       bool           `json:"has_more"`
+}
+
+// NotificationSettings lists the notification types that are muted
+type NotificationSettings struct {
+	Muted []string `json:"muted"`
+}
+
+// NotificationsReadEvent reports the unread count after notifications were marked read
+type NotificationsReadEvent struct {
+	Unread int `json:"unread"`
+}
+
+// Content visibility levels
+const (
+	VisibilityPublic  = "public"  // any validated peer
+	VisibilityFriends = "friends" // confirmed friends only
+	VisibilityGroup   = "group"   // members of a named friend group
+	VisibilityPrivate = "private" // nobody but us
+)
+
+// ContentVisibility is an access rule for a doc, media file or gallery directory. The path is relative
+// to the space184 directory like the files table; a rule on a directory covers everything below it
+// unless a deeper rule overrides it.
+type ContentVisibility struct {
+	Path       string    `json:"path"`
+	Visibility string    `json:"visibility"`
+	Group      string    `json:"group,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// EffectiveVisibility is the rule that applies to a path and where it comes from
+type EffectiveVisibility struct {
+	Path          string `json:"path"`
+	Visibility    string `json:"visibility"`
+	Group         string `json:"group,omitempty"`
+	InheritedFrom string `json:"inherited_from,omitempty"` // path of the rule, empty when the default applies
+}
+
+// ContentVisibilityResponse lists the visibility rules and the default for content without a rule
+type ContentVisibilityResponse struct {
+	Rules        []ContentVisibility `json:"rules"`
+	Count        int                 `json:"count"`
+	Default      string              `json:"default"`
+	DefaultGroup string              `json:"default_group,omitempty"`
+}
+
+// SetVisibilityRequest represents a request to set the visibility of a path, or of the default when Path is empty
+type SetVisibilityRequest struct {
+	Path       string `json:"path"`
+	Visibility string `json:"visibility"`
+	Group      string `json:"group,omitempty"`
+}
+
+// Privacy audiences deciding who learns a piece of our identity
+const (
+	PrivacyAnyone  = "anyone"  // every peer speaking the app protocol, strangers included
+	PrivacyFriends = "friends" // only friends
+	PrivacyNobody  = "nobody"  // no peer
+)
+
+// Identity items whose disclosure the privacy settings control
+const (
+	PrivacyItemFriendsList = "friends_list"
+	PrivacyItemAvatar      = "avatar"
+	PrivacyItemName        = "name"
+	PrivacyItemFiles       = "files"
+)
+
+// PrivacySettings holds the audience of each identity item sent during identify and over P2P requests
+type PrivacySettings struct {
+	FriendsList string `json:"friends_list"`
+	Avatar      string `json:"avatar"`
+	Name        string `json:"name"`
+	Files       string `json:"files"`
 }
 
 // FriendsResponse represents the response for friends list
@@ -239,6 +799,280 @@ type MediaFileResponse struct {
 	Size      int       `json:"size"`
 }
 
+// AvatarData represents avatar image data for peer identification
+type AvatarData struct {
+	Filename string `json:"filename"`
+	Data     string `json:"data"` // base64 encoded image data
+	Size     int    `json:"size"`
+}
+
+// IdentifyFriend is a friend entry shared during identification. The friend's signed profile
+// is relayed along when we have one so the receiver can verify the name.
+type IdentifyFriend struct {
+	PeerID   string         `json:"peer_id"`
+	PeerName string         `json:"peer_name"`
+	Profile  *ProfileRecord `json:"profile,omitempty"`
+}
+
+// Limits of the fields of a profile record
+const (
+	MaxProfileNameLength     = 64
+	MaxProfileBioLength      = 500
+	MaxProfileLocationLength = 100
+	MaxProfileLinks          = 5
+	MaxProfileLinkLength     = 200
+)
+
+// ProfileRecord is a node's profile signed with its libp2p key. The sequence number grows with every
+// change so receivers keep only the newest record, no matter which peer relayed it.
+type ProfileRecord struct {
+	PeerID     string    `json:"peer_id"`
+	Name       string    `json:"name"`
+	AvatarHash string    `json:"avatar_hash,omitempty"` // BLAKE3 hash of the primary avatar image
+	Bio        string    `json:"bio,omitempty"`
+	Links      []string  `json:"links,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	Seq        uint64    `json:"seq"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	PublicKey  []byte    `json:"public_key"`
+	Signature  []byte    `json:"signature"`
+}
+
+// Profile is our editable profile along with the avatar images to choose from and the signed
+// record peers receive
+type Profile struct {
+	PeerID   string         `json:"peer_id"`
+	Name     string         `json:"name"`
+	Bio      string         `json:"bio"`
+	Links    []string       `json:"links"`
+	Location string         `json:"location"`
+	Avatar   string         `json:"avatar,omitempty"` // filename of the chosen avatar image
+	Avatars  []string       `json:"avatars"`          // images in the avatar gallery
+	Record   *ProfileRecord `json:"record,omitempty"`
+}
+
+// UpdateProfileRequest changes our profile. Fields left out of the request keep their value.
+type UpdateProfileRequest struct {
+	Name     *string   `json:"name,omitempty"`
+	Bio      *string   `json:"bio,omitempty"`
+	Links    *[]string `json:"links,omitempty"`
+	Location *string   `json:"location,omitempty"`
+	Avatar   *string   `json:"avatar,omitempty"`
+}
+
+// ProfileUpdatePayload pushes a changed profile to a connected peer
+type ProfileUpdatePayload struct {
+	Profile *ProfileRecord `json:"profile"`
+	Avatar  *AvatarData    `json:"avatar,omitempty"` // only sent if our privacy settings disclose it
+}
+
+// ProfileUpdateAck acknowledges a pushed profile
+type ProfileUpdateAck struct {
+	Success bool `json:"success"`
+}
+
+// IdentityBackup is what an identity backup restores: the node key, the profile and privacy
+// settings and the friends list with its groups. It is only ever stored encrypted.
+type IdentityBackup struct {
+	Version    int               `json:"version"`
+	PeerID     string            `json:"peer_id"`
+	PrivateKey []byte            `json:"private_key"` // libp2p-marshalled node key
+	Settings   map[string]string `json:"settings"`    // profile and privacy settings
+	Friends    []BackupFriend    `json:"friends"`
+	Groups     []string          `json:"groups,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// BackupFriend is a friend as kept in an identity backup
+type BackupFriend struct {
+	PeerID   string   `json:"peer_id"`
+	PeerName string   `json:"peer_name"`
+	Mutual   bool     `json:"mutual"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// EncryptedIdentityBackup is the file format of an identity backup: the JSON encoded
+// IdentityBackup sealed with AES-256-GCM under a key derived from a passphrase with scrypt
+type EncryptedIdentityBackup struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	ScryptN    int    `json:"scrypt_n"`
+	ScryptR    int    `json:"scrypt_r"`
+	ScryptP    int    `json:"scrypt_p"`
+	Salt       []byte `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ExportIdentityRequest asks for an identity backup encrypted with the passphrase
+type ExportIdentityRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// ImportIdentityRequest restores an identity backup
+type ImportIdentityRequest struct {
+	Passphrase string                   `json:"passphrase"`
+	Backup     *EncryptedIdentityBackup `json:"backup"`
+}
+
+// ImportIdentityResult reports what an identity backup restored
+type ImportIdentityResult struct {
+	PeerID          string `json:"peer_id"`
+	Friends         int    `json:"friends"`
+	Groups          int    `json:"groups"`
+	RestartRequired bool   `json:"restart_required"` // the node key changed, it takes effect on the next start
+}
+
+// KeyRotation states that a node replaced its key. The old key vouches for the new peer ID and
+// the new key accepts the old identity, so peers can move what they stored to the new ID.
+type KeyRotation struct {
+	OldPeerID    string    `json:"old_peer_id"`
+	NewPeerID    string    `json:"new_peer_id"`
+	RotatedAt    time.Time `json:"rotated_at"`
+	OldPublicKey []byte    `json:"old_public_key"`
+	NewPublicKey []byte    `json:"new_public_key"`
+	OldSignature []byte    `json:"old_signature"`
+	NewSignature []byte    `json:"new_signature"`
+}
+
+// KeyRotationsResponse lists the key rotations of our node, oldest first
+type KeyRotationsResponse struct {
+	PeerID    string        `json:"peer_id"`
+	KeyType   string        `json:"key_type"`
+	Rotations []KeyRotation `json:"rotations"`
+}
+
+// RotateKeyResult reports a key rotation
+type RotateKeyResult struct {
+	Rotation        *KeyRotation `json:"rotation"`
+	RestartRequired bool         `json:"restart_required"` // the node keeps its old key until the next start
+}
+
+// Invite lifetimes, in hours
+const (
+	DefaultInviteExpiryHours = 24
+	MaxInviteExpiryHours     = 30 * 24
+)
+
+// Invite is the content of a signed invite code: where to reach the inviting node, who it is,
+// until when the invite is valid and the one-time token that makes the redeemer a friend
+type Invite struct {
+	PeerID    string    `json:"peer_id"`
+	Name      string    `json:"name"`
+	Addresses []string  `json:"addresses"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token"`
+	Friend    bool      `json:"friend"` // redeeming the token makes both sides friends without a request
+}
+
+// InviteRecord is an invite we created, kept so its token can be redeemed once
+type InviteRecord struct {
+	Token      string     `json:"token"`
+	Friend     bool       `json:"friend"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RedeemedBy string     `json:"redeemed_by,omitempty"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty"`
+}
+
+// InvitesResponse represents the response for the list of invites we created
+type InvitesResponse struct {
+	Invites []InviteRecord `json:"invites"`
+	Count   int            `json:"count"`
+}
+
+// CreateInviteRequest represents a request to create an invite code
+type CreateInviteRequest struct {
+	ExpiresInHours int   `json:"expires_in_hours,omitempty"` // DefaultInviteExpiryHours when empty
+	Friend         *bool `json:"friend,omitempty"`           // true when empty
+}
+
+// CreateInviteResponse carries a new invite code. The code only uses characters of the QR code
+// alphanumeric mode, so it can be rendered as a QR code as it is.
+type CreateInviteResponse struct {
+	Code   string `json:"code"`
+	Invite Invite `json:"invite"`
+}
+
+// RedeemInviteRequest represents a request to accept an invite code
+type RedeemInviteRequest struct {
+	Code      string `json:"code"`
+	AddFriend *bool  `json:"add_friend,omitempty"` // true when empty
+	Message   string `json:"message,omitempty"`    // sent with the friend request if the invite can't be redeemed
+}
+
+// RedeemInviteResult reports the connection to an inviting node and the friendship with it
+type RedeemInviteResult struct {
+	PeerID        string            `json:"peer_id"`
+	PeerName      string            `json:"peer_name"`
+	NodeInfo      *NodeInfoResponse `json:"node_info,omitempty"`
+	FriendRequest *FriendRequest    `json:"friend_request,omitempty"`
+}
+
+// RedeemInviteMessage asks the inviting node to redeem the token of its invite
+type RedeemInviteMessage struct {
+	Token    string `json:"token"`
+	PeerName string `json:"peer_name"`
+}
+
+// RedeemInviteMessageResponse reports the friend request status after redeeming an invite
+type RedeemInviteMessageResponse struct {
+	Status   string `json:"status"`
+	PeerName string `json:"peer_name"`
+}
+
+// IdentifyMessage is sent by both sides of the identify protocol.
+// Nodes older than protocol version 2 leave ProtocolVersion, Encodings and Capabilities empty.
+type IdentifyMessage struct {
+	App             string           `json:"app"`
+	Version         string           `json:"version"` // application version of the sender
+	NodeID          string           `json:"nodeId"`
+	Name            string           `json:"name"`
+	Avatar          *AvatarData      `json:"avatar,omitempty"`
+	Profile         *ProfileRecord   `json:"profile,omitempty"` // signed name, avatar hash and profile fields
+	Friends         []IdentifyFriend `json:"friends,omitempty"`
+	KeyRotations    []KeyRotation    `json:"key_rotations,omitempty"` // the peer IDs the node had before, oldest first
+	ProtocolVersion int              `json:"protocol_version,omitempty"`
+	Encodings       []string         `json:"encodings,omitempty"`
+	Capabilities    []string         `json:"capabilities,omitempty"`
+}
+
+// HolePunchAssistRequest asks a peer for the addresses of one of its connected peers
+type HolePunchAssistRequest struct {
+	TargetPeerID string `json:"target_peer_id"`
+}
+
+// HolePunchAssistResponse carries the addresses of the requested peer
+type HolePunchAssistResponse struct {
+	Success    bool     `json:"success"`
+	Error      string   `json:"error,omitempty"`
+	TargetPeer string   `json:"target_peer,omitempty"`
+	Addresses  []string `json:"addresses,omitempty"`
+	Name       string   `json:"name,omitempty"`
+}
+
+// BlobRequest represents a request on the blob transfer protocol
+type BlobRequest struct {
+	MediaType   MediaType `json:"media_type"`
+	GalleryName string    `json:"gallery_name"`
+	FileName    string    `json:"file_name"`
+	Offset      int64     `json:"offset"`
+	Length      int64     `json:"length"` // 0 means until the end of the file
+}
+
+// BlobResponse is the header sent ahead of the raw file bytes on the blob transfer protocol
+type BlobResponse struct {
+	Found    bool   `json:"found"`
+	Error    string `json:"error,omitempty"`
+	FilePath string `json:"filepath"` // path relative to space184, as stored in the files table
+	Hash     string `json:"hash"`     // BLAKE3 hash of the whole file
+	Size     int64  `json:"size"`     // size of the whole file
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"` // number of raw bytes following the header
+}
+
 // Legacy compatibility types for P2P communication
 type GalleriesResponse struct {
 	Galleries []MediaGallery `json:"galleries"`
@@ -298,4 +1132,132 @@ const (
 	MessageTypeGetMediaFileResp      = "getMediaFileResp"
 	MessageTypeGetFriends            = "getFriends"
 	MessageTypeGetFriendsResp        = "getFriendsResp"
+	MessageTypeFriendRequest         = "friendRequest"
+	MessageTypeFriendRequestResp     = "friendRequestResp"
+	MessageTypeFriendUpdate          = "friendUpdate"
+	MessageTypeFriendUpdateResp      = "friendUpdateResp"
+	MessageTypeDirectMessage         = "directMessage"
+	MessageTypeDirectMessageResp     = "directMessageResp"
+	MessageTypeMessageReceipt        = "messageReceipt"
+	MessageTypeMessageReceiptResp    = "messageReceiptResp"
+	MessageTypePostAnnounce          = "postAnnounce"
+	MessageTypePostAnnounceResp      = "postAnnounceResp"
+	MessageTypeGetPosts              = "getPosts"
+	MessageTypeGetPostsResp          = "getPostsResp"
+	MessageTypePostComment           = "postComment"
+	MessageTypePostCommentResp       = "postCommentResp"
+	MessageTypeDeleteComment         = "deleteComment"
+	MessageTypeDeleteCommentResp     = "deleteCommentResp"
+	MessageTypeProfileUpdate         = "profileUpdate"
+	MessageTypeProfileUpdateResp     = "profileUpdateResp"
+	MessageTypeRedeemInvite          = "redeemInvite"
+	MessageTypeRedeemInviteResp      = "redeemInviteResp"
 )
+
+// Event types published on the in-process event bus
+const (
+	EventPeerConnected    = "peer.connected"
+	EventPeerDisconnected = "peer.disconnected"
+	EventPeerValidated    = "peer.validated"
+	EventFriendAdded      = "friend.added"
+	EventFriendRemoved    = "friend.removed"
+	EventFriendOnline     = "friend.online"
+	EventFriendOffline    = "friend.offline"
+	EventFriendFilesAdded = "friend.files.added"
+	EventPeerBlocked      = "peer.blocked"
+	EventPeerUnblocked    = "peer.unblocked"
+	EventProfileUpdated   = "profile.updated"
+	EventPeerKeyRotated   = "peer.key_rotated"
+
+	EventReachabilityChanged = "node.reachability"
+
+	EventFriendRequestReceived  = "friend.request.received"
+	EventFriendRequestAccepted  = "friend.request.accepted"
+	EventFriendRequestDeclined  = "friend.request.declined"
+	EventFriendRequestCancelled = "friend.request.cancelled"
+
+	EventMessageReceived  = "message.received"
+	EventMessageDelivered = "message.delivered"
+	EventMessageRead      = "message.read"
+
+	EventPostCreated  = "post.created"
+	EventPostReceived = "post.received"
+
+	EventCommentReceived = "comment.received"
+	EventCommentRemoved  = "comment.removed"
+
+	EventNotificationCreated = "notification.created"
+	EventNotificationsRead   = "notifications.read"
+
+	EventFileAdded        = "file.added"
+	EventFileChanged      = "file.changed"
+	EventFileRemoved      = "file.removed"
+	EventFolderScanned    = "folder.scanned"
+	EventSyncStarted      = "sync.started"
+	EventSyncFinished     = "sync.finished"
+	EventDownloadProgress = "download.progress"
+)
+
+// Event is a single event delivered by the event bus
+type Event struct {
+	ID        uint64      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// PeerEvent describes a peer connecting, disconnecting or passing validation
+type PeerEvent struct {
+	PeerID    string `json:"peer_id"`
+	Name      string `json:"name,omitempty"`
+	Direction string `json:"direction,omitempty"` // "inbound" or "outbound"
+}
+
+// ReachabilityEvent describes a change of this node's reachability
+type ReachabilityEvent struct {
+	Reachability      string   `json:"reachability"`
+	ObservedAddresses []string `json:"observed_addresses,omitempty"`
+}
+
+// FriendEvent describes a friend being added or removed
+type FriendEvent struct {
+	PeerID   string `json:"peer_id"`
+	PeerName string `json:"peer_name,omitempty"`
+}
+
+// FileEvent describes a change to a file in the files table
+type FileEvent struct {
+	PeerID   string `json:"peer_id,omitempty"` // owner, empty for our own files
+	FilePath string `json:"file_path"`
+	Hash     string `json:"hash,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Type     string `json:"type,omitempty"`
+}
+
+// FriendFilesEvent reports the files a friend shared since our previous sync with them
+type FriendFilesEvent struct {
+	PeerID   string `json:"peer_id"`
+	PeerName string `json:"peer_name,omitempty"`
+	Count    int    `json:"count"`
+	FilePath string `json:"file_path,omitempty"` // set when a single file was shared
+}
+
+// SyncEvent describes a friend files metadata sync
+type SyncEvent struct {
+	PeerID  string `json:"peer_id,omitempty"` // empty when syncing all friends
+	Group   string `json:"group,omitempty"`   // set when syncing one friend group
+	Friends int    `json:"friends"`
+	Synced  int    `json:"synced"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DownloadEvent reports the progress of a file download from a peer
+type DownloadEvent struct {
+	PeerID   string `json:"peer_id"`
+	FileName string `json:"file_name"`
+	Received int64  `json:"received"`
+	Total    int64  `json:"total"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -23,7 +25,7 @@ type SQLiteRepository struct {
 // NewSQLiteRepository creates a new SQLite repository
 func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
 	// Ensure the directory exists
-	dir := utils.DefaultPathManager.GetSpace184Path()
+	dir := filepath.Dir(dbPath)
 	if err := utils.EnsureDir(dir); err != nil {
 		return nil, utils.WrapDatabaseError("create_directory", err)
 	}
@@ -58,6 +60,17 @@ func (r *SQLiteRepository) initializeTables() error {
 		{"connections", r.getConnectionsTableSQL()},
 		{"peer_friends", r.getPeerFriendsTableSQL()},
 		{"files", r.getFilesTableSQL()},
+		{"friend_requests", r.getFriendRequestsTableSQL()},
+		{"messages", r.getMessagesTableSQL()},
+		{"posts", r.getPostsTableSQL()},
+		{"comments", r.getCommentsTableSQL()},
+		{"notifications", r.getNotificationsTableSQL()},
+		{"content_visibility", r.getContentVisibilityTableSQL()},
+		{"friend_groups", r.getFriendGroupsTableSQL()},
+		{"blocked_peers", r.getBlockedPeersTableSQL()},
+		{"peer_profiles", r.getPeerProfilesTableSQL()},
+		{"invites", r.getInvitesTableSQL()},
+		{"peer_addresses", r.getPeerAddressesTableSQL()},
 	}
 
 	for _, table := range tables {
@@ -116,6 +129,165 @@ func (r *SQLiteRepository) getFilesTableSQL() string {
 	);`
 }
 
+func (r *SQLiteRepository) getFriendRequestsTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS friend_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		peer_id VARCHAR(255) NOT NULL UNIQUE,
+		peer_name VARCHAR(255) NOT NULL DEFAULT '',
+		direction VARCHAR(16) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		delivered BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+}
+
+func (r *SQLiteRepository) getMessagesTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id VARCHAR(64) NOT NULL UNIQUE,
+		peer_id VARCHAR(255) NOT NULL,
+		direction VARCHAR(16) NOT NULL,
+		body TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		sent_at DATETIME NOT NULL,
+		delivered_at DATETIME,
+		read_at DATETIME,
+		signature BLOB,
+		receipt_pending BOOLEAN NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_messages_peer ON messages (peer_id, id);`
+}
+
+func (r *SQLiteRepository) getPostsTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS posts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id VARCHAR(64) NOT NULL UNIQUE,
+		author_id VARCHAR(255) NOT NULL,
+		author_name VARCHAR(255) NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		media TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME NOT NULL,
+		received_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_posts_author ON posts (author_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_posts_created ON posts (created_at);`
+}
+
+func (r *SQLiteRepository) getCommentsTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		comment_id VARCHAR(64) NOT NULL UNIQUE,
+		owner_id VARCHAR(255) NOT NULL,
+		file_path TEXT NOT NULL,
+		file_hash VARCHAR(64) NOT NULL,
+		author_id VARCHAR(255) NOT NULL,
+		author_name VARCHAR(255) NOT NULL DEFAULT '',
+		kind VARCHAR(16) NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		hidden BOOLEAN NOT NULL DEFAULT 0,
+		public_key BLOB,
+		signature BLOB
+	);
+	CREATE INDEX IF NOT EXISTS idx_comments_file ON comments (file_path, file_hash);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_comments_reaction ON comments (file_path, file_hash, author_id, body) WHERE kind = 'reaction';
+	CREATE TABLE IF NOT EXISTS deleted_comments (
+		comment_id VARCHAR(64) PRIMARY KEY,
+		deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+}
+
+func (r *SQLiteRepository) getNotificationsTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type VARCHAR(32) NOT NULL,
+		peer_id VARCHAR(255) NOT NULL DEFAULT '',
+		peer_name VARCHAR(255) NOT NULL DEFAULT '',
+		body TEXT NOT NULL DEFAULT '',
+		ref TEXT NOT NULL DEFAULT '',
+		count INTEGER NOT NULL DEFAULT 1,
+		read BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (read, type, peer_id);`
+}
+
+func (r *SQLiteRepository) getContentVisibilityTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS content_visibility (
+		path TEXT PRIMARY KEY,
+		visibility VARCHAR(16) NOT NULL,
+		group_name VARCHAR(255) NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+}
+
+func (r *SQLiteRepository) getFriendGroupsTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS friend_groups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(64) NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS friend_group_members (
+		group_id INTEGER NOT NULL,
+		peer_id VARCHAR(255) NOT NULL,
+		added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (group_id, peer_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_friend_group_members_peer ON friend_group_members (peer_id);`
+}
+
+func (r *SQLiteRepository) getBlockedPeersTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS blocked_peers (
+		peer_id VARCHAR(255) PRIMARY KEY,
+		peer_name VARCHAR(255) NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		blocked_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+}
+
+func (r *SQLiteRepository) getPeerProfilesTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS peer_profiles (
+		peer_id VARCHAR(255) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		avatar_hash VARCHAR(64) NOT NULL DEFAULT '',
+		bio TEXT NOT NULL DEFAULT '',
+		links TEXT NOT NULL DEFAULT '[]',
+		location VARCHAR(255) NOT NULL DEFAULT '',
+		seq INTEGER NOT NULL,
+		updated_at DATETIME NOT NULL,
+		public_key BLOB NOT NULL,
+		signature BLOB NOT NULL,
+		received_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+}
+
+func (r *SQLiteRepository) getInvitesTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS invites (
+		token VARCHAR(64) PRIMARY KEY,
+		friend BOOLEAN NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		redeemed_by VARCHAR(255) NOT NULL DEFAULT '',
+		redeemed_at DATETIME
+	);`
+}
+
+func (r *SQLiteRepository) getPeerAddressesTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS peer_addresses (
+		peer_id VARCHAR(255) NOT NULL,
+		address TEXT NOT NULL,
+		transport VARCHAR(16) NOT NULL,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		last_connected DATETIME,
+		success_count INTEGER NOT NULL DEFAULT 0,
+		failure_count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (peer_id, address)
+	);`
+}
+
 // initializeDefaultSettings creates default settings if they don't exist
 func (r *SQLiteRepository) initializeDefaultSettings() error {
 	// Check if settings already exist
@@ -130,8 +302,8 @@ func (r *SQLiteRepository) initializeDefaultSettings() error {
 		return nil
 	}
 
-	// Generate a new node private key if none exists
-	nodePrivKey, _, err := crypto.GenerateKeyPair(crypto.RSA, 2048)
+	// Generate a new node private key if none exists, Ed25519 keys are fast and give short peer IDs
+	nodePrivKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
 	if err != nil {
 		return fmt.Errorf("failed to generate node key: %w", err)
 	}
@@ -147,9 +319,10 @@ func (r *SQLiteRepository) initializeDefaultSettings() error {
 		return fmt.Errorf("failed to marshal private key: %w", err)
 	}
 
-	// Insert default settings
+	// Insert default settings, naming the node after the end of its peer ID until the user picks a name
+	defaultName := defaultNodeName(nodeID)
 	settings := map[string]string{
-		"name":        "jerry",
+		"name":        defaultName,
 		"node_id":     nodeID.String(),
 		"private_key": string(privKeyBytes),
 	}
@@ -160,10 +333,19 @@ func (r *SQLiteRepository) initializeDefaultSettings() error {
 		}
 	}
 
-	log.Printf("🎯 Created default settings: name=jerry, node_id=%s", nodeID.String())
+	log.Printf("🎯 Created default settings: name=%s, node_id=%s", defaultName, nodeID.String())
 	return nil
 }
 
+// defaultNodeName derives a recognisable placeholder name from a peer ID
+func defaultNodeName(nodeID peer.ID) string {
+	id := nodeID.String()
+	if len(id) > 6 {
+		id = id[len(id)-6:]
+	}
+	return "node-" + id
+}
+
 // Settings Repository Implementation
 func (r *SQLiteRepository) GetSetting(key string) (string, error) {
 	var value string
@@ -343,13 +525,19 @@ func (r *SQLiteRepository) RemoveFriend(peerID string) error {
 		return utils.NewNotFoundError("friend", peerID)
 	}
 
+	// A former friend is no longer part of any friend group
+	if _, err := r.db.Exec("DELETE FROM friend_group_members WHERE peer_id = ?", peerID); err != nil {
+		return utils.WrapDatabaseError("remove_friend_group_memberships", err)
+	}
+
 	log.Printf("👥 Removed friend: %s", peerID)
 	return nil
 }
 
 func (r *SQLiteRepository) GetFriends() ([]models.Friend, error) {
 	rows, err := r.db.Query(`
-		SELECT id, peer_id, peer_name, first_connected, last_connected, 1 as is_online
+		SELECT id, peer_id, peer_name, first_connected, last_connected, 1 as is_online,
+			EXISTS(SELECT 1 FROM friend_requests fr WHERE fr.peer_id = connections.peer_id AND fr.status = 'accepted') as mutual
 		FROM connections
 		WHERE friend = 1
 		ORDER BY peer_name ASC
@@ -365,7 +553,7 @@ func (r *SQLiteRepository) GetFriends() ([]models.Friend, error) {
 
 		err := rows.Scan(
 			&friend.ID, &friend.PeerID, &friend.PeerName,
-			&friend.AddedAt, &friend.LastSeen, &friend.IsOnline,
+			&friend.AddedAt, &friend.LastSeen, &friend.IsOnline, &friend.Mutual,
 		)
 		if err != nil {
 			return nil, utils.WrapDatabaseError("scan_friend", err)
@@ -374,6 +562,17 @@ func (r *SQLiteRepository) GetFriends() ([]models.Friend, error) {
 		friends = append(friends, friend)
 	}
 
+	memberships, err := r.getFriendGroupMemberships()
+	if err != nil {
+		return nil, err
+	}
+	for i := range friends {
+		friends[i].Groups = memberships[friends[i].PeerID]
+		if friends[i].Groups == nil {
+			friends[i].Groups = []string{}
+		}
+	}
+
 	return friends, nil
 }
 
@@ -462,31 +661,1546 @@ func (r *SQLiteRepository) UpdateFriendStatus(peerID string, isOnline bool) erro
 	return nil
 }
 
-// Files Repository Implementation
-func (r *SQLiteRepository) FileExists(filePath string) (bool, string, error) {
-	var hash string
-	err := r.db.QueryRow("SELECT hash FROM files WHERE filepath = ?", filePath).Scan(&hash)
+// ConfirmFriend marks a peer as a friend after a friend request was accepted,
+// recording the connection first if we never stored one for the peer
+func (r *SQLiteRepository) ConfirmFriend(peerID, peerName string) error {
+	now := time.Now()
+	_, err := r.db.Exec(`
+		INSERT INTO connections (peer_id, address, first_connected, last_connected, connection_type, is_validated, peer_name, friend)
+		VALUES (?, '', ?, ?, 'unknown', 1, ?, 1)
+		ON CONFLICT(peer_id) DO UPDATE SET friend = 1, peer_name = CASE WHEN excluded.peer_name != '' THEN excluded.peer_name ELSE peer_name END
+	`, peerID, now, now, peerName)
+	if err != nil {
+		return utils.WrapDatabaseError("confirm_friend", err)
+	}
+
+	log.Printf("👥 Confirmed friend: %s (%s)", peerName, peerID)
+	return nil
+}
+
+// Friend Requests Repository Implementation
+func (r *SQLiteRepository) SaveFriendRequest(request *models.FriendRequest) error {
+	_, err := r.db.Exec(`
+		INSERT INTO friend_requests (peer_id, peer_name, direction, status, message, delivered, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(peer_id) DO UPDATE SET
+			peer_name = CASE WHEN excluded.peer_name != '' THEN excluded.peer_name ELSE peer_name END,
+			direction = excluded.direction,
+			status = excluded.status,
+			message = excluded.message,
+			delivered = excluded.delivered,
+			created_at = CASE WHEN direction != excluded.direction OR status != 'pending' THEN CURRENT_TIMESTAMP ELSE created_at END,
+			updated_at = CURRENT_TIMESTAMP
+	`, request.PeerID, request.PeerName, request.Direction, request.Status, request.Message, request.Delivered)
+	if err != nil {
+		return utils.WrapDatabaseError("save_friend_request", err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) GetFriendRequest(peerID string) (*models.FriendRequest, error) {
+	var request models.FriendRequest
+	err := r.db.QueryRow(`
+		SELECT id, peer_id, peer_name, direction, status, message, delivered, created_at, updated_at
+		FROM friend_requests
+		WHERE peer_id = ?
+	`, peerID).Scan(
+		&request.ID, &request.PeerID, &request.PeerName, &request.Direction, &request.Status,
+		&request.Message, &request.Delivered, &request.CreatedAt, &request.UpdatedAt,
+	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return false, "", nil
+			return nil, nil
 		}
-		return false, "", utils.WrapDatabaseError("check_file_exists", err)
+		return nil, utils.WrapDatabaseError("get_friend_request", err)
 	}
-	return true, hash, nil
+	return &request, nil
 }
 
-func (r *SQLiteRepository) UpsertFileRecord(filePath, hash string, size int64, extension, fileType, peerID string) error {
+func (r *SQLiteRepository) GetFriendRequests(direction, status string) ([]models.FriendRequest, error) {
+	rows, err := r.db.Query(`
+		SELECT id, peer_id, peer_name, direction, status, message, delivered, created_at, updated_at
+		FROM friend_requests
+		WHERE (? = '' OR direction = ?) AND (? = '' OR status = ?)
+		ORDER BY updated_at DESC
+	`, direction, direction, status, status)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_friend_requests", err)
+	}
+	defer rows.Close()
+
+	requests := []models.FriendRequest{}
+	for rows.Next() {
+		var request models.FriendRequest
+		err := rows.Scan(
+			&request.ID, &request.PeerID, &request.PeerName, &request.Direction, &request.Status,
+			&request.Message, &request.Delivered, &request.CreatedAt, &request.UpdatedAt,
+		)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_friend_request", err)
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+func (r *SQLiteRepository) UpdateFriendRequestStatus(peerID, status string, delivered bool) error {
+	result, err := r.db.Exec(`
+		UPDATE friend_requests
+		SET status = ?, delivered = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE peer_id = ?
+	`, status, delivered, peerID)
+	if err != nil {
+		return utils.WrapDatabaseError("update_friend_request", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("friend request", peerID)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) SetFriendRequestDelivered(peerID string, delivered bool) error {
+	_, err := r.db.Exec("UPDATE friend_requests SET delivered = ? WHERE peer_id = ?", delivered, peerID)
+	if err != nil {
+		return utils.WrapDatabaseError("set_friend_request_delivered", err)
+	}
+	return nil
+}
+
+// Messages Repository Implementation
+
+// SaveMessage stores a direct message, returning false if a message with the same ID was already stored
+func (r *SQLiteRepository) SaveMessage(message *models.DirectMessage) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT OR IGNORE INTO messages (message_id, peer_id, direction, body, status, sent_at, delivered_at, read_at, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, message.MessageID, message.PeerID, message.Direction, message.Body, message.Status,
+		message.SentAt, message.DeliveredAt, message.ReadAt, message.Signature)
+	if err != nil {
+		return false, utils.WrapDatabaseError("save_message", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		message.ID = int(id)
+	}
+	return true, nil
+}
+
+// GetMessage returns a direct message by its message ID, or nil if there is none
+func (r *SQLiteRepository) GetMessage(messageID string) (*models.DirectMessage, error) {
+	rows, err := r.db.Query("SELECT "+messageColumns+" FROM messages WHERE message_id = ?", messageID)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_message", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// GetMessages returns up to limit messages of a conversation, oldest first.
+// With beforeID > 0 only messages older than that message are returned, which pages backwards through the history.
+func (r *SQLiteRepository) GetMessages(peerID string, beforeID, limit int) ([]models.DirectMessage, error) {
+	rows, err := r.db.Query(`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE peer_id = ? AND (? <= 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`, peerID, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_messages", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first from the query, oldest first for the caller
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// GetPendingMessages returns the outbox of outgoing messages not yet delivered, oldest first.
+// An empty peerID returns the outbox for every peer.
+func (r *SQLiteRepository) GetPendingMessages(peerID string) ([]models.DirectMessage, error) {
+	rows, err := r.db.Query(`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE direction = ? AND status = ? AND (? = '' OR peer_id = ?)
+		ORDER BY id ASC
+	`, models.MessageOutgoing, models.MessageStatusPending, peerID, peerID)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_pending_messages", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// GetConversations returns one summary per peer we exchanged messages with, most recent first
+func (r *SQLiteRepository) GetConversations() ([]models.Conversation, error) {
+	rows, err := r.db.Query(`
+		SELECT m.peer_id, COALESCE(c.peer_name, ''), m.body, m.direction, m.sent_at,
+			(SELECT COUNT(*) FROM messages u WHERE u.peer_id = m.peer_id AND u.direction = ? AND u.read_at IS NULL),
+			(SELECT COUNT(*) FROM messages p WHERE p.peer_id = m.peer_id AND p.direction = ? AND p.status = ?)
+		FROM messages m
+		LEFT JOIN connections c ON c.peer_id = m.peer_id
+		WHERE m.id = (SELECT MAX(id) FROM messages l WHERE l.peer_id = m.peer_id)
+		ORDER BY m.id DESC
+	`, models.MessageIncoming, models.MessageOutgoing, models.MessageStatusPending)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_conversations", err)
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		var conversation models.Conversation
+		err := rows.Scan(
+			&conversation.PeerID, &conversation.PeerName, &conversation.LastMessage, &conversation.LastDirection,
+			&conversation.LastMessageAt, &conversation.UnreadCount, &conversation.PendingCount,
+		)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_conversation", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, nil
+}
+
+// MarkMessageDelivered records the delivery receipt of an outgoing message
+func (r *SQLiteRepository) MarkMessageDelivered(messageID string, deliveredAt time.Time) error {
 	_, err := r.db.Exec(`
-		INSERT OR REPLACE INTO files (filepath, hash, size, extension, type, peer_id, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`, filePath, hash, size, extension, fileType, peerID)
+		UPDATE messages
+		SET status = CASE WHEN status = ? THEN ? ELSE status END, delivered_at = COALESCE(delivered_at, ?)
+		WHERE message_id = ? AND direction = ?
+	`, models.MessageStatusPending, models.MessageStatusDelivered, deliveredAt, messageID, models.MessageOutgoing)
+	if err != nil {
+		return utils.WrapDatabaseError("mark_message_delivered", err)
+	}
+	return nil
+}
 
+// UpdateMessageSignature replaces the signature of an outgoing message, such as one queued before a key rotation
+func (r *SQLiteRepository) UpdateMessageSignature(messageID string, signature []byte) error {
+	_, err := r.db.Exec(
+		"UPDATE messages SET signature = ? WHERE message_id = ? AND direction = ?",
+		signature, messageID, models.MessageOutgoing,
+	)
 	if err != nil {
-		return utils.WrapDatabaseError("upsert_file_record", err)
+		return utils.WrapDatabaseError("update_message_signature", err)
+	}
+	return nil
+}
+
+// MarkMessagesRead records a read receipt for outgoing messages sent to a peer.
+// It returns the IDs of the messages that weren't marked read before.
+func (r *SQLiteRepository) MarkMessagesRead(peerID string, messageIDs []string, readAt time.Time) ([]string, error) {
+	var updated []string
+	for _, messageID := range messageIDs {
+		result, err := r.db.Exec(`
+			UPDATE messages
+			SET status = ?, read_at = ?, delivered_at = COALESCE(delivered_at, ?)
+			WHERE message_id = ? AND peer_id = ? AND direction = ? AND read_at IS NULL
+		`, models.MessageStatusRead, readAt, readAt, messageID, peerID, models.MessageOutgoing)
+		if err != nil {
+			return updated, utils.WrapDatabaseError("mark_messages_read", err)
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+			updated = append(updated, messageID)
+		}
+	}
+	return updated, nil
+}
+
+// MarkConversationRead marks every unread incoming message from a peer as read and queues read receipts for them.
+// It returns the IDs of the messages marked read.
+func (r *SQLiteRepository) MarkConversationRead(peerID string, readAt time.Time) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT message_id FROM messages
+		WHERE peer_id = ? AND direction = ? AND read_at IS NULL
+	`, peerID, models.MessageIncoming)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_unread_messages", err)
+	}
+
+	var messageIDs []string
+	for rows.Next() {
+		var messageID string
+		if err := rows.Scan(&messageID); err != nil {
+			rows.Close()
+			return nil, utils.WrapDatabaseError("scan_unread_message", err)
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	rows.Close()
+
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE messages
+		SET status = ?, read_at = ?, receipt_pending = 1
+		WHERE peer_id = ? AND direction = ? AND read_at IS NULL
+	`, models.MessageStatusRead, readAt, peerID, models.MessageIncoming)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("mark_conversation_read", err)
+	}
+
+	return messageIDs, nil
+}
+
+// GetPendingReadReceipts returns the IDs of messages from a peer that were read but whose read receipt wasn't delivered
+func (r *SQLiteRepository) GetPendingReadReceipts(peerID string) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT message_id FROM messages
+		WHERE peer_id = ? AND direction = ? AND receipt_pending = 1
+		ORDER BY id ASC
+	`, peerID, models.MessageIncoming)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_pending_read_receipts", err)
+	}
+	defer rows.Close()
+
+	var messageIDs []string
+	for rows.Next() {
+		var messageID string
+		if err := rows.Scan(&messageID); err != nil {
+			return nil, utils.WrapDatabaseError("scan_pending_read_receipt", err)
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	return messageIDs, nil
+}
+
+// ClearPendingReadReceipts records that the read receipts for messages were delivered
+func (r *SQLiteRepository) ClearPendingReadReceipts(messageIDs []string) error {
+	for _, messageID := range messageIDs {
+		if _, err := r.db.Exec("UPDATE messages SET receipt_pending = 0 WHERE message_id = ?", messageID); err != nil {
+			return utils.WrapDatabaseError("clear_pending_read_receipts", err)
+		}
 	}
 	return nil
 }
 
+// messageColumns lists the messages columns in the order scanMessages reads them
+const messageColumns = "id, message_id, peer_id, direction, body, status, sent_at, delivered_at, read_at, signature"
+
+// scanMessages reads direct messages selected with messageColumns
+func scanMessages(rows *sql.Rows) ([]models.DirectMessage, error) {
+	messages := []models.DirectMessage{}
+	for rows.Next() {
+		var message models.DirectMessage
+		var deliveredAt, readAt sql.NullTime
+
+		err := rows.Scan(
+			&message.ID, &message.MessageID, &message.PeerID, &message.Direction, &message.Body,
+			&message.Status, &message.SentAt, &deliveredAt, &readAt, &message.Signature,
+		)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_message", err)
+		}
+
+		if deliveredAt.Valid {
+			message.DeliveredAt = &deliveredAt.Time
+		}
+		if readAt.Valid {
+			message.ReadAt = &readAt.Time
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// Posts Repository Implementation
+
+// SavePost stores a post, returning false if a post with the same ID was already stored.
+// Times are stored in UTC so they sort chronologically.
+func (r *SQLiteRepository) SavePost(post *models.Post) (bool, error) {
+	media, err := json.Marshal(post.Media)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode post media: %w", err)
+	}
+
+	result, err := r.db.Exec(`
+		INSERT OR IGNORE INTO posts (post_id, author_id, author_name, body, media, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, post.PostID, post.AuthorID, post.AuthorName, post.Body, string(media), post.CreatedAt.UTC())
+	if err != nil {
+		return false, utils.WrapDatabaseError("save_post", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		post.ID = int(id)
+	}
+	return true, nil
+}
+
+// GetPostsSince returns up to limit posts by an author that follow the (since, afterPostID) position,
+// oldest first. Posts created at since itself are returned if their post ID sorts after afterPostID.
+func (r *SQLiteRepository) GetPostsSince(authorID string, since time.Time, afterPostID string, limit int) ([]models.Post, error) {
+	rows, err := r.db.Query(`
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE author_id = ?1 AND (created_at > ?2 OR (created_at = ?2 AND post_id > ?3))
+		ORDER BY created_at ASC, post_id ASC
+		LIMIT ?4
+	`, authorID, since.UTC(), afterPostID, limit)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_posts_since", err)
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetPosts returns up to limit posts by an author created before the given time, newest first.
+// A zero before starts with the newest post.
+func (r *SQLiteRepository) GetPosts(authorID string, before time.Time, limit int) ([]models.Post, error) {
+	rows, err := r.db.Query(`
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE author_id = ? AND (? OR created_at < ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, authorID, before.IsZero(), before.UTC(), limit)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_posts", err)
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetTimeline returns up to limit posts by us or our friends created before the given time, newest first.
+// A zero before starts with the newest post.
+func (r *SQLiteRepository) GetTimeline(selfID string, before time.Time, limit int) ([]models.Post, error) {
+	rows, err := r.db.Query(`
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE (author_id = ? OR author_id IN (SELECT peer_id FROM connections WHERE friend = 1))
+			AND (? OR created_at < ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, selfID, before.IsZero(), before.UTC(), limit)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_timeline", err)
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetLatestPostTime returns when the newest stored post by an author was created, or the zero time if there is none
+func (r *SQLiteRepository) GetLatestPostTime(authorID string) (time.Time, error) {
+	posts, err := r.GetPosts(authorID, time.Time{}, 1)
+	if err != nil || len(posts) == 0 {
+		return time.Time{}, err
+	}
+	return posts[0].CreatedAt, nil
+}
+
+// postColumns lists the posts columns in the order scanPosts reads them
+const postColumns = "id, post_id, author_id, author_name, body, media, created_at"
+
+// scanPosts reads posts selected with postColumns
+func scanPosts(rows *sql.Rows) ([]models.Post, error) {
+	posts := []models.Post{}
+	for rows.Next() {
+		var post models.Post
+		var media string
+
+		err := rows.Scan(&post.ID, &post.PostID, &post.AuthorID, &post.AuthorName, &post.Body, &media, &post.CreatedAt)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_post", err)
+		}
+
+		if err := json.Unmarshal([]byte(media), &post.Media); err != nil {
+			log.Printf("⚠️ Invalid media for post %s: %v", post.PostID, err)
+		}
+		if post.Media == nil {
+			post.Media = []models.PostMedia{}
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+// Comments Repository Implementation
+
+// SaveComment stores a comment, returning false if it or the same reaction by the same author was already stored
+func (r *SQLiteRepository) SaveComment(comment *models.Comment) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT OR IGNORE INTO comments (comment_id, owner_id, file_path, file_hash, author_id, author_name, kind, body, created_at, hidden, public_key, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, comment.CommentID, comment.OwnerID, comment.FilePath, comment.FileHash, comment.AuthorID, comment.AuthorName,
+		comment.Kind, comment.Body, comment.CreatedAt.UTC(), comment.Hidden, comment.PublicKey, comment.Signature)
+	if err != nil {
+		return false, utils.WrapDatabaseError("save_comment", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		comment.ID = int(id)
+	}
+	return true, nil
+}
+
+// GetComment returns a comment by its comment ID, or nil if there is none
+func (r *SQLiteRepository) GetComment(commentID string) (*models.Comment, error) {
+	rows, err := r.db.Query("SELECT "+commentColumns+" FROM comments WHERE comment_id = ?", commentID)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_comment", err)
+	}
+	defer rows.Close()
+
+	comments, err := scanComments(rows)
+	if err != nil || len(comments) == 0 {
+		return nil, err
+	}
+	return &comments[0], nil
+}
+
+// GetComments returns the comments on a file, oldest first. An empty fileHash matches every version
+// of the file, and hidden comments are only included when asked for.
+func (r *SQLiteRepository) GetComments(filePath, fileHash string, includeHidden bool) ([]models.Comment, error) {
+	rows, err := r.db.Query(`
+		SELECT `+commentColumns+`
+		FROM comments
+		WHERE file_path = ? AND (? = '' OR file_hash = ?) AND (? OR hidden = 0)
+		ORDER BY created_at ASC, id ASC
+	`, filePath, fileHash, fileHash, includeHidden)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_comments", err)
+	}
+	defer rows.Close()
+
+	return scanComments(rows)
+}
+
+// SetCommentHidden hides or shows a comment
+func (r *SQLiteRepository) SetCommentHidden(commentID string, hidden bool) error {
+	result, err := r.db.Exec("UPDATE comments SET hidden = ? WHERE comment_id = ?", hidden, commentID)
+	if err != nil {
+		return utils.WrapDatabaseError("set_comment_hidden", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("comment", commentID)
+	}
+	return nil
+}
+
+// DeleteComment removes a comment
+func (r *SQLiteRepository) DeleteComment(commentID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return utils.WrapDatabaseError("begin_transaction", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM comments WHERE comment_id = ?", commentID)
+	if err != nil {
+		return utils.WrapDatabaseError("delete_comment", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("comment", commentID)
+	}
+
+	// The tombstone keeps the signed comment from being stored again when its author resends it
+	if _, err := tx.Exec("INSERT OR IGNORE INTO deleted_comments (comment_id) VALUES (?)", commentID); err != nil {
+		return utils.WrapDatabaseError("save_deleted_comment", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.WrapDatabaseError("commit_transaction", err)
+	}
+	return nil
+}
+
+// IsCommentDeleted reports whether a comment was deleted from our content
+func (r *SQLiteRepository) IsCommentDeleted(commentID string) (bool, error) {
+	var deleted bool
+	err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM deleted_comments WHERE comment_id = ?)", commentID).Scan(&deleted)
+	if err != nil {
+		return false, utils.WrapDatabaseError("is_comment_deleted", err)
+	}
+	return deleted, nil
+}
+
+// commentColumns lists the comments columns in the order scanComments reads them
+const commentColumns = "id, comment_id, owner_id, file_path, file_hash, author_id, author_name, kind, body, created_at, hidden, public_key, signature"
+
+// scanComments reads comments selected with commentColumns
+func scanComments(rows *sql.Rows) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		err := rows.Scan(
+			&comment.ID, &comment.CommentID, &comment.OwnerID, &comment.FilePath, &comment.FileHash,
+			&comment.AuthorID, &comment.AuthorName, &comment.Kind, &comment.Body, &comment.CreatedAt,
+			&comment.Hidden, &comment.PublicKey, &comment.Signature,
+		)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_comment", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
+
+// Notifications Repository Implementation
+
+// SaveNotification stores a notification. An unread notification of the same type from the same peer
+// is updated in place instead, bumping its count, so a burst of activity shows up once.
+func (r *SQLiteRepository) SaveNotification(notification *models.Notification) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return utils.WrapDatabaseError("begin_transaction", err)
+	}
+	defer tx.Rollback()
+
+	createdAt := notification.CreatedAt.UTC()
+
+	var id, count int
+	err = tx.QueryRow(`
+		SELECT id, count FROM notifications
+		WHERE read = 0 AND type = ? AND peer_id = ?
+		ORDER BY id DESC LIMIT 1
+	`, notification.Type, notification.PeerID).Scan(&id, &count)
+
+	switch {
+	case err == sql.ErrNoRows:
+		result, err := tx.Exec(`
+			INSERT INTO notifications (type, peer_id, peer_name, body, ref, count, read, created_at)
+			VALUES (?, ?, ?, ?, ?, 1, 0, ?)
+		`, notification.Type, notification.PeerID, notification.PeerName, notification.Body, notification.Ref, createdAt)
+		if err != nil {
+			return utils.WrapDatabaseError("save_notification", err)
+		}
+		if insertID, err := result.LastInsertId(); err == nil {
+			notification.ID = int(insertID)
+		}
+		notification.Count = 1
+
+	case err != nil:
+		return utils.WrapDatabaseError("get_unread_notification", err)
+
+	default:
+		_, err = tx.Exec(`
+			UPDATE notifications SET peer_name = ?, body = ?, ref = ?, count = count + 1, created_at = ?
+			WHERE id = ?
+		`, notification.PeerName, notification.Body, notification.Ref, createdAt, id)
+		if err != nil {
+			return utils.WrapDatabaseError("update_notification", err)
+		}
+		notification.ID = id
+		notification.Count = count + 1
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.WrapDatabaseError("commit_transaction", err)
+	}
+	notification.Read = false
+	return nil
+}
+
+// GetNotifications returns notifications older than beforeID (0 for the newest), newest first
+func (r *SQLiteRepository) GetNotifications(unreadOnly bool, beforeID, limit int) ([]models.Notification, error) {
+	rows, err := r.db.Query(`
+		SELECT id, type, peer_id, peer_name, body, ref, count, read, created_at
+		FROM notifications
+		WHERE (? = 0 OR read = 0) AND (? <= 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`, unreadOnly, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_notifications", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		err := rows.Scan(
+			&notification.ID, &notification.Type, &notification.PeerID, &notification.PeerName, &notification.Body,
+			&notification.Ref, &notification.Count, &notification.Read, &notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_notification", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// CountUnreadNotifications returns the number of unread notifications
+func (r *SQLiteRepository) CountUnreadNotifications() (int, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE read = 0").Scan(&count); err != nil {
+		return 0, utils.WrapDatabaseError("count_unread_notifications", err)
+	}
+	return count, nil
+}
+
+// MarkNotificationRead marks one notification read
+func (r *SQLiteRepository) MarkNotificationRead(id int) error {
+	result, err := r.db.Exec("UPDATE notifications SET read = 1 WHERE id = ?", id)
+	if err != nil {
+		return utils.WrapDatabaseError("mark_notification_read", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("notification", fmt.Sprint(id))
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every notification read, returning how many were unread
+func (r *SQLiteRepository) MarkAllNotificationsRead() (int, error) {
+	result, err := r.db.Exec("UPDATE notifications SET read = 1 WHERE read = 0")
+	if err != nil {
+		return 0, utils.WrapDatabaseError("mark_all_notifications_read", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	return int(rowsAffected), nil
+}
+
+// DeleteNotification removes a notification
+func (r *SQLiteRepository) DeleteNotification(id int) error {
+	result, err := r.db.Exec("DELETE FROM notifications WHERE id = ?", id)
+	if err != nil {
+		return utils.WrapDatabaseError("delete_notification", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("notification", fmt.Sprint(id))
+	}
+	return nil
+}
+
+// Content Visibility Repository Implementation
+
+// SetContentVisibility creates or replaces the visibility rule of a path
+func (r *SQLiteRepository) SetContentVisibility(rule *models.ContentVisibility) error {
+	_, err := r.db.Exec(`
+		INSERT INTO content_visibility (path, visibility, group_name, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(path) DO UPDATE SET
+			visibility = excluded.visibility,
+			group_name = excluded.group_name,
+			updated_at = CURRENT_TIMESTAMP
+	`, rule.Path, rule.Visibility, rule.Group)
+	if err != nil {
+		return utils.WrapDatabaseError("set_content_visibility", err)
+	}
+	return nil
+}
+
+// GetContentVisibilities returns every visibility rule ordered by path
+func (r *SQLiteRepository) GetContentVisibilities() ([]models.ContentVisibility, error) {
+	rows, err := r.db.Query("SELECT path, visibility, group_name, updated_at FROM content_visibility ORDER BY path")
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_content_visibilities", err)
+	}
+	defer rows.Close()
+
+	rules := []models.ContentVisibility{}
+	for rows.Next() {
+		var rule models.ContentVisibility
+		if err := rows.Scan(&rule.Path, &rule.Visibility, &rule.Group, &rule.UpdatedAt); err != nil {
+			return nil, utils.WrapDatabaseError("scan_content_visibility", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// DeleteContentVisibility removes the visibility rule of a path
+func (r *SQLiteRepository) DeleteContentVisibility(path string) error {
+	result, err := r.db.Exec("DELETE FROM content_visibility WHERE path = ?", path)
+	if err != nil {
+		return utils.WrapDatabaseError("delete_content_visibility", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("visibility rule", path)
+	}
+	return nil
+}
+
+// Friend Groups Repository Implementation
+
+// CreateFriendGroup creates an empty friend group
+func (r *SQLiteRepository) CreateFriendGroup(name string) (*models.FriendGroup, error) {
+	result, err := r.db.Exec("INSERT INTO friend_groups (name, created_at) VALUES (?, ?)", name, time.Now().UTC())
+	if err != nil {
+		return nil, utils.WrapDatabaseError("create_friend_group", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_last_insert_id", err)
+	}
+
+	log.Printf("👪 Created friend group %s", name)
+	return r.GetFriendGroup(name, int(id))
+}
+
+// GetFriendGroup returns a friend group with its members by name, or by ID when id is positive.
+// It returns nil if there is no such group.
+func (r *SQLiteRepository) GetFriendGroup(name string, id int) (*models.FriendGroup, error) {
+	var group models.FriendGroup
+	err := r.db.QueryRow(`
+		SELECT id, name, created_at FROM friend_groups
+		WHERE (? > 0 AND id = ?) OR (? <= 0 AND name = ?)
+	`, id, id, id, name).Scan(&group.ID, &group.Name, &group.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_friend_group", err)
+	}
+
+	group.Members, err = r.getFriendGroupMembers(group.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetFriendGroups returns every friend group with its members, ordered by name
+func (r *SQLiteRepository) GetFriendGroups() ([]models.FriendGroup, error) {
+	rows, err := r.db.Query("SELECT id, name, created_at FROM friend_groups ORDER BY name COLLATE NOCASE")
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_friend_groups", err)
+	}
+
+	groups := []models.FriendGroup{}
+	for rows.Next() {
+		var group models.FriendGroup
+		if err := rows.Scan(&group.ID, &group.Name, &group.CreatedAt); err != nil {
+			rows.Close()
+			return nil, utils.WrapDatabaseError("scan_friend_group", err)
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+
+	for i := range groups {
+		if groups[i].Members, err = r.getFriendGroupMembers(groups[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// RenameFriendGroup renames a friend group and the visibility rules that target it
+func (r *SQLiteRepository) RenameFriendGroup(name, newName string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return utils.WrapDatabaseError("begin_transaction", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE friend_groups SET name = ? WHERE name = ?", newName, name)
+	if err != nil {
+		return utils.WrapDatabaseError("rename_friend_group", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("friend group", name)
+	}
+
+	_, err = tx.Exec("UPDATE content_visibility SET group_name = ? WHERE visibility = ? AND group_name = ?", newName, models.VisibilityGroup, name)
+	if err != nil {
+		return utils.WrapDatabaseError("rename_visibility_group", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.WrapDatabaseError("commit_transaction", err)
+	}
+
+	log.Printf("👪 Renamed friend group %s to %s", name, newName)
+	return nil
+}
+
+// DeleteFriendGroup removes a friend group and its memberships. Content visible to the group becomes
+// visible to nobody until its rule is changed.
+func (r *SQLiteRepository) DeleteFriendGroup(name string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return utils.WrapDatabaseError("begin_transaction", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM friend_group_members WHERE group_id IN (SELECT id FROM friend_groups WHERE name = ?)", name)
+	if err != nil {
+		return utils.WrapDatabaseError("delete_friend_group_members", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM friend_groups WHERE name = ?", name)
+	if err != nil {
+		return utils.WrapDatabaseError("delete_friend_group", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("friend group", name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.WrapDatabaseError("commit_transaction", err)
+	}
+
+	log.Printf("👪 Deleted friend group %s", name)
+	return nil
+}
+
+// AddFriendGroupMember adds a peer to a friend group, doing nothing if it is already a member
+func (r *SQLiteRepository) AddFriendGroupMember(name, peerID string) error {
+	result, err := r.db.Exec(`
+		INSERT OR IGNORE INTO friend_group_members (group_id, peer_id, added_at)
+		SELECT id, ?, ? FROM friend_groups WHERE name = ?
+	`, peerID, time.Now().UTC(), name)
+	if err != nil {
+		return utils.WrapDatabaseError("add_friend_group_member", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		// Either already a member or there is no such group
+		group, err := r.GetFriendGroup(name, 0)
+		if err != nil {
+			return err
+		}
+		if group == nil {
+			return utils.NewNotFoundError("friend group", name)
+		}
+	}
+	return nil
+}
+
+// RemoveFriendGroupMember removes a peer from a friend group
+func (r *SQLiteRepository) RemoveFriendGroupMember(name, peerID string) error {
+	result, err := r.db.Exec(`
+		DELETE FROM friend_group_members
+		WHERE peer_id = ? AND group_id IN (SELECT id FROM friend_groups WHERE name = ?)
+	`, peerID, name)
+	if err != nil {
+		return utils.WrapDatabaseError("remove_friend_group_member", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("friend group member", peerID)
+	}
+	return nil
+}
+
+// IsFriendGroupMember reports whether a peer belongs to a friend group
+func (r *SQLiteRepository) IsFriendGroupMember(name, peerID string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM friend_group_members m
+		JOIN friend_groups g ON g.id = m.group_id
+		WHERE g.name = ? AND m.peer_id = ?
+	`, name, peerID).Scan(&count)
+	if err != nil {
+		return false, utils.WrapDatabaseError("check_friend_group_member", err)
+	}
+	return count > 0, nil
+}
+
+// getFriendGroupMembers returns the peer IDs in a friend group
+func (r *SQLiteRepository) getFriendGroupMembers(groupID int) ([]string, error) {
+	rows, err := r.db.Query("SELECT peer_id FROM friend_group_members WHERE group_id = ? ORDER BY added_at, peer_id", groupID)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_friend_group_members", err)
+	}
+	defer rows.Close()
+
+	members := []string{}
+	for rows.Next() {
+		var peerID string
+		if err := rows.Scan(&peerID); err != nil {
+			return nil, utils.WrapDatabaseError("scan_friend_group_member", err)
+		}
+		members = append(members, peerID)
+	}
+	return members, nil
+}
+
+// getFriendGroupMemberships returns the names of the groups each peer belongs to
+func (r *SQLiteRepository) getFriendGroupMemberships() (map[string][]string, error) {
+	rows, err := r.db.Query(`
+		SELECT m.peer_id, g.name FROM friend_group_members m
+		JOIN friend_groups g ON g.id = m.group_id
+		ORDER BY g.name COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_friend_group_memberships", err)
+	}
+	defer rows.Close()
+
+	memberships := make(map[string][]string)
+	for rows.Next() {
+		var peerID, name string
+		if err := rows.Scan(&peerID, &name); err != nil {
+			return nil, utils.WrapDatabaseError("scan_friend_group_membership", err)
+		}
+		memberships[peerID] = append(memberships[peerID], name)
+	}
+	return memberships, nil
+}
+
+// Blocked Peers Repository Implementation
+
+// BlockPeer adds a peer to the block list, updating the name and reason if it is already blocked
+func (r *SQLiteRepository) BlockPeer(blocked *models.BlockedPeer) error {
+	if blocked.BlockedAt.IsZero() {
+		blocked.BlockedAt = time.Now()
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO blocked_peers (peer_id, peer_name, reason, blocked_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(peer_id) DO UPDATE SET peer_name = excluded.peer_name, reason = excluded.reason
+	`, blocked.PeerID, blocked.PeerName, blocked.Reason, blocked.BlockedAt.UTC())
+	if err != nil {
+		return utils.WrapDatabaseError("block_peer", err)
+	}
+
+	log.Printf("🚫 Blocked peer %s", blocked.PeerID)
+	return nil
+}
+
+// UnblockPeer removes a peer from the block list
+func (r *SQLiteRepository) UnblockPeer(peerID string) error {
+	result, err := r.db.Exec("DELETE FROM blocked_peers WHERE peer_id = ?", peerID)
+	if err != nil {
+		return utils.WrapDatabaseError("unblock_peer", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("blocked peer", peerID)
+	}
+
+	log.Printf("✅ Unblocked peer %s", peerID)
+	return nil
+}
+
+// GetBlockedPeers returns the block list, most recently blocked first
+func (r *SQLiteRepository) GetBlockedPeers() ([]models.BlockedPeer, error) {
+	rows, err := r.db.Query("SELECT peer_id, peer_name, reason, blocked_at FROM blocked_peers ORDER BY blocked_at DESC")
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_blocked_peers", err)
+	}
+	defer rows.Close()
+
+	blocked := []models.BlockedPeer{}
+	for rows.Next() {
+		var blockedPeer models.BlockedPeer
+		if err := rows.Scan(&blockedPeer.PeerID, &blockedPeer.PeerName, &blockedPeer.Reason, &blockedPeer.BlockedAt); err != nil {
+			return nil, utils.WrapDatabaseError("scan_blocked_peer", err)
+		}
+		blocked = append(blocked, blockedPeer)
+	}
+	return blocked, nil
+}
+
+// IsPeerBlocked reports whether a peer is on the block list
+func (r *SQLiteRepository) IsPeerBlocked(peerID string) (bool, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM blocked_peers WHERE peer_id = ?", peerID).Scan(&count); err != nil {
+		return false, utils.WrapDatabaseError("check_blocked_peer", err)
+	}
+	return count > 0, nil
+}
+
+// Invites Repository Implementation
+
+// SaveInvite stores an invite we created
+func (r *SQLiteRepository) SaveInvite(invite *models.InviteRecord) error {
+	_, err := r.db.Exec(
+		"INSERT INTO invites (token, friend, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		invite.Token, invite.Friend, invite.CreatedAt.UTC(), invite.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return utils.WrapDatabaseError("save_invite", err)
+	}
+	return nil
+}
+
+// inviteColumns lists the invites columns in the order scanInvite reads them
+const inviteColumns = "token, friend, created_at, expires_at, redeemed_by, redeemed_at"
+
+// scanInvite reads an invite selected with inviteColumns
+func scanInvite(scanner interface{ Scan(...interface{}) error }) (*models.InviteRecord, error) {
+	var invite models.InviteRecord
+	var redeemedAt sql.NullTime
+	if err := scanner.Scan(&invite.Token, &invite.Friend, &invite.CreatedAt, &invite.ExpiresAt, &invite.RedeemedBy, &redeemedAt); err != nil {
+		return nil, err
+	}
+	if redeemedAt.Valid {
+		invite.RedeemedAt = &redeemedAt.Time
+	}
+	return &invite, nil
+}
+
+// GetInvites returns the invites we created, newest first
+func (r *SQLiteRepository) GetInvites() ([]models.InviteRecord, error) {
+	rows, err := r.db.Query("SELECT " + inviteColumns + " FROM invites ORDER BY created_at DESC")
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_invites", err)
+	}
+	defer rows.Close()
+
+	invites := []models.InviteRecord{}
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, utils.WrapDatabaseError("scan_invite", err)
+		}
+		invites = append(invites, *invite)
+	}
+	return invites, nil
+}
+
+// RedeemInvite marks an unexpired, unused invite as redeemed by a peer and returns it.
+// Redeeming it again is harmless for the peer that already redeemed it.
+func (r *SQLiteRepository) RedeemInvite(token, peerID string, redeemedAt time.Time) (*models.InviteRecord, error) {
+	invite, err := scanInvite(r.db.QueryRow("SELECT "+inviteColumns+" FROM invites WHERE token = ?", token))
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("invite", token)
+	}
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_invite", err)
+	}
+
+	switch {
+	case invite.RedeemedBy == peerID:
+		return invite, nil
+	case invite.RedeemedBy != "":
+		return nil, utils.NewValidationError("token", "invite has already been redeemed")
+	case !redeemedAt.Before(invite.ExpiresAt):
+		return nil, utils.NewValidationError("token", "invite has expired")
+	}
+
+	// Only the first of two peers redeeming at once gets the invite
+	result, err := r.db.Exec(
+		"UPDATE invites SET redeemed_by = ?, redeemed_at = ? WHERE token = ? AND redeemed_by = ''",
+		peerID, redeemedAt.UTC(), token,
+	)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("redeem_invite", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return nil, utils.NewValidationError("token", "invite has already been redeemed")
+	}
+
+	invite.RedeemedBy = peerID
+	invite.RedeemedAt = &redeemedAt
+	return invite, nil
+}
+
+// DeleteInvite revokes an invite
+func (r *SQLiteRepository) DeleteInvite(token string) error {
+	result, err := r.db.Exec("DELETE FROM invites WHERE token = ?", token)
+	if err != nil {
+		return utils.WrapDatabaseError("delete_invite", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	if rowsAffected == 0 {
+		return utils.NewNotFoundError("invite", token)
+	}
+	return nil
+}
+
+const peerAddressColumns = "peer_id, address, transport, first_seen, last_seen, last_connected, success_count, failure_count"
+
+// ObservePeerAddress records that an address was seen for a peer, and whether we are connected
+// to the peer through it. Only the MaxPeerAddresses most recently seen addresses are kept.
+func (r *SQLiteRepository) ObservePeerAddress(peerID, address, transport string, connected bool) error {
+	now := time.Now().UTC()
+	var lastConnected interface{}
+	successes := 0
+	if connected {
+		lastConnected = now
+		successes = 1
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO peer_addresses (peer_id, address, transport, first_seen, last_seen, last_connected, success_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(peer_id, address) DO UPDATE SET
+			transport = excluded.transport,
+			last_seen = excluded.last_seen,
+			last_connected = COALESCE(excluded.last_connected, peer_addresses.last_connected),
+			success_count = peer_addresses.success_count + excluded.success_count
+	`, peerID, address, transport, now, now, lastConnected, successes)
+	if err != nil {
+		return utils.WrapDatabaseError("observe_peer_address", err)
+	}
+
+	_, err = r.db.Exec(`
+		DELETE FROM peer_addresses WHERE peer_id = ?1 AND address NOT IN (
+			SELECT address FROM peer_addresses WHERE peer_id = ?1 ORDER BY last_seen DESC LIMIT ?2
+		)
+	`, peerID, models.MaxPeerAddresses)
+	if err != nil {
+		return utils.WrapDatabaseError("prune_peer_addresses", err)
+	}
+	return nil
+}
+
+// RecordPeerAddressFailures counts a failed dial for each of the addresses we know for a peer
+func (r *SQLiteRepository) RecordPeerAddressFailures(peerID string, addresses []string) error {
+	for _, address := range addresses {
+		_, err := r.db.Exec(
+			"UPDATE peer_addresses SET failure_count = failure_count + 1 WHERE peer_id = ? AND address = ?",
+			peerID, address,
+		)
+		if err != nil {
+			return utils.WrapDatabaseError("record_peer_address_failure", err)
+		}
+	}
+	return nil
+}
+
+// GetPeerAddresses returns the addresses observed for a peer, those we last connected through first
+func (r *SQLiteRepository) GetPeerAddresses(peerID string) ([]models.PeerAddress, error) {
+	rows, err := r.db.Query(`
+		SELECT `+peerAddressColumns+` FROM peer_addresses WHERE peer_id = ?
+		ORDER BY last_connected IS NULL, last_connected DESC, last_seen DESC
+	`, peerID)
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_peer_addresses", err)
+	}
+	defer rows.Close()
+
+	addresses := []models.PeerAddress{}
+	for rows.Next() {
+		var address models.PeerAddress
+		var lastConnected sql.NullTime
+		if err := rows.Scan(&address.PeerID, &address.Address, &address.Transport, &address.FirstSeen,
+			&address.LastSeen, &lastConnected, &address.Successes, &address.Failures); err != nil {
+			return nil, utils.WrapDatabaseError("scan_peer_address", err)
+		}
+		if lastConnected.Valid {
+			address.LastConnected = &lastConnected.Time
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// PurgePeerData deletes what we stored about a peer: its connection history and friendship,
+// its friend requests and group memberships, the friends list it shared, its file records, profile and addresses
+func (r *SQLiteRepository) PurgePeerData(peerID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return utils.WrapDatabaseError("begin_transaction", err)
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		operation string
+		sql       string
+	}{
+		{"purge_connections", "DELETE FROM connections WHERE peer_id = ?"},
+		{"purge_friend_requests", "DELETE FROM friend_requests WHERE peer_id = ?"},
+		{"purge_friend_group_members", "DELETE FROM friend_group_members WHERE peer_id = ?"},
+		{"purge_peer_friends", "DELETE FROM peer_friends WHERE peer_id = ?1 OR friend_peer_id = ?1"},
+		{"purge_files", "DELETE FROM files WHERE peer_id = ?"},
+		{"purge_peer_profiles", "DELETE FROM peer_profiles WHERE peer_id = ?"},
+		{"purge_peer_addresses", "DELETE FROM peer_addresses WHERE peer_id = ?"},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.sql, peerID); err != nil {
+			return utils.WrapDatabaseError(statement.operation, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.WrapDatabaseError("commit_transaction", err)
+	}
+
+	log.Printf("🧹 Purged stored data of peer %s", peerID)
+	return nil
+}
+
+// Peer Profiles Repository Implementation
+
+// SavePeerProfile stores a verified profile record unless we already have one with the same
+// or a higher sequence number. It reports whether the record was stored.
+func (r *SQLiteRepository) SavePeerProfile(record *models.ProfileRecord) (bool, error) {
+	links, err := json.Marshal(record.Links)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode profile links: %w", err)
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO peer_profiles (peer_id, name, avatar_hash, bio, links, location, seq, updated_at, public_key, signature, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(peer_id) DO UPDATE SET
+			name = excluded.name,
+			avatar_hash = excluded.avatar_hash,
+			bio = excluded.bio,
+			links = excluded.links,
+			location = excluded.location,
+			seq = excluded.seq,
+			updated_at = excluded.updated_at,
+			public_key = excluded.public_key,
+			signature = excluded.signature,
+			received_at = excluded.received_at
+		WHERE excluded.seq > peer_profiles.seq
+	`, record.PeerID, record.Name, record.AvatarHash, record.Bio, string(links), record.Location, int64(record.Seq),
+		record.UpdatedAt.UTC(), record.PublicKey, record.Signature, time.Now().UTC())
+	if err != nil {
+		return false, utils.WrapDatabaseError("save_peer_profile", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, utils.WrapDatabaseError("get_rows_affected", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetPeerProfile returns the newest profile record we have of a peer, or nil if we have none
+func (r *SQLiteRepository) GetPeerProfile(peerID string) (*models.ProfileRecord, error) {
+	var record models.ProfileRecord
+	var links string
+	var seq int64
+	err := r.db.QueryRow(`
+		SELECT peer_id, name, avatar_hash, bio, links, location, seq, updated_at, public_key, signature
+		FROM peer_profiles WHERE peer_id = ?
+	`, peerID).Scan(&record.PeerID, &record.Name, &record.AvatarHash, &record.Bio, &links, &record.Location, &seq,
+		&record.UpdatedAt, &record.PublicKey, &record.Signature)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.WrapDatabaseError("get_peer_profile", err)
+	}
+
+	if err := json.Unmarshal([]byte(links), &record.Links); err != nil {
+		return nil, fmt.Errorf("failed to decode profile links of %s: %w", peerID, err)
+	}
+	record.Seq = uint64(seq)
+	return &record, nil
+}
+
+// Key Rotation Repository Implementation
+
+// MigratePeerID moves everything stored under a peer's old ID to the new ID the peer rotated to.
+// Rows the new ID already has win over the old ones, except that friendship carries over.
+// It reports whether we had a connection with the old ID.
+func (r *SQLiteRepository) MigratePeerID(oldPeerID, newPeerID string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, utils.WrapDatabaseError("begin_transaction", err)
+	}
+	defer tx.Rollback()
+
+	var known int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM connections WHERE peer_id = ?", oldPeerID).Scan(&known); err != nil {
+		return false, utils.WrapDatabaseError("check_connection", err)
+	}
+
+	statements := []struct {
+		operation string
+		sql       string
+	}{
+		{"migrate_friendship", "UPDATE connections SET friend = 1 WHERE peer_id = ?2 AND EXISTS (SELECT 1 FROM connections WHERE peer_id = ?1 AND friend = 1)"},
+		{"drop_duplicate_connection", "DELETE FROM connections WHERE peer_id = ?1 AND EXISTS (SELECT 1 FROM connections WHERE peer_id = ?2)"},
+		{"migrate_connections", "UPDATE connections SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"drop_duplicate_friend_request", "DELETE FROM friend_requests WHERE peer_id = ?2 AND EXISTS (SELECT 1 FROM friend_requests WHERE peer_id = ?1)"},
+		{"migrate_friend_requests", "UPDATE friend_requests SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"migrate_friend_group_members", "UPDATE OR IGNORE friend_group_members SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"migrate_peer_friends", "UPDATE OR IGNORE peer_friends SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"migrate_peer_friend_entries", "UPDATE OR IGNORE peer_friends SET friend_peer_id = ?2 WHERE friend_peer_id = ?1"},
+		{"migrate_files", "UPDATE OR IGNORE files SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"migrate_messages", "UPDATE messages SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"migrate_posts", "UPDATE posts SET author_id = ?2 WHERE author_id = ?1"},
+		{"migrate_comment_owners", "UPDATE comments SET owner_id = ?2 WHERE owner_id = ?1"},
+		{"migrate_comment_authors", "UPDATE OR IGNORE comments SET author_id = ?2 WHERE author_id = ?1"},
+		{"migrate_notifications", "UPDATE notifications SET peer_id = ?2 WHERE peer_id = ?1"},
+		{"migrate_invites", "UPDATE invites SET redeemed_by = ?2 WHERE redeemed_by = ?1"},
+		{"migrate_peer_addresses", "UPDATE OR IGNORE peer_addresses SET peer_id = ?2 WHERE peer_id = ?1"},
+		// Leftovers are duplicates of rows the new ID already has, the old profile record no longer verifies
+		{"drop_old_friend_group_members", "DELETE FROM friend_group_members WHERE peer_id = ?1"},
+		{"drop_old_peer_friends", "DELETE FROM peer_friends WHERE peer_id = ?1 OR friend_peer_id = ?1"},
+		{"drop_old_files", "DELETE FROM files WHERE peer_id = ?1"},
+		{"drop_old_comments", "DELETE FROM comments WHERE author_id = ?1"},
+		{"drop_old_peer_profile", "DELETE FROM peer_profiles WHERE peer_id = ?1"},
+		{"drop_old_peer_addresses", "DELETE FROM peer_addresses WHERE peer_id = ?1"},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.sql, oldPeerID, newPeerID); err != nil {
+			return false, utils.WrapDatabaseError(statement.operation, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, utils.WrapDatabaseError("commit_transaction", err)
+	}
+
+	log.Printf("🔑 Migrated stored data of %s to %s", oldPeerID, newPeerID)
+	return known > 0, nil
+}
+
+// Files Repository Implementation
+func (r *SQLiteRepository) FileExists(filePath string) (bool, string, error) {
+	var hash string
+	err := r.db.QueryRow("SELECT hash FROM files WHERE filepath = ?", filePath).Scan(&hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, "", nil
+		}
+		return false, "", utils.WrapDatabaseError("check_file_exists", err)
+	}
+	return true, hash, nil
+}
+
+// GetFileRecord returns the file record for a path owned by the given peer, or nil if there is none
+func (r *SQLiteRepository) GetFileRecord(filePath, peerID string) (*models.FileRecord, error) {
+	var file models.FileRecord
+	err := r.db.QueryRow(`
+		SELECT id, filepath, hash, size, extension, type, peer_id, updated_at
+		FROM files
+		WHERE filepath = ? AND peer_id = ?
+	`, filePath, peerID).Scan(
+		&file.ID, &file.FilePath, &file.Hash,
+		&file.Size, &file.Extension, &file.Type, &file.PeerID, &file.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, utils.WrapDatabaseError("get_file_record", err)
+	}
+	return &file, nil
+}
+
+func (r *SQLiteRepository) UpsertFileRecord(filePath, hash string, size int64, extension, fileType, peerID string) error {
+	_, err := r.db.Exec(`
+		INSERT OR REPLACE INTO files (filepath, hash, size, extension, type, peer_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, filePath, hash, size, extension, fileType, peerID)
+
+	if err != nil {
+		return utils.WrapDatabaseError("upsert_file_record", err)
+	}
+	return nil
+}
+
+// CountPeerFiles returns how many file records we store for a peer
+func (r *SQLiteRepository) CountPeerFiles(peerID string) (int, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM files WHERE peer_id = ?", peerID).Scan(&count); err != nil {
+		return 0, utils.WrapDatabaseError("count_peer_files", err)
+	}
+	return count, nil
+}
+
 func (r *SQLiteRepository) GetFiles() ([]models.FileRecord, error) {
 	rows, err := r.db.Query(`
 		SELECT id, filepath, hash, size, extension, type, peer_id, updated_at
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+// defaultVisibilitySetting is the settings key holding the visibility of content without a rule
+const defaultVisibilitySetting = "default_visibility"
+
+// AccessService decides which docs, galleries and files a peer may see. Rules are stored per path
+// relative to the space184 directory and a rule on a directory covers everything below it.
+type AccessService struct {
+	database         interfaces.DatabaseService
+	directoryService DirectoryServiceInterface
+	pathManager      *utils.PathManager
+}
+
+// NewAccessService creates a new access service
+func NewAccessService(database interfaces.DatabaseService, directoryService DirectoryServiceInterface, pathManager *utils.PathManager) *AccessService {
+	return &AccessService{
+		database:         database,
+		directoryService: directoryService,
+		pathManager:      pathManager,
+	}
+}
+
+// GetRules returns every visibility rule and the default visibility
+func (as *AccessService) GetRules() (*models.ContentVisibilityResponse, error) {
+	rules, err := as.database.GetContentVisibilities()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultRule := as.defaultVisibility()
+	return &models.ContentVisibilityResponse{
+		Rules:        rules,
+		Count:        len(rules),
+		Default:      defaultRule.Visibility,
+		DefaultGroup: defaultRule.Group,
+	}, nil
+}
+
+// GetEffectiveVisibility returns the rule that applies to a path
+func (as *AccessService) GetEffectiveVisibility(contentPath string) (*models.EffectiveVisibility, error) {
+	contentPath, err := as.cleanContentPath(contentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := as.loadRules()
+	if err != nil {
+		return nil, err
+	}
+
+	rule, inheritedFrom := resolveVisibility(rules, contentPath, as.defaultVisibility())
+	return &models.EffectiveVisibility{
+		Path:          contentPath,
+		Visibility:    rule.Visibility,
+		Group:         rule.Group,
+		InheritedFrom: inheritedFrom,
+	}, nil
+}
+
+// SetVisibility sets the visibility of a doc, media file or directory, or the default when the path is empty
+func (as *AccessService) SetVisibility(request models.SetVisibilityRequest) (*models.ContentVisibility, error) {
+	if err := validateVisibility(request.Visibility, request.Group); err != nil {
+		return nil, err
+	}
+	if request.Visibility != models.VisibilityGroup {
+		request.Group = ""
+	} else {
+		group, err := as.database.GetFriendGroup(request.Group, 0)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return nil, utils.NewNotFoundError("friend group", request.Group)
+		}
+	}
+
+	if request.Path == "" {
+		value := request.Visibility
+		if request.Group != "" {
+			value += ":" + request.Group
+		}
+		if err := as.database.SetSetting(defaultVisibilitySetting, value); err != nil {
+			return nil, err
+		}
+
+		log.Printf("🔐 Default visibility set to %s", value)
+		return &models.ContentVisibility{Visibility: request.Visibility, Group: request.Group}, nil
+	}
+
+	contentPath, err := as.cleanContentPath(request.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(as.pathManager.GetSpace184Path(), filepath.FromSlash(contentPath))); err != nil {
+		return nil, utils.NewNotFoundError("path", contentPath)
+	}
+
+	rule := &models.ContentVisibility{
+		Path:       contentPath,
+		Visibility: request.Visibility,
+		Group:      request.Group,
+	}
+	if err := as.database.SetContentVisibility(rule); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔐 Visibility of %s set to %s", contentPath, request.Visibility)
+	return rule, nil
+}
+
+// RemoveVisibility removes the rule of a path so it inherits from its directory again
+func (as *AccessService) RemoveVisibility(contentPath string) error {
+	contentPath, err := as.cleanContentPath(contentPath)
+	if err != nil {
+		return err
+	}
+
+	if err := as.database.DeleteContentVisibility(contentPath); err != nil {
+		return err
+	}
+
+	log.Printf("🔐 Removed visibility rule of %s", contentPath)
+	return nil
+}
+
+// ForPeer returns the access decisions for one peer, loading the rules and the peer's friendship once.
+// If the rules can't be loaded nothing is shared.
+func (as *AccessService) ForPeer(peerID peer.ID) *ContentAccess {
+	access := &ContentAccess{service: as, peerID: peerID.String()}
+
+	rules, err := as.loadRules()
+	if err != nil {
+		log.Printf("⚠️ Failed to load visibility rules, sharing nothing with %s: %v", peerID, err)
+		return access
+	}
+	access.rules = rules
+	access.defaultRule = as.defaultVisibility()
+
+	isFriend, err := as.database.IsFriend(access.peerID)
+	if err != nil {
+		log.Printf("⚠️ Failed to check friendship of %s: %v", peerID, err)
+	}
+	access.isFriend = isFriend
+	access.loaded = true
+
+	return access
+}
+
+// contentAccess returns the access decisions for a peer requesting our content, sharing nothing
+// when the access service isn't available
+func (p *P2PService) contentAccess(peerID peer.ID) *ContentAccess {
+	if p.container == nil || p.container.GetAccessService() == nil {
+		return &ContentAccess{peerID: peerID.String()}
+	}
+	return p.container.GetAccessService().ForPeer(peerID)
+}
+
+// loadRules returns the visibility rules keyed by path
+func (as *AccessService) loadRules() (map[string]models.ContentVisibility, error) {
+	rules, err := as.database.GetContentVisibilities()
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]models.ContentVisibility, len(rules))
+	for _, rule := range rules {
+		byPath[rule.Path] = rule
+	}
+	return byPath, nil
+}
+
+// defaultVisibility returns the visibility of content without a rule, public unless configured
+func (as *AccessService) defaultVisibility() models.ContentVisibility {
+	value, err := as.database.GetSetting(defaultVisibilitySetting)
+	if err != nil || value == "" {
+		return models.ContentVisibility{Visibility: models.VisibilityPublic}
+	}
+
+	visibility, group, _ := strings.Cut(value, ":")
+	return models.ContentVisibility{Visibility: visibility, Group: group}
+}
+
+// cleanContentPath normalizes a path relative to the space184 directory and rejects paths outside it
+func (as *AccessService) cleanContentPath(contentPath string) (string, error) {
+	if contentPath == "" {
+		return "", utils.NewValidationError("path", "path is required")
+	}
+	if filepath.IsAbs(contentPath) || path.IsAbs(filepath.ToSlash(contentPath)) {
+		return "", utils.NewValidationError("path", "path must be relative to the space184 directory")
+	}
+
+	cleaned := path.Clean(filepath.ToSlash(contentPath))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", utils.NewValidationError("path", "path must be inside the space184 directory")
+	}
+	return cleaned, nil
+}
+
+// relativePath converts an absolute path inside the space184 directory to a rule path
+func (as *AccessService) relativePath(absolutePath string) (string, bool) {
+	relPath, err := as.pathManager.GetRelativePath(absolutePath)
+	if err != nil {
+		return "", false
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", false
+	}
+	return relPath, true
+}
+
+// mediaFileRelativePath returns the rule path of a file in one of our media galleries
+func (as *AccessService) mediaFileRelativePath(mediaType models.MediaType, galleryName, fileName string) (string, bool) {
+	mediaDir, rootGalleryName := as.mediaDirectory(mediaType)
+	if mediaDir == "" {
+		return "", false
+	}
+
+	// Files of named galleries live in the gallery directory, the root gallery collects them from everywhere
+	if galleryName != rootGalleryName {
+		return as.relativePath(filepath.Join(mediaDir, galleryName, fileName))
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, fileName)); err == nil {
+		return as.relativePath(filepath.Join(mediaDir, fileName))
+	}
+
+	filePath, err := as.directoryService.GetMediaFilePath(mediaType, galleryName, fileName)
+	if err != nil {
+		return "", false
+	}
+	return as.relativePath(filePath)
+}
+
+// mediaDirectory returns the directory and root gallery name of a media type
+func (as *AccessService) mediaDirectory(mediaType models.MediaType) (string, string) {
+	switch mediaType {
+	case models.MediaTypeImage:
+		return as.pathManager.GetImagesPath(), "root_images"
+	case models.MediaTypeAudio:
+		return as.pathManager.GetAudioPath(), "root_audio"
+	case models.MediaTypeVideo:
+		return as.pathManager.GetVideoPath(), "root_video"
+	case models.MediaTypeDocs:
+		return as.pathManager.GetDocsPath(), "root_docs"
+	}
+	return "", ""
+}
+
+// ContentAccess answers whether one peer may see a piece of our content
+type ContentAccess struct {
+	service     *AccessService
+	peerID      string
+	rules       map[string]models.ContentVisibility
+	defaultRule models.ContentVisibility
+	isFriend    bool
+	loaded      bool
+	groups      map[string]bool
+}
+
+// CanRead reports whether the peer may see the content at a path relative to the space184 directory
+func (ca *ContentAccess) CanRead(contentPath string) bool {
+	if !ca.loaded {
+		return false
+	}
+
+	contentPath = path.Clean(filepath.ToSlash(contentPath))
+	if ca.isAvatarPath(contentPath) && !ca.Discloses(models.PrivacyItemAvatar) {
+		return false
+	}
+
+	rule, _ := resolveVisibility(ca.rules, contentPath, ca.defaultRule)
+	switch rule.Visibility {
+	case models.VisibilityPublic:
+		return true
+	case models.VisibilityFriends:
+		return ca.isFriend
+	case models.VisibilityGroup:
+		return ca.isFriend && ca.inGroup(rule.Group)
+	default:
+		return false
+	}
+}
+
+// CanReadDoc reports whether the peer may see a doc in the docs directory
+func (ca *ContentAccess) CanReadDoc(filename string) bool {
+	if !ca.loaded {
+		return false
+	}
+	relPath, ok := ca.service.relativePath(filepath.Join(ca.service.directoryService.GetDocsDirectory(), filename))
+	return ok && ca.CanRead(relPath)
+}
+
+// CanReadMediaFile reports whether the peer may see a file of one of our media galleries
+func (ca *ContentAccess) CanReadMediaFile(mediaType models.MediaType, galleryName, fileName string) bool {
+	if !ca.loaded {
+		return false
+	}
+	relPath, ok := ca.service.mediaFileRelativePath(mediaType, galleryName, fileName)
+	return ok && ca.CanRead(relPath)
+}
+
+// FilterDocs returns the docs the peer may see
+func (ca *ContentAccess) FilterDocs(docs []models.Doc) []models.Doc {
+	visible := []models.Doc{}
+	for _, doc := range docs {
+		if ca.CanReadDoc(doc.Filename) {
+			visible = append(visible, doc)
+		}
+	}
+	return visible
+}
+
+// FilterGallery removes the files the peer may not see from a gallery and reports whether
+// the gallery should be shown at all
+func (ca *ContentAccess) FilterGallery(gallery *models.MediaGallery) bool {
+	if !ca.loaded {
+		return false
+	}
+	if len(gallery.Files) == 0 {
+		mediaDir, _ := ca.service.mediaDirectory(gallery.MediaType)
+		relPath, ok := ca.service.relativePath(filepath.Join(mediaDir, gallery.Name))
+		return ok && ca.CanRead(relPath)
+	}
+
+	files := []string{}
+	for _, fileName := range gallery.Files {
+		if ca.CanReadMediaFile(gallery.MediaType, gallery.Name, fileName) {
+			files = append(files, fileName)
+		}
+	}
+
+	gallery.Files = files
+	gallery.FileCount = len(files)
+	return len(files) > 0
+}
+
+// FilterGalleries returns the galleries the peer may see, with their files filtered
+func (ca *ContentAccess) FilterGalleries(galleries []models.MediaGallery) []models.MediaGallery {
+	visible := []models.MediaGallery{}
+	for _, gallery := range galleries {
+		if ca.FilterGallery(&gallery) {
+			visible = append(visible, gallery)
+		}
+	}
+	return visible
+}
+
+// FilterFiles returns the files table records the peer may see
+func (ca *ContentAccess) FilterFiles(files []models.FileRecord) []models.FileRecord {
+	visible := []models.FileRecord{}
+	for _, file := range files {
+		if ca.CanRead(file.FilePath) {
+			visible = append(visible, file)
+		}
+	}
+	return visible
+}
+
+// inGroup reports whether the peer belongs to a friend group, remembering the answer for the
+// rest of the request. A failed lookup counts as not a member.
+func (ca *ContentAccess) inGroup(group string) bool {
+	if member, ok := ca.groups[group]; ok {
+		return member
+	}
+
+	member, err := ca.service.database.IsFriendGroupMember(group, ca.peerID)
+	if err != nil {
+		log.Printf("⚠️ Failed to check membership of %s in friend group %s: %v", ca.peerID, group, err)
+	}
+
+	if ca.groups == nil {
+		ca.groups = make(map[string]bool)
+	}
+	ca.groups[group] = member
+	return member
+}
+
+// resolveVisibility finds the rule of a path or its closest directory, falling back to the default.
+// It also returns the path of the rule that applied, empty for the default.
+func resolveVisibility(rules map[string]models.ContentVisibility, contentPath string, defaultRule models.ContentVisibility) (models.ContentVisibility, string) {
+	for current := contentPath; current != "." && current != "/" && current != ""; current = path.Dir(current) {
+		if rule, ok := rules[current]; ok {
+			return rule, current
+		}
+	}
+	return defaultRule, ""
+}
+
+// validateVisibility checks a visibility level and the group it needs
+func validateVisibility(visibility, group string) error {
+	switch visibility {
+	case models.VisibilityPublic, models.VisibilityFriends, models.VisibilityPrivate:
+		return nil
+	case models.VisibilityGroup:
+		if strings.TrimSpace(group) == "" {
+			return utils.NewValidationError("group", "group visibility needs a group name")
+		}
+		return nil
+	default:
+		return utils.NewValidationError("visibility", fmt.Sprintf("unknown visibility %q, expected public, friends, group or private", visibility))
+	}
+}
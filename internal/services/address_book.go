@@ -0,0 +1,142 @@
+package services
+
+import (
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+
+	"old-school/internal/models"
+)
+
+// watchPeerAddresses records in the address book the listen addresses app peers announce
+// during libp2p identify, and the addresses we successfully dialed them at
+func (p *P2PService) watchPeerAddresses() error {
+	sub, err := p.host.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				p.recordPeerAddresses(e.(event.EvtPeerIdentificationCompleted))
+			}
+		}
+	}()
+	return nil
+}
+
+// recordPeerAddresses stores the addresses learned from an identify exchange with an app peer
+func (p *P2PService) recordPeerAddresses(evt event.EvtPeerIdentificationCompleted) {
+	if !speaksAppProtocol(evt.Protocols) {
+		return
+	}
+
+	peerID := evt.Peer.String()
+	remote := evt.Conn.RemoteMultiaddr()
+	loopback := manet.IsIPLoopback(remote)
+
+	// The remote address of an inbound connection is an ephemeral port of the peer
+	if evt.Conn.Stat().Direction == network.DirOutbound {
+		if err := p.dbService.ObservePeerAddress(peerID, remote.String(), addressTransport(remote), true); err != nil {
+			log.Printf("⚠️ Warning: Failed to record address of peer %s: %v", peerID, err)
+		}
+	}
+
+	for _, addr := range evt.ListenAddrs {
+		if addr.Equal(remote) || (manet.IsIPLoopback(addr) && !loopback) || manet.IsIP6LinkLocal(addr) {
+			continue
+		}
+		if err := p.dbService.ObservePeerAddress(peerID, addr.String(), addressTransport(addr), false); err != nil {
+			log.Printf("⚠️ Warning: Failed to record address of peer %s: %v", peerID, err)
+		}
+	}
+}
+
+// seedPeerstore hands the addresses stored for our friends to libp2p, so they can be dialed
+// even though their addresses changed since they were last connected
+func (p *P2PService) seedPeerstore() {
+	friends, err := p.dbService.GetFriends()
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to get friends for the peerstore: %v", err)
+		return
+	}
+
+	seeded := 0
+	for _, friend := range friends {
+		pid, err := peer.Decode(friend.PeerID)
+		if err != nil {
+			continue
+		}
+		addrs := p.storedPeerAddresses(pid)
+		if len(addrs) == 0 {
+			continue
+		}
+		p.host.Peerstore().AddAddrs(pid, addrs, peerstore.AddressTTL)
+		seeded++
+	}
+
+	if seeded > 0 {
+		log.Printf("📒 Loaded stored addresses of %d friend(s) into the peerstore", seeded)
+	}
+}
+
+// storedPeerAddresses returns the address book entries of a peer, best first
+func (p *P2PService) storedPeerAddresses(pid peer.ID) []multiaddr.Multiaddr {
+	records, err := p.dbService.GetPeerAddresses(pid.String())
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to get addresses of peer %s: %v", pid, err)
+		return nil
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0, len(records))
+	for _, record := range records {
+		if addr, err := multiaddr.NewMultiaddr(record.Address); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// speaksAppProtocol reports whether a peer supports our identify protocol
+func speaksAppProtocol(protocols []protocol.ID) bool {
+	for _, id := range protocols {
+		if id == protocol.ID(IdentifyProtocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressTransport classifies a multiaddr by the transport used to reach it
+func addressTransport(addr multiaddr.Multiaddr) string {
+	transport := models.TransportOther
+	multiaddr.ForEach(addr, func(c multiaddr.Component) bool {
+		switch c.Protocol().Code {
+		case multiaddr.P_CIRCUIT:
+			transport = models.TransportRelay
+			return false
+		case multiaddr.P_QUIC, multiaddr.P_QUIC_V1:
+			transport = models.TransportQUIC
+		case multiaddr.P_TCP:
+			if transport == models.TransportOther {
+				transport = models.TransportTCP
+			}
+		}
+		return true
+	})
+	return transport
+}
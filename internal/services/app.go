@@ -3,6 +3,7 @@ package services
 import (
 	"log"
 
+	"old-school/internal/config"
 	"old-school/internal/interfaces"
 	"old-school/internal/models"
 )
@@ -14,9 +15,9 @@ type AppService struct {
 }
 
 // NewAppService creates a new application service
-func NewAppService() *AppService {
+func NewAppService(cfg *config.Config) *AppService {
 	// Initialize service container
-	container, err := NewServiceContainer()
+	container, err := NewServiceContainer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create service container: %v", err)
 	}
@@ -25,9 +26,13 @@ func NewAppService() *AppService {
 		container: container,
 	}
 
-	// Initialize monitor service now that AppService is available
-	if err := container.InitializeMonitorService(appService); err != nil {
-		log.Printf("⚠️ Warning: failed to initialize monitor service: %v", err)
+	// Keep folder information current with the monitor's scans
+	if err := container.GetEventBus().Subscribe(models.EventFolderScanned, func(data interface{}) {
+		if folderInfo, ok := data.(*models.FolderInfo); ok {
+			appService.SetFolderInfo(folderInfo)
+		}
+	}); err != nil {
+		log.Printf("⚠️ Warning: failed to subscribe to folder scans: %v", err)
 	}
 
 	// Perform startup tasks
@@ -121,3 +126,48 @@ func (a *AppService) GetDatabaseService() interfaces.DatabaseService {
 func (a *AppService) GetFriendService() *FriendService {
 	return a.container.GetFriendService()
 }
+
+// GetChatService returns the chat service
+func (a *AppService) GetChatService() *ChatService {
+	return a.container.GetChatService()
+}
+
+// GetPostService returns the post service
+func (a *AppService) GetPostService() *PostService {
+	return a.container.GetPostService()
+}
+
+// GetAccessService returns the access service
+func (a *AppService) GetAccessService() *AccessService {
+	return a.container.GetAccessService()
+}
+
+// GetCommentService returns the comment service
+func (a *AppService) GetCommentService() *CommentService {
+	return a.container.GetCommentService()
+}
+
+// GetNotificationService returns the notification service
+func (a *AppService) GetNotificationService() *NotificationService {
+	return a.container.GetNotificationService()
+}
+
+// GetProfileService returns the profile service
+func (a *AppService) GetProfileService() *ProfileService {
+	return a.container.GetProfileService()
+}
+
+// GetIdentityService returns the identity backup service
+func (a *AppService) GetIdentityService() *IdentityService {
+	return a.container.GetIdentityService()
+}
+
+// GetBlockService returns the block service
+func (a *AppService) GetBlockService() *BlockService {
+	return a.container.GetBlockService()
+}
+
+// GetInviteService returns the invite service
+func (a *AppService) GetInviteService() *InviteService {
+	return a.container.GetInviteService()
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+const (
+	// BlobChunkSize is the size of the chunks raw file data is streamed in
+	BlobChunkSize = 256 * 1024
+
+	// BlobIdleTimeout is how long a blob transfer may stall before it is aborted
+	BlobIdleTimeout = 30 * time.Second
+
+	// blobPartSuffix marks partially downloaded files that can be resumed
+	blobPartSuffix = ".part"
+
+	// blobProgressInterval is the minimum time between download progress events
+	blobProgressInterval = 500 * time.Millisecond
+)
+
+// handleBlobStream serves a byte range of a media file as raw bytes.
+// The stream carries a JSON BlobRequest, answered by a JSON BlobResponse header followed by Length raw bytes.
+func (p *P2PService) handleBlobStream(stream network.Stream) {
+	defer stream.Close()
+
+	peerID := stream.Conn().RemotePeer()
+
+	stream.SetReadDeadline(time.Now().Add(BlobIdleTimeout))
+
+	var request models.BlobRequest
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&request); err != nil {
+		log.Printf("Failed to decode blob request: %v", err)
+		return
+	}
+
+	log.Printf("📦 Processing blob request for %s/%s/%s (offset %d) from %s",
+		request.MediaType, request.GalleryName, request.FileName, request.Offset, peerID)
+
+	file, response := p.openBlob(peerID, &request)
+	if file != nil {
+		defer file.Close()
+	}
+
+	stream.SetWriteDeadline(time.Now().Add(BlobIdleTimeout))
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(response); err != nil {
+		log.Printf("Failed to send blob response header: %v", err)
+		return
+	}
+
+	if file == nil || response.Length == 0 {
+		return
+	}
+
+	if _, err := file.Seek(response.Offset, io.SeekStart); err != nil {
+		log.Printf("Failed to seek blob %s: %v", response.FilePath, err)
+		return
+	}
+
+	reader := io.LimitReader(file, response.Length)
+	buffer := make([]byte, BlobChunkSize)
+	var sent int64
+
+	for sent < response.Length {
+		n, readErr := reader.Read(buffer)
+		if n > 0 {
+			stream.SetWriteDeadline(time.Now().Add(BlobIdleTimeout))
+			if _, err := stream.Write(buffer[:n]); err != nil {
+				log.Printf("Failed to send blob %s to %s after %d bytes: %v", response.FilePath, peerID, sent, err)
+				return
+			}
+			sent += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			log.Printf("Failed to read blob %s: %v", response.FilePath, readErr)
+			return
+		}
+	}
+
+	log.Printf("📦 Sent %d bytes of %s to %s", sent, response.FilePath, peerID)
+}
+
+// openBlob resolves a blob request to an open file and the response header describing the range to send
+func (p *P2PService) openBlob(peerID peer.ID, request *models.BlobRequest) (*os.File, *models.BlobResponse) {
+	if p.container == nil || p.container.GetDirectoryService() == nil {
+		return nil, &models.BlobResponse{Found: false, Error: "directory service not available"}
+	}
+
+	// Content the peer may not see is reported as missing
+	if !p.contentAccess(peerID).CanReadMediaFile(request.MediaType, request.GalleryName, request.FileName) {
+		log.Printf("🔐 Blob %s/%s/%s is not visible to %s", request.MediaType, request.GalleryName, request.FileName, peerID)
+		return nil, &models.BlobResponse{Found: false, Error: "file not found"}
+	}
+
+	filePath, err := p.container.GetDirectoryService().GetMediaFilePath(request.MediaType, request.GalleryName, request.FileName)
+	if err != nil {
+		log.Printf("Failed to resolve blob %s/%s/%s: %v", request.MediaType, request.GalleryName, request.FileName, err)
+		return nil, &models.BlobResponse{Found: false, Error: "file not found"}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Printf("Failed to open blob %s: %v", filePath, err)
+		return nil, &models.BlobResponse{Found: false, Error: "file not found"}
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		log.Printf("Failed to stat blob %s: %v", filePath, err)
+		return nil, &models.BlobResponse{Found: false, Error: "file not readable"}
+	}
+
+	relPath, hash, err := p.getLocalFileHash(filePath, fileInfo)
+	if err != nil {
+		file.Close()
+		log.Printf("Failed to hash blob %s: %v", filePath, err)
+		return nil, &models.BlobResponse{Found: false, Error: "file not readable"}
+	}
+
+	size := fileInfo.Size()
+	if request.Offset < 0 || request.Offset > size {
+		file.Close()
+		return nil, &models.BlobResponse{
+			Found:    false,
+			Error:    fmt.Sprintf("offset %d out of range", request.Offset),
+			FilePath: relPath,
+			Hash:     hash,
+			Size:     size,
+		}
+	}
+
+	length := size - request.Offset
+	if request.Length > 0 && request.Length < length {
+		length = request.Length
+	}
+
+	return file, &models.BlobResponse{
+		Found:    true,
+		FilePath: relPath,
+		Hash:     hash,
+		Size:     size,
+		Offset:   request.Offset,
+		Length:   length,
+	}
+}
+
+// getLocalFileHash returns the relative path and BLAKE3 hash of one of our files,
+// preferring the hash stored in the files table and hashing the file if the record is missing or stale.
+// A stale record, one of another size or older than the file, is updated with the new hash.
+func (p *P2PService) getLocalFileHash(filePath string, fileInfo os.FileInfo) (string, string, error) {
+	relPath := filePath
+	if pathManager := p.container.GetPathManager(); pathManager != nil {
+		if rel, err := pathManager.GetRelativePath(filePath); err == nil {
+			relPath = rel
+		}
+	}
+
+	db := p.container.GetDatabase()
+	nodeID := p.GetNode().ID.String()
+
+	var record *models.FileRecord
+	if db != nil {
+		var err error
+		record, err = db.GetFileRecord(relPath, nodeID)
+		if err != nil {
+			log.Printf("⚠️ Failed to look up file record for %s: %v", relPath, err)
+		}
+	}
+
+	// updated_at has a resolution of one second
+	if record != nil && record.Size == fileInfo.Size() && !fileInfo.ModTime().Truncate(time.Second).After(record.UpdatedAt) {
+		return relPath, record.Hash, nil
+	}
+
+	hash, err := utils.DefaultHashService.ComputeFileHash(filePath)
+	if err != nil {
+		return relPath, "", err
+	}
+
+	if record != nil && record.Hash != hash {
+		log.Printf("🔄 File %s changed since it was last scanned, updating its hash", relPath)
+		if err := db.UpsertFileRecord(relPath, hash, fileInfo.Size(), record.Extension, record.Type, nodeID); err != nil {
+			log.Printf("⚠️ Failed to update file record for %s: %v", relPath, err)
+		}
+	}
+
+	return relPath, hash, nil
+}
+
+// FetchPeerBlob streams a byte range of a peer's media file into w and returns the response header
+func (p *P2PService) FetchPeerBlob(peerID string, request models.BlobRequest, w io.Writer) (*models.BlobResponse, error) {
+	peer, err := peer.Decode(peerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ID: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
+	defer cancel()
+
+	stream, err := p.host.NewStream(ctx, peer, protocol.ID(BlobProtocol))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob stream: %w", err)
+	}
+	defer stream.Close()
+
+	stream.SetWriteDeadline(time.Now().Add(BlobIdleTimeout))
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send blob request: %w", err)
+	}
+
+	stream.SetReadDeadline(time.Now().Add(BlobIdleTimeout))
+	decoder := json.NewDecoder(stream)
+	var response models.BlobResponse
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode blob response: %w", err)
+	}
+
+	if !response.Found {
+		return &response, fmt.Errorf("peer could not serve %s: %s", request.FileName, response.Error)
+	}
+
+	if progress, ok := w.(*blobProgressWriter); ok {
+		progress.total = response.Size
+		if progress.checkHeader != nil {
+			if err := progress.checkHeader(&response); err != nil {
+				return &response, err
+			}
+		}
+	}
+
+	// The decoder may already have buffered the first raw bytes after the header
+	reader := io.LimitReader(io.MultiReader(decoder.Buffered(), stream), response.Length)
+	buffer := make([]byte, BlobChunkSize)
+	var received int64
+
+	for received < response.Length {
+		stream.SetReadDeadline(time.Now().Add(BlobIdleTimeout))
+		n, readErr := reader.Read(buffer)
+		if n > 0 {
+			if _, err := w.Write(buffer[:n]); err != nil {
+				return &response, fmt.Errorf("failed to write blob data: %w", err)
+			}
+			received += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return &response, fmt.Errorf("failed to read blob data after %d bytes: %w", received, readErr)
+		}
+	}
+
+	if received != response.Length {
+		return &response, fmt.Errorf("blob transfer incomplete: received %d of %d bytes", received, response.Length)
+	}
+
+	return &response, nil
+}
+
+// DownloadPeerBlob downloads a peer's media file to destPath over the blob protocol.
+// An interrupted download is resumed from its partial file, and the result is verified
+// against the BLAKE3 hash our files table holds for the peer's file before it is moved into place.
+func (p *P2PService) DownloadPeerBlob(peerID string, mediaType models.MediaType, galleryName, fileName, destPath string) error {
+	pid, err := peer.Decode(peerID)
+	if err != nil {
+		return fmt.Errorf("invalid peer ID: %w", err)
+	}
+
+	// Older nodes don't serve the blob protocol, callers fall back to the JSON transfer
+	if peerProtocol := p.GetPeerProtocol(pid); peerProtocol != nil && !peerProtocol.Supports(wire.CapabilityBlob) {
+		return fmt.Errorf("peer %s does not support blob transfer", peerID)
+	}
+
+	// Only one download per destination, concurrent requests wait for it and reuse the result
+	lock, _ := p.blobDownloads.LoadOrStore(destPath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(destPath); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	err = p.downloadPeerBlob(peerID, mediaType, galleryName, fileName, destPath, true)
+
+	// Report the outcome so progress indicators can finish
+	result := models.DownloadEvent{PeerID: peerID, FileName: fileName, Done: true}
+	if err != nil {
+		result.Error = err.Error()
+	} else if fileInfo, statErr := os.Stat(destPath); statErr == nil {
+		result.Received = fileInfo.Size()
+		result.Total = fileInfo.Size()
+	}
+	publishEvent(p.container.GetEventBus(), models.EventDownloadProgress, result)
+
+	return err
+}
+
+// downloadPeerBlob performs a single download attempt, restarting from scratch once if resuming fails verification
+func (p *P2PService) downloadPeerBlob(peerID string, mediaType models.MediaType, galleryName, fileName, destPath string, allowRestart bool) error {
+	partPath := destPath + blobPartSuffix
+
+	var offset int64
+	if partInfo, err := os.Stat(partPath); err == nil {
+		offset = partInfo.Size()
+		log.Printf("📦 Resuming download of %s from %s at %d bytes", fileName, peerID, offset)
+	}
+
+	partFile, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open partial file: %w", err)
+	}
+
+	request := models.BlobRequest{
+		MediaType:   mediaType,
+		GalleryName: galleryName,
+		FileName:    fileName,
+		Offset:      offset,
+	}
+
+	// The download is verified against what the files metadata sync told us, not the serving peer's word
+	var expectedHash string
+	progress := &blobProgressWriter{
+		w:        partFile,
+		events:   p.container.GetEventBus(),
+		peerID:   peerID,
+		fileName: fileName,
+		received: offset,
+		checkHeader: func(response *models.BlobResponse) error {
+			hash, err := p.expectedPeerFileHash(peerID, response.FilePath)
+			if err != nil {
+				return err
+			}
+			if hash != response.Hash {
+				return fmt.Errorf("%s changed on peer %s since its files were last synced, sync them again", response.FilePath, peerID)
+			}
+			expectedHash = hash
+			return nil
+		},
+	}
+
+	response, fetchErr := p.FetchPeerBlob(peerID, request, progress)
+	closeErr := partFile.Close()
+
+	if fetchErr != nil {
+		if offset == 0 {
+			os.Remove(partPath)
+		}
+		// A partial file larger than the peer's file can never be completed
+		if response != nil && !response.Found && offset > 0 && offset > response.Size && allowRestart {
+			os.Remove(partPath)
+			return p.downloadPeerBlob(peerID, mediaType, galleryName, fileName, destPath, false)
+		}
+		return fetchErr
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to write partial file: %w", closeErr)
+	}
+
+	hash, err := utils.DefaultHashService.ComputeFileHash(partPath)
+	if err != nil {
+		return err
+	}
+
+	if hash != expectedHash {
+		os.Remove(partPath)
+		if offset > 0 && allowRestart {
+			// The file may have changed on the peer since the partial download was made
+			log.Printf("⚠️ Hash mismatch after resuming %s from %s, restarting download", fileName, peerID)
+			return p.downloadPeerBlob(peerID, mediaType, galleryName, fileName, destPath, false)
+		}
+		return fmt.Errorf("hash mismatch for %s: expected %s, got %s", fileName, expectedHash, hash)
+	}
+
+	if err := os.Rename(partPath, destPath); err != nil {
+		return fmt.Errorf("failed to move downloaded file into place: %w", err)
+	}
+
+	log.Printf("📦 Downloaded %s from %s (%d bytes, BLAKE3 verified)", fileName, peerID, response.Size)
+	return nil
+}
+
+// expectedPeerFileHash returns the BLAKE3 hash our files table holds for a peer's file
+func (p *P2PService) expectedPeerFileHash(peerID, filePath string) (string, error) {
+	if p.container == nil || p.container.GetDatabase() == nil {
+		return "", fmt.Errorf("database not available to verify %s", filePath)
+	}
+
+	record, err := p.container.GetDatabase().GetFileRecord(filePath, peerID)
+	if err != nil {
+		return "", err
+	}
+	if record == nil || record.Hash == "" {
+		return "", fmt.Errorf("no files record for %s of peer %s to verify it against, sync its files first", filePath, peerID)
+	}
+	return record.Hash, nil
+}
+
+// blobProgressWriter passes downloaded data through and publishes download progress events
+type blobProgressWriter struct {
+	w           io.Writer
+	events      interfaces.EventPublisher
+	peerID      string
+	fileName    string
+	received    int64
+	total       int64
+	lastPublish time.Time
+
+	// checkHeader, when set, may refuse the transfer once the response header arrived
+	checkHeader func(response *models.BlobResponse) error
+}
+
+// Write writes to the underlying writer, publishing progress at most every blobProgressInterval
+func (pw *blobProgressWriter) Write(data []byte) (int, error) {
+	n, err := pw.w.Write(data)
+	pw.received += int64(n)
+
+	if time.Since(pw.lastPublish) >= blobProgressInterval {
+		pw.lastPublish = time.Now()
+		publishEvent(pw.events, models.EventDownloadProgress, models.DownloadEvent{
+			PeerID:   pw.peerID,
+			FileName: pw.fileName,
+			Received: pw.received,
+			Total:    pw.total,
+		})
+	}
+
+	return n, err
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+// peerGater is the libp2p connection gater refusing blocked peers. Dials to them are refused
+// and incoming connections are dropped as soon as the remote peer ID is known, before any stream opens.
+type peerGater struct {
+	blocked map[peer.ID]bool
+	mutex   sync.RWMutex
+}
+
+// newPeerGater creates a connection gater with the block list stored in the database
+func newPeerGater(dbService interfaces.DatabaseService) *peerGater {
+	gater := &peerGater{blocked: make(map[peer.ID]bool)}
+
+	blockedPeers, err := dbService.GetBlockedPeers()
+	if err != nil {
+		log.Printf("⚠️ Warning: failed to load blocked peers: %v", err)
+		return gater
+	}
+
+	for _, blockedPeer := range blockedPeers {
+		peerID, err := peer.Decode(blockedPeer.PeerID)
+		if err != nil {
+			log.Printf("⚠️ Ignoring invalid blocked peer ID %s: %v", blockedPeer.PeerID, err)
+			continue
+		}
+		gater.blocked[peerID] = true
+	}
+
+	if len(gater.blocked) > 0 {
+		log.Printf("🚫 Loaded %d blocked peer(s)", len(gater.blocked))
+	}
+	return gater
+}
+
+// block refuses connections with a peer from now on
+func (g *peerGater) block(peerID peer.ID) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.blocked[peerID] = true
+}
+
+// unblock allows connections with a peer again
+func (g *peerGater) unblock(peerID peer.ID) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.blocked, peerID)
+}
+
+// isBlocked reports whether a peer is blocked
+func (g *peerGater) isBlocked(peerID peer.ID) bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.blocked[peerID]
+}
+
+// InterceptPeerDial refuses to dial blocked peers
+func (g *peerGater) InterceptPeerDial(peerID peer.ID) bool {
+	return !g.isBlocked(peerID)
+}
+
+// InterceptAddrDial refuses to dial any address of a blocked peer
+func (g *peerGater) InterceptAddrDial(peerID peer.ID, _ multiaddr.Multiaddr) bool {
+	return !g.isBlocked(peerID)
+}
+
+// InterceptAccept accepts every incoming connection, the remote peer isn't known yet
+func (g *peerGater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured drops connections with blocked peers once the security handshake identified them
+func (g *peerGater) InterceptSecured(_ network.Direction, peerID peer.ID, _ network.ConnMultiaddrs) bool {
+	return !g.isBlocked(peerID)
+}
+
+// InterceptUpgraded drops connections that were upgraded while the peer was being blocked
+func (g *peerGater) InterceptUpgraded(conn network.Conn) (bool, control.DisconnectReason) {
+	return !g.isBlocked(conn.RemotePeer()), 0
+}
+
+// BlockService manages the block list and cuts blocked peers off the P2P host
+type BlockService struct {
+	database    interfaces.DatabaseService
+	p2pService  *P2PService
+	pathManager *utils.PathManager
+	events      interfaces.EventPublisher
+}
+
+// NewBlockService creates a new block service
+func NewBlockService(database interfaces.DatabaseService, p2pService *P2PService, pathManager *utils.PathManager, events interfaces.EventPublisher) *BlockService {
+	return &BlockService{
+		database:    database,
+		p2pService:  p2pService,
+		pathManager: pathManager,
+		events:      events,
+	}
+}
+
+// GetBlockedPeers returns the block list
+func (bs *BlockService) GetBlockedPeers() ([]models.BlockedPeer, error) {
+	return bs.database.GetBlockedPeers()
+}
+
+// BlockPeer blocks a peer, closes its connections and, if asked, purges what we stored about it
+func (bs *BlockService) BlockPeer(request models.BlockPeerRequest) (*models.BlockedPeer, error) {
+	peerID, err := peer.Decode(request.PeerID)
+	if err != nil {
+		return nil, utils.NewValidationError("peer_id", fmt.Sprintf("invalid peer ID: %v", err))
+	}
+	if bs.p2pService != nil && peerID == bs.p2pService.host.ID() {
+		return nil, utils.NewValidationError("peer_id", "cannot block ourselves")
+	}
+
+	blocked := &models.BlockedPeer{
+		PeerID:   peerID.String(),
+		PeerName: request.PeerName,
+		Reason:   request.Reason,
+	}
+	if blocked.PeerName == "" {
+		blocked.PeerName = bs.knownPeerName(blocked.PeerID)
+	}
+	if err := bs.database.BlockPeer(blocked); err != nil {
+		return nil, err
+	}
+
+	if bs.p2pService != nil {
+		bs.p2pService.gater.block(peerID)
+		if err := bs.p2pService.host.Network().ClosePeer(peerID); err != nil {
+			log.Printf("⚠️ Failed to close connections to blocked peer %s: %v", peerID, err)
+		}
+	}
+
+	if request.Purge {
+		if err := bs.purgePeer(blocked.PeerID); err != nil {
+			return nil, err
+		}
+	}
+
+	publishEvent(bs.events, models.EventPeerBlocked, models.PeerEvent{PeerID: blocked.PeerID, Name: blocked.PeerName})
+	return blocked, nil
+}
+
+// UnblockPeer removes a peer from the block list so it can connect again
+func (bs *BlockService) UnblockPeer(peerIDStr string) error {
+	if err := bs.database.UnblockPeer(peerIDStr); err != nil {
+		return err
+	}
+
+	if peerID, err := peer.Decode(peerIDStr); err == nil && bs.p2pService != nil {
+		bs.p2pService.gater.unblock(peerID)
+	}
+
+	publishEvent(bs.events, models.EventPeerUnblocked, models.PeerEvent{PeerID: peerIDStr})
+	return nil
+}
+
+// purgePeer deletes the peer's downloads and its rows in the database
+func (bs *BlockService) purgePeer(peerID string) error {
+	if err := bs.database.PurgePeerData(peerID); err != nil {
+		return err
+	}
+
+	downloadPath := bs.pathManager.GetPeerDownloadPath(peerID)
+	if err := os.RemoveAll(downloadPath); err != nil {
+		return fmt.Errorf("failed to remove downloads of %s: %w", peerID, err)
+	}
+
+	log.Printf("🧹 Removed downloads of blocked peer %s", peerID)
+	return nil
+}
+
+// knownPeerName returns the name we last saw a peer under, if any
+func (bs *BlockService) knownPeerName(peerID string) string {
+	history, err := bs.database.GetConnectionHistory()
+	if err != nil {
+		return ""
+	}
+
+	for _, record := range history {
+		if record.PeerID == peerID {
+			return record.PeerName
+		}
+	}
+	return ""
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+const (
+	// outboxRetryInterval is how often friends with undelivered messages are reconnected to
+	outboxRetryInterval = 2 * time.Minute
+
+	// DefaultMessagePageSize is the number of messages returned per history page unless asked otherwise
+	DefaultMessagePageSize = 50
+
+	// MaxMessagePageSize bounds the number of messages returned per history page
+	MaxMessagePageSize = 200
+)
+
+func init() {
+	registerMessageHandlers(chatMessageHandlers)
+}
+
+// chatMessageHandlers returns the handlers for incoming direct messages and read receipts
+func chatMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypeDirectMessage, models.MessageTypeDirectMessageResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.DirectMessagePayload) (*models.DirectMessageAck, error) {
+				chatService := p.container.GetChatService()
+				if chatService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "chat service not available")
+				}
+				return chatService.handleDirectMessage(peerID, request)
+			}),
+
+		NewMessageHandler(models.MessageTypeMessageReceipt, models.MessageTypeMessageReceiptResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.MessageReceiptPayload) (*models.MessageReceiptAck, error) {
+				chatService := p.container.GetChatService()
+				if chatService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "chat service not available")
+				}
+				return chatService.handleMessageReceipt(peerID, request)
+			}),
+	}
+}
+
+// ChatService handles direct messages between friends. Outgoing messages wait in an outbox
+// until the friend acknowledges them, and every message is signed with the sender's node key.
+type ChatService struct {
+	database      interfaces.DatabaseService
+	p2pService    *P2PService
+	friendService *FriendService
+	events        interfaces.EventPublisher
+
+	// Per-peer locks so deliveries to one peer don't overlap and keep their order
+	deliveryLocks sync.Map
+}
+
+// NewChatService creates a new chat service
+func NewChatService(database interfaces.DatabaseService, p2pService *P2PService, friendService *FriendService, events interfaces.EventPublisher) *ChatService {
+	return &ChatService{
+		database:      database,
+		p2pService:    p2pService,
+		friendService: friendService,
+		events:        events,
+	}
+}
+
+// WatchOutbox delivers queued messages and read receipts whenever a peer connects,
+// and periodically reconnects to friends that have messages waiting
+func (cs *ChatService) WatchOutbox(events *EventBus) {
+	events.SubscribeEvents(models.EventPeerValidated, func(event models.Event) {
+		if peerEvent, ok := event.Data.(models.PeerEvent); ok {
+			cs.deliverOutbox(peerEvent.PeerID)
+		}
+	})
+
+	if cs.p2pService != nil {
+		go cs.startOutboxRetry()
+	}
+}
+
+// startOutboxRetry periodically reconnects to offline friends that have undelivered messages
+func (cs *ChatService) startOutboxRetry() {
+	ticker := time.NewTicker(outboxRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cs.p2pService.ctx.Done():
+			return
+		case <-ticker.C:
+			cs.retryOutbox()
+		}
+	}
+}
+
+// retryOutbox delivers the outbox to connected friends and reconnects to the others,
+// whose messages are then delivered by the peer validation event
+func (cs *ChatService) retryOutbox() {
+	pending, err := cs.database.GetPendingMessages("")
+	if err != nil {
+		log.Printf("⚠️ Failed to read message outbox: %v", err)
+		return
+	}
+
+	connected := make(map[string]bool)
+	for _, peerID := range cs.p2pService.GetConnectedPeers() {
+		connected[peerID.String()] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, message := range pending {
+		if seen[message.PeerID] {
+			continue
+		}
+		seen[message.PeerID] = true
+
+		if connected[message.PeerID] {
+			cs.deliverOutbox(message.PeerID)
+			continue
+		}
+
+		if cs.friendService == nil {
+			continue
+		}
+		log.Printf("📬 Reconnecting to %s to deliver queued messages", message.PeerID)
+		if err := cs.friendService.ReconnectToFriend(message.PeerID); err != nil {
+			log.Printf("📭 Messages for %s stay queued: %v", message.PeerID, err)
+		}
+	}
+}
+
+// SendMessage signs a message to a friend, queues it in the outbox and tries to deliver it right away
+func (cs *ChatService) SendMessage(peerID, body string) (*models.DirectMessage, error) {
+	if _, err := peer.Decode(peerID); err != nil {
+		return nil, utils.NewValidationError("peer_id", "invalid peer ID")
+	}
+
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, utils.NewValidationError("body", "message is empty")
+	}
+	if len(body) > models.MaxDirectMessageLength {
+		return nil, utils.NewValidationError("body", fmt.Sprintf("message is longer than %d bytes", models.MaxDirectMessageLength))
+	}
+
+	isFriend, err := cs.database.IsFriend(peerID)
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, utils.NewNotFoundError("friend", peerID)
+	}
+
+	messageID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := cs.signMessage(messageID, peerID, body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	message := &models.DirectMessage{
+		MessageID: messageID,
+		PeerID:    peerID,
+		Direction: models.MessageOutgoing,
+		Body:      body,
+		Status:    models.MessageStatusPending,
+		SentAt:    time.UnixMilli(payload.SentAt),
+		Signature: payload.Signature,
+	}
+	if _, err := cs.database.SaveMessage(message); err != nil {
+		return nil, err
+	}
+
+	log.Printf("✉️ Queued message %s for %s", messageID, peerID)
+	cs.deliverOutbox(peerID)
+
+	// Return the message with its delivery status after the first attempt
+	if stored, err := cs.database.GetMessage(messageID); err == nil && stored != nil {
+		return stored, nil
+	}
+	return message, nil
+}
+
+// GetConversations lists the conversations with friends, most recent first
+func (cs *ChatService) GetConversations() ([]models.Conversation, error) {
+	return cs.database.GetConversations()
+}
+
+// GetMessages returns a page of a conversation, oldest first. beforeID pages backwards from a message ID.
+func (cs *ChatService) GetMessages(peerID string, beforeID, limit int) (*models.MessagesResponse, error) {
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	}
+	if limit > MaxMessagePageSize {
+		limit = MaxMessagePageSize
+	}
+
+	// Fetch one extra message to find out whether there are older ones
+	messages, err := cs.database.GetMessages(peerID, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[1:]
+	}
+
+	return &models.MessagesResponse{
+		PeerID:   peerID,
+		Messages: messages,
+		Count:    len(messages),
+		HasMore:  hasMore,
+	}, nil
+}
+
+// MarkConversationRead marks a conversation's incoming messages as read and sends read receipts to the friend
+func (cs *ChatService) MarkConversationRead(peerID string) (int, error) {
+	messageIDs, err := cs.database.MarkConversationRead(peerID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if len(messageIDs) > 0 {
+		go cs.deliverOutbox(peerID)
+	}
+	return len(messageIDs), nil
+}
+
+// deliverOutbox sends a peer's queued messages in order, then any read receipts it hasn't received.
+// Delivery stops at the first failure; the rest is retried on the next connection.
+func (cs *ChatService) deliverOutbox(peerID string) {
+	if cs.p2pService == nil {
+		return
+	}
+
+	lock, _ := cs.deliveryLocks.LoadOrStore(peerID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	pid, err := peer.Decode(peerID)
+	if err != nil {
+		return
+	}
+
+	pending, err := cs.database.GetPendingMessages(peerID)
+	if err != nil {
+		log.Printf("⚠️ Failed to read message outbox for %s: %v", peerID, err)
+		return
+	}
+
+	var delivered []string
+	for _, message := range pending {
+		payload, err := cs.payloadFor(message)
+		if err != nil {
+			log.Printf("⚠️ Failed to prepare message %s: %v", message.MessageID, err)
+			continue
+		}
+
+		ack, err := requestPeer[models.DirectMessageAck](cs.p2pService, pid, models.MessageTypeDirectMessage, payload)
+		if err != nil {
+			log.Printf("📭 Message %s to %s not delivered, it stays queued: %v", message.MessageID, peerID, err)
+			break
+		}
+
+		deliveredAt := time.UnixMilli(ack.DeliveredAt)
+		if ack.DeliveredAt == 0 {
+			deliveredAt = time.Now()
+		}
+		if err := cs.database.MarkMessageDelivered(message.MessageID, deliveredAt); err != nil {
+			log.Printf("⚠️ Failed to record delivery of message %s: %v", message.MessageID, err)
+			continue
+		}
+		delivered = append(delivered, message.MessageID)
+	}
+
+	if len(delivered) > 0 {
+		log.Printf("📬 Delivered %d message(s) to %s", len(delivered), peerID)
+		publishEvent(cs.events, models.EventMessageDelivered, models.MessageEvent{PeerID: peerID, MessageIDs: delivered})
+	}
+
+	cs.sendReadReceipts(pid)
+}
+
+// sendReadReceipts tells a peer which of its messages we read
+func (cs *ChatService) sendReadReceipts(pid peer.ID) {
+	messageIDs, err := cs.database.GetPendingReadReceipts(pid.String())
+	if err != nil || len(messageIDs) == 0 {
+		return
+	}
+
+	_, err = requestPeer[models.MessageReceiptAck](cs.p2pService, pid, models.MessageTypeMessageReceipt,
+		models.MessageReceiptPayload{MessageIDs: messageIDs, ReadAt: time.Now().UnixMilli()})
+	if err != nil {
+		log.Printf("📭 Read receipts for %s not delivered, will retry: %v", pid, err)
+		return
+	}
+
+	if err := cs.database.ClearPendingReadReceipts(messageIDs); err != nil {
+		log.Printf("⚠️ Failed to record delivered read receipts for %s: %v", pid, err)
+	}
+}
+
+// handleDirectMessage verifies and stores a message from a friend and acknowledges its delivery
+func (cs *ChatService) handleDirectMessage(peerID peer.ID, payload *models.DirectMessagePayload) (*models.DirectMessageAck, error) {
+	if payload.From != peerID.String() {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "message sender %s does not match the connection", payload.From)
+	}
+	if cs.p2pService != nil && payload.To != cs.p2pService.host.ID().String() {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "message is addressed to %s", payload.To)
+	}
+	if payload.MessageID == "" || payload.Body == "" || len(payload.Body) > models.MaxDirectMessageLength {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "invalid message")
+	}
+
+	isFriend, err := cs.database.IsFriend(peerID.String())
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, wire.NewError(wire.ErrCodeForbidden, "only friends can send messages")
+	}
+
+	if err := verifyMessageSignature(payload); err != nil {
+		log.Printf("🚫 Rejected message %s from %s: %v", payload.MessageID, peerID, err)
+		return nil, wire.NewError(wire.ErrCodeForbidden, "invalid message signature")
+	}
+
+	now := time.Now()
+	message := &models.DirectMessage{
+		MessageID:   payload.MessageID,
+		PeerID:      peerID.String(),
+		Direction:   models.MessageIncoming,
+		Body:        payload.Body,
+		Status:      models.MessageStatusDelivered,
+		SentAt:      time.UnixMilli(payload.SentAt),
+		DeliveredAt: &now,
+		Signature:   payload.Signature,
+	}
+
+	stored, err := cs.database.SaveMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// A retry of a message we already have is acknowledged again without a second notification
+	if stored {
+		log.Printf("💬 Received message %s from %s", payload.MessageID, peerID)
+		publishEvent(cs.events, models.EventMessageReceived, models.MessageEvent{
+			PeerID:     peerID.String(),
+			MessageIDs: []string{payload.MessageID},
+			Body:       payload.Body,
+		})
+	}
+
+	return &models.DirectMessageAck{MessageID: payload.MessageID, DeliveredAt: now.UnixMilli()}, nil
+}
+
+// handleMessageReceipt records that a friend read our messages
+func (cs *ChatService) handleMessageReceipt(peerID peer.ID, payload *models.MessageReceiptPayload) (*models.MessageReceiptAck, error) {
+	readAt := time.UnixMilli(payload.ReadAt)
+	if payload.ReadAt == 0 {
+		readAt = time.Now()
+	}
+
+	updated, err := cs.database.MarkMessagesRead(peerID.String(), payload.MessageIDs, readAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(updated) > 0 {
+		log.Printf("👀 %s read %d message(s)", peerID, len(updated))
+		publishEvent(cs.events, models.EventMessageRead, models.MessageEvent{PeerID: peerID.String(), MessageIDs: updated})
+	}
+
+	return &models.MessageReceiptAck{Success: true}, nil
+}
+
+// signMessage builds the signed wire payload for a new message
+func (cs *ChatService) signMessage(messageID, to, body string, sentAt time.Time) (*models.DirectMessagePayload, error) {
+	if cs.p2pService == nil {
+		return nil, fmt.Errorf("P2P service not available")
+	}
+
+	payload := &models.DirectMessagePayload{
+		MessageID: messageID,
+		From:      cs.p2pService.host.ID().String(),
+		To:        to,
+		Body:      body,
+		SentAt:    sentAt.UnixMilli(),
+	}
+
+	var err error
+	payload.PublicKey, payload.Signature, err = signWithNodeKey(runningNodeKey(cs.p2pService, cs.database), messageSigningBytes(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	return payload, nil
+}
+
+// payloadFor rebuilds the wire payload of a stored outgoing message, reusing its original signature
+func (cs *ChatService) payloadFor(message models.DirectMessage) (*models.DirectMessagePayload, error) {
+	publicKey, err := nodePublicKey(runningNodeKey(cs.p2pService, cs.database))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DirectMessagePayload{
+		MessageID: message.MessageID,
+		From:      cs.p2pService.host.ID().String(),
+		To:        message.PeerID,
+		Body:      message.Body,
+		SentAt:    message.SentAt.UnixMilli(),
+		PublicKey: publicKey,
+		Signature: message.Signature,
+	}, nil
+}
+
+// verifyMessageSignature checks that a message was signed by the key its sender's peer ID is derived from
+func verifyMessageSignature(payload *models.DirectMessagePayload) error {
+	return verifyPeerSignature(payload.From, payload.PublicKey, messageSigningBytes(payload), payload.Signature)
+}
+
+// messageSigningBytes returns the bytes a message signature covers
+func messageSigningBytes(payload *models.DirectMessagePayload) []byte {
+	return signingBytes("old-school/direct-message/v1",
+		payload.MessageID, payload.From, payload.To, strconv.FormatInt(payload.SentAt, 10), payload.Body)
+}
+
+// newRandomID returns a random hex ID for messages and posts
+func newRandomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+func init() {
+	registerMessageHandlers(commentMessageHandlers)
+}
+
+// commentMessageHandlers returns the handlers for comments sent to us as the content owner
+func commentMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypePostComment, models.MessageTypePostCommentResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.Comment) (*models.CommentAck, error) {
+				commentService := p.container.GetCommentService()
+				if commentService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "comment service not available")
+				}
+				return commentService.handlePostComment(peerID, request)
+			}),
+
+		NewMessageHandler(models.MessageTypeDeleteComment, models.MessageTypeDeleteCommentResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.DeleteCommentMessage) (*models.DeleteCommentAck, error) {
+				commentService := p.container.GetCommentService()
+				if commentService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "comment service not available")
+				}
+				return commentService.handleDeleteComment(peerID, request)
+			}),
+	}
+}
+
+// CommentService handles comments and reactions on docs and media files. Comments are stored on the
+// node that owns the file, keyed by the file's path and hash, and served together with the doc or gallery.
+type CommentService struct {
+	database         interfaces.DatabaseService
+	p2pService       *P2PService
+	directoryService DirectoryServiceInterface
+	pathManager      *utils.PathManager
+	events           interfaces.EventPublisher
+}
+
+// NewCommentService creates a new comment service
+func NewCommentService(database interfaces.DatabaseService, p2pService *P2PService, directoryService DirectoryServiceInterface, pathManager *utils.PathManager, events interfaces.EventPublisher) *CommentService {
+	return &CommentService{
+		database:         database,
+		p2pService:       p2pService,
+		directoryService: directoryService,
+		pathManager:      pathManager,
+		events:           events,
+	}
+}
+
+// AddComment signs a comment or reaction on a file and sends it to the node that owns the file
+func (cs *CommentService) AddComment(ownerID string, request models.AddCommentRequest) (*models.Comment, error) {
+	if _, err := peer.Decode(ownerID); err != nil {
+		return nil, utils.NewValidationError("peer_id", "invalid peer ID")
+	}
+	if cs.p2pService == nil {
+		return nil, fmt.Errorf("P2P service not available")
+	}
+
+	if request.Kind == "" {
+		request.Kind = models.CommentKindComment
+	}
+	request.Body = strings.TrimSpace(request.Body)
+	if err := validateComment(request.Kind, request.Body); err != nil {
+		return nil, err
+	}
+	if request.FilePath == "" || request.FileHash == "" {
+		return nil, utils.NewValidationError("file_path", "file path and hash are required")
+	}
+
+	commentID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	authorName, _ := cs.database.GetSetting("name")
+	comment := &models.Comment{
+		CommentID:  commentID,
+		OwnerID:    ownerID,
+		FilePath:   request.FilePath,
+		FileHash:   request.FileHash,
+		AuthorID:   cs.p2pService.host.ID().String(),
+		AuthorName: authorName,
+		Kind:       request.Kind,
+		Body:       request.Body,
+		CreatedAt:  time.UnixMilli(time.Now().UnixMilli()),
+	}
+
+	comment.PublicKey, comment.Signature, err = signWithNodeKey(runningNodeKey(cs.p2pService, cs.database), commentSigningBytes(comment))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign comment: %w", err)
+	}
+
+	// Comments on our own content are stored directly
+	if ownerID == comment.AuthorID {
+		if _, err := cs.storeComment(comment); err != nil {
+			return nil, err
+		}
+		return comment, nil
+	}
+
+	ack, err := requestPeerByID[models.CommentAck](cs.p2pService, ownerID, models.MessageTypePostComment, comment)
+	if err != nil {
+		var wireErr *wire.Error
+		if errors.As(err, &wireErr) && wireErr.Code == wire.ErrCodeNotFound {
+			return nil, utils.NewNotFoundError("file", comment.FilePath+"@"+comment.FileHash)
+		}
+		return nil, fmt.Errorf("failed to send comment to %s: %w", ownerID, err)
+	}
+	if !ack.Stored {
+		return nil, utils.NewValidationError("body", "this reaction was already added")
+	}
+
+	log.Printf("💬 Sent %s %s on %s to %s", comment.Kind, commentID, comment.FilePath, ownerID)
+	return comment, nil
+}
+
+// DeleteOwnComment asks the content owner to remove a comment we wrote
+func (cs *CommentService) DeleteOwnComment(ownerID, commentID string) error {
+	if cs.p2pService == nil {
+		return fmt.Errorf("P2P service not available")
+	}
+
+	if ownerID == cs.p2pService.host.ID().String() {
+		return cs.DeleteComment(commentID)
+	}
+
+	_, err := requestPeerByID[models.DeleteCommentAck](cs.p2pService, ownerID, models.MessageTypeDeleteComment,
+		models.DeleteCommentMessage{CommentID: commentID})
+	if err != nil {
+		var wireErr *wire.Error
+		if errors.As(err, &wireErr) && wireErr.Code == wire.ErrCodeNotFound {
+			return utils.NewNotFoundError("comment", commentID)
+		}
+		return fmt.Errorf("failed to delete comment on %s: %w", ownerID, err)
+	}
+	return nil
+}
+
+// GetComments lists every comment on one of our files, hidden ones included, for moderation
+func (cs *CommentService) GetComments(filePath string) ([]models.Comment, error) {
+	return cs.database.GetComments(filePath, "", true)
+}
+
+// HideComment hides or shows a comment on our content
+func (cs *CommentService) HideComment(commentID string, hidden bool) error {
+	if err := cs.database.SetCommentHidden(commentID, hidden); err != nil {
+		return err
+	}
+
+	log.Printf("🙈 Comment %s hidden: %t", commentID, hidden)
+	return nil
+}
+
+// DeleteComment removes a comment from our content
+func (cs *CommentService) DeleteComment(commentID string) error {
+	comment, err := cs.database.GetComment(commentID)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		return utils.NewNotFoundError("comment", commentID)
+	}
+
+	if err := cs.database.DeleteComment(commentID); err != nil {
+		return err
+	}
+
+	log.Printf("🗑️ Deleted comment %s on %s", commentID, comment.FilePath)
+	publishEvent(cs.events, models.EventCommentRemoved, models.CommentEvent{
+		CommentID: commentID,
+		FilePath:  comment.FilePath,
+		AuthorID:  comment.AuthorID,
+		Kind:      comment.Kind,
+	})
+	return nil
+}
+
+// AttachDocComments adds the visible comments on one of our docs before it is served to a peer
+func (cs *CommentService) AttachDocComments(doc *models.Doc) {
+	if doc == nil {
+		return
+	}
+
+	filePath, err := cs.pathManager.GetRelativePath(filepath.Join(cs.directoryService.GetDocsDirectory(), doc.Filename))
+	if err != nil {
+		return
+	}
+	doc.Comments = cs.contentComments(filePath)
+}
+
+// AttachGalleryComments adds the visible comments on the files of one of our galleries before it is served to a peer
+func (cs *CommentService) AttachGalleryComments(gallery *models.MediaGallery) {
+	if gallery == nil {
+		return
+	}
+
+	for _, fileName := range gallery.Files {
+		absolutePath, err := cs.directoryService.GetMediaFilePath(gallery.MediaType, gallery.Name, fileName)
+		if err != nil {
+			continue
+		}
+		filePath, err := cs.pathManager.GetRelativePath(absolutePath)
+		if err != nil {
+			continue
+		}
+
+		if comments := cs.contentComments(filePath); comments != nil {
+			if gallery.Comments == nil {
+				gallery.Comments = make(map[string]*models.ContentComments)
+			}
+			gallery.Comments[fileName] = comments
+		}
+	}
+}
+
+// VerifyContentComments drops comments a peer served for its content that aren't signed by their author
+// or don't belong to the file, so a node can't put words in someone else's mouth
+func VerifyContentComments(ownerID string, contentComments *models.ContentComments) {
+	if contentComments == nil {
+		return
+	}
+
+	verified := []models.Comment{}
+	for _, comment := range contentComments.Comments {
+		if comment.OwnerID != ownerID || comment.FilePath != contentComments.FilePath || comment.FileHash != contentComments.FileHash {
+			continue
+		}
+		if err := verifyPeerSignature(comment.AuthorID, comment.PublicKey, commentSigningBytes(&comment), comment.Signature); err != nil {
+			log.Printf("⚠️ Dropping comment %s from %s served by %s: %v", comment.CommentID, comment.AuthorID, ownerID, err)
+			continue
+		}
+		verified = append(verified, comment)
+	}
+
+	contentComments.Comments = verified
+	contentComments.Reactions = countReactions(verified)
+}
+
+// contentComments returns the visible comments on the current version of one of our files,
+// or nil if the file isn't in the files table yet
+func (cs *CommentService) contentComments(filePath string) *models.ContentComments {
+	if cs.p2pService == nil {
+		return nil
+	}
+
+	record, err := cs.database.GetFileRecord(filePath, cs.p2pService.host.ID().String())
+	if err != nil || record == nil {
+		return nil
+	}
+
+	comments, err := cs.database.GetComments(filePath, record.Hash, false)
+	if err != nil {
+		log.Printf("⚠️ Failed to load comments on %s: %v", filePath, err)
+		return nil
+	}
+
+	return &models.ContentComments{
+		FilePath:  filePath,
+		FileHash:  record.Hash,
+		Comments:  comments,
+		Reactions: countReactions(comments),
+	}
+}
+
+// handlePostComment stores a friend's comment on one of our files
+func (cs *CommentService) handlePostComment(peerID peer.ID, comment *models.Comment) (*models.CommentAck, error) {
+	if comment.AuthorID != peerID.String() {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "comment author %s does not match the connection", comment.AuthorID)
+	}
+	if cs.p2pService != nil && comment.OwnerID != cs.p2pService.host.ID().String() {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "comment is for content owned by %s", comment.OwnerID)
+	}
+	if comment.CommentID == "" || validateComment(comment.Kind, comment.Body) != nil {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "invalid comment")
+	}
+
+	isFriend, err := cs.database.IsFriend(peerID.String())
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, wire.NewError(wire.ErrCodeForbidden, "only friends can comment")
+	}
+
+	// Content the friend may not see is reported as missing
+	if !cs.p2pService.contentAccess(peerID).CanRead(comment.FilePath) {
+		return nil, wire.NewError(wire.ErrCodeNotFound, "file %s not found", comment.FilePath)
+	}
+
+	if err := verifyPeerSignature(comment.AuthorID, comment.PublicKey, commentSigningBytes(comment), comment.Signature); err != nil {
+		log.Printf("🚫 Rejected comment %s from %s: %v", comment.CommentID, peerID, err)
+		return nil, wire.NewError(wire.ErrCodeForbidden, "invalid comment signature")
+	}
+
+	stored, err := cs.storeComment(comment)
+	if err != nil {
+		var notFoundErr utils.NotFoundError
+		var validationErr utils.ValidationError
+		switch {
+		case errors.As(err, &notFoundErr):
+			return nil, wire.NewError(wire.ErrCodeNotFound, "%v", err)
+		case errors.As(err, &validationErr):
+			return nil, wire.NewError(wire.ErrCodeForbidden, "%v", err)
+		}
+		return nil, err
+	}
+	return &models.CommentAck{CommentID: comment.CommentID, Stored: stored}, nil
+}
+
+// storeComment checks that a comment refers to the current version of one of our files and stores it.
+// Comments we deleted are refused, so their author can't bring them back by sending them again.
+func (cs *CommentService) storeComment(comment *models.Comment) (bool, error) {
+	deleted, err := cs.database.IsCommentDeleted(comment.CommentID)
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		return false, utils.NewValidationError("comment_id", "comment "+comment.CommentID+" was deleted")
+	}
+
+	record, err := cs.database.GetFileRecord(comment.FilePath, comment.OwnerID)
+	if err != nil {
+		return false, err
+	}
+	if record == nil || record.Hash != comment.FileHash {
+		return false, utils.NewNotFoundError("file", comment.FilePath+"@"+comment.FileHash)
+	}
+
+	comment.Hidden = false
+	stored, err := cs.database.SaveComment(comment)
+	if err != nil {
+		return false, err
+	}
+
+	if stored {
+		log.Printf("💬 Stored %s %s from %s on %s", comment.Kind, comment.CommentID, comment.AuthorID, comment.FilePath)
+		publishEvent(cs.events, models.EventCommentReceived, models.CommentEvent{
+			CommentID: comment.CommentID,
+			FilePath:  comment.FilePath,
+			AuthorID:  comment.AuthorID,
+			Kind:      comment.Kind,
+		})
+	}
+	return stored, nil
+}
+
+// handleDeleteComment removes a comment at the request of its author
+func (cs *CommentService) handleDeleteComment(peerID peer.ID, request *models.DeleteCommentMessage) (*models.DeleteCommentAck, error) {
+	comment, err := cs.database.GetComment(request.CommentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment == nil {
+		return nil, wire.NewError(wire.ErrCodeNotFound, "comment %s not found", request.CommentID)
+	}
+	if comment.AuthorID != peerID.String() {
+		return nil, wire.NewError(wire.ErrCodeForbidden, "only the author can delete a comment")
+	}
+
+	if err := cs.DeleteComment(request.CommentID); err != nil {
+		return nil, err
+	}
+	return &models.DeleteCommentAck{Success: true}, nil
+}
+
+// validateComment checks a comment's kind and body
+func validateComment(kind, body string) error {
+	switch kind {
+	case models.CommentKindComment:
+		if body == "" {
+			return utils.NewValidationError("body", "comment is empty")
+		}
+		if len(body) > models.MaxCommentLength {
+			return utils.NewValidationError("body", fmt.Sprintf("comment is longer than %d bytes", models.MaxCommentLength))
+		}
+	case models.CommentKindReaction:
+		if body == "" || len(body) > models.MaxReactionLength || !utf8.ValidString(body) || strings.ContainsAny(body, " \t\n") {
+			return utils.NewValidationError("body", "a reaction is a single emoji")
+		}
+	default:
+		return utils.NewValidationError("kind", fmt.Sprintf("unknown comment kind %q", kind))
+	}
+	return nil
+}
+
+// countReactions counts the reactions among comments by emoji
+func countReactions(comments []models.Comment) map[string]int {
+	reactions := make(map[string]int)
+	for _, comment := range comments {
+		if comment.Kind == models.CommentKindReaction {
+			reactions[comment.Body]++
+		}
+	}
+	return reactions
+}
+
+// commentSigningBytes returns the bytes a comment signature covers
+func commentSigningBytes(comment *models.Comment) []byte {
+	return signingBytes("old-school/comment/v1",
+		comment.CommentID, comment.OwnerID, comment.FilePath, comment.FileHash, comment.AuthorID, comment.AuthorName,
+		comment.Kind, comment.Body, strconv.FormatInt(comment.CreatedAt.UnixMilli(), 10))
+}
@@ -33,6 +33,7 @@ type DirectoryServiceInterface interface {
 	// Unified media gallery methods
 	GetMediaGalleries(mediaType models.MediaType) ([]models.MediaGallery, error)
 	GetMediaGalleryFiles(mediaType models.MediaType, galleryName string) ([]string, error)
+	GetMediaFilePath(mediaType models.MediaType, galleryName, fileName string) (string, error)
 	GetPeerMediaGalleries(peerID string, mediaType models.MediaType) ([]models.MediaGallery, error)
 	GetPeerMediaGalleryFiles(peerID, galleryName string, mediaType models.MediaType) ([]string, error)
 	GetMediaGalleryNames(mediaType models.MediaType) ([]string, error)
@@ -47,9 +48,9 @@ type DirectoryService struct {
 }
 
 // NewDirectoryService creates a new directory service
-func NewDirectoryService() *DirectoryService {
+func NewDirectoryService(pathManager *utils.PathManager) *DirectoryService {
 	return &DirectoryService{
-		pathManager:   utils.DefaultPathManager,
+		pathManager:   pathManager,
 		pathValidator: utils.DefaultPathValidator,
 	}
 }
@@ -509,6 +510,77 @@ func (d *DirectoryService) GetMediaGalleryFiles(mediaType models.MediaType, gall
 	return mediaFiles, nil
 }
 
+// GetMediaFilePath resolves the on-disk path of a file that belongs to a media gallery.
+// Files of a root gallery may live in any of its subdirectories.
+func (d *DirectoryService) GetMediaFilePath(mediaType models.MediaType, galleryName, fileName string) (string, error) {
+	if err := d.pathValidator.ValidateFilename(fileName); err != nil {
+		return "", fmt.Errorf("invalid file name: %s - %w", fileName, err)
+	}
+
+	var mediaDir string
+	var rootGalleryName string
+
+	switch mediaType {
+	case models.MediaTypeImage:
+		mediaDir = d.pathManager.GetImagesPath()
+		rootGalleryName = "root_images"
+	case models.MediaTypeAudio:
+		mediaDir = d.pathManager.GetAudioPath()
+		rootGalleryName = "root_audio"
+	case models.MediaTypeVideo:
+		mediaDir = d.pathManager.GetVideoPath()
+		rootGalleryName = "root_video"
+	case models.MediaTypeDocs:
+		mediaDir = d.pathManager.GetDocsPath()
+		rootGalleryName = "root_docs"
+	default:
+		return "", fmt.Errorf("unsupported media type: %s", mediaType)
+	}
+
+	// Only files listed in the gallery can be resolved
+	files, err := d.GetMediaGalleryFiles(mediaType, galleryName)
+	if err != nil {
+		return "", err
+	}
+
+	found := false
+	for _, file := range files {
+		if file == fileName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("%s file %s not found in gallery %s", mediaType, fileName, galleryName)
+	}
+
+	if galleryName != rootGalleryName {
+		return filepath.Join(mediaDir, galleryName, fileName), nil
+	}
+
+	rootFilePath := filepath.Join(mediaDir, fileName)
+	if _, err := os.Stat(rootFilePath); err == nil {
+		return rootFilePath, nil
+	}
+
+	entries, err := os.ReadDir(mediaDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s directory: %w", mediaType, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		subFilePath := filepath.Join(mediaDir, entry.Name(), fileName)
+		if _, err := os.Stat(subFilePath); err == nil {
+			return subFilePath, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s file %s not found", mediaType, fileName)
+}
+
 // GetPeerMediaGalleries returns a list of all downloaded media galleries for a specific peer
 func (d *DirectoryService) GetPeerMediaGalleries(peerID string, mediaType models.MediaType) ([]models.MediaGallery, error) {
 	var peerMediaDir string
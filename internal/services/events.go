@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+)
+
+const (
+	// EventTypeAll subscribes to every event type
+	EventTypeAll = "*"
+
+	// eventQueueSize is how many events a slow subscriber may fall behind before events are dropped
+	eventQueueSize = 256
+
+	// eventHistorySize is how many recent events are kept for replay to reconnecting subscribers
+	eventHistorySize = 512
+)
+
+// eventSubscription delivers events to one handler, in publish order, from its own goroutine
+type eventSubscription struct {
+	eventType string
+	handler   func(event models.Event)
+	queue     chan models.Event
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// EventBus is an in-process publish/subscribe bus that lets services react to each other without direct calls
+type EventBus struct {
+	subscriptions map[*eventSubscription]struct{}
+	history       []models.Event
+	mutex         sync.RWMutex
+	lastEventID   uint64
+	closed        bool
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscriptions: make(map[*eventSubscription]struct{}),
+		// IDs continue from the start time so IDs from before a restart are never mistaken for current ones
+		lastEventID: uint64(time.Now().UnixMilli()),
+	}
+}
+
+// Publish sends an event to every subscriber of its type
+func (b *EventBus) Publish(eventType string, data interface{}) error {
+	if b == nil {
+		return fmt.Errorf("event bus not available")
+	}
+
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return fmt.Errorf("event bus is closed")
+	}
+
+	b.lastEventID++
+	event := models.Event{
+		ID:        b.lastEventID,
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	// Enqueue while holding the lock so every subscriber sees events in ID order
+	for subscription := range b.subscriptions {
+		if subscription.eventType != EventTypeAll && subscription.eventType != eventType {
+			continue
+		}
+
+		select {
+		case subscription.queue <- event:
+		default:
+			log.Printf("⚠️ Event subscriber for %s is falling behind, dropping %s event %d", subscription.eventType, eventType, event.ID)
+		}
+	}
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// Subscribe registers a handler for the data of every event of a type
+func (b *EventBus) Subscribe(eventType string, handler func(data interface{})) error {
+	if b.SubscribeEvents(eventType, func(event models.Event) { handler(event.Data) }) == nil {
+		return fmt.Errorf("event bus is closed")
+	}
+	return nil
+}
+
+// SubscribeEvents registers a handler for every event of a type, or of all types with EventTypeAll.
+// It returns a function that removes the subscription, or nil if the bus is closed.
+func (b *EventBus) SubscribeEvents(eventType string, handler func(event models.Event)) func() {
+	unsubscribe, _ := b.SubscribeEventsAfter(eventType, b.LastEventID(), handler)
+	return unsubscribe
+}
+
+// SubscribeEventsAfter is SubscribeEvents that first replays the retained events published after lastEventID.
+// The returned bool is false when events after lastEventID were already dropped from the history,
+// in which case the subscriber has missed changes and should reload its state.
+func (b *EventBus) SubscribeEventsAfter(eventType string, lastEventID uint64, handler func(event models.Event)) (func(), bool) {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil, false
+	}
+
+	// Replay and registration happen under the same lock so no event is missed or delivered twice
+	var replay []models.Event
+	complete := lastEventID == b.lastEventID ||
+		(lastEventID < b.lastEventID && len(b.history) > 0 && b.history[0].ID <= lastEventID+1)
+	for _, event := range b.history {
+		if event.ID > lastEventID && (eventType == EventTypeAll || event.Type == eventType) {
+			replay = append(replay, event)
+		}
+	}
+
+	subscription := &eventSubscription{
+		eventType: eventType,
+		handler:   handler,
+		queue:     make(chan models.Event, eventQueueSize+len(replay)),
+		done:      make(chan struct{}),
+	}
+	for _, event := range replay {
+		subscription.queue <- event
+	}
+
+	b.subscriptions[subscription] = struct{}{}
+	b.mutex.Unlock()
+
+	go subscription.run()
+
+	return func() {
+		b.mutex.Lock()
+		delete(b.subscriptions, subscription)
+		b.mutex.Unlock()
+		subscription.stop()
+	}, complete
+}
+
+// LastEventID returns the ID of the most recently published event
+func (b *EventBus) LastEventID() uint64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.lastEventID
+}
+
+// Close stops delivery to all subscribers
+func (b *EventBus) Close() {
+	b.mutex.Lock()
+	b.closed = true
+	subscriptions := b.subscriptions
+	b.subscriptions = make(map[*eventSubscription]struct{})
+	b.mutex.Unlock()
+
+	for subscription := range subscriptions {
+		subscription.stop()
+	}
+}
+
+// run delivers queued events until the subscription is stopped
+func (s *eventSubscription) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-s.queue:
+			s.deliver(event)
+		}
+	}
+}
+
+// deliver calls the handler, keeping a panicking handler from taking down the subscription
+func (s *eventSubscription) deliver(event models.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler panic recovered for %s: %v", event.Type, r)
+		}
+	}()
+	s.handler(event)
+}
+
+// stop ends delivery, events still queued are discarded
+func (s *eventSubscription) stop() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// publishEvent publishes to the bus if one is set, logging instead of failing the caller
+func publishEvent(events interfaces.EventPublisher, eventType string, data interface{}) {
+	if events == nil {
+		return
+	}
+	if err := events.Publish(eventType, data); err != nil {
+		log.Printf("⚠️ Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// Ensure EventBus implements the event interfaces
+var _ interfaces.EventPublisher = (*EventBus)(nil)
+var _ interfaces.EventSubscriber = (*EventBus)(nil)
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"old-school/internal/interfaces"
+	"old-school/internal/models"
 	"old-school/internal/utils"
 )
 
@@ -17,15 +18,17 @@ type FileScannerService struct {
 	hashService   *utils.HashService
 	pathManager   *utils.PathManager
 	getPeerIDFunc func() string
+	events        interfaces.EventPublisher
 }
 
 // NewFileScannerService creates a new file scanner service
-func NewFileScannerService(filesRepo interfaces.FilesRepository) *FileScannerService {
+func NewFileScannerService(filesRepo interfaces.FilesRepository, pathManager *utils.PathManager, events interfaces.EventPublisher) *FileScannerService {
 	return &FileScannerService{
 		filesRepo:     filesRepo,
 		hashService:   utils.DefaultHashService,
-		pathManager:   utils.DefaultPathManager,
+		pathManager:   pathManager,
 		getPeerIDFunc: func() string { return "unknown" }, // Default placeholder
+		events:        events,
 	}
 }
 
@@ -34,7 +37,7 @@ func (fs *FileScannerService) SetPeerIDFunc(fn func() string) {
 	fs.getPeerIDFunc = fn
 }
 
-// ScanFiles scans the space184/docs and space184/images directories and updates the files table
+// ScanFiles scans the space184 docs, images, audio and video directories and updates the files table
 func (fs *FileScannerService) ScanFiles() error {
 	log.Printf("🔍 Starting file scan...")
 
@@ -42,6 +45,8 @@ func (fs *FileScannerService) ScanFiles() error {
 	scanDirs := []string{
 		fs.pathManager.GetDocsPath(),
 		fs.pathManager.GetImagesPath(),
+		fs.pathManager.GetAudioPath(),
+		fs.pathManager.GetVideoPath(),
 	}
 
 	for _, dir := range scanDirs {
@@ -122,10 +127,19 @@ func (fs *FileScannerService) processFile(path string, info os.FileInfo, err err
 		return nil
 	}
 
+	fileEvent := models.FileEvent{
+		FilePath: relPath,
+		Hash:     hash,
+		Size:     info.Size(),
+		Type:     fileType,
+	}
+
 	if exists {
 		log.Printf("📝 Updated file: %s", relPath)
+		publishEvent(fs.events, models.EventFileChanged, fileEvent)
 	} else {
 		log.Printf("📄 Added file: %s (%s)", relPath, fileType)
+		publishEvent(fs.events, models.EventFileAdded, fileEvent)
 	}
 
 	return nil
@@ -150,6 +164,7 @@ func (fs *FileScannerService) CleanupDeletedFiles() error {
 				continue
 			}
 			log.Printf("🗑️ Removed deleted file: %s", file.FilePath)
+			publishEvent(fs.events, models.EventFileRemoved, models.FileEvent{FilePath: file.FilePath, Hash: file.Hash, Type: file.Type})
 			deletedCount++
 		}
 	}
@@ -3,8 +3,7 @@ package services
 import (
 	"fmt"
 	"log"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"old-school/internal/interfaces"
@@ -15,17 +14,93 @@ import (
 type FriendService struct {
 	database   interfaces.DatabaseService
 	p2pService *P2PService
+	events     interfaces.EventPublisher
+
+	// Reconnect state of each friend, kept by the reconnect supervisor
+	reconnects     map[string]*models.ReconnectStatus
+	reconnectMutex sync.Mutex
 }
 
 // NewFriendService creates a new friend service
-func NewFriendService(database interfaces.DatabaseService, p2pService *P2PService) *FriendService {
+func NewFriendService(database interfaces.DatabaseService, p2pService *P2PService, events interfaces.EventPublisher) *FriendService {
 	return &FriendService{
 		database:   database,
 		p2pService: p2pService,
+		events:     events,
+		reconnects: make(map[string]*models.ReconnectStatus),
 	}
 }
 
-// AttemptReconnectToAllFriends attempts to reconnect to all friends from the database
+// WatchFriendPresence turns peer validation and disconnect events into friend online and offline events
+func (fs *FriendService) WatchFriendPresence(events *EventBus) {
+	events.SubscribeEvents(models.EventPeerValidated, func(event models.Event) {
+		if peerEvent, ok := event.Data.(models.PeerEvent); ok {
+			fs.updateFriendPresence(peerEvent, true)
+			// Friend requests and answers the peer missed while offline
+			fs.deliverFriendRequest(peerEvent.PeerID)
+		}
+	})
+
+	events.SubscribeEvents(models.EventPeerDisconnected, func(event models.Event) {
+		if peerEvent, ok := event.Data.(models.PeerEvent); ok {
+			fs.updateFriendPresence(peerEvent, false)
+		}
+	})
+}
+
+// updateFriendPresence records a friend's connection and publishes its online status
+func (fs *FriendService) updateFriendPresence(peerEvent models.PeerEvent, isOnline bool) {
+	isFriend, err := fs.database.IsFriend(peerEvent.PeerID)
+	if err != nil || !isFriend {
+		return
+	}
+
+	if err := fs.database.UpdateFriendStatus(peerEvent.PeerID, isOnline); err != nil {
+		log.Printf("⚠️ Failed to update friend status for %s: %v", peerEvent.PeerID, err)
+	}
+
+	if isOnline {
+		fs.markReconnected(peerEvent.PeerID, "")
+	} else {
+		// A friend that just left is tried again soon, with a fresh backoff
+		fs.scheduleReconnect(peerEvent.PeerID, reconnectAfterDisconnect)
+	}
+
+	eventType := models.EventFriendOffline
+	if isOnline {
+		eventType = models.EventFriendOnline
+	}
+	publishEvent(fs.events, eventType, models.FriendEvent{PeerID: peerEvent.PeerID, PeerName: peerEvent.Name})
+}
+
+// AddFriend adds a peer to the friends list
+func (fs *FriendService) AddFriend(peerID, peerName string) error {
+	if err := fs.database.AddFriend(peerID, peerName); err != nil {
+		return err
+	}
+
+	log.Printf("👥 Added friend %s (%s)", peerName, peerID)
+	publishEvent(fs.events, models.EventFriendAdded, models.FriendEvent{PeerID: peerID, PeerName: peerName})
+	return nil
+}
+
+// RemoveFriend removes a peer from the friends list and tells the peer the friendship ended
+func (fs *FriendService) RemoveFriend(peerID string) error {
+	if err := fs.database.RemoveFriend(peerID); err != nil {
+		return err
+	}
+
+	if err := fs.notifyUnfriend(peerID); err != nil {
+		log.Printf("⚠️ Failed to notify %s about the unfriend: %v", peerID, err)
+	}
+
+	log.Printf("👥 Removed friend %s", peerID)
+	publishEvent(fs.events, models.EventFriendRemoved, models.FriendEvent{PeerID: peerID})
+	return nil
+}
+
+// AttemptReconnectToAllFriends attempts to reconnect to all friends from the database.
+// Friends it can't reach are left to the reconnect supervisor.
 func (fs *FriendService) AttemptReconnectToAllFriends() {
 	if fs.database == nil || fs.p2pService == nil {
 		log.Printf("⚠️ Warning: Database or P2P service not available for friend reconnection")
@@ -41,6 +116,12 @@ func (fs *FriendService) AttemptReconnectToAllFriends() {
 		return
 	}
 
+	fs.reconnectToFriends(friends)
+}
+
+// reconnectToFriends dials each offline friend whose reconnect is due, skipping the friends
+// the supervisor is already dialing or backing off from
+func (fs *FriendService) reconnectToFriends(friends []models.Friend) {
 	if len(friends) == 0 {
 		log.Printf("📭 No friends found to reconnect to")
 		return
@@ -48,103 +129,27 @@ func (fs *FriendService) AttemptReconnectToAllFriends() {
 
 	log.Printf("👥 Found %d friend(s) to reconnect to", len(friends))
 
-	// Get connection history to find last known addresses
-	connectionHistory, err := fs.database.GetConnectionHistory()
-	if err != nil {
-		log.Printf("⚠️ Warning: Failed to get connection history: %v", err)
-		return
-	}
-
-	// Create a map for quick lookup of connection info
-	lastConnectionMap := make(map[string]*models.ConnectionRecord)
-	for i := range connectionHistory {
-		record := &connectionHistory[i]
-		if record.IsValidated {
-			// Keep the most recent connection for each peer
-			if existing, exists := lastConnectionMap[record.PeerID]; !exists || record.LastConnected.After(existing.LastConnected) {
-				lastConnectionMap[record.PeerID] = record
-			}
-		}
-	}
-
-	// Attempt to reconnect to each friend
 	successCount := 0
+	skippedCount := 0
 	for _, friend := range friends {
-		if lastConnection, exists := lastConnectionMap[friend.PeerID]; exists {
-			success := fs.attemptFriendReconnection(friend, lastConnection)
-			if success {
+		if fs.reconnectDue(friend.PeerID, time.Now()) {
+			if err := fs.reconnectFriend(friend); err == nil {
 				successCount++
 			}
-			// Add a small delay between connection attempts to avoid overwhelming the network
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			log.Printf("⚠️ No connection history found for friend %s (%s)", friend.PeerName, friend.PeerID)
+			continue
 		}
-	}
 
-	log.Printf("✅ Friend reconnection completed: %d/%d successful", successCount, len(friends))
-}
-
-// attemptFriendReconnection attempts to reconnect to a specific friend
-func (fs *FriendService) attemptFriendReconnection(friend models.Friend, lastConnection *models.ConnectionRecord) bool {
-	ip, port, err := fs.extractIPAndPort(lastConnection.Address)
-	if err != nil {
-		log.Printf("⚠️ Failed to extract IP/port for friend %s: %v", friend.PeerName, err)
-		return false
-	}
-
-	log.Printf("🔄 Attempting to reconnect to friend %s (%s) at %s:%d", friend.PeerName, friend.PeerID, ip, port)
-
-	// Attempt connection with a reasonable timeout
-	_, err = fs.p2pService.ConnectByIP(ip, port, friend.PeerID)
-	if err != nil {
-		log.Printf("❌ Failed to reconnect to friend %s: %v", friend.PeerName, err)
-		return false
-	}
-
-	log.Printf("✅ Successfully reconnected to friend %s", friend.PeerName)
-	return true
-}
-
-// extractIPAndPort extracts IP and port from a connection address
-func (fs *FriendService) extractIPAndPort(address string) (string, int, error) {
-	var ip string
-	var port int
-	var err error
-
-	if strings.Contains(address, "/ip4/") {
-		// Parse multiaddr format: /ip4/192.168.1.100/tcp/4001
-		parts := strings.Split(address, "/")
-		if len(parts) >= 5 {
-			ip = parts[2]
-			port, err = strconv.Atoi(parts[4])
-			if err != nil {
-				return "", 0, fmt.Errorf("invalid port in multiaddr: %s", parts[4])
-			}
+		if status := fs.GetReconnectStatus(friend.PeerID); status != nil && status.State == models.ReconnectConnected {
+			successCount++
 		} else {
-			return "", 0, fmt.Errorf("invalid multiaddr format: %s", address)
+			skippedCount++
 		}
-	} else if strings.Contains(address, ":") {
-		// Parse IP:PORT format: 192.168.1.100:4001
-		parts := strings.Split(address, ":")
-		if len(parts) >= 2 {
-			ip = parts[0]
-			port, err = strconv.Atoi(parts[1])
-			if err != nil {
-				return "", 0, fmt.Errorf("invalid port: %s", parts[1])
-			}
-		} else {
-			return "", 0, fmt.Errorf("invalid IP:PORT format: %s", address)
-		}
-	} else {
-		return "", 0, fmt.Errorf("unsupported address format: %s", address)
 	}
 
-	if ip == "" || port == 0 {
-		return "", 0, fmt.Errorf("could not extract valid IP and port from address: %s", address)
+	log.Printf("✅ Friend reconnection completed: %d/%d successful", successCount, len(friends))
+	if skippedCount > 0 {
+		log.Printf("⏳ %d friend(s) already being dialed or waiting for their next attempt", skippedCount)
 	}
-
-	return ip, port, nil
 }
 
 // ReconnectToFriend attempts to reconnect to a specific friend by peer ID
@@ -155,46 +160,20 @@ func (fs *FriendService) ReconnectToFriend(peerID string) error {
 		return fmt.Errorf("failed to get friends list: %w", err)
 	}
 
-	var targetFriend *models.Friend
 	for _, friend := range friends {
-		if friend.PeerID == peerID {
-			targetFriend = &friend
-			break
+		if friend.PeerID != peerID {
+			continue
 		}
-	}
-
-	if targetFriend == nil {
-		return fmt.Errorf("friend with peer ID %s not found", peerID)
-	}
-
-	// Get connection history for this friend
-	connectionHistory, err := fs.database.GetConnectionHistory()
-	if err != nil {
-		return fmt.Errorf("failed to get connection history: %w", err)
-	}
-
-	// Find most recent validated connection for this peer
-	var lastConnection *models.ConnectionRecord
-	for i := range connectionHistory {
-		record := &connectionHistory[i]
-		if record.PeerID == peerID && record.IsValidated {
-			if lastConnection == nil || record.LastConnected.After(lastConnection.LastConnected) {
-				lastConnection = record
+		if !fs.reconnectDue(peerID, time.Now()) {
+			if status := fs.GetReconnectStatus(peerID); status != nil && status.State == models.ReconnectConnected {
+				return nil
 			}
+			return fmt.Errorf("friend %s is already being dialed or waiting for its next attempt", friend.PeerName)
 		}
+		return fs.reconnectFriend(friend)
 	}
 
-	if lastConnection == nil {
-		return fmt.Errorf("no connection history found for friend %s", targetFriend.PeerName)
-	}
-
-	// Attempt reconnection
-	success := fs.attemptFriendReconnection(*targetFriend, lastConnection)
-	if !success {
-		return fmt.Errorf("failed to reconnect to friend %s", targetFriend.PeerName)
-	}
-
-	return nil
+	return fmt.Errorf("friend with peer ID %s not found", peerID)
 }
 
 // GetFriendsConnectionStatus returns the current connection status of all friends
@@ -239,12 +218,20 @@ func (fs *FriendService) SyncFriendFilesMetadata() error {
 		return fmt.Errorf("failed to get friends list: %w", err)
 	}
 
+	fs.syncFriendsFiles(friends, "")
+	return nil
+}
+
+// syncFriendsFiles requests and stores the files table of each friend, reporting progress
+// as a sync of the given group, or of all friends when group is empty
+func (fs *FriendService) syncFriendsFiles(friends []models.Friend, group string) {
 	if len(friends) == 0 {
 		log.Printf("📭 No friends found for files sync")
-		return nil
+		return
 	}
 
 	log.Printf("👥 Syncing files metadata from %d friend(s)", len(friends))
+	publishEvent(fs.events, models.EventSyncStarted, models.SyncEvent{Group: group, Friends: len(friends)})
 
 	successCount := 0
 	errorCount := 0
@@ -266,23 +253,7 @@ func (fs *FriendService) SyncFriendFilesMetadata() error {
 		}
 
 		// Store friend's files metadata in our database
-		storedCount := 0
-		for _, file := range filesResponse.Files {
-			// The file already has the correct peer_id from the friend's response
-			err := fs.database.UpsertFileRecord(
-				file.FilePath,
-				file.Hash,
-				file.Size,
-				file.Extension,
-				file.Type,
-				file.PeerID, // This will be the friend's peer ID
-			)
-			if err != nil {
-				log.Printf("⚠️ Failed to store file record %s from friend %s: %v", file.FilePath, friend.PeerName, err)
-				continue
-			}
-			storedCount++
-		}
+		storedCount := fs.storeFriendFiles(friend, filesResponse.Files)
 
 		log.Printf("✅ Stored %d files metadata from friend %s", storedCount, friend.PeerName)
 		successCount++
@@ -296,7 +267,12 @@ func (fs *FriendService) SyncFriendFilesMetadata() error {
 		log.Printf("⚠️ %d friends had errors during sync", errorCount)
 	}
 
-	return nil
+	publishEvent(fs.events, models.EventSyncFinished, models.SyncEvent{
+		Group:   group,
+		Friends: len(friends),
+		Synced:  successCount,
+		Failed:  errorCount,
+	})
 }
 
 // SyncSpecificFriendFiles syncs files metadata from a specific friend
@@ -324,36 +300,74 @@ func (fs *FriendService) SyncSpecificFriendFiles(peerID string) error {
 	}
 
 	log.Printf("📁 Syncing files metadata from friend %s (%s)", targetFriend.PeerName, peerID)
+	publishEvent(fs.events, models.EventSyncStarted, models.SyncEvent{PeerID: peerID, Friends: 1})
 
 	// Request friend's files table
 	filesResponse, err := fs.p2pService.RequestPeerFiles(peerID)
 	if err != nil {
+		publishEvent(fs.events, models.EventSyncFinished, models.SyncEvent{PeerID: peerID, Friends: 1, Failed: 1, Error: err.Error()})
 		return fmt.Errorf("failed to get files from friend %s: %w", targetFriend.PeerName, err)
 	}
 
 	if filesResponse == nil || len(filesResponse.Files) == 0 {
 		log.Printf("📭 No files found for friend %s", targetFriend.PeerName)
+		publishEvent(fs.events, models.EventSyncFinished, models.SyncEvent{PeerID: peerID, Friends: 1, Synced: 1})
 		return nil
 	}
 
 	// Store friend's files metadata in our database
+	storedCount := fs.storeFriendFiles(*targetFriend, filesResponse.Files)
+
+	log.Printf("✅ Stored %d files metadata from friend %s", storedCount, targetFriend.PeerName)
+	publishEvent(fs.events, models.EventSyncFinished, models.SyncEvent{PeerID: peerID, Friends: 1, Synced: 1})
+	return nil
+}
+
+// storeFriendFiles stores a friend's files metadata and publishes the files we didn't know yet.
+// Nothing is published on the first sync with a friend, when every file would be new.
+func (fs *FriendService) storeFriendFiles(friend models.Friend, files []models.FileRecord) int {
+	known, err := fs.database.CountPeerFiles(friend.PeerID)
+	if err != nil {
+		log.Printf("⚠️ Failed to count stored files of friend %s: %v", friend.PeerName, err)
+	}
+	initialSync := err == nil && known == 0
+
 	storedCount := 0
-	for _, file := range filesResponse.Files {
+	var added []string
+	for _, file := range files {
+		// Records are stored under the friend we asked, whatever peer ID the response claims,
+		// so a friend can't plant the hashes downloads from another peer are verified against
+		existing, lookupErr := fs.database.GetFileRecord(file.FilePath, friend.PeerID)
+		if lookupErr != nil {
+			log.Printf("⚠️ Failed to look up file record %s from friend %s: %v", file.FilePath, friend.PeerName, lookupErr)
+		}
+
 		err := fs.database.UpsertFileRecord(
 			file.FilePath,
 			file.Hash,
 			file.Size,
 			file.Extension,
 			file.Type,
-			file.PeerID, // This will be the friend's peer ID
+			friend.PeerID,
 		)
 		if err != nil {
-			log.Printf("⚠️ Failed to store file record %s from friend %s: %v", file.FilePath, targetFriend.PeerName, err)
+			log.Printf("⚠️ Failed to store file record %s from friend %s: %v", file.FilePath, friend.PeerName, err)
 			continue
 		}
 		storedCount++
+
+		if lookupErr == nil && (existing == nil || existing.Hash != file.Hash) {
+			added = append(added, file.FilePath)
+		}
 	}
 
-	log.Printf("✅ Stored %d files metadata from friend %s", storedCount, targetFriend.PeerName)
-	return nil
+	if !initialSync && len(added) > 0 {
+		filesEvent := models.FriendFilesEvent{PeerID: friend.PeerID, PeerName: friend.PeerName, Count: len(added)}
+		if len(added) == 1 {
+			filesEvent.FilePath = added[0]
+		}
+		publishEvent(fs.events, models.EventFriendFilesAdded, filesEvent)
+	}
+
+	return storedCount
 }
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+// GetGroups returns every friend group with its members
+func (fs *FriendService) GetGroups() ([]models.FriendGroup, error) {
+	return fs.database.GetFriendGroups()
+}
+
+// GetGroup returns one friend group with its members
+func (fs *FriendService) GetGroup(name string) (*models.FriendGroup, error) {
+	group, err := fs.database.GetFriendGroup(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, utils.NewNotFoundError("friend group", name)
+	}
+	return group, nil
+}
+
+// CreateGroup creates a friend group with its initial members, who must all be friends
+func (fs *FriendService) CreateGroup(request models.FriendGroupRequest) (*models.FriendGroup, error) {
+	name, err := validateFriendGroupName(request.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := fs.database.GetFriendGroup(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, utils.NewValidationError("name", fmt.Sprintf("friend group %q already exists", name))
+	}
+
+	for _, peerID := range request.Members {
+		if err := fs.requireFriend(peerID); err != nil {
+			return nil, err
+		}
+	}
+
+	group, err := fs.database.CreateFriendGroup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, peerID := range request.Members {
+		if err := fs.database.AddFriendGroupMember(name, peerID); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs.GetGroup(group.Name)
+}
+
+// RenameGroup renames a friend group. Visibility rules targeting the group follow the new name.
+func (fs *FriendService) RenameGroup(name, newName string) (*models.FriendGroup, error) {
+	newName, err := validateFriendGroupName(newName)
+	if err != nil {
+		return nil, err
+	}
+
+	if newName != name {
+		existing, err := fs.database.GetFriendGroup(newName, 0)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, utils.NewValidationError("name", fmt.Sprintf("friend group %q already exists", newName))
+		}
+
+		if err := fs.database.RenameFriendGroup(name, newName); err != nil {
+			return nil, err
+		}
+
+		// The default visibility lives in the settings table rather than in the rules
+		if value, err := fs.database.GetSetting(defaultVisibilitySetting); err == nil && value == models.VisibilityGroup+":"+name {
+			if err := fs.database.SetSetting(defaultVisibilitySetting, models.VisibilityGroup+":"+newName); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fs.GetGroup(newName)
+}
+
+// DeleteGroup removes a friend group. Content visible only to the group is then shared with nobody.
+func (fs *FriendService) DeleteGroup(name string) error {
+	return fs.database.DeleteFriendGroup(name)
+}
+
+// AddGroupMember adds a friend to a friend group
+func (fs *FriendService) AddGroupMember(name, peerID string) (*models.FriendGroup, error) {
+	if err := fs.requireFriend(peerID); err != nil {
+		return nil, err
+	}
+
+	if err := fs.database.AddFriendGroupMember(name, peerID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("👪 Added %s to friend group %s", peerID, name)
+	return fs.GetGroup(name)
+}
+
+// RemoveGroupMember removes a friend from a friend group
+func (fs *FriendService) RemoveGroupMember(name, peerID string) (*models.FriendGroup, error) {
+	if _, err := fs.GetGroup(name); err != nil {
+		return nil, err
+	}
+
+	if err := fs.database.RemoveFriendGroupMember(name, peerID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("👪 Removed %s from friend group %s", peerID, name)
+	return fs.GetGroup(name)
+}
+
+// GetGroupFriends returns the friends that belong to a friend group
+func (fs *FriendService) GetGroupFriends(name string) ([]models.Friend, error) {
+	if _, err := fs.GetGroup(name); err != nil {
+		return nil, err
+	}
+
+	friends, err := fs.database.GetFriends()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friends list: %w", err)
+	}
+
+	members := []models.Friend{}
+	for _, friend := range friends {
+		for _, group := range friend.Groups {
+			if group == name {
+				members = append(members, friend)
+				break
+			}
+		}
+	}
+	return members, nil
+}
+
+// AttemptReconnectToGroup attempts to reconnect to the friends in a friend group
+func (fs *FriendService) AttemptReconnectToGroup(name string) error {
+	if fs.database == nil || fs.p2pService == nil {
+		return fmt.Errorf("database or P2P service not available")
+	}
+
+	friends, err := fs.GetGroupFriends(name)
+	if err != nil {
+		return err
+	}
+
+	// Asked for explicitly, so the friends start over with a fresh backoff
+	for _, friend := range friends {
+		fs.scheduleReconnect(friend.PeerID, 0)
+	}
+
+	log.Printf("🔄 Attempting to reconnect to friend group %s...", name)
+	fs.reconnectToFriends(friends)
+	return nil
+}
+
+// SyncGroupFilesMetadata requests and stores the files table data of the friends in a friend group
+func (fs *FriendService) SyncGroupFilesMetadata(name string) error {
+	if fs.database == nil || fs.p2pService == nil {
+		return fmt.Errorf("database or P2P service not available")
+	}
+
+	friends, err := fs.GetGroupFriends(name)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📁 Starting files metadata sync of friend group %s...", name)
+	fs.syncFriendsFiles(friends, name)
+	return nil
+}
+
+// requireFriend returns a validation error unless the peer is a friend
+func (fs *FriendService) requireFriend(peerID string) error {
+	if peerID == "" {
+		return utils.NewValidationError("peer_id", "peer ID is required")
+	}
+
+	isFriend, err := fs.database.IsFriend(peerID)
+	if err != nil {
+		return err
+	}
+	if !isFriend {
+		return utils.NewValidationError("peer_id", fmt.Sprintf("%s is not a friend", peerID))
+	}
+	return nil
+}
+
+// validateFriendGroupName trims a group name and checks it only uses letters, digits, spaces,
+// dashes and underscores so it is safe in URLs and in the default visibility setting
+func validateFriendGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", utils.NewValidationError("name", "group name is required")
+	}
+	if len(name) > models.MaxFriendGroupNameLength {
+		return "", utils.NewValidationError("name", fmt.Sprintf("group name is longer than %d bytes", models.MaxFriendGroupNameLength))
+	}
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_' {
+			return "", utils.NewValidationError("name", fmt.Sprintf("group name may not contain %q", r))
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+func init() {
+	registerMessageHandlers(friendRequestMessageHandlers)
+}
+
+// friendRequestMessageHandlers returns the handlers for incoming friend requests and their answers
+func friendRequestMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypeFriendRequest, models.MessageTypeFriendRequestResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.FriendRequestMessage) (*models.FriendRequestMessageResponse, error) {
+				friendService := p.container.GetFriendService()
+				if friendService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "friend service not available")
+				}
+				return friendService.handleFriendRequest(peerID.String(), request)
+			}),
+
+		NewMessageHandler(models.MessageTypeFriendUpdate, models.MessageTypeFriendUpdateResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.FriendUpdateMessage) (*models.FriendUpdateMessageResponse, error) {
+				friendService := p.container.GetFriendService()
+				if friendService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "friend service not available")
+				}
+				return friendService.handleFriendUpdate(peerID.String(), request)
+			}),
+	}
+}
+
+// SendFriendRequest asks a peer to become friends. The friendship becomes mutual once the peer accepts.
+// If the peer can't be reached the request stays pending and is delivered when the peer connects again.
+func (fs *FriendService) SendFriendRequest(peerID, peerName, message string) (*models.FriendRequest, error) {
+	if _, err := peer.Decode(peerID); err != nil {
+		return nil, utils.NewValidationError("peer_id", "invalid peer ID")
+	}
+
+	existing, err := fs.database.GetFriendRequest(peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		switch {
+		case existing.Status == models.FriendRequestAccepted:
+			return existing, nil
+		case existing.Direction == models.FriendRequestIncoming && existing.Status == models.FriendRequestPending:
+			// They already asked us, sending a request back means yes
+			return fs.AcceptFriendRequest(peerID)
+		}
+		if peerName == "" {
+			peerName = existing.PeerName
+		}
+	}
+
+	request := &models.FriendRequest{
+		PeerID:    peerID,
+		PeerName:  peerName,
+		Direction: models.FriendRequestOutgoing,
+		Status:    models.FriendRequestPending,
+		Message:   message,
+	}
+	if err := fs.database.SaveFriendRequest(request); err != nil {
+		return nil, err
+	}
+
+	log.Printf("📨 Sending friend request to %s (%s)", peerName, peerID)
+	fs.deliverFriendRequest(peerID)
+
+	return fs.database.GetFriendRequest(peerID)
+}
+
+// AcceptFriendRequest accepts a pending incoming friend request
+func (fs *FriendService) AcceptFriendRequest(peerID string) (*models.FriendRequest, error) {
+	request, err := fs.getPendingFriendRequest(peerID, models.FriendRequestIncoming)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.database.ConfirmFriend(peerID, request.PeerName); err != nil {
+		return nil, err
+	}
+	if err := fs.database.UpdateFriendRequestStatus(peerID, models.FriendRequestAccepted, false); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🤝 Accepted friend request from %s (%s)", request.PeerName, peerID)
+	publishEvent(fs.events, models.EventFriendAdded, models.FriendEvent{PeerID: peerID, PeerName: request.PeerName})
+
+	fs.deliverFriendRequest(peerID)
+	return fs.database.GetFriendRequest(peerID)
+}
+
+// DeclineFriendRequest declines a pending incoming friend request
+func (fs *FriendService) DeclineFriendRequest(peerID string) (*models.FriendRequest, error) {
+	request, err := fs.getPendingFriendRequest(peerID, models.FriendRequestIncoming)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.database.UpdateFriendRequestStatus(peerID, models.FriendRequestDeclined, false); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🙅 Declined friend request from %s (%s)", request.PeerName, peerID)
+
+	fs.deliverFriendRequest(peerID)
+	return fs.database.GetFriendRequest(peerID)
+}
+
+// CancelFriendRequest withdraws a pending outgoing friend request
+func (fs *FriendService) CancelFriendRequest(peerID string) (*models.FriendRequest, error) {
+	request, err := fs.getPendingFriendRequest(peerID, models.FriendRequestOutgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	// A request the peer never received needs no cancellation message
+	if err := fs.database.UpdateFriendRequestStatus(peerID, models.FriendRequestCancelled, !request.Delivered); err != nil {
+		return nil, err
+	}
+
+	log.Printf("↩️ Cancelled friend request to %s (%s)", request.PeerName, peerID)
+
+	fs.deliverFriendRequest(peerID)
+	return fs.database.GetFriendRequest(peerID)
+}
+
+// getPendingFriendRequest returns the pending request with a peer in the given direction
+func (fs *FriendService) getPendingFriendRequest(peerID, direction string) (*models.FriendRequest, error) {
+	request, err := fs.database.GetFriendRequest(peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request == nil || request.Direction != direction || request.Status != models.FriendRequestPending {
+		return nil, utils.NewNotFoundError("pending "+direction+" friend request", peerID)
+	}
+
+	return request, nil
+}
+
+// GetFriendRequests lists friend requests, optionally filtered by direction and status
+func (fs *FriendService) GetFriendRequests(direction, status string) ([]models.FriendRequest, error) {
+	return fs.database.GetFriendRequests(direction, status)
+}
+
+// deliverFriendRequest tells the peer about the current state of our friend request record.
+// Failures are left undelivered and retried when the peer connects again.
+func (fs *FriendService) deliverFriendRequest(peerID string) {
+	if fs.p2pService == nil {
+		return
+	}
+
+	request, err := fs.database.GetFriendRequest(peerID)
+	if err != nil || request == nil || request.Delivered {
+		return
+	}
+
+	pid, err := peer.Decode(peerID)
+	if err != nil {
+		return
+	}
+
+	ourName := ""
+	if name, err := fs.database.GetSetting("name"); err == nil {
+		ourName = name
+	}
+
+	if request.Direction == models.FriendRequestOutgoing && request.Status == models.FriendRequestPending {
+		response, err := requestPeer[models.FriendRequestMessageResponse](fs.p2pService, pid, models.MessageTypeFriendRequest,
+			models.FriendRequestMessage{PeerName: ourName, Message: request.Message})
+		if err != nil {
+			log.Printf("⏳ Friend request to %s not delivered, will retry when the peer connects: %v", peerID, err)
+			return
+		}
+
+		if err := fs.database.SetFriendRequestDelivered(peerID, true); err != nil {
+			log.Printf("⚠️ Failed to mark friend request to %s as delivered: %v", peerID, err)
+		}
+
+		// The peer had already asked us, so the friendship is mutual right away
+		if response.Status == models.FriendRequestAccepted {
+			fs.confirmAcceptedRequest(request)
+		}
+		return
+	}
+
+	action := friendUpdateAction(request)
+	if action == "" {
+		return
+	}
+
+	_, err = requestPeer[models.FriendUpdateMessageResponse](fs.p2pService, pid, models.MessageTypeFriendUpdate,
+		models.FriendUpdateMessage{Action: action, PeerName: ourName})
+	if err != nil {
+		log.Printf("⏳ Friend %s for %s not delivered, will retry when the peer connects: %v", action, peerID, err)
+		return
+	}
+
+	if err := fs.database.SetFriendRequestDelivered(peerID, true); err != nil {
+		log.Printf("⚠️ Failed to mark friend %s for %s as delivered: %v", action, peerID, err)
+	}
+}
+
+// friendUpdateAction returns the update that tells the peer about a request's status
+func friendUpdateAction(request *models.FriendRequest) string {
+	switch {
+	case request.Status == models.FriendRequestRemoved:
+		return models.FriendUpdateUnfriend
+	case request.Direction == models.FriendRequestIncoming && request.Status == models.FriendRequestAccepted:
+		return models.FriendUpdateAccept
+	case request.Direction == models.FriendRequestIncoming && request.Status == models.FriendRequestDeclined:
+		return models.FriendUpdateDecline
+	case request.Direction == models.FriendRequestOutgoing && request.Status == models.FriendRequestCancelled:
+		return models.FriendUpdateCancel
+	default:
+		return ""
+	}
+}
+
+// confirmAcceptedRequest makes an outgoing request mutual once the peer accepted it
+func (fs *FriendService) confirmAcceptedRequest(request *models.FriendRequest) {
+	if err := fs.database.ConfirmFriend(request.PeerID, request.PeerName); err != nil {
+		log.Printf("⚠️ Failed to add friend %s: %v", request.PeerID, err)
+		return
+	}
+	if err := fs.database.UpdateFriendRequestStatus(request.PeerID, models.FriendRequestAccepted, true); err != nil {
+		log.Printf("⚠️ Failed to update friend request for %s: %v", request.PeerID, err)
+	}
+
+	request.Status = models.FriendRequestAccepted
+	log.Printf("🤝 %s (%s) accepted our friend request", request.PeerName, request.PeerID)
+	publishEvent(fs.events, models.EventFriendRequestAccepted, *request)
+	publishEvent(fs.events, models.EventFriendAdded, models.FriendEvent{PeerID: request.PeerID, PeerName: request.PeerName})
+}
+
+// handleFriendRequest stores a friend request received from a peer
+func (fs *FriendService) handleFriendRequest(peerID string, message *models.FriendRequestMessage) (*models.FriendRequestMessageResponse, error) {
+	existing, err := fs.database.GetFriendRequest(peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.Status == models.FriendRequestAccepted {
+		// Already friends, the peer probably lost our answer
+		return &models.FriendRequestMessageResponse{Status: models.FriendRequestAccepted}, nil
+	}
+
+	if existing != nil && existing.Direction == models.FriendRequestOutgoing && existing.Status == models.FriendRequestPending {
+		// Both sides asked, which is consent from both
+		if message.PeerName != "" {
+			existing.PeerName = message.PeerName
+		}
+		fs.confirmAcceptedRequest(existing)
+		return &models.FriendRequestMessageResponse{Status: models.FriendRequestAccepted}, nil
+	}
+
+	request := &models.FriendRequest{
+		PeerID:    peerID,
+		PeerName:  message.PeerName,
+		Direction: models.FriendRequestIncoming,
+		Status:    models.FriendRequestPending,
+		Message:   message.Message,
+		Delivered: true,
+	}
+	if err := fs.database.SaveFriendRequest(request); err != nil {
+		return nil, err
+	}
+
+	log.Printf("📨 Received friend request from %s (%s)", message.PeerName, peerID)
+	publishEvent(fs.events, models.EventFriendRequestReceived, *request)
+
+	return &models.FriendRequestMessageResponse{Status: models.FriendRequestPending}, nil
+}
+
+// handleFriendUpdate applies a peer's answer to our request, its cancellation, or the end of a friendship
+func (fs *FriendService) handleFriendUpdate(peerID string, message *models.FriendUpdateMessage) (*models.FriendUpdateMessageResponse, error) {
+	request, err := fs.database.GetFriendRequest(peerID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch message.Action {
+	case models.FriendUpdateAccept, models.FriendUpdateDecline:
+		if request == nil || request.Direction != models.FriendRequestOutgoing {
+			return nil, wire.NewError(wire.ErrCodeNotFound, "no friend request sent to %s", peerID)
+		}
+		if request.Status != models.FriendRequestPending {
+			// Repeated delivery of an answer we already applied
+			return &models.FriendUpdateMessageResponse{Success: true}, nil
+		}
+
+		if message.PeerName != "" {
+			request.PeerName = message.PeerName
+		}
+
+		if message.Action == models.FriendUpdateAccept {
+			fs.confirmAcceptedRequest(request)
+		} else {
+			if err := fs.database.UpdateFriendRequestStatus(peerID, models.FriendRequestDeclined, true); err != nil {
+				return nil, err
+			}
+			request.Status = models.FriendRequestDeclined
+			log.Printf("🙅 %s (%s) declined our friend request", request.PeerName, peerID)
+			publishEvent(fs.events, models.EventFriendRequestDeclined, *request)
+		}
+
+	case models.FriendUpdateCancel:
+		if request == nil || request.Direction != models.FriendRequestIncoming || request.Status != models.FriendRequestPending {
+			return &models.FriendUpdateMessageResponse{Success: true}, nil
+		}
+
+		if err := fs.database.UpdateFriendRequestStatus(peerID, models.FriendRequestCancelled, true); err != nil {
+			return nil, err
+		}
+		request.Status = models.FriendRequestCancelled
+		log.Printf("↩️ %s (%s) cancelled their friend request", request.PeerName, peerID)
+		publishEvent(fs.events, models.EventFriendRequestCancelled, *request)
+
+	case models.FriendUpdateUnfriend:
+		if err := fs.database.RemoveFriend(peerID); err != nil {
+			log.Printf("⚠️ Unfriend from %s for a peer that wasn't a friend: %v", peerID, err)
+		}
+		if request != nil {
+			if err := fs.database.UpdateFriendRequestStatus(peerID, models.FriendRequestRemoved, true); err != nil {
+				return nil, err
+			}
+		}
+
+		log.Printf("💔 %s ended the friendship", peerID)
+		publishEvent(fs.events, models.EventFriendRemoved, models.FriendEvent{PeerID: peerID, PeerName: message.PeerName})
+
+	default:
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "unknown friend update action %q", message.Action)
+	}
+
+	return &models.FriendUpdateMessageResponse{Success: true}, nil
+}
+
+// notifyUnfriend records that we ended a friendship so the peer is told, now or when it reconnects
+func (fs *FriendService) notifyUnfriend(peerID string) error {
+	request := &models.FriendRequest{
+		PeerID:    peerID,
+		Direction: models.FriendRequestOutgoing,
+		Status:    models.FriendRequestRemoved,
+	}
+	if existing, err := fs.database.GetFriendRequest(peerID); err == nil && existing != nil {
+		request.PeerName = existing.PeerName
+		request.Direction = existing.Direction
+	}
+
+	if err := fs.database.SaveFriendRequest(request); err != nil {
+		return fmt.Errorf("failed to record unfriend: %w", err)
+	}
+
+	go fs.deliverFriendRequest(peerID)
+	return nil
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/crypto/scrypt"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+const (
+	// identityBackupFormat marks a file as an identity backup of this application
+	identityBackupFormat = "old-school-identity-backup"
+
+	// identityBackupVersion is the version of the backup format and its contents
+	identityBackupVersion = 1
+
+	// MinBackupPassphraseLength is the shortest passphrase an identity backup is encrypted with
+	MinBackupPassphraseLength = 8
+
+	// scrypt parameters for new backups, about 32 MB of memory per derivation
+	backupScryptN = 1 << 15
+	backupScryptR = 8
+	backupScryptP = 1
+
+	// maxBackupScryptN bounds the cost a backup file may ask for when it is decrypted
+	maxBackupScryptN = 1 << 20
+)
+
+// backupSettingKeys are the settings carried in an identity backup besides the node key
+var backupSettingKeys = []string{
+	"name",
+	profileNameSetSetting,
+	profileBioSetting,
+	profileLinksSetting,
+	profileLocationSetting,
+	profileAvatarSetting,
+	profileRecordSetting,
+	defaultVisibilitySetting,
+	keyRotationsSetting,
+}
+
+// IdentityService exports the node identity as a passphrase-encrypted backup and restores it,
+// rotates the node key and follows the key rotations of peers
+type IdentityService struct {
+	database    interfaces.DatabaseService
+	pathManager *utils.PathManager
+	events      interfaces.EventPublisher
+	mutex       sync.Mutex
+}
+
+// NewIdentityService creates a new identity service
+func NewIdentityService(database interfaces.DatabaseService, pathManager *utils.PathManager, events interfaces.EventPublisher) *IdentityService {
+	return &IdentityService{
+		database:    database,
+		pathManager: pathManager,
+		events:      events,
+	}
+}
+
+// ExportIdentity returns our node key, profile and friends list encrypted with the passphrase,
+// encoded as the contents of a backup file
+func (is *IdentityService) ExportIdentity(passphrase string) ([]byte, error) {
+	if err := validateBackupPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	backup, err := is.buildBackup()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(backup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode identity backup: %w", err)
+	}
+
+	encrypted, err := encryptIdentityBackup(plaintext, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(encrypted, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode encrypted identity backup: %w", err)
+	}
+
+	log.Printf("🔐 Exported identity backup of %s with %d friend(s)", backup.PeerID, len(backup.Friends))
+	return data, nil
+}
+
+// ImportIdentity decrypts an identity backup and restores its key, settings, friends and
+// friend groups. A different identity is only restored on a node without friends, so an
+// identity in use isn't replaced by accident. A new key takes effect on the next start.
+func (is *IdentityService) ImportIdentity(encrypted *models.EncryptedIdentityBackup, passphrase string) (*models.ImportIdentityResult, error) {
+	if encrypted == nil {
+		return nil, utils.NewValidationError("backup", "backup is required")
+	}
+
+	plaintext, err := decryptIdentityBackup(encrypted, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var backup models.IdentityBackup
+	if err := json.Unmarshal(plaintext, &backup); err != nil {
+		return nil, utils.NewValidationError("backup", fmt.Sprintf("unreadable backup contents: %v", err))
+	}
+	if backup.Version != identityBackupVersion {
+		return nil, utils.NewValidationError("backup", fmt.Sprintf("unsupported backup version %d", backup.Version))
+	}
+
+	privateKey, err := crypto.UnmarshalPrivateKey(backup.PrivateKey)
+	if err != nil {
+		return nil, utils.NewValidationError("backup", fmt.Sprintf("invalid node key: %v", err))
+	}
+	peerID, err := peer.IDFromPrivateKey(privateKey)
+	if err != nil || peerID.String() != backup.PeerID {
+		return nil, utils.NewValidationError("backup", "node key does not match the backed up peer ID")
+	}
+
+	currentID, err := is.database.GetNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %w", err)
+	}
+	identityChanged := currentID != peerID
+	if identityChanged {
+		friends, err := is.database.GetFriends()
+		if err != nil {
+			return nil, err
+		}
+		if len(friends) > 0 {
+			return nil, utils.NewValidationError("backup", fmt.Sprintf("this node already has an identity with %d friend(s); restore on a fresh install", len(friends)))
+		}
+	}
+
+	// The key goes first so a restore that fails halfway can simply be repeated
+	if err := is.database.SetSetting("private_key", string(backup.PrivateKey)); err != nil {
+		return nil, err
+	}
+	if err := is.database.SetSetting("node_id", peerID.String()); err != nil {
+		return nil, err
+	}
+
+	groups, err := is.restoreFriends(&backup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Settings last since the default visibility may name a restored friend group
+	for _, key := range backupSettingKeys {
+		if value, ok := backup.Settings[key]; ok {
+			if err := is.database.SetSetting(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for key, value := range backup.Settings {
+		if strings.HasPrefix(key, privacySettingPrefix) {
+			if err := is.database.SetSetting(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	log.Printf("🔐 Restored identity %s with %d friend(s) and %d group(s)", peerID, len(backup.Friends), groups)
+	if identityChanged {
+		log.Printf("🔁 Node key changed, restart the node to use the restored identity")
+	}
+
+	return &models.ImportIdentityResult{
+		PeerID:          peerID.String(),
+		Friends:         len(backup.Friends),
+		Groups:          groups,
+		RestartRequired: identityChanged,
+	}, nil
+}
+
+// buildBackup collects what an identity backup carries
+func (is *IdentityService) buildBackup() (*models.IdentityBackup, error) {
+	nodeID, err := is.database.GetNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %w", err)
+	}
+	privateKey, err := is.database.GetSetting("private_key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node key: %w", err)
+	}
+
+	settings, err := is.database.GetAllSettings()
+	if err != nil {
+		return nil, err
+	}
+	backupSettings := make(map[string]string)
+	for key, value := range settings {
+		if isBackupSetting(key) {
+			backupSettings[key] = value
+		}
+	}
+
+	friends, err := is.database.GetFriends()
+	if err != nil {
+		return nil, err
+	}
+	backupFriends := make([]models.BackupFriend, 0, len(friends))
+	for _, friend := range friends {
+		backupFriends = append(backupFriends, models.BackupFriend{
+			PeerID:   friend.PeerID,
+			PeerName: friend.PeerName,
+			Mutual:   friend.Mutual,
+			Groups:   friend.Groups,
+		})
+	}
+
+	groups, err := is.database.GetFriendGroups()
+	if err != nil {
+		return nil, err
+	}
+	groupNames := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupNames = append(groupNames, group.Name)
+	}
+
+	return &models.IdentityBackup{
+		Version:    identityBackupVersion,
+		PeerID:     nodeID.String(),
+		PrivateKey: []byte(privateKey),
+		Settings:   backupSettings,
+		Friends:    backupFriends,
+		Groups:     groupNames,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// restoreFriends adds the backed up friends and friend groups, keeping what the node already has.
+// It returns the number of friend groups in the backup.
+func (is *IdentityService) restoreFriends(backup *models.IdentityBackup) (int, error) {
+	for _, name := range backup.Groups {
+		group, err := is.database.GetFriendGroup(name, 0)
+		if err != nil {
+			return 0, err
+		}
+		if group == nil {
+			if _, err := is.database.CreateFriendGroup(name); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	for _, friend := range backup.Friends {
+		if _, err := peer.Decode(friend.PeerID); err != nil {
+			log.Printf("⚠️ Skipping backed up friend with invalid peer ID %s: %v", friend.PeerID, err)
+			continue
+		}
+
+		if err := is.database.ConfirmFriend(friend.PeerID, friend.PeerName); err != nil {
+			return 0, err
+		}
+		if friend.Mutual {
+			if err := is.database.SaveFriendRequest(&models.FriendRequest{
+				PeerID:    friend.PeerID,
+				PeerName:  friend.PeerName,
+				Direction: models.FriendRequestOutgoing,
+				Status:    models.FriendRequestAccepted,
+				Delivered: true,
+			}); err != nil {
+				return 0, err
+			}
+		}
+		for _, group := range friend.Groups {
+			if err := is.database.AddFriendGroupMember(group, friend.PeerID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(backup.Groups), nil
+}
+
+// isBackupSetting reports whether a setting is carried in an identity backup
+func isBackupSetting(key string) bool {
+	if strings.HasPrefix(key, privacySettingPrefix) {
+		return true
+	}
+	for _, backupKey := range backupSettingKeys {
+		if key == backupKey {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBackupPassphrase checks the passphrase a new backup is encrypted with
+func validateBackupPassphrase(passphrase string) error {
+	if len(passphrase) < MinBackupPassphraseLength {
+		return utils.NewValidationError("passphrase", fmt.Sprintf("passphrase must be at least %d characters", MinBackupPassphraseLength))
+	}
+	return nil
+}
+
+// encryptIdentityBackup seals a backup with AES-256-GCM under a key derived from the passphrase
+// with scrypt and a random salt
+func encryptIdentityBackup(plaintext []byte, passphrase string) (*models.EncryptedIdentityBackup, error) {
+	encrypted := &models.EncryptedIdentityBackup{
+		Format:  identityBackupFormat,
+		Version: identityBackupVersion,
+		KDF:     "scrypt",
+		ScryptN: backupScryptN,
+		ScryptR: backupScryptR,
+		ScryptP: backupScryptP,
+		Salt:    make([]byte, 16),
+		Cipher:  "aes-256-gcm",
+	}
+	if _, err := rand.Read(encrypted.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	aead, err := backupCipher(encrypted, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(encrypted.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	encrypted.Ciphertext = aead.Seal(nil, encrypted.Nonce, plaintext, backupAssociatedData(encrypted))
+	return encrypted, nil
+}
+
+// decryptIdentityBackup opens a backup sealed by encryptIdentityBackup
+func decryptIdentityBackup(encrypted *models.EncryptedIdentityBackup, passphrase string) ([]byte, error) {
+	if encrypted.Format != identityBackupFormat {
+		return nil, utils.NewValidationError("backup", "not an identity backup")
+	}
+	if encrypted.Version != identityBackupVersion || encrypted.KDF != "scrypt" || encrypted.Cipher != "aes-256-gcm" {
+		return nil, utils.NewValidationError("backup", fmt.Sprintf("unsupported backup version %d (%s, %s)", encrypted.Version, encrypted.KDF, encrypted.Cipher))
+	}
+	if encrypted.ScryptN > maxBackupScryptN || encrypted.ScryptR > 32 || encrypted.ScryptP > 16 {
+		return nil, utils.NewValidationError("backup", "backup asks for an excessive key derivation cost")
+	}
+
+	aead, err := backupCipher(encrypted, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted.Nonce) != aead.NonceSize() {
+		return nil, utils.NewValidationError("backup", "invalid nonce")
+	}
+
+	plaintext, err := aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, backupAssociatedData(encrypted))
+	if err != nil {
+		return nil, utils.NewValidationError("passphrase", "wrong passphrase or corrupted backup")
+	}
+	return plaintext, nil
+}
+
+// backupCipher derives the backup key from the passphrase and returns the AEAD sealing the backup
+func backupCipher(encrypted *models.EncryptedIdentityBackup, passphrase string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), encrypted.Salt, encrypted.ScryptN, encrypted.ScryptR, encrypted.ScryptP, 32)
+	if err != nil {
+		return nil, utils.NewValidationError("backup", fmt.Sprintf("invalid key derivation parameters: %v", err))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// backupAssociatedData binds the header of a backup to its ciphertext so the key derivation
+// parameters can't be swapped without failing decryption
+func backupAssociatedData(encrypted *models.EncryptedIdentityBackup) []byte {
+	return signingBytes(encrypted.Format,
+		strconv.Itoa(encrypted.Version), encrypted.KDF,
+		strconv.Itoa(encrypted.ScryptN), strconv.Itoa(encrypted.ScryptR), strconv.Itoa(encrypted.ScryptP),
+		string(encrypted.Salt), encrypted.Cipher)
+}
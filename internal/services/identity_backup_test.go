@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"old-school/internal/models"
+)
+
+func TestIdentityBackupRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"node_id":"12D3KooW","private_key":"secret"}`)
+
+	encrypted, err := encryptIdentityBackup(plaintext, "correct horse battery staple")
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted.Ciphertext), "secret")
+
+	decrypted, err := decryptIdentityBackup(encrypted, "correct horse battery staple")
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
+
+func TestDecryptIdentityBackupRejectsTampering(t *testing.T) {
+	const passphrase = "correct horse battery staple"
+
+	encrypted, err := encryptIdentityBackup([]byte("identity"), passphrase)
+	require.NoError(t, err)
+
+	// A second backup of the same identity, whose header is swapped into the first one
+	other, err := encryptIdentityBackup([]byte("identity"), passphrase)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		passphrase string
+		tamper     func(backup *models.EncryptedIdentityBackup)
+	}{
+		{name: "wrong passphrase", passphrase: "wrong horse battery staple", tamper: func(backup *models.EncryptedIdentityBackup) {}},
+		{name: "empty passphrase", passphrase: "", tamper: func(backup *models.EncryptedIdentityBackup) {}},
+		{name: "other format", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Format = "some-other-backup"
+		}},
+		{name: "other version", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Version++
+		}},
+		{name: "other key derivation", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.KDF = "pbkdf2"
+		}},
+		{name: "other cipher", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Cipher = "chacha20-poly1305"
+		}},
+		{name: "lowered scrypt N", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.ScryptN /= 2
+		}},
+		{name: "lowered scrypt r", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.ScryptR--
+		}},
+		{name: "raised scrypt p", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.ScryptP++
+		}},
+		{name: "excessive scrypt N", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.ScryptN = maxBackupScryptN * 2
+		}},
+		{name: "invalid scrypt N", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.ScryptN = 1000
+		}},
+		{name: "swapped salt", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Salt = other.Salt
+		}},
+		{name: "swapped nonce", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Nonce = other.Nonce
+		}},
+		{name: "short nonce", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Nonce = backup.Nonce[:4]
+		}},
+		{name: "swapped ciphertext", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Ciphertext = other.Ciphertext
+		}},
+		{name: "flipped ciphertext bit", passphrase: passphrase, tamper: func(backup *models.EncryptedIdentityBackup) {
+			backup.Ciphertext[0] ^= 0x01
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := *encrypted
+			backup.Salt = append([]byte(nil), encrypted.Salt...)
+			backup.Nonce = append([]byte(nil), encrypted.Nonce...)
+			backup.Ciphertext = append([]byte(nil), encrypted.Ciphertext...)
+			tt.tamper(&backup)
+
+			decrypted, err := decryptIdentityBackup(&backup, tt.passphrase)
+			assert.Error(t, err)
+			assert.Nil(t, decrypted)
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+const (
+	// inviteCodePrefix starts every invite code
+	inviteCodePrefix = "OSINVITE:"
+
+	// inviteFormatVersion is the version of the binary invite layout
+	inviteFormatVersion = 1
+
+	// inviteTokenBytes is the size of the one-time invite token
+	inviteTokenBytes = 16
+
+	// maxInviteAddresses bounds the addresses written into and read from an invite
+	maxInviteAddresses = 16
+)
+
+// inviteEncoding is unpadded base32, whose upper case alphabet fits the QR code alphanumeric mode
+var inviteEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func init() {
+	registerMessageHandlers(inviteMessageHandlers)
+}
+
+// inviteMessageHandlers returns the handler redeeming the tokens of our invites
+func inviteMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypeRedeemInvite, models.MessageTypeRedeemInviteResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.RedeemInviteMessage) (*models.RedeemInviteMessageResponse, error) {
+				inviteService := p.container.GetInviteService()
+				if inviteService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "invite service not available")
+				}
+				return inviteService.handleRedeemInvite(peerID.String(), request)
+			}),
+	}
+}
+
+// InviteService creates signed invite codes and redeems them
+type InviteService struct {
+	database      interfaces.DatabaseService
+	p2pService    *P2PService
+	friendService *FriendService
+	events        interfaces.EventPublisher
+}
+
+// NewInviteService creates a new invite service
+func NewInviteService(database interfaces.DatabaseService, p2pService *P2PService, friendService *FriendService, events interfaces.EventPublisher) *InviteService {
+	return &InviteService{
+		database:      database,
+		p2pService:    p2pService,
+		friendService: friendService,
+		events:        events,
+	}
+}
+
+// CreateInvite creates an invite code carrying our addresses, peer ID and name, signed by our node key.
+// Unless the request turns it off, redeeming the invite makes the redeemer our friend right away.
+func (is *InviteService) CreateInvite(request models.CreateInviteRequest) (*models.CreateInviteResponse, error) {
+	hours := request.ExpiresInHours
+	if hours == 0 {
+		hours = models.DefaultInviteExpiryHours
+	}
+	if hours < 0 || hours > models.MaxInviteExpiryHours {
+		return nil, utils.NewValidationError("expires_in_hours", fmt.Sprintf("must be between 1 and %d", models.MaxInviteExpiryHours))
+	}
+
+	if is.p2pService == nil {
+		return nil, fmt.Errorf("P2P service not available")
+	}
+	addresses := is.p2pService.inviteAddresses()
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("the node has no address to invite to")
+	}
+
+	token := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate invite token: %w", err)
+	}
+
+	name, _ := is.database.GetSetting("name")
+	now := time.Now().UTC()
+	invite := models.Invite{
+		PeerID:    is.p2pService.host.ID().String(),
+		Name:      name,
+		Addresses: addresses,
+		ExpiresAt: now.Add(time.Duration(hours) * time.Hour).Truncate(time.Second),
+		Token:     hex.EncodeToString(token),
+		Friend:    request.Friend == nil || *request.Friend,
+	}
+
+	code, err := encodeInvite(&invite, runningNodeKey(is.p2pService, is.database))
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.InviteRecord{
+		Token:     invite.Token,
+		Friend:    invite.Friend,
+		CreatedAt: now,
+		ExpiresAt: invite.ExpiresAt,
+	}
+	if err := is.database.SaveInvite(record); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🎟️ Created invite valid until %s", invite.ExpiresAt.Format(time.RFC3339))
+	return &models.CreateInviteResponse{Code: code, Invite: invite}, nil
+}
+
+// GetInvites returns the invites we created
+func (is *InviteService) GetInvites() ([]models.InviteRecord, error) {
+	return is.database.GetInvites()
+}
+
+// RevokeInvite deletes an invite so its token can't be redeemed
+func (is *InviteService) RevokeInvite(token string) error {
+	if err := is.database.DeleteInvite(token); err != nil {
+		return err
+	}
+	log.Printf("🎟️ Revoked invite %s", token)
+	return nil
+}
+
+// RedeemInvite connects to the node of an invite code and validates it. Unless the request turns it
+// off, the inviting node becomes a friend: right away when the invite includes friendship and its
+// token is accepted, otherwise through an ordinary friend request.
+func (is *InviteService) RedeemInvite(request models.RedeemInviteRequest) (*models.RedeemInviteResult, error) {
+	invite, err := DecodeInvite(request.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(invite.ExpiresAt) {
+		return nil, utils.NewValidationError("code", "the invite expired at "+invite.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if is.p2pService == nil {
+		return nil, fmt.Errorf("P2P service not available")
+	}
+	if invite.PeerID == is.p2pService.host.ID().String() {
+		return nil, utils.NewValidationError("code", "the invite was created by this node")
+	}
+
+	log.Printf("🎟️ Redeeming invite of %s (%s)", invite.Name, invite.PeerID)
+	nodeInfo, err := is.p2pService.ConnectToAddresses(invite.PeerID, invite.Addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RedeemInviteResult{
+		PeerID:   invite.PeerID,
+		PeerName: invite.Name,
+		NodeInfo: nodeInfo,
+	}
+	if request.AddFriend != nil && !*request.AddFriend {
+		return result, nil
+	}
+
+	result.FriendRequest, err = is.befriendInviter(invite, request.Message)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// befriendInviter redeems the token of a friendship invite, falling back to a friend request
+// when the invite doesn't include friendship, the node can't redeem invites or refuses the token
+func (is *InviteService) befriendInviter(invite *models.Invite, message string) (*models.FriendRequest, error) {
+	pid, err := peer.Decode(invite.PeerID)
+	if err != nil {
+		return nil, utils.NewValidationError("code", "invalid peer ID")
+	}
+
+	if invite.Friend && is.p2pService.GetPeerProtocol(pid).Supports(wire.CapabilityInvites) {
+		ourName, _ := is.database.GetSetting("name")
+		response, err := requestPeer[models.RedeemInviteMessageResponse](is.p2pService, pid, models.MessageTypeRedeemInvite,
+			models.RedeemInviteMessage{Token: invite.Token, PeerName: ourName})
+		if err == nil && response.Status == models.FriendRequestAccepted {
+			peerName := response.PeerName
+			if peerName == "" {
+				peerName = invite.Name
+			}
+			if err := is.recordInviteFriendship(invite.PeerID, peerName, models.FriendRequestOutgoing); err != nil {
+				return nil, err
+			}
+			return is.database.GetFriendRequest(invite.PeerID)
+		}
+		log.Printf("⚠️ Invite of %s not redeemed, sending a friend request instead: %v", invite.PeerID, err)
+	}
+
+	if is.friendService == nil {
+		return nil, fmt.Errorf("friend service not available")
+	}
+	return is.friendService.SendFriendRequest(invite.PeerID, invite.Name, message)
+}
+
+// handleRedeemInvite redeems the token of one of our invites for the peer presenting it.
+// A friendship invite makes the peer our friend, the invite being our consent.
+func (is *InviteService) handleRedeemInvite(peerID string, message *models.RedeemInviteMessage) (*models.RedeemInviteMessageResponse, error) {
+	invite, err := is.database.RedeemInvite(message.Token, peerID, time.Now().UTC())
+	if err != nil {
+		var notFoundErr utils.NotFoundError
+		var validationErr utils.ValidationError
+		switch {
+		case errors.As(err, &notFoundErr):
+			return nil, wire.NewError(wire.ErrCodeNotFound, "unknown invite")
+		case errors.As(err, &validationErr):
+			return nil, wire.NewError(wire.ErrCodeForbidden, "%s", validationErr.Message)
+		}
+		return nil, err
+	}
+	if !invite.Friend {
+		return nil, wire.NewError(wire.ErrCodeForbidden, "the invite does not include friendship")
+	}
+
+	if err := is.recordInviteFriendship(peerID, message.PeerName, models.FriendRequestIncoming); err != nil {
+		return nil, err
+	}
+
+	ourName, _ := is.database.GetSetting("name")
+	return &models.RedeemInviteMessageResponse{Status: models.FriendRequestAccepted, PeerName: ourName}, nil
+}
+
+// recordInviteFriendship stores a friendship agreed through an invite as an accepted friend request
+// both sides already know about
+func (is *InviteService) recordInviteFriendship(peerID, peerName, direction string) error {
+	if err := is.database.ConfirmFriend(peerID, peerName); err != nil {
+		return err
+	}
+
+	request := &models.FriendRequest{
+		PeerID:    peerID,
+		PeerName:  peerName,
+		Direction: direction,
+		Status:    models.FriendRequestAccepted,
+		Delivered: true,
+	}
+	if err := is.database.SaveFriendRequest(request); err != nil {
+		return err
+	}
+
+	log.Printf("🤝 %s (%s) is now a friend through an invite", peerName, peerID)
+	publishEvent(is.events, models.EventFriendAdded, models.FriendEvent{PeerID: peerID, PeerName: peerName})
+	return nil
+}
+
+// inviteAddresses returns the addresses others can reach us at, public ones first, without
+// loopback and IPv6 link-local addresses
+func (p *P2PService) inviteAddresses() []string {
+	var public, private []string
+	for _, addr := range p.host.Addrs() {
+		if manet.IsIPLoopback(addr) || manet.IsIP6LinkLocal(addr) {
+			continue
+		}
+		if manet.IsPublicAddr(addr) {
+			public = append(public, addr.String())
+		} else {
+			private = append(private, addr.String())
+		}
+	}
+
+	addresses := append(public, private...)
+	if len(addresses) > maxInviteAddresses {
+		addresses = addresses[:maxInviteAddresses]
+	}
+	return addresses
+}
+
+// encodeInvite writes an invite in its compact binary layout, signs it with our node key and
+// returns it as an invite code. The public key is only included when the peer ID doesn't embed it.
+func encodeInvite(invite *models.Invite, keys nodeKeySource) (string, error) {
+	pid, err := peer.Decode(invite.PeerID)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer ID: %w", err)
+	}
+	token, err := hex.DecodeString(invite.Token)
+	if err != nil {
+		return "", fmt.Errorf("invalid invite token: %w", err)
+	}
+
+	data := []byte{inviteFormatVersion}
+	data = appendInviteField(data, []byte(pid))
+	data = appendInviteField(data, []byte(invite.Name))
+	data = binary.AppendUvarint(data, uint64(invite.ExpiresAt.Unix()))
+	data = appendInviteField(data, token)
+	if invite.Friend {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+	data = binary.AppendUvarint(data, uint64(len(invite.Addresses)))
+	for _, address := range invite.Addresses {
+		addr, err := multiaddr.NewMultiaddr(address)
+		if err != nil {
+			return "", fmt.Errorf("invalid address %s: %w", address, err)
+		}
+		data = appendInviteField(data, addr.Bytes())
+	}
+
+	publicKey, signature, err := signWithNodeKey(keys, inviteSigningBytes(data))
+	if err != nil {
+		return "", err
+	}
+	if _, err := pid.ExtractPublicKey(); err == nil {
+		publicKey = nil
+	}
+	data = appendInviteField(data, publicKey)
+	data = appendInviteField(data, signature)
+
+	return inviteCodePrefix + inviteEncoding.EncodeToString(data), nil
+}
+
+// DecodeInvite reads an invite code and checks that the key of the inviting peer signed it.
+// Letter case and whitespace, such as line breaks added while sharing the code, are ignored.
+func DecodeInvite(code string) (*models.Invite, error) {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), ""))
+	if !strings.HasPrefix(code, inviteCodePrefix) {
+		return nil, utils.NewValidationError("code", "not an invite code, it should start with "+inviteCodePrefix)
+	}
+
+	data, err := inviteEncoding.DecodeString(code[len(inviteCodePrefix):])
+	if err != nil {
+		return nil, utils.NewValidationError("code", "the invite code is damaged")
+	}
+
+	reader := &inviteReader{data: data}
+	if version := reader.readByte(); version != inviteFormatVersion {
+		return nil, utils.NewValidationError("code", fmt.Sprintf("unsupported invite version %d", version))
+	}
+
+	peerIDBytes := reader.readField()
+	name := reader.readField()
+	expiresAt := reader.readUvarint()
+	token := reader.readField()
+	friend := reader.readByte()
+	count := reader.readUvarint()
+	if count > maxInviteAddresses {
+		return nil, utils.NewValidationError("code", "the invite lists too many addresses")
+	}
+
+	var addresses []string
+	for i := uint64(0); i < count && reader.err == nil; i++ {
+		addr, err := multiaddr.NewMultiaddrBytes(reader.readField())
+		if err != nil {
+			reader.err = err
+			break
+		}
+		addresses = append(addresses, addr.String())
+	}
+
+	signed := data[:reader.offset]
+	publicKey := reader.readField()
+	signature := reader.readField()
+	if reader.err != nil || reader.offset != len(data) || friend > 1 || len(token) != inviteTokenBytes {
+		return nil, utils.NewValidationError("code", "the invite code is damaged")
+	}
+
+	pid, err := peer.IDFromBytes(peerIDBytes)
+	if err != nil {
+		return nil, utils.NewValidationError("code", "the invite carries an invalid peer ID")
+	}
+	if len(publicKey) == 0 {
+		key, err := pid.ExtractPublicKey()
+		if err != nil {
+			return nil, utils.NewValidationError("code", "the invite lacks the public key of its peer")
+		}
+		if publicKey, err = crypto.MarshalPublicKey(key); err != nil {
+			return nil, utils.NewValidationError("code", "the invite lacks the public key of its peer")
+		}
+	}
+	if err := verifyPeerSignature(pid.String(), publicKey, inviteSigningBytes(signed), signature); err != nil {
+		return nil, utils.NewValidationError("code", "the invite signature is invalid")
+	}
+
+	return &models.Invite{
+		PeerID:    pid.String(),
+		Name:      string(name),
+		Addresses: addresses,
+		ExpiresAt: time.Unix(int64(expiresAt), 0).UTC(),
+		Token:     hex.EncodeToString(token),
+		Friend:    friend == 1,
+	}, nil
+}
+
+// inviteSigningBytes returns the bytes the signature of an invite covers
+func inviteSigningBytes(data []byte) []byte {
+	return signingBytes("old-school/invite/v1", string(data))
+}
+
+// appendInviteField appends a length-prefixed field to an encoded invite
+func appendInviteField(data, field []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(field)))
+	return append(data, field...)
+}
+
+// inviteReader reads the fields of an encoded invite, remembering the first error
+type inviteReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+// readByte reads a single byte
+func (r *inviteReader) readByte() byte {
+	if r.err != nil || r.offset >= len(r.data) {
+		r.err = fmt.Errorf("invite truncated")
+		return 0
+	}
+	value := r.data[r.offset]
+	r.offset++
+	return value
+}
+
+// readUvarint reads an unsigned varint
+func (r *inviteReader) readUvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(r.data[r.offset:])
+	if n <= 0 {
+		r.err = fmt.Errorf("invalid varint")
+		return 0
+	}
+	r.offset += n
+	return value
+}
+
+// readField reads a length-prefixed field
+func (r *inviteReader) readField() []byte {
+	length := r.readUvarint()
+	if r.err != nil {
+		return nil
+	}
+	if length > uint64(len(r.data)-r.offset) {
+		r.err = fmt.Errorf("invite truncated")
+		return nil
+	}
+	value := r.data[r.offset : r.offset+int(length)]
+	r.offset += int(length)
+	return value
+}
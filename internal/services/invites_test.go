@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"old-school/internal/models"
+)
+
+// staticNodeKey is a nodeKeySource holding a fixed key
+type staticNodeKey struct {
+	key crypto.PrivKey
+}
+
+func (k staticNodeKey) GetNodePrivateKey() (crypto.PrivKey, error) {
+	return k.key, nil
+}
+
+// newTestInvite returns an invite of a fresh peer with the given key type, and that peer's key
+func newTestInvite(t *testing.T, keyType, bits int, addresses int) (*models.Invite, staticNodeKey) {
+	t.Helper()
+
+	key, _, err := crypto.GenerateKeyPair(keyType, bits)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+
+	invite := &models.Invite{
+		PeerID:    pid.String(),
+		Name:      "Alice",
+		ExpiresAt: time.Now().Add(24 * time.Hour).Truncate(time.Second).UTC(),
+		Token:     strings.Repeat("ab", inviteTokenBytes),
+		Friend:    true,
+	}
+	for i := 0; i < addresses; i++ {
+		invite.Addresses = append(invite.Addresses, fmt.Sprintf("/ip4/203.0.113.%d/tcp/4001", i+1))
+	}
+	return invite, staticNodeKey{key: key}
+}
+
+// mutateInvite decodes the payload of an invite code, lets mutate change it and encodes it again
+func mutateInvite(t *testing.T, code string, mutate func(data []byte) []byte) string {
+	t.Helper()
+
+	data, err := inviteEncoding.DecodeString(strings.TrimPrefix(code, inviteCodePrefix))
+	require.NoError(t, err)
+	return inviteCodePrefix + inviteEncoding.EncodeToString(mutate(data))
+}
+
+// embeddedInviteKey returns the public key field of an invite code
+func embeddedInviteKey(t *testing.T, code string) []byte {
+	t.Helper()
+
+	data, err := inviteEncoding.DecodeString(strings.TrimPrefix(code, inviteCodePrefix))
+	require.NoError(t, err)
+
+	reader := &inviteReader{data: data}
+	reader.readByte()    // version
+	reader.readField()   // peer ID
+	reader.readField()   // name
+	reader.readUvarint() // expiry
+	reader.readField()   // token
+	reader.readByte()    // friend
+	for count := reader.readUvarint(); count > 0; count-- {
+		reader.readField()
+	}
+	publicKey := reader.readField()
+	require.NoError(t, reader.err)
+	return publicKey
+}
+
+func TestInviteRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyType   int
+		bits      int
+		embedsKey bool // the peer ID doesn't contain the public key
+		format    func(code string) string
+	}{
+		{name: "ed25519", keyType: crypto.Ed25519, bits: -1, format: func(code string) string { return code }},
+		{name: "rsa embeds its public key", keyType: crypto.RSA, bits: 2048, embedsKey: true, format: func(code string) string { return code }},
+		{name: "lower case with line breaks", keyType: crypto.Ed25519, bits: -1, format: func(code string) string {
+			return strings.ToLower(code[:20]) + "\n" + code[20:40] + " \n" + strings.ToLower(code[40:])
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invite, keys := newTestInvite(t, tt.keyType, tt.bits, 2)
+
+			code, err := encodeInvite(invite, keys)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(code, inviteCodePrefix))
+
+			publicKey, err := crypto.MarshalPublicKey(keys.key.GetPublic())
+			require.NoError(t, err)
+			if tt.embedsKey {
+				assert.Equal(t, publicKey, embeddedInviteKey(t, code))
+			} else {
+				assert.Empty(t, embeddedInviteKey(t, code))
+			}
+
+			decoded, err := DecodeInvite(tt.format(code))
+			require.NoError(t, err)
+			assert.Equal(t, invite, decoded)
+		})
+	}
+}
+
+func TestDecodeInviteRejectsDamagedCodes(t *testing.T) {
+	invite, keys := newTestInvite(t, crypto.Ed25519, -1, 2)
+	code, err := encodeInvite(invite, keys)
+	require.NoError(t, err)
+
+	tooMany, tooManyKeys := newTestInvite(t, crypto.Ed25519, -1, maxInviteAddresses+1)
+	tooManyCode, err := encodeInvite(tooMany, tooManyKeys)
+	require.NoError(t, err)
+
+	rsaInvite, rsaKeys := newTestInvite(t, crypto.RSA, 2048, 1)
+	rsaCode, err := encodeInvite(rsaInvite, rsaKeys)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{name: "missing prefix", code: strings.TrimPrefix(code, inviteCodePrefix)},
+		{name: "not base32", code: code + "!"},
+		{name: "truncated", code: code[:len(code)-8]},
+		{name: "truncated to the header", code: code[:len(inviteCodePrefix)+4]},
+		{name: "trailing data", code: mutateInvite(t, code, func(data []byte) []byte { return append(data, 0) })},
+		{name: "unknown version", code: mutateInvite(t, code, func(data []byte) []byte {
+			data[0] = inviteFormatVersion + 1
+			return data
+		})},
+		{name: "tampered name", code: mutateInvite(t, code, func(data []byte) []byte {
+			i := strings.Index(string(data), invite.Name)
+			data[i] ^= 0x20
+			return data
+		})},
+		{name: "tampered signature", code: mutateInvite(t, code, func(data []byte) []byte {
+			data[len(data)-1] ^= 0x01
+			return data
+		})},
+		{name: "too many addresses", code: tooManyCode},
+		{name: "rsa key of another peer", code: mutateInvite(t, rsaCode, func(data []byte) []byte {
+			// The embedded key and signature stay those of the inviter while the peer ID changes
+			ownID, err := peer.Decode(rsaInvite.PeerID)
+			require.NoError(t, err)
+			otherID, err := peer.IDFromPrivateKey(mustRSAKey(t))
+			require.NoError(t, err)
+			return []byte(strings.Replace(string(data), string(ownID), string(otherID), 1))
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeInvite(tt.code)
+			assert.Error(t, err)
+			assert.Nil(t, decoded)
+		})
+	}
+
+	// Every single flipped byte of the payload is caught
+	data, err := inviteEncoding.DecodeString(strings.TrimPrefix(code, inviteCodePrefix))
+	require.NoError(t, err)
+	for i := range data {
+		tampered := mutateInvite(t, code, func(data []byte) []byte {
+			data[i] ^= 0x01
+			return data
+		})
+		_, err := DecodeInvite(tampered)
+		assert.Error(t, err, "byte %d flipped", i)
+	}
+}
+
+func mustRSAKey(t *testing.T) crypto.PrivKey {
+	t.Helper()
+	key, _, err := crypto.GenerateKeyPair(crypto.RSA, 2048)
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/models"
+)
+
+const (
+	// keyRotationsSetting is the settings key holding our key rotation statements as JSON, oldest first
+	keyRotationsSetting = "key_rotations"
+
+	// maxKeyRotationChain bounds how many rotation statements of a peer are followed back
+	maxKeyRotationChain = 32
+)
+
+// RotateKey replaces our node key with a new Ed25519 key. The rotation statement is signed by the
+// old and the new key and sent during identify, so friends move our rows to the new peer ID.
+// The running node keeps its old key until the next start.
+func (is *IdentityService) RotateKey() (*models.RotateKeyResult, error) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	oldID, err := is.database.GetNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %w", err)
+	}
+
+	newKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	newID, err := peer.IDFromPrivateKey(newKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+
+	rotation := &models.KeyRotation{
+		OldPeerID: oldID.String(),
+		NewPeerID: newID.String(),
+		RotatedAt: time.Now().UTC(),
+	}
+	data := keyRotationSigningBytes(rotation)
+
+	rotation.OldPublicKey, rotation.OldSignature, err = signWithNodeKey(is.database, data)
+	if err != nil {
+		return nil, err
+	}
+	rotation.NewPublicKey, err = crypto.MarshalPublicKey(newKey.GetPublic())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	rotation.NewSignature, err = newKey.Sign(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign key rotation: %w", err)
+	}
+
+	newKeyBytes, err := crypto.MarshalPrivateKey(newKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	// The statement is stored before the key so a node never runs a key it can't prove
+	rotations := append(is.ownKeyRotations(), *rotation)
+	encoded, err := json.Marshal(rotations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key rotations: %w", err)
+	}
+	if err := is.database.SetSetting(keyRotationsSetting, string(encoded)); err != nil {
+		return nil, err
+	}
+	if err := is.database.SetSetting("private_key", string(newKeyBytes)); err != nil {
+		return nil, err
+	}
+	if err := is.database.SetSetting("node_id", newID.String()); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔑 Rotated node key from %s to %s, restart the node to use it", oldID, newID)
+	return &models.RotateKeyResult{Rotation: rotation, RestartRequired: true}, nil
+}
+
+// GetKeyRotations returns our peer ID, key type and key rotation statements
+func (is *IdentityService) GetKeyRotations() (*models.KeyRotationsResponse, error) {
+	privateKey, err := is.database.GetNodePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node key: %w", err)
+	}
+	nodeID, err := peer.IDFromPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+
+	rotations := is.ownKeyRotations()
+	if rotations == nil {
+		rotations = []models.KeyRotation{}
+	}
+
+	return &models.KeyRotationsResponse{
+		PeerID:    nodeID.String(),
+		KeyType:   privateKey.Type().String(),
+		Rotations: rotations,
+	}, nil
+}
+
+// MigrateOwnKeyRotations moves our own posts, comments and files stored under one of our previous
+// peer IDs to the current ID. It runs at startup, when the stored key is the one the host uses,
+// since until then the running node still serves its rows under the old ID, and re-signs the outbox.
+func (is *IdentityService) MigrateOwnKeyRotations() {
+	rotations := is.ownKeyRotations()
+	if len(rotations) == 0 {
+		return
+	}
+
+	current := rotations[len(rotations)-1].NewPeerID
+	for _, rotation := range rotations {
+		if _, err := is.database.MigratePeerID(rotation.OldPeerID, current); err != nil {
+			log.Printf("⚠️ Failed to migrate data of our previous peer ID %s: %v", rotation.OldPeerID, err)
+		}
+	}
+
+	is.resignOutbox(current)
+}
+
+// resignOutbox signs undelivered messages again whose signature doesn't verify for our current
+// peer ID, those queued under a previous key, so they are not refused when they are finally sent
+func (is *IdentityService) resignOutbox(nodeID string) {
+	pending, err := is.database.GetPendingMessages("")
+	if err != nil {
+		log.Printf("⚠️ Failed to get the outbox to re-sign: %v", err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	publicKey, err := nodePublicKey(is.database)
+	if err != nil {
+		log.Printf("⚠️ Failed to re-sign the outbox: %v", err)
+		return
+	}
+
+	resigned := 0
+	for _, message := range pending {
+		payload := &models.DirectMessagePayload{
+			MessageID: message.MessageID,
+			From:      nodeID,
+			To:        message.PeerID,
+			Body:      message.Body,
+			SentAt:    message.SentAt.UnixMilli(),
+		}
+		data := messageSigningBytes(payload)
+		if verifyPeerSignature(nodeID, publicKey, data, message.Signature) == nil {
+			continue
+		}
+
+		_, signature, err := signWithNodeKey(is.database, data)
+		if err != nil {
+			log.Printf("⚠️ Failed to re-sign message %s: %v", message.MessageID, err)
+			continue
+		}
+		if err := is.database.UpdateMessageSignature(message.MessageID, signature); err != nil {
+			log.Printf("⚠️ Failed to store the new signature of message %s: %v", message.MessageID, err)
+			continue
+		}
+		resigned++
+	}
+
+	if resigned > 0 {
+		log.Printf("🔑 Re-signed %d queued message(s) with our current key", resigned)
+	}
+}
+
+// ApplyPeerKeyRotations follows the rotation statements a peer sent back from its current ID and
+// moves what we stored under each verified previous ID, including its downloads, to the current one
+func (is *IdentityService) ApplyPeerKeyRotations(peerID string, rotations []models.KeyRotation) {
+	if len(rotations) == 0 {
+		return
+	}
+
+	byNewID := make(map[string]models.KeyRotation, len(rotations))
+	for _, rotation := range rotations {
+		byNewID[rotation.NewPeerID] = rotation
+	}
+
+	current := peerID
+	for i := 0; i < maxKeyRotationChain; i++ {
+		rotation, ok := byNewID[current]
+		if !ok {
+			return
+		}
+		if err := verifyKeyRotation(&rotation); err != nil {
+			log.Printf("⚠️ Ignoring key rotation of %s: %v", peerID, err)
+			return
+		}
+
+		known, err := is.database.MigratePeerID(rotation.OldPeerID, peerID)
+		if err != nil {
+			log.Printf("⚠️ Failed to migrate %s to %s: %v", rotation.OldPeerID, peerID, err)
+			return
+		}
+		if known {
+			is.moveDownloads(rotation.OldPeerID, peerID)
+			log.Printf("🔑 Peer %s rotated its key, now known as %s", rotation.OldPeerID, peerID)
+			publishEvent(is.events, models.EventPeerKeyRotated, models.PeerEvent{PeerID: peerID})
+		}
+
+		current = rotation.OldPeerID
+	}
+}
+
+// ownKeyRotations returns our key rotation statements, oldest first. Statements that don't lead
+// to our current peer ID, such as those of an identity replaced by a restored backup, are ignored.
+func (is *IdentityService) ownKeyRotations() []models.KeyRotation {
+	value, err := is.database.GetSetting(keyRotationsSetting)
+	if err != nil || value == "" {
+		return nil
+	}
+
+	var rotations []models.KeyRotation
+	if err := json.Unmarshal([]byte(value), &rotations); err != nil {
+		log.Printf("⚠️ Ignoring unreadable key rotations: %v", err)
+		return nil
+	}
+	if len(rotations) == 0 {
+		return nil
+	}
+
+	nodeID, err := is.database.GetNodeID()
+	if err != nil || rotations[len(rotations)-1].NewPeerID != nodeID.String() {
+		return nil
+	}
+	return rotations
+}
+
+// moveDownloads renames the download directory of a peer's old ID unless the new ID has one
+func (is *IdentityService) moveDownloads(oldPeerID, newPeerID string) {
+	if is.pathManager == nil {
+		return
+	}
+
+	oldPath := is.pathManager.GetPeerDownloadPath(oldPeerID)
+	newPath := is.pathManager.GetPeerDownloadPath(newPeerID)
+	if _, err := os.Stat(oldPath); err != nil {
+		return
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		log.Printf("⚠️ Failed to move downloads of %s to %s: %v", oldPeerID, newPeerID, err)
+	}
+}
+
+// verifyKeyRotation checks that both the old and the new key signed a rotation statement
+func verifyKeyRotation(rotation *models.KeyRotation) error {
+	if rotation.OldPeerID == rotation.NewPeerID {
+		return fmt.Errorf("rotation to the same peer ID")
+	}
+
+	data := keyRotationSigningBytes(rotation)
+	if err := verifyPeerSignature(rotation.OldPeerID, rotation.OldPublicKey, data, rotation.OldSignature); err != nil {
+		return fmt.Errorf("invalid old key signature: %w", err)
+	}
+	if err := verifyPeerSignature(rotation.NewPeerID, rotation.NewPublicKey, data, rotation.NewSignature); err != nil {
+		return fmt.Errorf("invalid new key signature: %w", err)
+	}
+	return nil
+}
+
+// keyRotationSigningBytes returns the bytes both signatures of a rotation statement cover
+func keyRotationSigningBytes(rotation *models.KeyRotation) []byte {
+	return signingBytes("old-school/key-rotation/v1",
+		rotation.OldPeerID, rotation.NewPeerID, strconv.FormatInt(rotation.RotatedAt.UnixMilli(), 10))
+}
+
+// keyRotationsForIdentify returns our rotation statements for the identify message
+func (p *P2PService) keyRotationsForIdentify() []models.KeyRotation {
+	if p.container == nil || p.container.GetIdentityService() == nil {
+		return nil
+	}
+	return p.container.GetIdentityService().ownKeyRotations()
+}
+
+// applyPeerKeyRotations moves our rows of a peer's previous IDs to the ID it identified with
+func (p *P2PService) applyPeerKeyRotations(peerID peer.ID, rotations []models.KeyRotation) {
+	if len(rotations) == 0 || p.container == nil || p.container.GetIdentityService() == nil {
+		return
+	}
+	p.container.GetIdentityService().ApplyPeerKeyRotations(peerID.String(), rotations)
+}
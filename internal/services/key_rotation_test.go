@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"old-school/internal/models"
+)
+
+// rotationKey is a key taking part in a test key rotation
+type rotationKey struct {
+	key       crypto.PrivKey
+	id        peer.ID
+	publicKey []byte
+}
+
+func newRotationKey(t *testing.T) rotationKey {
+	t.Helper()
+
+	key, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	publicKey, err := crypto.MarshalPublicKey(key.GetPublic())
+	require.NoError(t, err)
+	return rotationKey{key: key, id: id, publicKey: publicKey}
+}
+
+// sign signs the statement of a rotation with the key
+func (k rotationKey) sign(t *testing.T, rotation *models.KeyRotation) []byte {
+	t.Helper()
+
+	signature, err := k.key.Sign(keyRotationSigningBytes(rotation))
+	require.NoError(t, err)
+	return signature
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	oldKey := newRotationKey(t)
+	newKey := newRotationKey(t)
+	otherKey := newRotationKey(t)
+
+	// signed returns a rotation from the old to the new key, signed by both
+	signed := func() *models.KeyRotation {
+		rotation := &models.KeyRotation{
+			OldPeerID:    oldKey.id.String(),
+			NewPeerID:    newKey.id.String(),
+			RotatedAt:    time.Now().UTC(),
+			OldPublicKey: oldKey.publicKey,
+			NewPublicKey: newKey.publicKey,
+		}
+		rotation.OldSignature = oldKey.sign(t, rotation)
+		rotation.NewSignature = newKey.sign(t, rotation)
+		return rotation
+	}
+
+	tests := []struct {
+		name    string
+		change  func(rotation *models.KeyRotation)
+		wantErr bool
+	}{
+		{name: "signed by both keys", change: func(rotation *models.KeyRotation) {}},
+		{name: "signed only by the old key", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.NewSignature = nil
+		}},
+		{name: "signed only by the new key", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.OldSignature = nil
+		}},
+		{name: "old key signed in place of the new one", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.NewPublicKey = oldKey.publicKey
+			rotation.NewSignature = rotation.OldSignature
+		}},
+		{name: "new key signed in place of the old one", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.OldPublicKey = newKey.publicKey
+			rotation.OldSignature = rotation.NewSignature
+		}},
+		{name: "hijacked by another key", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.NewPeerID = otherKey.id.String()
+			rotation.NewPublicKey = otherKey.publicKey
+			rotation.NewSignature = otherKey.sign(t, rotation)
+		}},
+		{name: "signed by another old key", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.OldPublicKey = otherKey.publicKey
+			rotation.OldSignature = otherKey.sign(t, rotation)
+		}},
+		{name: "rotation time changed after signing", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.RotatedAt = rotation.RotatedAt.Add(time.Second)
+		}},
+		{name: "rotation to the same peer ID", wantErr: true, change: func(rotation *models.KeyRotation) {
+			rotation.NewPeerID = rotation.OldPeerID
+			rotation.NewPublicKey = oldKey.publicKey
+			rotation.NewSignature = oldKey.sign(t, rotation)
+			rotation.OldSignature = oldKey.sign(t, rotation)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rotation := signed()
+			tt.change(rotation)
+
+			err := verifyKeyRotation(rotation)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"old-school/internal/models"
+	"old-school/internal/wire"
+)
+
+// codecForProtocol returns the envelope codec selected by an app protocol ID
+func codecForProtocol(protocolID protocol.ID) (wire.Codec, error) {
+	switch protocolID {
+	case protocol.ID(AppProtocolCBOR):
+		return wire.CodecFor(wire.EncodingCBOR)
+	case protocol.ID(AppProtocolJSON):
+		return wire.CodecFor(wire.EncodingJSON)
+	default:
+		return nil, fmt.Errorf("no envelope codec for protocol %s", protocolID)
+	}
+}
+
+// appProtocolsFor returns the app protocols to offer a peer, preferred first.
+// All of them are always offered so multistream-select still finds a common one if the peer changed since identification.
+func (p *P2PService) appProtocolsFor(peerID peer.ID) []protocol.ID {
+	peerProtocol := p.GetPeerProtocol(peerID)
+
+	switch {
+	case peerProtocol != nil && peerProtocol.Version < wire.ProtocolVersion:
+		return []protocol.ID{protocol.ID(AppProtocol), protocol.ID(AppProtocolCBOR), protocol.ID(AppProtocolJSON)}
+	case peerProtocol != nil && peerProtocol.Encoding == wire.EncodingJSON:
+		return []protocol.ID{protocol.ID(AppProtocolJSON), protocol.ID(AppProtocolCBOR), protocol.ID(AppProtocol)}
+	default:
+		return []protocol.ID{protocol.ID(AppProtocolCBOR), protocol.ID(AppProtocolJSON), protocol.ID(AppProtocol)}
+	}
+}
+
+// handleEnvelopeStream serves requests of the typed envelope protocol
+func (p *P2PService) handleEnvelopeStream(stream network.Stream) {
+	defer stream.Close()
+
+	peerID := stream.Conn().RemotePeer()
+
+	// Store peer information for incoming connections
+	p.storePeerInfo(peerID, "inbound")
+
+	codec, err := codecForProtocol(stream.Protocol())
+	if err != nil {
+		log.Printf("Failed to select codec: %v", err)
+		return
+	}
+
+	var request wire.Envelope
+	decoder := codec.NewDecoder(io.LimitReader(stream, wire.MaxRequestSize))
+	if err := decoder.Decode(&request); err != nil {
+		log.Printf("Failed to decode %s envelope: %v", codec.Name(), err)
+		return
+	}
+
+	log.Printf("Received message type: %s (v%d, %s) from %s", request.Type, request.Version, codec.Name(), peerID)
+
+	response := wire.Envelope{
+		Version:   wire.ProtocolVersion,
+		RequestID: request.RequestID,
+	}
+
+	if request.Version > wire.ProtocolVersion {
+		response.Error = wire.NewError(wire.ErrCodeUnsupportedVersion, "protocol version %d not supported, highest is %d", request.Version, wire.ProtocolVersion)
+	} else {
+		decode := func(v interface{}) error {
+			if len(request.Payload) == 0 {
+				return nil
+			}
+			return codec.Unmarshal(request.Payload, v)
+		}
+
+		responseType, payload, err := p.router.RouteMessage(request.Type, decode, peerID)
+		response.Type = responseType
+
+		var wireErr *wire.Error
+		if errors.As(err, &wireErr) {
+			response.Error = wireErr
+		} else if err != nil {
+			response.Error = wire.NewError(wire.ErrCodeInternal, "%v", err)
+		} else {
+			payloadData, err := codec.Marshal(payload)
+			if err != nil {
+				log.Printf("Failed to encode %s payload: %v", responseType, err)
+				response.Error = wire.NewError(wire.ErrCodeInternal, "failed to encode response")
+			} else {
+				response.Payload = payloadData
+			}
+		}
+	}
+
+	// Send response
+	stream.SetWriteDeadline(time.Now().Add(p.messageTimeout(request.Type)))
+	encoder := codec.NewEncoder(stream)
+	if err := encoder.Encode(&response); err != nil {
+		log.Printf("Failed to send response: %v", err)
+	}
+}
+
+// sendMessage sends a typed request to a peer and decodes the typed response into response.
+// Peers that speak the envelope protocol get a versioned envelope in the negotiated encoding,
+// older peers get the legacy JSON P2PMessage.
+func (p *P2PService) sendMessage(peerID peer.ID, msgType, responseType string, request, response interface{}, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(p.ctx, timeout)
+	defer cancel()
+
+	stream, err := p.host.NewStream(ctx, peerID, p.appProtocolsFor(peerID)...)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	if stream.Protocol() == protocol.ID(AppProtocol) {
+		return p.sendLegacyMessage(stream, msgType, responseType, request, response)
+	}
+
+	codec, err := codecForProtocol(stream.Protocol())
+	if err != nil {
+		return err
+	}
+
+	payloadData, err := codec.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", msgType, err)
+	}
+
+	envelope := wire.Envelope{
+		Version:   wire.ProtocolVersion,
+		RequestID: p.requestCounter.Add(1),
+		Type:      msgType,
+		Payload:   payloadData,
+	}
+
+	encoder := codec.NewEncoder(stream)
+	if err := encoder.Encode(&envelope); err != nil {
+		return fmt.Errorf("failed to send %s request: %w", msgType, err)
+	}
+
+	// Read response
+	var reply wire.Envelope
+	decoder := codec.NewDecoder(stream)
+	if err := decoder.Decode(&reply); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if reply.RequestID != envelope.RequestID {
+		return fmt.Errorf("response for request %d does not match request %d", reply.RequestID, envelope.RequestID)
+	}
+
+	if reply.Error != nil {
+		return reply.Error
+	}
+
+	if reply.Type != responseType {
+		return fmt.Errorf("unexpected response type: %s", reply.Type)
+	}
+
+	if response == nil || len(reply.Payload) == 0 {
+		return nil
+	}
+
+	if err := codec.Unmarshal(reply.Payload, response); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", msgType, err)
+	}
+
+	return nil
+}
+
+// sendLegacyMessage exchanges a request and response over the legacy JSON P2PMessage protocol
+func (p *P2PService) sendLegacyMessage(stream network.Stream, msgType, responseType string, request, response interface{}) error {
+	msg := models.P2PMessage{
+		Type:    msgType,
+		Payload: request,
+	}
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(msg); err != nil {
+		return fmt.Errorf("failed to send %s request: %w", msgType, err)
+	}
+
+	// Read response
+	var reply models.P2PMessage
+	decoder := json.NewDecoder(stream)
+	if err := decoder.Decode(&reply); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if reply.Type != responseType {
+		return fmt.Errorf("unexpected response type: %s", reply.Type)
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	// Parse response payload
+	responseData, err := json.Marshal(reply.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response payload: %w", err)
+	}
+
+	if err := json.Unmarshal(responseData, response); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", msgType, err)
+	}
+
+	return nil
+}
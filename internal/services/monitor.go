@@ -8,13 +8,16 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
 )
 
 // MonitorService handles file system monitoring for the space184 directory
 type MonitorService struct {
 	watcher          *fsnotify.Watcher
 	directoryService DirectoryServiceInterface
-	appService       *AppService
+	events           interfaces.EventPublisher
 	ctx              context.Context
 	cancel           context.CancelFunc
 	lastScanTime     time.Time
@@ -24,7 +27,7 @@ type MonitorService struct {
 }
 
 // NewMonitorService creates a new file system monitor
-func NewMonitorService(directoryService DirectoryServiceInterface, appService *AppService) (*MonitorService, error) {
+func NewMonitorService(directoryService DirectoryServiceInterface, events interfaces.EventPublisher) (*MonitorService, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -35,7 +38,7 @@ func NewMonitorService(directoryService DirectoryServiceInterface, appService *A
 	monitor := &MonitorService{
 		watcher:          watcher,
 		directoryService: directoryService,
-		appService:       appService,
+		events:           events,
 		ctx:              ctx,
 		cancel:           cancel,
 		debounceDuration: 500 * time.Millisecond, // Debounce rapid file changes
@@ -201,8 +204,8 @@ func (m *MonitorService) performScan(reason string) error {
 		return err
 	}
 
-	// Update app state
-	m.appService.SetFolderInfo(folderInfo)
+	// Let the app state and other subscribers pick up the new scan
+	publishEvent(m.events, models.EventFolderScanned, folderInfo)
 
 	fileCount := len(folderInfo.Files)
 	if fileCount == 0 {
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+const (
+	// DefaultNotificationPageSize is the number of notifications returned per page unless asked otherwise
+	DefaultNotificationPageSize = 50
+
+	// MaxNotificationPageSize bounds the number of notifications returned per page
+	MaxNotificationPageSize = 200
+
+	// notificationSnippetLength is how much of a message or post a notification quotes
+	notificationSnippetLength = 140
+
+	// notificationsMutedSetting is the settings key holding the muted notification types, comma separated
+	notificationsMutedSetting = "notifications_muted"
+)
+
+// NotificationService records what friends did into the notification inbox. It is fed by the
+// events the other services publish for peer validation, friend file syncs and incoming P2P messages.
+type NotificationService struct {
+	database   interfaces.DatabaseService
+	p2pService *P2PService
+	events     interfaces.EventPublisher
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(database interfaces.DatabaseService, p2pService *P2PService, events interfaces.EventPublisher) *NotificationService {
+	return &NotificationService{
+		database:   database,
+		p2pService: p2pService,
+		events:     events,
+	}
+}
+
+// WatchEvents subscribes to the events that produce notifications
+func (ns *NotificationService) WatchEvents(events *EventBus) {
+	events.SubscribeEvents(models.EventFriendOnline, func(event models.Event) {
+		if friendEvent, ok := event.Data.(models.FriendEvent); ok {
+			ns.notify(models.NotificationFriendOnline, friendEvent.PeerID, friendEvent.PeerName, "came online", "")
+		}
+	})
+
+	events.SubscribeEvents(models.EventFriendRequestReceived, func(event models.Event) {
+		if request, ok := event.Data.(models.FriendRequest); ok {
+			ns.notify(models.NotificationFriendRequest, request.PeerID, request.PeerName, withSnippet("sent you a friend request", request.Message), "")
+		}
+	})
+
+	events.SubscribeEvents(models.EventFriendRequestAccepted, func(event models.Event) {
+		if request, ok := event.Data.(models.FriendRequest); ok && request.Direction == models.FriendRequestOutgoing {
+			ns.notify(models.NotificationFriendAccepted, request.PeerID, request.PeerName, "accepted your friend request", "")
+		}
+	})
+
+	events.SubscribeEvents(models.EventFriendFilesAdded, func(event models.Event) {
+		filesEvent, ok := event.Data.(models.FriendFilesEvent)
+		if !ok || filesEvent.Count == 0 {
+			return
+		}
+
+		if filesEvent.Count == 1 {
+			ns.notify(models.NotificationFriendFiles, filesEvent.PeerID, filesEvent.PeerName, "shared "+filesEvent.FilePath, filesEvent.FilePath)
+			return
+		}
+		ns.notify(models.NotificationFriendFiles, filesEvent.PeerID, filesEvent.PeerName, fmt.Sprintf("shared %d new files", filesEvent.Count), "")
+	})
+
+	events.SubscribeEvents(models.EventMessageReceived, func(event models.Event) {
+		if messageEvent, ok := event.Data.(models.MessageEvent); ok && len(messageEvent.MessageIDs) > 0 {
+			ns.notify(models.NotificationMessage, messageEvent.PeerID, "", withSnippet("sent you a message", messageEvent.Body), messageEvent.MessageIDs[0])
+		}
+	})
+
+	events.SubscribeEvents(models.EventPostReceived, func(event models.Event) {
+		if postEvent, ok := event.Data.(models.PostEvent); ok {
+			ns.notify(models.NotificationPost, postEvent.AuthorID, postEvent.AuthorName, "posted something new", postEvent.PostID)
+		}
+	})
+
+	events.SubscribeEvents(models.EventCommentReceived, func(event models.Event) {
+		commentEvent, ok := event.Data.(models.CommentEvent)
+		if !ok || (ns.p2pService != nil && commentEvent.AuthorID == ns.p2pService.host.ID().String()) {
+			return
+		}
+
+		body := "commented on " + commentEvent.FilePath
+		if commentEvent.Kind == models.CommentKindReaction {
+			body = "reacted to " + commentEvent.FilePath
+		}
+		ns.notify(models.NotificationComment, commentEvent.AuthorID, "", body, commentEvent.CommentID)
+	})
+}
+
+// GetNotifications returns a page of notifications, newest first, along with the unread count
+func (ns *NotificationService) GetNotifications(unreadOnly bool, beforeID, limit int) (*models.NotificationsResponse, error) {
+	if limit <= 0 {
+		limit = DefaultNotificationPageSize
+	}
+	if limit > MaxNotificationPageSize {
+		limit = MaxNotificationPageSize
+	}
+
+	notifications, err := ns.database.GetNotifications(unreadOnly, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(notifications) > limit
+	if hasMore {
+		notifications = notifications[:limit]
+	}
+
+	unread, err := ns.database.CountUnreadNotifications()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.NotificationsResponse{
+		Notifications: notifications,
+		Count:         len(notifications),
+		Unread:        unread,
+		HasMore:       hasMore,
+	}, nil
+}
+
+// MarkRead marks one notification read
+func (ns *NotificationService) MarkRead(id int) error {
+	if err := ns.database.MarkNotificationRead(id); err != nil {
+		return err
+	}
+	ns.publishUnreadCount()
+	return nil
+}
+
+// MarkAllRead marks every notification read, returning how many were unread
+func (ns *NotificationService) MarkAllRead() (int, error) {
+	count, err := ns.database.MarkAllNotificationsRead()
+	if err != nil {
+		return 0, err
+	}
+	ns.publishUnreadCount()
+	return count, nil
+}
+
+// DeleteNotification removes a notification from the inbox
+func (ns *NotificationService) DeleteNotification(id int) error {
+	if err := ns.database.DeleteNotification(id); err != nil {
+		return err
+	}
+	ns.publishUnreadCount()
+	return nil
+}
+
+// GetSettings returns the muted notification types
+func (ns *NotificationService) GetSettings() *models.NotificationSettings {
+	muted := ns.mutedTypes()
+	settings := &models.NotificationSettings{Muted: []string{}}
+	for _, notificationType := range models.NotificationTypes {
+		if muted[notificationType] {
+			settings.Muted = append(settings.Muted, notificationType)
+		}
+	}
+	return settings
+}
+
+// UpdateSettings replaces the muted notification types
+func (ns *NotificationService) UpdateSettings(settings models.NotificationSettings) (*models.NotificationSettings, error) {
+	for _, notificationType := range settings.Muted {
+		if !isNotificationType(notificationType) {
+			return nil, utils.NewValidationError("muted", fmt.Sprintf("unknown notification type %q", notificationType))
+		}
+	}
+
+	if err := ns.database.SetSetting(notificationsMutedSetting, strings.Join(settings.Muted, ",")); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔕 Muted notification types: %v", settings.Muted)
+	return ns.GetSettings(), nil
+}
+
+// notify stores a notification unless its type is muted and publishes it for the UI
+func (ns *NotificationService) notify(notificationType, peerID, peerName, body, ref string) {
+	if ns.mutedTypes()[notificationType] {
+		return
+	}
+
+	if peerName == "" {
+		peerName = ns.peerName(peerID)
+	}
+
+	notification := &models.Notification{
+		Type:      notificationType,
+		PeerID:    peerID,
+		PeerName:  peerName,
+		Body:      body,
+		Ref:       ref,
+		CreatedAt: time.Now(),
+	}
+	if err := ns.database.SaveNotification(notification); err != nil {
+		log.Printf("⚠️ Failed to save %s notification from %s: %v", notificationType, peerID, err)
+		return
+	}
+
+	publishEvent(ns.events, models.EventNotificationCreated, *notification)
+}
+
+// publishUnreadCount tells the UI the unread count changed
+func (ns *NotificationService) publishUnreadCount() {
+	unread, err := ns.database.CountUnreadNotifications()
+	if err != nil {
+		log.Printf("⚠️ Failed to count unread notifications: %v", err)
+		return
+	}
+	publishEvent(ns.events, models.EventNotificationsRead, models.NotificationsReadEvent{Unread: unread})
+}
+
+// mutedTypes returns the muted notification types as a set
+func (ns *NotificationService) mutedTypes() map[string]bool {
+	muted := make(map[string]bool)
+
+	// The setting doesn't exist until something is muted
+	value, err := ns.database.GetSetting(notificationsMutedSetting)
+	if err != nil || value == "" {
+		return muted
+	}
+
+	for _, notificationType := range strings.Split(value, ",") {
+		muted[strings.TrimSpace(notificationType)] = true
+	}
+	return muted
+}
+
+// peerName looks up the name of a friend for events that only carry a peer ID
+func (ns *NotificationService) peerName(peerID string) string {
+	friends, err := ns.database.GetFriends()
+	if err != nil {
+		return ""
+	}
+
+	for _, friend := range friends {
+		if friend.PeerID == peerID {
+			return friend.PeerName
+		}
+	}
+	return ""
+}
+
+// isNotificationType reports whether a notification type is known
+func isNotificationType(notificationType string) bool {
+	for _, known := range models.NotificationTypes {
+		if known == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// withSnippet appends a shortened quote of text to a notification body
+func withSnippet(body, text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return body
+	}
+
+	if runes := []rune(text); len(runes) > notificationSnippetLength {
+		text = string(runes[:notificationSnippetLength]) + "…"
+	}
+	return body + ": " + text
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,26 +13,39 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 
+	"old-school/internal/config"
 	"old-school/internal/interfaces"
 	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/version"
+	"old-school/internal/wire"
 )
 
 const (
-	// Protocol ID for our application
+	// Protocol ID for our application (legacy untyped JSON messages)
 	AppProtocol = "/old-school/1.0.0"
 
+	// Typed envelope protocol, one ID per encoding so multistream-select settles the encoding
+	AppProtocolCBOR = "/old-school/2.0.0/cbor"
+	AppProtocolJSON = "/old-school/2.0.0/json"
+
 	// Service tag for mDNS discovery
 	ServiceTag = "old-school-p2p"
 
@@ -46,6 +60,12 @@ const (
 
 	// NAT traversal assistance protocol
 	NATAssistProtocol = "/old-school/nat-assist/1.0.0"
+
+	// Raw file transfer protocol with byte ranges
+	BlobProtocol = "/old-school/blob/1.0.0"
+
+	// Largest media file served in a single MediaFileResponse
+	MaxMediaFileTransferSize = 50 * 1024 * 1024
 )
 
 // PeerInfo stores information about connected peers
@@ -60,13 +80,6 @@ type PeerInfo struct {
 	HasAvatar      bool      `json:"has_avatar"`      // whether peer has avatar images
 }
 
-// AvatarData represents avatar image data for peer identification
-type AvatarData struct {
-	Filename string `json:"filename"`
-	Data     string `json:"data"` // base64 encoded image data
-	Size     int    `json:"size"`
-}
-
 // P2PService handles libp2p networking
 type P2PService struct {
 	host           host.Host
@@ -78,13 +91,30 @@ type P2PService struct {
 	validatedPeers map[peer.ID]bool
 	peersMutex     sync.RWMutex
 
-	// NAT detection and relay assistance
-	isPublicNode   bool
-	natDetected    bool
-	connectedPeers map[peer.ID]*PeerInfo
-	peerInfoMutex  sync.RWMutex
+	// Reachability reported by AutoNAT and relay assistance
+	reachability      network.Reachability
+	reachabilityMutex sync.RWMutex
+	connectedPeers    map[peer.ID]*PeerInfo
+	peerInfoMutex     sync.RWMutex
+
+	// Blob downloads in progress, keyed by destination path
+	blobDownloads sync.Map
+
+	// Wire protocol negotiated with each peer during identification
+	peerProtocols  map[peer.ID]*wire.PeerProtocol
+	protocolMutex  sync.RWMutex
+	requestCounter atomic.Uint64
+
+	// Handlers for incoming requests, keyed by message type
+	router *MessageRouter
+
+	// Connection gater refusing blocked peers
+	gater *peerGater
 }
 
+// localCapabilities lists the optional features this build serves to peers
+var localCapabilities = []string{wire.CapabilityMediaGalleries, wire.CapabilityBlob, wire.CapabilityProfileUpdates, wire.CapabilityInvites}
+
 // NewP2PService creates a new P2P service
 func NewP2PService(container *ServiceContainer, dbService interfaces.DatabaseService) (*P2PService, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -107,31 +137,32 @@ func NewP2PService(container *ServiceContainer, dbService interfaces.DatabaseSer
 		return nil, fmt.Errorf("failed to create connection manager: %w", err)
 	}
 
-	// Find available ports for P2P communication
-	tcpPort, err := FindAvailablePort(9000)
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to find available TCP port: %w", err)
-	}
-
-	quicPort, err := FindAvailablePort(tcpPort + 1)
+	// Resolve P2P ports from configuration, falling back to automatic discovery
+	tcpPort, quicPort, err := resolveP2PPorts(container.GetConfig())
 	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("failed to find available QUIC port: %w", err)
+		return nil, err
 	}
 
 	log.Printf("🔌 Using P2P ports - TCP: %d, QUIC: %d", tcpPort, quicPort)
 
+	// Blocked peers are refused before any stream opens
+	gater := newPeerGater(dbService)
+
 	// Create libp2p host with persistent identity and available ports
 	h, err := libp2p.New(
 		libp2p.Identity(privateKey), // Use persistent private key
 		libp2p.ListenAddrStrings(
-			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", tcpPort),       // TCP on available port
-			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", quicPort), // QUIC on available port
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", tcpPort),          // TCP on available port
+			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", quicPort), // QUIC on available port
+			fmt.Sprintf("/ip6/::/tcp/%d", tcpPort),               // The same ports over IPv6
+			fmt.Sprintf("/ip6/::/udp/%d/quic-v1", quicPort),
 		),
 		libp2p.ConnectionManager(connmgr),
+		libp2p.ConnectionGater(gater),
 		libp2p.EnableHolePunching(), // Enable hole punching
 		libp2p.EnableNATService(),   // Enable NAT service
+		libp2p.EnableRelayService(), // Relay for friends that can't reach each other, once we're publicly reachable
 		libp2p.DefaultSecurity,      // Use default security protocols
 		libp2p.DefaultMuxers,        // Use default stream multiplexers
 	)
@@ -156,16 +187,38 @@ func NewP2PService(container *ServiceContainer, dbService interfaces.DatabaseSer
 		dbService:      dbService,
 		validatedPeers: make(map[peer.ID]bool),
 		connectedPeers: make(map[peer.ID]*PeerInfo),
+		peerProtocols:  make(map[peer.ID]*wire.PeerProtocol),
+		gater:          gater,
 	}
 
+	// Publish connection changes on the event bus
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF:    service.onPeerConnected,
+		DisconnectedF: service.onPeerDisconnected,
+	})
+
+	// Register message handlers before serving requests
+	service.setupMessageRouter()
+
 	// Set stream handler for our protocol
 	h.SetStreamHandler(protocol.ID(AppProtocol), service.handleStream)
+	h.SetStreamHandler(protocol.ID(AppProtocolCBOR), service.handleEnvelopeStream)
+	h.SetStreamHandler(protocol.ID(AppProtocolJSON), service.handleEnvelopeStream)
 	h.SetStreamHandler(protocol.ID(IdentifyProtocol), service.handleIdentifyStream)
 	h.SetStreamHandler(protocol.ID(RendezvousProtocol), service.handleRendezvousStream)
 	h.SetStreamHandler(protocol.ID(NATAssistProtocol), service.handleNATAssistStream)
+	h.SetStreamHandler(protocol.ID(BlobProtocol), service.handleBlobStream)
+
+	// Keep the address book of app peers and give libp2p the addresses of our friends
+	if err := service.watchPeerAddresses(); err != nil {
+		log.Printf("Warning: peer address book setup failed: %v", err)
+	}
+	service.seedPeerstore()
 
-	// Detect NAT status
-	service.detectNATStatus()
+	// Follow the reachability AutoNAT determines
+	if err := service.watchReachability(); err != nil {
+		log.Printf("Warning: reachability detection setup failed: %v", err)
+	}
 
 	// Initialize DHT for global peer discovery
 	if err := service.setupDHT(); err != nil {
@@ -182,6 +235,35 @@ func NewP2PService(container *ServiceContainer, dbService interfaces.DatabaseSer
 	return service, nil
 }
 
+// resolveP2PPorts picks the TCP and QUIC listen ports. Configured ports are used as-is;
+// otherwise the first free port from config.DefaultP2PStartPort is taken.
+func resolveP2PPorts(cfg *config.Config) (int, int, error) {
+	tcpPort := cfg.P2PPort
+	if tcpPort == 0 {
+		port, err := FindAvailablePort(config.DefaultP2PStartPort)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to find available TCP port: %w", err)
+		}
+		tcpPort = port
+	}
+
+	quicPort := cfg.QUICPort
+	if quicPort == 0 {
+		if cfg.P2PPort != 0 {
+			// An explicit P2P port is usually published for both TCP and UDP
+			quicPort = tcpPort
+		} else {
+			port, err := FindAvailablePort(tcpPort + 1)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to find available QUIC port: %w", err)
+			}
+			quicPort = port
+		}
+	}
+
+	return tcpPort, quicPort, nil
+}
+
 // setupDHT initializes the DHT for global peer discovery
 func (p *P2PService) setupDHT() error {
 	// Create DHT
@@ -229,19 +311,17 @@ func (p *P2PService) setupRelayDiscovery() {
 }
 
 // prepareAvatarData reads the primary avatar image and encodes it for transmission
-func (p *P2PService) prepareAvatarData() *AvatarData {
+func (p *P2PService) prepareAvatarData() *models.AvatarData {
 	if p.container == nil || p.container.GetDirectoryService() == nil {
 		return nil
 	}
 
-	// Get avatar images
-	avatarImages, err := p.container.GetDirectoryService().GetAvatarImages()
-	if err != nil || len(avatarImages) == 0 {
+	// Use the chosen avatar image, or the first one if none was chosen
+	primaryAvatar := primaryAvatarImage(p.dbService, p.container.GetDirectoryService())
+	if primaryAvatar == "" {
 		return nil
 	}
 
-	// Use the first image as the primary avatar
-	primaryAvatar := avatarImages[0]
 	avatarDir := p.container.GetDirectoryService().GetAvatarDirectory()
 	avatarPath := filepath.Join(avatarDir, primaryAvatar)
 
@@ -261,7 +341,7 @@ func (p *P2PService) prepareAvatarData() *AvatarData {
 	// Encode to base64
 	encodedData := base64.StdEncoding.EncodeToString(imageData)
 
-	return &AvatarData{
+	return &models.AvatarData{
 		Filename: primaryAvatar,
 		Data:     encodedData,
 		Size:     len(imageData),
@@ -269,7 +349,7 @@ func (p *P2PService) prepareAvatarData() *AvatarData {
 }
 
 // prepareFriendsData prepares the current user's friends list for transmission
-func (p *P2PService) prepareFriendsData() []map[string]interface{} {
+func (p *P2PService) prepareFriendsData() []models.IdentifyFriend {
 	if p.dbService == nil {
 		return nil
 	}
@@ -285,22 +365,23 @@ func (p *P2PService) prepareFriendsData() []map[string]interface{} {
 		return nil
 	}
 
-	// Convert friends to transmission format
-	friendsData := make([]map[string]interface{}, 0, len(friends))
+	// Convert friends to transmission format, relaying their signed profiles
+	friendsData := make([]models.IdentifyFriend, 0, len(friends))
 	for _, friend := range friends {
-		friendData := map[string]interface{}{
-			"peer_id":   friend.PeerID,
-			"peer_name": friend.PeerName,
-		}
-		friendsData = append(friendsData, friendData)
+		friendsData = append(friendsData, models.IdentifyFriend{
+			PeerID:   friend.PeerID,
+			PeerName: friend.PeerName,
+			Profile:  p.storedPeerProfile(friend.PeerID),
+		})
 	}
 
 	log.Printf("📤 Prepared %d friends for transmission", len(friendsData))
 	return friendsData
 }
 
-// saveReceivedAvatar saves avatar data received from a peer
-func (p *P2PService) saveReceivedAvatar(peerID peer.ID, avatarData *AvatarData) error {
+// saveReceivedAvatar saves avatar data received from a peer. If the peer's signed profile names
+// an avatar hash the image must match it.
+func (p *P2PService) saveReceivedAvatar(peerID peer.ID, avatarData *models.AvatarData, expectedHash string) error {
 	if p.container == nil || p.container.GetDirectoryService() == nil || avatarData == nil {
 		return fmt.Errorf("invalid service or avatar data")
 	}
@@ -316,6 +397,10 @@ func (p *P2PService) saveReceivedAvatar(peerID peer.ID, avatarData *AvatarData)
 		return fmt.Errorf("avatar data size mismatch: expected %d, got %d", avatarData.Size, len(imageData))
 	}
 
+	if expectedHash != "" && utils.DefaultHashService.ComputeDataHash(imageData) != expectedHash {
+		return fmt.Errorf("avatar does not match the signed profile")
+	}
+
 	// Save using DirectoryService
 	err = p.container.GetDirectoryService().SavePeerAvatar(peerID.String(), avatarData.Filename, imageData)
 	if err != nil {
@@ -334,116 +419,145 @@ func (p *P2PService) handleIdentifyStream(stream network.Stream) {
 	log.Printf("🔍 Received identification request from peer: %s", peerID)
 
 	// Read the requesting peer's identification data (client sends first)
-	var peerRequest map[string]interface{}
+	var peerRequest models.IdentifyMessage
 	decoder := json.NewDecoder(stream)
 	if err := decoder.Decode(&peerRequest); err != nil {
 		log.Printf("Failed to decode peer identification: %v", err)
 		return
 	}
 
-	// Get our node name from database
-	nodeName := "unknown"
-	if p.dbService != nil {
-		if name, err := p.dbService.GetSetting("name"); err == nil {
-			nodeName = name
-		}
+	// Follow a key rotation first so a friend with a new peer ID is treated as a friend in our response
+	if peerRequest.App == AppIdentifier {
+		p.applyPeerKeyRotations(peerID, peerRequest.KeyRotations)
 	}
 
-	// Prepare our avatar data for transmission
-	avatarData := p.prepareAvatarData()
+	// Send our application identifier response including name, avatar and protocol support
+	response := p.buildIdentifyMessage(peerID)
+
+	encoder := json.NewEncoder(stream)
+	if err := encoder.Encode(response); err != nil {
+		log.Printf("Failed to send identification response: %v", err)
+		return
+	}
 
-	// Prepare our friends data for transmission
-	friendsData := p.prepareFriendsData()
+	// Validate it's our application and store what the peer sent
+	if peerRequest.App == AppIdentifier {
+		peerName := p.processIdentifyMessage(peerID, &peerRequest)
 
-	// Send our application identifier response including name and avatar
-	response := map[string]interface{}{
-		"app":     AppIdentifier,
-		"version": "1.0.0",
-		"nodeId":  p.host.ID().String(),
-		"name":    nodeName,
+		// Mark peer as validated and save connection with name
+		p.markPeerValidationWithName(peerID, true, peerName)
+		log.Printf("✅ Validated incoming peer: %s (name: %s)", peerID, peerName)
 	}
 
-	// Include avatar data if available
-	if avatarData != nil {
-		response["avatar"] = avatarData
+	log.Printf("✅ Sent identification response to peer: %s (name: %s)", peerID, response.Name)
+}
+
+// buildIdentifyMessage prepares our identification data for a peer, advertising the wire protocol versions
+// and encodings we speak. The name, avatar and friends list are only included if our privacy settings
+// disclose them to the peer.
+func (p *P2PService) buildIdentifyMessage(peerID peer.ID) *models.IdentifyMessage {
+	message := &models.IdentifyMessage{
+		App:             AppIdentifier,
+		Version:         version.Version,
+		NodeID:          p.host.ID().String(),
+		ProtocolVersion: wire.ProtocolVersion,
+		Encodings:       wire.SupportedEncodings,
+		Capabilities:    localCapabilities,
+	}
+
+	access := p.contentAccess(peerID)
+
+	if access.Discloses(models.PrivacyItemName) {
+		// Get our node name from database
+		message.Name = "unknown"
+		if p.dbService != nil {
+			if name, err := p.dbService.GetSetting("name"); err == nil {
+				message.Name = name
+			}
+		}
+
+		// The signed profile lets the peer and whoever it relays it to verify the name
+		if p.container != nil && p.container.GetProfileService() != nil {
+			profile, err := p.container.GetProfileService().OwnProfile()
+			if err != nil {
+				log.Printf("⚠️ Failed to sign profile record: %v", err)
+			}
+			message.Profile = profile
+		}
+
+		// Friends that knew us under an earlier key follow us to the current peer ID
+		message.KeyRotations = p.keyRotationsForIdentify()
+	}
+	if access.Discloses(models.PrivacyItemAvatar) {
+		message.Avatar = p.prepareAvatarData()
+	}
+	if access.Discloses(models.PrivacyItemFriendsList) {
+		message.Friends = p.prepareFriendsData()
 	}
 
-	// Include friends data if available
-	if friendsData != nil {
-		response["friends"] = friendsData
+	return message
+}
+
+// processIdentifyMessage stores the avatar and friends a peer sent during identification,
+// negotiates the wire protocol with it and returns the peer's name
+func (p *P2PService) processIdentifyMessage(peerID peer.ID, message *models.IdentifyMessage) string {
+	peerName := "unknown"
+	if message.Name != "" {
+		peerName = message.Name
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(response); err != nil {
-		log.Printf("Failed to send identification response: %v", err)
-		return
+	// A verified profile record is the authority on the peer's name and avatar
+	avatarHash := ""
+	if profile := p.receivePeerProfile(message.Profile, peerID.String()); profile != nil {
+		peerName = profile.Name
+		avatarHash = profile.AvatarHash
 	}
 
-	// Extract peer name and validate it's our application
-	if app, exists := peerRequest["app"]; exists && app == AppIdentifier {
-		peerName := "unknown"
-		if name, exists := peerRequest["name"]; exists {
-			if nameStr, ok := name.(string); ok {
-				peerName = nameStr
-			}
+	// Save the received avatar
+	if message.Avatar != nil {
+		if err := p.saveReceivedAvatar(peerID, message.Avatar, avatarHash); err != nil {
+			log.Printf("Failed to save avatar from peer %s: %v", peerID, err)
 		}
+	}
 
-		// Process avatar data if present
-		if avatarInterface, exists := peerRequest["avatar"]; exists {
-			// Convert interface{} to AvatarData
-			if avatarMap, ok := avatarInterface.(map[string]interface{}); ok {
-				avatarData := &AvatarData{}
-				if filename, ok := avatarMap["filename"].(string); ok {
-					avatarData.Filename = filename
-				}
-				if data, ok := avatarMap["data"].(string); ok {
-					avatarData.Data = data
-				}
-				if size, ok := avatarMap["size"].(float64); ok {
-					avatarData.Size = int(size)
-				}
-
-				// Save the received avatar
-				if err := p.saveReceivedAvatar(peerID, avatarData); err != nil {
-					log.Printf("Failed to save avatar from peer %s: %v", peerID, err)
-				}
-			}
+	// Save the received friends list
+	if len(message.Friends) > 0 && p.dbService != nil {
+		friends := make([]models.Friend, 0, len(message.Friends))
+		for _, friend := range message.Friends {
+			friends = append(friends, models.Friend{
+				PeerID:   friend.PeerID,
+				PeerName: friend.PeerName,
+				Profile:  friend.Profile,
+			})
 		}
+		friends = p.verifyRelayedProfiles(friends)
 
-		// Process friends data if present
-		if friendsInterface, exists := peerRequest["friends"]; exists {
-			if friendsSlice, ok := friendsInterface.([]interface{}); ok {
-				friends := make([]models.Friend, 0, len(friendsSlice))
-				for _, friendInterface := range friendsSlice {
-					if friendMap, ok := friendInterface.(map[string]interface{}); ok {
-						friend := models.Friend{}
-						if peerID, ok := friendMap["peer_id"].(string); ok {
-							friend.PeerID = peerID
-						}
-						if peerName, ok := friendMap["peer_name"].(string); ok {
-							friend.PeerName = peerName
-						}
-						friends = append(friends, friend)
-					}
-				}
-				
-				// Save the received friends list
-				if len(friends) > 0 {
-					if err := p.dbService.SavePeerFriends(peerID.String(), friends); err != nil {
-						log.Printf("Failed to save friends from peer %s: %v", peerID, err)
-					} else {
-						log.Printf("✅ Saved %d friends from peer %s", len(friends), peerID)
-					}
-				}
-			}
+		if err := p.dbService.SavePeerFriends(peerID.String(), friends); err != nil {
+			log.Printf("Failed to save friends from peer %s: %v", peerID, err)
+		} else {
+			log.Printf("✅ Saved %d friends from peer %s", len(friends), peerID)
 		}
+	}
 
-		// Mark peer as validated and save connection with name
-		p.markPeerValidationWithName(peerID, true, peerName)
-		log.Printf("✅ Validated incoming peer: %s (name: %s)", peerID, peerName)
+	protocolVersion, encoding := wire.Negotiate(message.ProtocolVersion, message.Encodings)
+	p.protocolMutex.Lock()
+	p.peerProtocols[peerID] = &wire.PeerProtocol{
+		AppVersion:   message.Version,
+		Version:      protocolVersion,
+		Encoding:     encoding,
+		Capabilities: message.Capabilities,
 	}
+	p.protocolMutex.Unlock()
 
-	log.Printf("✅ Sent identification response to peer: %s (name: %s)", peerID, nodeName)
+	log.Printf("🤝 Negotiated protocol v%d (%s) with %s (app version %s)", protocolVersion, encoding, peerID, message.Version)
+	return peerName
+}
+
+// GetPeerProtocol returns the wire protocol negotiated with a peer, or nil if it has not been identified yet
+func (p *P2PService) GetPeerProtocol(peerID peer.ID) *wire.PeerProtocol {
+	p.protocolMutex.RLock()
+	defer p.protocolMutex.RUnlock()
+	return p.peerProtocols[peerID]
 }
 
 // validatePeer checks if a peer is running our application
@@ -476,35 +590,7 @@ func (p *P2PService) validatePeer(peerID peer.ID) bool {
 	defer stream.Close()
 
 	// As the stream initiator (client), send our identification data first
-	ourNodeName := "unknown"
-	if p.dbService != nil {
-		if name, err := p.dbService.GetSetting("name"); err == nil {
-			ourNodeName = name
-		}
-	}
-
-	// Prepare our avatar data for transmission
-	avatarData := p.prepareAvatarData()
-
-	// Prepare our friends data for transmission
-	friendsData := p.prepareFriendsData()
-
-	ourRequest := map[string]interface{}{
-		"app":     AppIdentifier,
-		"version": "1.0.0",
-		"nodeId":  p.host.ID().String(),
-		"name":    ourNodeName,
-	}
-
-	// Include avatar data if available
-	if avatarData != nil {
-		ourRequest["avatar"] = avatarData
-	}
-
-	// Include friends data if available
-	if friendsData != nil {
-		ourRequest["friends"] = friendsData
-	}
+	ourRequest := p.buildIdentifyMessage(peerID)
 
 	encoder := json.NewEncoder(stream)
 	if err := encoder.Encode(ourRequest); err != nil {
@@ -514,7 +600,7 @@ func (p *P2PService) validatePeer(peerID peer.ID) bool {
 	}
 
 	// Read identification response from remote peer
-	var response map[string]interface{}
+	var response models.IdentifyMessage
 	decoder := json.NewDecoder(stream)
 	if err := decoder.Decode(&response); err != nil {
 		log.Printf("❌ Failed to decode identification from %s: %v", peerID, err)
@@ -523,77 +609,20 @@ func (p *P2PService) validatePeer(peerID peer.ID) bool {
 	}
 
 	// Check if it's our application
-	app, exists := response["app"]
-	if !exists {
+	if response.App == "" {
 		log.Printf("❌ Peer %s identification missing app field", peerID)
 		p.markPeerValidation(peerID, false)
 		return false
 	}
 
-	appStr, ok := app.(string)
-	if !ok || appStr != AppIdentifier {
-		log.Printf("❌ Peer %s is not running our application (app: %v)", peerID, app)
+	if response.App != AppIdentifier {
+		log.Printf("❌ Peer %s is not running our application (app: %v)", peerID, response.App)
 		p.markPeerValidation(peerID, false)
 		return false
 	}
 
-	// Extract peer name if available
-	peerName := "unknown"
-	if name, exists := response["name"]; exists {
-		if nameStr, ok := name.(string); ok {
-			peerName = nameStr
-		}
-	}
-
-	// Process avatar data if present
-	if avatarInterface, exists := response["avatar"]; exists {
-		// Convert interface{} to AvatarData
-		if avatarMap, ok := avatarInterface.(map[string]interface{}); ok {
-			receivedAvatarData := &AvatarData{}
-			if filename, ok := avatarMap["filename"].(string); ok {
-				receivedAvatarData.Filename = filename
-			}
-			if data, ok := avatarMap["data"].(string); ok {
-				receivedAvatarData.Data = data
-			}
-			if size, ok := avatarMap["size"].(float64); ok {
-				receivedAvatarData.Size = int(size)
-			}
-
-			// Save the received avatar
-			if err := p.saveReceivedAvatar(peerID, receivedAvatarData); err != nil {
-				log.Printf("Failed to save avatar from peer %s: %v", peerID, err)
-			}
-		}
-	}
-
-	// Process friends data if present
-	if friendsInterface, exists := response["friends"]; exists {
-		if friendsSlice, ok := friendsInterface.([]interface{}); ok {
-			friends := make([]models.Friend, 0, len(friendsSlice))
-			for _, friendInterface := range friendsSlice {
-				if friendMap, ok := friendInterface.(map[string]interface{}); ok {
-					friend := models.Friend{}
-					if peerID, ok := friendMap["peer_id"].(string); ok {
-						friend.PeerID = peerID
-					}
-					if peerName, ok := friendMap["peer_name"].(string); ok {
-						friend.PeerName = peerName
-					}
-					friends = append(friends, friend)
-				}
-			}
-			
-			// Save the received friends list
-			if len(friends) > 0 {
-				if err := p.dbService.SavePeerFriends(peerID.String(), friends); err != nil {
-					log.Printf("Failed to save friends from peer %s: %v", peerID, err)
-				} else {
-					log.Printf("✅ Saved %d friends from peer %s", len(friends), peerID)
-				}
-			}
-		}
-	}
+	p.applyPeerKeyRotations(peerID, response.KeyRotations)
+	peerName := p.processIdentifyMessage(peerID, &response)
 
 	log.Printf("✅ Peer %s validated as our application (name: %s)", peerID, peerName)
 	p.markPeerValidationWithName(peerID, true, peerName)
@@ -622,9 +651,14 @@ func (p *P2PService) checkPeerHasAvatar(peerID peer.ID) bool {
 // markPeerValidationWithName marks a peer as validated with name information
 func (p *P2PService) markPeerValidationWithName(peerID peer.ID, isValid bool, peerName string) {
 	p.peersMutex.Lock()
+	wasValid := p.validatedPeers[peerID]
 	p.validatedPeers[peerID] = isValid
 	p.peersMutex.Unlock()
 
+	if isValid && !wasValid {
+		publishEvent(p.container.GetEventBus(), models.EventPeerValidated, models.PeerEvent{PeerID: peerID.String(), Name: peerName})
+	}
+
 	// Update peer info validation status and name
 	p.peerInfoMutex.Lock()
 	if peerInfo, exists := p.connectedPeers[peerID]; exists {
@@ -715,7 +749,7 @@ func (n *discoveryNotifee) HandlePeerFound(peerInfo peer.AddrInfo) {
 	}()
 }
 
-// handleStream handles incoming streams
+// handleStream serves requests of the legacy JSON P2PMessage protocol spoken by older nodes
 func (p *P2PService) handleStream(stream network.Stream) {
 	defer stream.Close()
 
@@ -733,110 +767,32 @@ func (p *P2PService) handleStream(stream network.Stream) {
 
 	log.Printf("Received message type: %s from %s", msg.Type, peerID)
 
-	var response models.P2PMessage
-
-	switch msg.Type {
-	case models.MessageTypeGetInfo:
-		// Return node and folder information
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetInfoResp,
-			Payload: p.getNodeInfo(),
-		}
-
-	case models.MessageTypeDiscovery:
-		// Handle discovery request
-		response = models.P2PMessage{
-			Type:    models.MessageTypeDiscoveryResp,
-			Payload: p.getNodeInfo(),
-		}
-
-	case models.MessageTypeGetPeerList:
-		// Return list of connected peers
-		log.Printf("📋 Processing peer list request from %s", peerID)
-		peerList := p.getConnectedPeersList()
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetPeerListResp,
-			Payload: peerList,
-		}
-		log.Printf("📋 Prepared peer list response with %d peers for %s", peerList.Count, peerID)
-
-	case models.MessageTypeHolePunchAssist:
-		// Handle hole punching assistance request
-		assistResponse := p.handleHolePunchAssistRequest(msg.Payload)
-		response = models.P2PMessage{
-			Type:    models.MessageTypeHolePunchResp,
-			Payload: assistResponse,
-		}
-
-	case models.MessageTypeGetDocs:
-		// Handle docs list request
-		log.Printf("📝 Processing docs request from %s", peerID)
-		docsResponse := p.handleGetDocsRequest()
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetDocsResp,
-			Payload: docsResponse,
-		}
-
-	case models.MessageTypeGetDoc:
-		// Handle specific doc request
-		log.Printf("📝 Processing doc request from %s", peerID)
-		docResponse := p.handleGetDocRequest(msg.Payload)
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetDocResp,
-			Payload: docResponse,
-		}
-
-	case models.MessageTypeGetFiles:
-		// Handle files table request
-		log.Printf("📁 Processing files table request from %s", peerID)
-		filesResponse := p.handleGetFilesRequest()
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetFilesResp,
-			Payload: filesResponse,
-		}
-
-	case models.MessageTypeGetGalleries:
-		// Handle galleries request
-		log.Printf("📷 Processing galleries request from %s", peerID)
-		galleriesResponse := p.handleGetGalleriesRequest()
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetGalleriesResp,
-			Payload: galleriesResponse,
-		}
-
-	case models.MessageTypeGetGallery:
-		// Handle specific gallery request
-		log.Printf("📷 Processing gallery request from %s", peerID)
-		galleryResponse := p.handleGetGalleryRequest(msg.Payload)
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetGalleryResp,
-			Payload: galleryResponse,
-		}
-
-	case models.MessageTypeGetGalleryImage:
-		// Handle gallery image request
-		log.Printf("📷 Processing gallery image request from %s", peerID)
-		imageResponse := p.handleGetGalleryImageRequest(msg.Payload)
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetGalleryImageResp,
-			Payload: imageResponse,
+	// Legacy payloads arrive as generic JSON values and are converted into the typed request
+	decode := func(v interface{}) error {
+		if msg.Payload == nil {
+			return nil
 		}
-
-	case models.MessageTypeGetFriends:
-		// Handle friends list request
-		log.Printf("👥 Processing friends request from %s", peerID)
-		friendsResponse := p.handleGetFriendsRequest()
-		response = models.P2PMessage{
-			Type:    models.MessageTypeGetFriendsResp,
-			Payload: friendsResponse,
+		payloadData, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
 		}
+		return json.Unmarshal(payloadData, v)
+	}
 
-	default:
-		log.Printf("Unknown message type: %s", msg.Type)
+	responseType, payload, err := p.router.RouteMessage(msg.Type, decode, peerID)
+	if err != nil {
+		// The legacy protocol has no way to report errors
+		log.Printf("Failed to handle %s from %s: %v", msg.Type, peerID, err)
 		return
 	}
 
+	response := models.P2PMessage{
+		Type:    responseType,
+		Payload: payload,
+	}
+
 	// Send response
+	stream.SetWriteDeadline(time.Now().Add(p.messageTimeout(msg.Type)))
 	encoder := json.NewEncoder(stream)
 	if err := encoder.Encode(response); err != nil {
 		log.Printf("Failed to send response: %v", err)
@@ -852,6 +808,16 @@ func (p *P2PService) GetNode() *models.NetworkNode {
 	}
 }
 
+// GetNodePrivateKey returns the private key the running host uses. After a key rotation it stays
+// the old key until restart, so records signed with it verify against the peer ID peers know us by.
+func (p *P2PService) GetNodePrivateKey() (crypto.PrivKey, error) {
+	privateKey := p.host.Peerstore().PrivKey(p.host.ID())
+	if privateKey == nil {
+		return nil, fmt.Errorf("no private key for host %s", p.host.ID())
+	}
+	return privateKey, nil
+}
+
 // DiscoverPeer attempts to discover and communicate with a peer
 func (p *P2PService) DiscoverPeer(peerID string) (*models.NodeInfoResponse, error) {
 	// Parse peer ID
@@ -883,43 +849,8 @@ func (p *P2PService) DiscoverPeer(peerID string) (*models.NodeInfoResponse, erro
 		return nil, fmt.Errorf("peer %s is not running our application", pid)
 	}
 
-	// Open stream to peer
-	stream, err := p.host.NewStream(ctx, pid, protocol.ID(AppProtocol))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Send discovery message
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetInfo,
-		Payload: nil,
-	}
-
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send message: %w", err)
-	}
-
-	// Read response
-	var response models.P2PMessage
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
-	}
-
-	var nodeInfo models.NodeInfoResponse
-	if err := json.Unmarshal(responseData, &nodeInfo); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal node info: %w", err)
-	}
-
-	return &nodeInfo, nil
+	// Request node info from the peer
+	return requestPeer[models.NodeInfoResponse](p, pid, models.MessageTypeGetInfo, nil)
 }
 
 // GetConnectedPeers returns list of validated connected peers
@@ -1027,76 +958,121 @@ func (p *P2PService) checkAndRetryMissingPeerData() {
 	}
 }
 
-// detectNATStatus determines if this node is publicly accessible
-func (p *P2PService) detectNATStatus() {
-	p.natDetected = true
-	p.isPublicNode = false
+// watchReachability follows the reachability AutoNAT determines by having peers dial us back,
+// so whether this node assists others with NAT traversal changes along with it
+func (p *P2PService) watchReachability() error {
+	sub, err := p.host.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		return err
+	}
 
-	// Check if any of our listening addresses are public
-	for _, addr := range p.host.Addrs() {
-		addrStr := addr.String()
-
-		// Extract IP from multiaddr (format: /ip4/x.x.x.x/tcp/port)
-		if ip := extractIPFromMultiaddr(addrStr); ip != nil {
-			if isPublicIP(ip) {
-				p.isPublicNode = true
-				log.Printf("🌐 Detected as PUBLIC node - can assist with NAT traversal")
-				log.Printf("   Public address: %s", addrStr)
+	log.Printf("❔ Reachability unknown until AutoNAT hears back from peers - will seek assistance for connections")
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-p.ctx.Done():
 				return
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				p.setReachability(e.(event.EvtLocalReachabilityChanged).Reachability)
 			}
 		}
-	}
-
-	log.Printf("🏠 Detected as NAT'd node - will seek assistance for connections")
+	}()
+	return nil
 }
 
-// extractIPFromMultiaddr extracts IP address from multiaddr string
-func extractIPFromMultiaddr(addrStr string) net.IP {
-	// Simple extraction for /ip4/x.x.x.x/... format
-	if len(addrStr) > 5 && addrStr[:5] == "/ip4/" {
-		parts := []rune(addrStr[5:])
-		var ipStr string
-		for _, r := range parts {
-			if r == '/' {
-				break
-			}
-			ipStr += string(r)
+// setReachability records a reachability reported by AutoNAT and announces changes
+func (p *P2PService) setReachability(reachability network.Reachability) {
+	p.reachabilityMutex.Lock()
+	changed := p.reachability != reachability
+	p.reachability = reachability
+	p.reachabilityMutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	observed := p.observedPublicAddresses()
+	switch reachability {
+	case network.ReachabilityPublic:
+		log.Printf("🌐 AutoNAT: node is PUBLIC - can assist with NAT traversal")
+		for _, addr := range observed {
+			log.Printf("   Public address: %s", addr)
 		}
-		return net.ParseIP(ipStr)
+	case network.ReachabilityPrivate:
+		log.Printf("🏠 AutoNAT: node is behind NAT - will seek assistance for connections")
+	default:
+		log.Printf("❔ AutoNAT: reachability unknown - will seek assistance for connections")
 	}
-	return nil
+
+	publishEvent(p.container.GetEventBus(), models.EventReachabilityChanged, models.ReachabilityEvent{
+		Reachability:      reachabilityName(reachability),
+		ObservedAddresses: observed,
+	})
 }
 
-// isPublicIP checks if an IP address is publicly routable
-func isPublicIP(ip net.IP) bool {
-	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-		return false
+// getReachability returns the reachability last reported by AutoNAT
+func (p *P2PService) getReachability() network.Reachability {
+	p.reachabilityMutex.RLock()
+	defer p.reachabilityMutex.RUnlock()
+	return p.reachability
+}
+
+// observedPublicAddresses returns the public addresses peers observed us at during identify,
+// along with the public addresses the host announces
+func (p *P2PService) observedPublicAddresses() []string {
+	var candidates []multiaddr.Multiaddr
+	if ids, ok := p.host.(interface{ IDService() identify.IDService }); ok {
+		candidates = append(candidates, ids.IDService().OwnObservedAddrs()...)
 	}
+	candidates = append(candidates, p.host.Addrs()...)
 
-	// Check for private IP ranges
-	if ip4 := ip.To4(); ip4 != nil {
-		// 10.0.0.0/8
-		if ip4[0] == 10 {
-			return false
-		}
-		// 172.16.0.0/12
-		if ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31 {
-			return false
-		}
-		// 192.168.0.0/16
-		if ip4[0] == 192 && ip4[1] == 168 {
-			return false
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, addr := range candidates {
+		if !manet.IsPublicAddr(addr) || seen[addr.String()] {
+			continue
 		}
+		seen[addr.String()] = true
+		addresses = append(addresses, addr.String())
 	}
+	return addresses
+}
 
-	return true
+// reachabilityName returns the API name of a libp2p reachability
+func reachabilityName(reachability network.Reachability) string {
+	switch reachability {
+	case network.ReachabilityPublic:
+		return models.ReachabilityPublic
+	case network.ReachabilityPrivate:
+		return models.ReachabilityPrivate
+	default:
+		return models.ReachabilityUnknown
+	}
+}
+
+// extractIPFromMultiaddr extracts the IP address of an /ip4/ or /ip6/ multiaddr string
+func extractIPFromMultiaddr(addrStr string) net.IP {
+	addr, err := multiaddr.NewMultiaddr(addrStr)
+	if err != nil {
+		return nil
+	}
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return nil
+	}
+	return ip
 }
 
 // handleRendezvousStream handles rendezvous/relay assistance requests
 func (p *P2PService) handleRendezvousStream(stream network.Stream) {
 	defer stream.Close()
 
-	if !p.isPublicNode {
+	if !p.IsPublicNode() {
 		log.Printf("⚠️ Received rendezvous request but this node is not public")
 		return
 	}
@@ -1134,7 +1110,7 @@ func (p *P2PService) handleRendezvousStream(stream network.Stream) {
 func (p *P2PService) handleNATAssistStream(stream network.Stream) {
 	defer stream.Close()
 
-	if !p.isPublicNode {
+	if !p.IsPublicNode() {
 		log.Printf("⚠️ Received NAT assist request but this node is not public")
 		return
 	}
@@ -1185,6 +1161,38 @@ func (p *P2PService) handleNATAssistStream(stream network.Stream) {
 	}
 }
 
+// onPeerConnected publishes the first connection to a peer
+func (p *P2PService) onPeerConnected(n network.Network, conn network.Conn) {
+	peerID := conn.RemotePeer()
+	if len(n.ConnsToPeer(peerID)) > 1 {
+		return
+	}
+
+	direction := "outbound"
+	if conn.Stat().Direction == network.DirInbound {
+		direction = "inbound"
+	}
+
+	publishEvent(p.container.GetEventBus(), models.EventPeerConnected, models.PeerEvent{PeerID: peerID.String(), Direction: direction})
+}
+
+// onPeerDisconnected publishes the loss of the last connection to a peer
+func (p *P2PService) onPeerDisconnected(n network.Network, conn network.Conn) {
+	peerID := conn.RemotePeer()
+	if n.Connectedness(peerID) == network.Connected {
+		return
+	}
+
+	name := ""
+	p.peerInfoMutex.RLock()
+	if peerInfo, exists := p.connectedPeers[peerID]; exists {
+		name = peerInfo.Name
+	}
+	p.peerInfoMutex.RUnlock()
+
+	publishEvent(p.container.GetEventBus(), models.EventPeerDisconnected, models.PeerEvent{PeerID: peerID.String(), Name: name})
+}
+
 // storePeerInfo stores information about a connected peer
 func (p *P2PService) storePeerInfo(peerID peer.ID, connectionType string) {
 	if peerID == p.host.ID() {
@@ -1219,7 +1227,7 @@ func (p *P2PService) storePeerInfo(peerID peer.ID, connectionType string) {
 		}
 		p.connectedPeers[peerID] = peerInfo
 
-		if p.isPublicNode {
+		if p.IsPublicNode() {
 			log.Printf("📝 Stored peer info: %s (%s connection)", peerID, connectionType)
 		}
 	} else {
@@ -1256,9 +1264,10 @@ func (p *P2PService) getKnownPeersList() []string {
 	return peers
 }
 
-// IsPublicNode returns whether this node can assist with NAT traversal
+// IsPublicNode returns whether this node can assist with NAT traversal, which is when
+// AutoNAT found it publicly reachable
 func (p *P2PService) IsPublicNode() bool {
-	return p.isPublicNode
+	return p.getReachability() == network.ReachabilityPublic
 }
 
 // GetConnectedPeerInfo returns detailed information about connected peers
@@ -1276,18 +1285,24 @@ func (p *P2PService) GetConnectedPeerInfo() map[peer.ID]*PeerInfo {
 	return result
 }
 
-// ConnectByIP connects to a peer using IP address and port
+// ConnectByIP connects to a peer using IP address and port. The IP may also be an IPv6 address,
+// with or without brackets, a DNS name or a multiaddr, which may carry the peer ID instead.
 func (p *P2PService) ConnectByIP(ip string, port int, peerIDStr string) (*models.NodeInfoResponse, error) {
+	addr, addrPeerID, err := dialMultiaddr(ip, port)
+	if err != nil {
+		return nil, err
+	}
+	if peerIDStr == "" && addrPeerID != "" {
+		peerIDStr = addrPeerID.String()
+	}
+
 	// Parse peer ID
 	pid, err := peer.Decode(peerIDStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid peer ID: %w", err)
 	}
-
-	// Create multiaddr from IP and port
-	addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", ip, port))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create multiaddr: %w", err)
+	if addrPeerID != "" && addrPeerID != pid {
+		return nil, fmt.Errorf("address %s belongs to peer %s, not %s", ip, addrPeerID, pid)
 	}
 
 	// Create peer info
@@ -1296,7 +1311,7 @@ func (p *P2PService) ConnectByIP(ip string, port int, peerIDStr string) (*models
 		Addrs: []multiaddr.Multiaddr{addr},
 	}
 
-	log.Printf("🌐 Attempting to connect to peer %s at %s:%d", pid, ip, port)
+	log.Printf("🌐 Attempting to connect to peer %s at %s", pid, addr)
 
 	// Connect to peer with timeout
 	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
@@ -1306,94 +1321,145 @@ func (p *P2PService) ConnectByIP(ip string, port int, peerIDStr string) (*models
 		// Provide more helpful error messages
 		errStr := err.Error()
 		if strings.Contains(errStr, "failed to negotiate security protocol") {
-			return nil, fmt.Errorf("failed to connect to peer at %s:%d: Protocol mismatch - make sure you're using the P2P port (not web port). Original error: %w", ip, port, err)
+			return nil, fmt.Errorf("failed to connect to peer at %s: Protocol mismatch - make sure you're using the P2P port (not web port). Original error: %w", addr, err)
 		}
 		if strings.Contains(errStr, "connection refused") {
-			return nil, fmt.Errorf("failed to connect to peer at %s:%d: Connection refused - check if the node is running and port %d is open in firewall. Original error: %w", ip, port, port, err)
+			return nil, fmt.Errorf("failed to connect to peer at %s: Connection refused - check if the node is running and its port is open in firewall. Original error: %w", addr, err)
 		}
 		if strings.Contains(errStr, "timeout") {
-			return nil, fmt.Errorf("failed to connect to peer at %s:%d: Connection timeout - check network connectivity and firewall settings. Original error: %w", ip, port, err)
+			return nil, fmt.Errorf("failed to connect to peer at %s: Connection timeout - check network connectivity and firewall settings. Original error: %w", addr, err)
 		}
-		return nil, fmt.Errorf("failed to connect to peer at %s:%d: %w", ip, port, err)
+		return nil, fmt.Errorf("failed to connect to peer at %s: %w", addr, err)
 	}
 
-	log.Printf("✅ Successfully connected to peer %s at %s:%d", pid, ip, port)
-
-	// Store peer information
-	p.storePeerInfo(pid, "outbound")
+	log.Printf("✅ Successfully connected to peer %s at %s", pid, addr)
+	return p.validateConnectedPeer(pid)
+}
 
-	// Validate that this peer is running our application
-	if !p.validatePeer(pid) {
-		log.Printf("❌ Peer %s is not running our application, disconnecting", pid)
-		p.host.Network().ClosePeer(pid)
-		return nil, fmt.Errorf("peer %s is not running our application", pid)
+// dialMultiaddr builds the TCP multiaddr to dial for a host and port: /ip4/ or /ip6/ for
+// IP addresses, /dns/ for names. A host that already is a multiaddr is used as-is, and the
+// peer ID it ends with is returned.
+func dialMultiaddr(host string, port int) (multiaddr.Multiaddr, peer.ID, error) {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "/") {
+		addr, err := multiaddr.NewMultiaddr(host)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid multiaddr %s: %w", host, err)
+		}
+		transport, id := peer.SplitAddr(addr)
+		if transport == nil {
+			return nil, "", fmt.Errorf("multiaddr %s has no address to dial", host)
+		}
+		return transport, id, nil
 	}
 
-	// Open stream to get node info
-	stream, err := p.host.NewStream(ctx, pid, protocol.ID(AppProtocol))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return nil, "", fmt.Errorf("IP address or host name is required")
 	}
-	defer stream.Close()
-
-	// Send discovery message
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetInfo,
-		Payload: nil,
+	if port < 1 || port > 65535 {
+		return nil, "", fmt.Errorf("invalid port %d", port)
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send message: %w", err)
+	var addrStr string
+	switch ip := net.ParseIP(host); {
+	case ip == nil:
+		addrStr = fmt.Sprintf("/dns/%s/tcp/%d", host, port)
+	case ip.To4() != nil:
+		addrStr = fmt.Sprintf("/ip4/%s/tcp/%d", ip, port)
+	default:
+		addrStr = fmt.Sprintf("/ip6/%s/tcp/%d", ip, port)
 	}
 
-	// Read response
-	var response models.P2PMessage
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	addr, err := multiaddr.NewMultiaddr(addrStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create multiaddr: %w", err)
 	}
+	return addr, "", nil
+}
 
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
+// ConnectToAddresses connects to a peer at any of the given multiaddrs, such as those of an invite
+func (p *P2PService) ConnectToAddresses(peerIDStr string, addresses []string) (*models.NodeInfoResponse, error) {
+	pid, err := peer.Decode(peerIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
+		return nil, fmt.Errorf("invalid peer ID: %w", err)
 	}
 
-	var nodeInfo models.NodeInfoResponse
-	if err := json.Unmarshal(responseData, &nodeInfo); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal node info: %w", err)
+	peerInfo := peer.AddrInfo{ID: pid}
+	for _, address := range addresses {
+		addr, err := multiaddr.NewMultiaddr(address)
+		if err != nil {
+			log.Printf("⚠️ Skipping invalid address %s of peer %s: %v", address, pid, err)
+			continue
+		}
+		peerInfo.Addrs = append(peerInfo.Addrs, addr)
 	}
-
-	log.Printf("📋 Received node info from peer %s", pid)
-	return &nodeInfo, nil
-}
-
-// GetConnectionInfo returns connection information for sharing
-func (p *P2PService) GetConnectionInfo() *models.ConnectionInfo {
-	connectionInfo := &models.ConnectionInfo{
-		PeerID:       p.host.ID().String(),
-		IsPublicNode: p.isPublicNode,
+	if len(peerInfo.Addrs) == 0 {
+		return nil, fmt.Errorf("no valid address for peer %s", pid)
+	}
+
+	log.Printf("🌐 Attempting to connect to peer %s at %d address(es)", pid, len(peerInfo.Addrs))
+
+	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
+	defer cancel()
+
+	if err := p.host.Connect(ctx, peerInfo); err != nil {
+		return nil, fmt.Errorf("failed to connect to peer %s at any of its addresses: %w", pid, err)
+	}
+
+	log.Printf("✅ Successfully connected to peer %s", pid)
+	return p.validateConnectedPeer(pid)
+}
+
+// validateConnectedPeer checks that a peer we just connected to runs our application and
+// returns its node info, disconnecting peers that don't
+func (p *P2PService) validateConnectedPeer(pid peer.ID) (*models.NodeInfoResponse, error) {
+	// Store peer information
+	p.storePeerInfo(pid, "outbound")
+
+	// Validate that this peer is running our application
+	if !p.validatePeer(pid) {
+		log.Printf("❌ Peer %s is not running our application, disconnecting", pid)
+		p.host.Network().ClosePeer(pid)
+		return nil, fmt.Errorf("peer %s is not running our application", pid)
+	}
+
+	// Request node info from the peer
+	nodeInfo, err := requestPeer[models.NodeInfoResponse](p, pid, models.MessageTypeGetInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("📋 Received node info from peer %s", pid)
+	return nodeInfo, nil
+}
+
+// GetConnectionInfo returns connection information for sharing
+func (p *P2PService) GetConnectionInfo() *models.ConnectionInfo {
+	reachability := p.getReachability()
+	connectionInfo := &models.ConnectionInfo{
+		PeerID:            p.host.ID().String(),
+		ObservedAddresses: p.observedPublicAddresses(),
+		Reachability:      reachabilityName(reachability),
+		IsPublicNode:      reachability == network.ReachabilityPublic,
 	}
 
 	// Get all listening addresses
 	var localAddresses []string
+	for _, addr := range p.host.Addrs() {
+		localAddresses = append(localAddresses, addr.String())
+	}
+
+	// The first observed public TCP address is the one to share
 	var publicAddress string
 	var port int
-
-	for _, addr := range p.host.Addrs() {
-		addrStr := addr.String()
-		localAddresses = append(localAddresses, addrStr)
-
-		// Extract IP and port for public addresses
-		if ip := extractIPFromMultiaddr(addrStr); ip != nil {
-			if isPublicIP(ip) && publicAddress == "" {
-				publicAddress = ip.String()
-				// Extract port from multiaddr
-				if portValue := extractPortFromMultiaddr(addrStr); portValue != 0 {
-					port = portValue
-				}
-			}
+	for _, addrStr := range connectionInfo.ObservedAddresses {
+		ip := extractIPFromMultiaddr(addrStr)
+		portValue := extractPortFromMultiaddr(addrStr)
+		if ip != nil && portValue != 0 {
+			publicAddress = ip.String()
+			port = portValue
+			break
 		}
 	}
 
@@ -1406,28 +1472,14 @@ func (p *P2PService) GetConnectionInfo() *models.ConnectionInfo {
 	return connectionInfo
 }
 
-// extractPortFromMultiaddr extracts port from multiaddr string
+// extractPortFromMultiaddr extracts the TCP port from multiaddr string
 func extractPortFromMultiaddr(addrStr string) int {
-	// Simple extraction for /ip4/x.x.x.x/tcp/port format
-	parts := []rune(addrStr)
-	var portStr string
-	var inPort bool
-
-	for i := 0; i < len(parts)-4; i++ {
-		if string(parts[i:i+5]) == "/tcp/" {
-			inPort = true
-			i += 4
-			continue
-		}
-		if inPort {
-			if parts[i] == '/' {
-				break
-			}
-			portStr += string(parts[i])
-		}
+	addr, err := multiaddr.NewMultiaddr(addrStr)
+	if err != nil {
+		return 0
 	}
 
-	if portStr != "" {
+	if portStr, err := addr.ValueForProtocol(multiaddr.P_TCP); err == nil {
 		if port, err := parsePort(portStr); err == nil {
 			return port
 		}
@@ -1484,92 +1536,36 @@ func (p *P2PService) getConnectedPeersList() models.PeerListResponse {
 
 // requestPeerListFromPeer requests the peer list from a connected peer
 func (p *P2PService) requestPeerListFromPeer(peerID peer.ID) (*models.PeerListResponse, error) {
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
-
-	// Open stream to peer
-	stream, err := p.host.NewStream(ctx, peerID, protocol.ID(AppProtocol))
+	peerListResponse, err := requestPeer[models.PeerListResponse](p, peerID, models.MessageTypeGetPeerList, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Send peer list request
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetPeerList,
-		Payload: nil,
-	}
-
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send peer list request: %w", err)
-	}
-
-	// Read response
-	var response models.P2PMessage
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&response); err != nil {
 		// Special handling for EOF - this might happen if peer has no connections
-		if err == io.EOF {
+		if errors.Is(err, io.EOF) {
 			// Return empty peer list
 			return &models.PeerListResponse{
 				Peers: []models.PeerListItem{},
 				Count: 0,
 			}, nil
 		}
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, err
 	}
 
-	if response.Type != models.MessageTypeGetPeerListResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
-	}
-
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
-	}
-
-	var peerListResponse models.PeerListResponse
-	if err := json.Unmarshal(responseData, &peerListResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal peer list: %w", err)
-	}
-
-	return &peerListResponse, nil
+	return peerListResponse, nil
 }
 
 // handleHolePunchAssistRequest handles hole punching assistance requests
-func (p *P2PService) handleHolePunchAssistRequest(payload interface{}) map[string]interface{} {
-	// Parse the request payload
-	payloadData, err := json.Marshal(payload)
-	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "failed to parse request",
-		}
-	}
-
-	var request map[string]string
-	if err := json.Unmarshal(payloadData, &request); err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "invalid request format",
+func (p *P2PService) handleHolePunchAssistRequest(request models.HolePunchAssistRequest) *models.HolePunchAssistResponse {
+	if request.TargetPeerID == "" {
+		return &models.HolePunchAssistResponse{
+			Success: false,
+			Error:   "missing target_peer_id",
 		}
 	}
 
-	targetPeerStr, exists := request["target_peer_id"]
-	if !exists {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "missing target_peer_id",
-		}
-	}
-
-	targetPeerID, err := peer.Decode(targetPeerStr)
+	targetPeerID, err := peer.Decode(request.TargetPeerID)
 	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "invalid target peer ID",
+		return &models.HolePunchAssistResponse{
+			Success: false,
+			Error:   "invalid target peer ID",
 		}
 	}
 
@@ -1579,18 +1575,18 @@ func (p *P2PService) handleHolePunchAssistRequest(payload interface{}) map[strin
 	p.peerInfoMutex.RUnlock()
 
 	if !exists {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "target peer not connected to this node",
+		return &models.HolePunchAssistResponse{
+			Success: false,
+			Error:   "target peer not connected to this node",
 		}
 	}
 
 	// Return target peer's connection information
-	return map[string]interface{}{
-		"success":     true,
-		"target_peer": targetPeerStr,
-		"addresses":   targetInfo.Addresses,
-		"name":        targetInfo.Name,
+	return &models.HolePunchAssistResponse{
+		Success:    true,
+		TargetPeer: request.TargetPeerID,
+		Addresses:  targetInfo.Addresses,
+		Name:       targetInfo.Name,
 	}
 }
 
@@ -1613,66 +1609,28 @@ func (p *P2PService) ConnectToSecondDegreePeer(targetPeerID, viaPeerID string) (
 	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
 	defer cancel()
 
-	stream, err := p.host.NewStream(ctx, viaPeer, protocol.ID(AppProtocol))
+	assistRequest := models.HolePunchAssistRequest{TargetPeerID: targetPeerID}
+	assistResponse, err := requestPeer[models.HolePunchAssistResponse](p, viaPeer, models.MessageTypeHolePunchAssist, assistRequest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open stream to via peer: %w", err)
-	}
-	defer stream.Close()
-
-	// Send hole punch assistance request
-	assistRequest := models.P2PMessage{
-		Type: models.MessageTypeHolePunchAssist,
-		Payload: map[string]string{
-			"target_peer_id": targetPeerID,
-		},
+		return nil, fmt.Errorf("failed to request hole punch assistance: %w", err)
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(assistRequest); err != nil {
-		return nil, fmt.Errorf("failed to send hole punch request: %w", err)
-	}
-
-	// Read response
-	var response models.P2PMessage
-	decoder := json.NewDecoder(stream)
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode hole punch response: %w", err)
-	}
-
-	// Parse the response
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
-	}
-
-	var assistResponse map[string]interface{}
-	if err := json.Unmarshal(responseData, &assistResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal assist response: %w", err)
-	}
-
-	success, exists := assistResponse["success"].(bool)
-	if !exists || !success {
+	if !assistResponse.Success {
 		errorMsg := "hole punch assistance failed"
-		if errStr, exists := assistResponse["error"].(string); exists {
-			errorMsg = errStr
+		if assistResponse.Error != "" {
+			errorMsg = assistResponse.Error
 		}
 		return nil, fmt.Errorf("hole punch assistance failed: %s", errorMsg)
 	}
 
 	// Extract target peer addresses
-	addresses, exists := assistResponse["addresses"].([]interface{})
-	if !exists || len(addresses) == 0 {
+	if len(assistResponse.Addresses) == 0 {
 		return nil, fmt.Errorf("no addresses provided for target peer")
 	}
 
 	// Try to connect using the provided addresses
 	var lastErr error
-	for _, addrInterface := range addresses {
-		addrStr, ok := addrInterface.(string)
-		if !ok {
-			continue
-		}
-
+	for _, addrStr := range assistResponse.Addresses {
 		// Parse multiaddr
 		addr, err := multiaddr.NewMultiaddr(addrStr)
 		if err != nil {
@@ -1719,7 +1677,7 @@ func (p *P2PService) ConnectToSecondDegreePeer(targetPeerID, viaPeerID string) (
 }
 
 // handleGetDocsRequest handles P2P request for docs list
-func (p *P2PService) handleGetDocsRequest() *models.DocsResponse {
+func (p *P2PService) handleGetDocsRequest(peerID peer.ID) *models.DocsResponse {
 	if p.container == nil || p.container.GetDirectoryService() == nil {
 		return &models.DocsResponse{
 			Docs:  []models.Doc{},
@@ -1736,6 +1694,9 @@ func (p *P2PService) handleGetDocsRequest() *models.DocsResponse {
 		}
 	}
 
+	// Only the docs the peer is allowed to see
+	docs = p.contentAccess(peerID).FilterDocs(docs)
+
 	return &models.DocsResponse{
 		Docs:  docs,
 		Count: len(docs),
@@ -1743,8 +1704,8 @@ func (p *P2PService) handleGetDocsRequest() *models.DocsResponse {
 }
 
 // handleGetFriendsRequest handles P2P request for friends list
-func (p *P2PService) handleGetFriendsRequest() *models.FriendsResponse {
-	if p.dbService == nil {
+func (p *P2PService) handleGetFriendsRequest(peerID peer.ID) *models.FriendsResponse {
+	if p.dbService == nil || !p.contentAccess(peerID).Discloses(models.PrivacyItemFriendsList) {
 		return &models.FriendsResponse{
 			Friends: []models.Friend{},
 			Count:   0,
@@ -1760,6 +1721,12 @@ func (p *P2PService) handleGetFriendsRequest() *models.FriendsResponse {
 		}
 	}
 
+	// Our friend groups are for our eyes only, their signed profiles are relayed along
+	for i := range friends {
+		friends[i].Groups = nil
+		friends[i].Profile = p.storedPeerProfile(friends[i].PeerID)
+	}
+
 	return &models.FriendsResponse{
 		Friends: friends,
 		Count:   len(friends),
@@ -1767,23 +1734,15 @@ func (p *P2PService) handleGetFriendsRequest() *models.FriendsResponse {
 }
 
 // handleGetDocRequest handles P2P request for specific doc
-func (p *P2PService) handleGetDocRequest(payload interface{}) *models.DocResponse {
+func (p *P2PService) handleGetDocRequest(peerID peer.ID, docRequest models.DocRequest) *models.DocResponse {
 	if p.container == nil || p.container.GetDirectoryService() == nil {
 		return &models.DocResponse{
 			Doc: nil,
 		}
 	}
 
-	// Parse the request payload
-	requestData, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Failed to marshal doc request payload: %v", err)
-		return &models.DocResponse{Doc: nil}
-	}
-
-	var docRequest models.DocRequest
-	if err := json.Unmarshal(requestData, &docRequest); err != nil {
-		log.Printf("Failed to parse doc request: %v", err)
+	if !p.contentAccess(peerID).CanReadDoc(docRequest.Filename) {
+		log.Printf("🔐 Doc %s is not visible to %s", docRequest.Filename, peerID)
 		return &models.DocResponse{Doc: nil}
 	}
 
@@ -1793,12 +1752,17 @@ func (p *P2PService) handleGetDocRequest(payload interface{}) *models.DocRespons
 		return &models.DocResponse{Doc: nil}
 	}
 
+	if commentService := p.container.GetCommentService(); commentService != nil {
+		commentService.AttachDocComments(doc)
+	}
+
 	return &models.DocResponse{Doc: doc}
 }
 
 // handleGetFilesRequest handles P2P request for files table
-func (p *P2PService) handleGetFilesRequest() *models.FilesResponse {
-	if p.container == nil || p.container.GetDatabase() == nil {
+func (p *P2PService) handleGetFilesRequest(peerID peer.ID) *models.FilesResponse {
+	access := p.contentAccess(peerID)
+	if p.container == nil || p.container.GetDatabase() == nil || !access.Discloses(models.PrivacyItemFiles) {
 		return &models.FilesResponse{
 			Files:  []models.FileRecord{},
 			PeerID: p.GetNode().ID.String(),
@@ -1825,6 +1789,9 @@ func (p *P2PService) handleGetFilesRequest() *models.FilesResponse {
 		}
 	}
 
+	// Only the files the peer is allowed to see
+	ownFiles = access.FilterFiles(ownFiles)
+
 	return &models.FilesResponse{
 		Files:  ownFiles,
 		PeerID: myPeerID,
@@ -1834,164 +1801,29 @@ func (p *P2PService) handleGetFilesRequest() *models.FilesResponse {
 
 // RequestPeerDocs requests docs list from a peer
 func (p *P2PService) RequestPeerDocs(peerID string) (*models.DocsResponse, error) {
-	peer, err := peer.Decode(peerID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
-
-	stream, err := p.host.NewStream(ctx, peer, protocol.ID(AppProtocol))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Send docs request
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetDocs,
-		Payload: models.DocsRequest{},
-	}
-
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send docs request: %w", err)
-	}
-
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if response.Type != models.MessageTypeGetDocsResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
-	}
-
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
-	}
-
-	var docsResponse models.DocsResponse
-	if err := json.Unmarshal(responseData, &docsResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse docs response: %w", err)
-	}
-
-	return &docsResponse, nil
+	return requestPeerByID[models.DocsResponse](p, peerID, models.MessageTypeGetDocs, models.DocsRequest{})
 }
 
 // RequestPeerFiles requests files table from a peer
 func (p *P2PService) RequestPeerFiles(peerID string) (*models.FilesResponse, error) {
-	peer, err := peer.Decode(peerID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
-
-	stream, err := p.host.NewStream(ctx, peer, protocol.ID(AppProtocol))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Send files request
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetFiles,
-		Payload: models.FilesRequest{},
-	}
-
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send files request: %w", err)
-	}
-
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if response.Type != models.MessageTypeGetFilesResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
-	}
-
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
-	}
-
-	var filesResponse models.FilesResponse
-	if err := json.Unmarshal(responseData, &filesResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal files response: %w", err)
-	}
-
-	return &filesResponse, nil
+	return requestPeerByID[models.FilesResponse](p, peerID, models.MessageTypeGetFiles, models.FilesRequest{})
 }
 
 // RequestPeerDoc requests a specific doc from a peer
 func (p *P2PService) RequestPeerDoc(peerID, filename string) (*models.DocResponse, error) {
-	peer, err := peer.Decode(peerID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
-
-	stream, err := p.host.NewStream(ctx, peer, protocol.ID(AppProtocol))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Send doc request
-	msg := models.P2PMessage{
-		Type: models.MessageTypeGetDoc,
-		Payload: models.DocRequest{
-			Filename: filename,
-		},
-	}
-
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send doc request: %w", err)
-	}
-
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if response.Type != models.MessageTypeGetDocResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
-	}
-
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
+	response, err := requestPeerByID[models.DocResponse](p, peerID, models.MessageTypeGetDoc, models.DocRequest{Filename: filename})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
+		return nil, err
 	}
 
-	var docResponse models.DocResponse
-	if err := json.Unmarshal(responseData, &docResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse doc response: %w", err)
+	if response.Doc != nil {
+		VerifyContentComments(peerID, response.Doc.Comments)
 	}
-
-	return &docResponse, nil
+	return response, nil
 }
 
 // handleGetGalleriesRequest handles P2P request for galleries list
-func (p *P2PService) handleGetGalleriesRequest() *models.GalleriesResponse {
+func (p *P2PService) handleGetGalleriesRequest(peerID peer.ID) *models.GalleriesResponse {
 	if p.container == nil || p.container.GetDirectoryService() == nil {
 		return &models.GalleriesResponse{
 			Galleries: []models.MediaGallery{},
@@ -2008,6 +1840,9 @@ func (p *P2PService) handleGetGalleriesRequest() *models.GalleriesResponse {
 		}
 	}
 
+	// Only the galleries and files the peer is allowed to see
+	galleries = p.contentAccess(peerID).FilterGalleries(galleries)
+
 	return &models.GalleriesResponse{
 		Galleries: galleries,
 		Count:     len(galleries),
@@ -2015,26 +1850,13 @@ func (p *P2PService) handleGetGalleriesRequest() *models.GalleriesResponse {
 }
 
 // handleGetGalleryRequest handles P2P request for specific gallery
-func (p *P2PService) handleGetGalleryRequest(payload interface{}) *models.GalleryResponse {
+func (p *P2PService) handleGetGalleryRequest(peerID peer.ID, galleryRequest models.GalleryRequest) *models.GalleryResponse {
 	if p.container == nil || p.container.GetDirectoryService() == nil {
 		return &models.GalleryResponse{
 			Gallery: nil,
 		}
 	}
 
-	// Parse the request payload
-	requestData, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Failed to marshal gallery request payload: %v", err)
-		return &models.GalleryResponse{Gallery: nil}
-	}
-
-	var galleryRequest models.GalleryRequest
-	if err := json.Unmarshal(requestData, &galleryRequest); err != nil {
-		log.Printf("Failed to parse gallery request: %v", err)
-		return &models.GalleryResponse{Gallery: nil}
-	}
-
 	// Get gallery files using unified method
 	files, err := p.container.GetDirectoryService().GetMediaGalleryFiles(models.MediaTypeImage, galleryRequest.GalleryName)
 	if err != nil {
@@ -2049,11 +1871,20 @@ func (p *P2PService) handleGetGalleryRequest(payload interface{}) *models.Galler
 		Files:     files,
 	}
 
+	if !p.contentAccess(peerID).FilterGallery(gallery) {
+		log.Printf("🔐 Gallery %s is not visible to %s", galleryRequest.GalleryName, peerID)
+		return &models.GalleryResponse{Gallery: nil}
+	}
+
+	if commentService := p.container.GetCommentService(); commentService != nil {
+		commentService.AttachGalleryComments(gallery)
+	}
+
 	return &models.GalleryResponse{Gallery: gallery}
 }
 
 // handleGetGalleryImageRequest handles P2P request for specific gallery image
-func (p *P2PService) handleGetGalleryImageRequest(payload interface{}) *models.GalleryImageResponse {
+func (p *P2PService) handleGetGalleryImageRequest(peerID peer.ID, imageRequest models.GalleryImageRequest) *models.GalleryImageResponse {
 	if p.container == nil || p.container.GetDirectoryService() == nil {
 		return &models.GalleryImageResponse{
 			ImageData: "",
@@ -2062,16 +1893,8 @@ func (p *P2PService) handleGetGalleryImageRequest(payload interface{}) *models.G
 		}
 	}
 
-	// Parse the request payload
-	requestData, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Failed to marshal gallery image request payload: %v", err)
-		return &models.GalleryImageResponse{ImageData: "", Filename: "", Size: 0}
-	}
-
-	var imageRequest models.GalleryImageRequest
-	if err := json.Unmarshal(requestData, &imageRequest); err != nil {
-		log.Printf("Failed to parse gallery image request: %v", err)
+	if !p.contentAccess(peerID).CanReadMediaFile(models.MediaTypeImage, imageRequest.GalleryName, imageRequest.ImageName) {
+		log.Printf("🔐 Image %s in gallery %s is not visible to %s", imageRequest.ImageName, imageRequest.GalleryName, peerID)
 		return &models.GalleryImageResponse{ImageData: "", Filename: "", Size: 0}
 	}
 
@@ -2124,163 +1947,157 @@ func (p *P2PService) handleGetGalleryImageRequest(payload interface{}) *models.G
 
 // RequestPeerGalleries requests galleries list from a peer
 func (p *P2PService) RequestPeerGalleries(peerID string) (*models.GalleriesResponse, error) {
-	peer, err := peer.Decode(peerID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
+	return requestPeerByID[models.GalleriesResponse](p, peerID, models.MessageTypeGetGalleries, models.GalleriesRequest{})
+}
 
-	stream, err := p.host.NewStream(ctx, peer, protocol.ID(AppProtocol))
+// RequestPeerGallery requests a specific gallery from a peer
+func (p *P2PService) RequestPeerGallery(peerID, galleryName string) (*models.GalleryResponse, error) {
+	response, err := requestPeerByID[models.GalleryResponse](p, peerID, models.MessageTypeGetGallery, models.GalleryRequest{GalleryName: galleryName})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Send galleries request
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetGalleries,
-		Payload: models.GalleriesRequest{},
+		return nil, err
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send galleries request: %w", err)
+	if response.Gallery != nil {
+		for _, comments := range response.Gallery.Comments {
+			VerifyContentComments(peerID, comments)
+		}
 	}
+	return response, nil
+}
 
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+// RequestPeerGalleryImage requests a specific image from a peer's gallery
+func (p *P2PService) RequestPeerGalleryImage(peerID, galleryName, imageName string) (*models.GalleryImageResponse, error) {
+	return requestPeerByID[models.GalleryImageResponse](p, peerID, models.MessageTypeGetGalleryImage, models.GalleryImageRequest{GalleryName: galleryName, ImageName: imageName})
+}
 
-	if response.Type != models.MessageTypeGetGalleriesResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
+// handleGetMediaGalleriesRequest handles P2P request for the media galleries list of a given type
+func (p *P2PService) handleGetMediaGalleriesRequest(peerID peer.ID, galleriesRequest models.MediaGalleriesRequest) *models.MediaGalleriesResponse {
+	if p.container == nil || p.container.GetDirectoryService() == nil {
+		return &models.MediaGalleriesResponse{
+			MediaType: galleriesRequest.MediaType,
+			Galleries: []models.MediaGallery{},
+			Count:     0,
+		}
 	}
 
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
+	galleries, err := p.container.GetDirectoryService().GetMediaGalleries(galleriesRequest.MediaType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
+		log.Printf("Failed to get %s galleries for P2P request: %v", galleriesRequest.MediaType, err)
+		return &models.MediaGalleriesResponse{
+			MediaType: galleriesRequest.MediaType,
+			Galleries: []models.MediaGallery{},
+			Count:     0,
+		}
 	}
 
-	var galleriesResponse models.GalleriesResponse
-	if err := json.Unmarshal(responseData, &galleriesResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse galleries response: %w", err)
-	}
+	// Only the galleries and files the peer is allowed to see
+	galleries = p.contentAccess(peerID).FilterGalleries(galleries)
 
-	return &galleriesResponse, nil
+	return &models.MediaGalleriesResponse{
+		MediaType: galleriesRequest.MediaType,
+		Galleries: galleries,
+		Count:     len(galleries),
+	}
 }
 
-// RequestPeerGallery requests a specific gallery from a peer
-func (p *P2PService) RequestPeerGallery(peerID, galleryName string) (*models.GalleryResponse, error) {
-	peer, err := peer.Decode(peerID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
+// handleGetMediaGalleryRequest handles P2P request for a specific media gallery
+func (p *P2PService) handleGetMediaGalleryRequest(peerID peer.ID, galleryRequest models.MediaGalleryRequest) *models.MediaGalleryResponse {
+	if p.container == nil || p.container.GetDirectoryService() == nil {
+		return &models.MediaGalleryResponse{Gallery: nil}
 	}
 
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
-
-	stream, err := p.host.NewStream(ctx, peer, protocol.ID(AppProtocol))
+	files, err := p.container.GetDirectoryService().GetMediaGalleryFiles(galleryRequest.MediaType, galleryRequest.GalleryName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
+		log.Printf("Failed to get %s gallery %s for P2P request: %v", galleryRequest.MediaType, galleryRequest.GalleryName, err)
+		return &models.MediaGalleryResponse{Gallery: nil}
 	}
-	defer stream.Close()
 
-	// Send gallery request
-	msg := models.P2PMessage{
-		Type: models.MessageTypeGetGallery,
-		Payload: models.GalleryRequest{
-			GalleryName: galleryName,
-		},
+	gallery := &models.MediaGallery{
+		Name:      galleryRequest.GalleryName,
+		MediaType: galleryRequest.MediaType,
+		FileCount: len(files),
+		Files:     files,
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send gallery request: %w", err)
+	if !p.contentAccess(peerID).FilterGallery(gallery) {
+		log.Printf("🔐 %s gallery %s is not visible to %s", galleryRequest.MediaType, galleryRequest.GalleryName, peerID)
+		return &models.MediaGalleryResponse{Gallery: nil}
 	}
 
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if commentService := p.container.GetCommentService(); commentService != nil {
+		commentService.AttachGalleryComments(gallery)
 	}
 
-	if response.Type != models.MessageTypeGetGalleryResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
-	}
+	return &models.MediaGalleryResponse{Gallery: gallery}
+}
 
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
+// handleGetMediaFileRequest handles P2P request for a specific file from a media gallery
+func (p *P2PService) handleGetMediaFileRequest(peerID peer.ID, fileRequest models.MediaFileRequest) *models.MediaFileResponse {
+	if p.container == nil || p.container.GetDirectoryService() == nil {
+		return &models.MediaFileResponse{}
 	}
 
-	var galleryResponse models.GalleryResponse
-	if err := json.Unmarshal(responseData, &galleryResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse gallery response: %w", err)
+	if !p.contentAccess(peerID).CanReadMediaFile(fileRequest.MediaType, fileRequest.GalleryName, fileRequest.FileName) {
+		log.Printf("🔐 %s file %s in gallery %s is not visible to %s", fileRequest.MediaType, fileRequest.FileName, fileRequest.GalleryName, peerID)
+		return &models.MediaFileResponse{MediaType: fileRequest.MediaType}
 	}
 
-	return &galleryResponse, nil
-}
-
-// RequestPeerGalleryImage requests a specific image from a peer's gallery
-func (p *P2PService) RequestPeerGalleryImage(peerID, galleryName, imageName string) (*models.GalleryImageResponse, error) {
-	peer, err := peer.Decode(peerID)
+	// Resolving the path also checks that the file belongs to the gallery
+	filePath, err := p.container.GetDirectoryService().GetMediaFilePath(fileRequest.MediaType, fileRequest.GalleryName, fileRequest.FileName)
 	if err != nil {
-		return nil, fmt.Errorf("invalid peer ID: %w", err)
+		log.Printf("Failed to resolve %s file %s in gallery %s: %v", fileRequest.MediaType, fileRequest.FileName, fileRequest.GalleryName, err)
+		return &models.MediaFileResponse{MediaType: fileRequest.MediaType}
 	}
 
-	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second) // Longer timeout for image transfer
-	defer cancel()
-
-	stream, err := p.host.NewStream(ctx, peer, protocol.ID(AppProtocol))
+	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
+		log.Printf("Failed to stat media file %s: %v", filePath, err)
+		return &models.MediaFileResponse{MediaType: fileRequest.MediaType}
 	}
-	defer stream.Close()
 
-	// Send gallery image request
-	msg := models.P2PMessage{
-		Type: models.MessageTypeGetGalleryImage,
-		Payload: models.GalleryImageRequest{
-			GalleryName: galleryName,
-			ImageName:   imageName,
-		},
+	// Limit media file size for transmission
+	if fileInfo.Size() > MaxMediaFileTransferSize {
+		log.Printf("Media file %s too large (%d bytes), skipping", fileRequest.FileName, fileInfo.Size())
+		return &models.MediaFileResponse{MediaType: fileRequest.MediaType}
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send gallery image request: %w", err)
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Printf("Failed to read media file %s: %v", filePath, err)
+		return &models.MediaFileResponse{MediaType: fileRequest.MediaType}
 	}
 
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	return &models.MediaFileResponse{
+		MediaType: fileRequest.MediaType,
+		FileData:  base64.StdEncoding.EncodeToString(fileData),
+		Filename:  fileRequest.FileName,
+		Size:      len(fileData),
 	}
+}
 
-	if response.Type != models.MessageTypeGetGalleryImageResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
-	}
+// RequestPeerMediaGalleries requests the media galleries list of a given type from a peer
+func (p *P2PService) RequestPeerMediaGalleries(peerID string, mediaType models.MediaType) (*models.MediaGalleriesResponse, error) {
+	return requestPeerByID[models.MediaGalleriesResponse](p, peerID, models.MessageTypeGetMediaGalleries, models.MediaGalleriesRequest{MediaType: mediaType})
+}
 
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
+// RequestPeerMediaGallery requests a specific media gallery from a peer
+func (p *P2PService) RequestPeerMediaGallery(peerID string, mediaType models.MediaType, galleryName string) (*models.MediaGalleryResponse, error) {
+	response, err := requestPeerByID[models.MediaGalleryResponse](p, peerID, models.MessageTypeGetMediaGallery, models.MediaGalleryRequest{MediaType: mediaType, GalleryName: galleryName})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
+		return nil, err
 	}
 
-	var imageResponse models.GalleryImageResponse
-	if err := json.Unmarshal(responseData, &imageResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse gallery image response: %w", err)
+	if response.Gallery != nil {
+		for _, comments := range response.Gallery.Comments {
+			VerifyContentComments(peerID, comments)
+		}
 	}
+	return response, nil
+}
 
-	return &imageResponse, nil
+// RequestPeerMediaFile requests a specific file from a peer's media gallery
+func (p *P2PService) RequestPeerMediaFile(peerID string, mediaType models.MediaType, galleryName, fileName string) (*models.MediaFileResponse, error) {
+	return requestPeerByID[models.MediaFileResponse](p, peerID, models.MessageTypeGetMediaFile, models.MediaFileRequest{MediaType: mediaType, GalleryName: galleryName, FileName: fileName})
 }
 
 // getNodeInfo creates a NodeInfoResponse for P2P communication
@@ -2313,50 +2130,82 @@ func (p *P2PService) getNodeInfo() *models.NodeInfoResponse {
 
 // FetchPeerFriends requests friends list from a remote peer
 func (p *P2PService) FetchPeerFriends(peerID peer.ID) ([]models.Friend, error) {
-	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
-	defer cancel()
-
-	stream, err := p.host.NewStream(ctx, peerID, protocol.ID(AppProtocol))
+	friendsResponse, err := requestPeer[models.FriendsResponse](p, peerID, models.MessageTypeGetFriends, models.FriendsRequest{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open stream: %w", err)
+		return nil, err
 	}
-	defer stream.Close()
 
-	// Send friends request
-	msg := models.P2PMessage{
-		Type:    models.MessageTypeGetFriends,
-		Payload: models.FriendsRequest{},
+	log.Printf("✅ Successfully fetched %d friends from peer %s", len(friendsResponse.Friends), peerID)
+	return p.verifyRelayedProfiles(friendsResponse.Friends), nil
+}
+
+// receivePeerProfile verifies and stores a profile record presented for peerID and returns the
+// newest verified record we have of the peer, or nil if the record is missing or invalid
+func (p *P2PService) receivePeerProfile(record *models.ProfileRecord, peerID string) *models.ProfileRecord {
+	if record == nil || p.container == nil || p.container.GetProfileService() == nil {
+		return nil
 	}
 
-	encoder := json.NewEncoder(stream)
-	if err := encoder.Encode(msg); err != nil {
-		return nil, fmt.Errorf("failed to send friends request: %w", err)
+	newest, err := p.container.GetProfileService().ReceiveProfile(record, peerID)
+	if err != nil {
+		log.Printf("⚠️ Ignoring profile record for %s: %v", peerID, err)
+		return nil
 	}
+	return newest
+}
 
-	// Read response
-	decoder := json.NewDecoder(stream)
-	var response models.P2PMessage
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// handleProfileUpdate applies a profile record a connected peer pushed after changing its profile
+func (p *P2PService) handleProfileUpdate(peerID peer.ID, update *models.ProfileUpdatePayload) (*models.ProfileUpdateAck, error) {
+	record := p.receivePeerProfile(update.Profile, peerID.String())
+	if record == nil {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "invalid profile record")
+	}
+
+	if update.Avatar != nil {
+		if err := p.saveReceivedAvatar(peerID, update.Avatar, record.AvatarHash); err != nil {
+			log.Printf("⚠️ Failed to save pushed avatar of %s: %v", peerID, err)
+		}
 	}
 
-	if response.Type != models.MessageTypeGetFriendsResp {
-		return nil, fmt.Errorf("unexpected response type: %s", response.Type)
+	p.peersMutex.RLock()
+	validated := p.validatedPeers[peerID]
+	p.peersMutex.RUnlock()
+	if validated {
+		p.markPeerValidationWithName(peerID, true, record.Name)
 	}
 
-	// Parse response payload
-	responseData, err := json.Marshal(response.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response payload: %w", err)
+	log.Printf("🪪 Peer %s pushed profile record #%d (name: %s)", peerID, record.Seq, record.Name)
+	return &models.ProfileUpdateAck{Success: true}, nil
+}
+
+// verifyRelayedProfiles checks the profile records a peer relayed along with its friends list.
+// Friends with a valid record get the name from the newest record we know, invalid records are dropped.
+func (p *P2PService) verifyRelayedProfiles(friends []models.Friend) []models.Friend {
+	for i := range friends {
+		if friends[i].Profile == nil {
+			continue
+		}
+
+		friends[i].Profile = p.receivePeerProfile(friends[i].Profile, friends[i].PeerID)
+		if friends[i].Profile != nil {
+			friends[i].PeerName = friends[i].Profile.Name
+		}
 	}
+	return friends
+}
 
-	var friendsResponse models.FriendsResponse
-	if err := json.Unmarshal(responseData, &friendsResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse friends response: %w", err)
+// storedPeerProfile returns the newest profile record we have of a peer for relaying, or nil
+func (p *P2PService) storedPeerProfile(peerID string) *models.ProfileRecord {
+	if p.dbService == nil {
+		return nil
 	}
 
-	log.Printf("✅ Successfully fetched %d friends from peer %s", len(friendsResponse.Friends), peerID)
-	return friendsResponse.Friends, nil
+	record, err := p.dbService.GetPeerProfile(peerID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load profile record of %s: %v", peerID, err)
+		return nil
+	}
+	return record
 }
 
 // FetchAndSavePeerFriends fetches friends from a remote peer and saves them to the database
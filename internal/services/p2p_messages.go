@@ -0,0 +1,104 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+)
+
+func init() {
+	registerMessageHandlers(coreMessageHandlers)
+}
+
+// coreMessageHandlers returns the handlers for node info, peer lists, hole punching, docs, files, galleries and friends
+func coreMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypeGetInfo, models.MessageTypeGetInfoResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *struct{}) (*models.NodeInfoResponse, error) {
+				// Return node and folder information
+				return p.getNodeInfo(), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeDiscovery, models.MessageTypeDiscoveryResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.DiscoveryRequest) (*models.NodeInfoResponse, error) {
+				return p.getNodeInfo(), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetPeerList, models.MessageTypeGetPeerListResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *struct{}) (models.PeerListResponse, error) {
+				log.Printf("📋 Processing peer list request from %s", peerID)
+				peerList := p.getConnectedPeersList()
+				log.Printf("📋 Prepared peer list response with %d peers for %s", peerList.Count, peerID)
+				return peerList, nil
+			}),
+
+		NewMessageHandler(models.MessageTypeHolePunchAssist, models.MessageTypeHolePunchResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.HolePunchAssistRequest) (*models.HolePunchAssistResponse, error) {
+				return p.handleHolePunchAssistRequest(*request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetDocs, models.MessageTypeGetDocsResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.DocsRequest) (*models.DocsResponse, error) {
+				log.Printf("📝 Processing docs request from %s", peerID)
+				return p.handleGetDocsRequest(peerID), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetDoc, models.MessageTypeGetDocResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.DocRequest) (*models.DocResponse, error) {
+				log.Printf("📝 Processing doc request from %s", peerID)
+				return p.handleGetDocRequest(peerID, *request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetFiles, models.MessageTypeGetFilesResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.FilesRequest) (*models.FilesResponse, error) {
+				log.Printf("📁 Processing files table request from %s", peerID)
+				return p.handleGetFilesRequest(peerID), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetGalleries, models.MessageTypeGetGalleriesResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.GalleriesRequest) (*models.GalleriesResponse, error) {
+				log.Printf("📷 Processing galleries request from %s", peerID)
+				return p.handleGetGalleriesRequest(peerID), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetGallery, models.MessageTypeGetGalleryResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.GalleryRequest) (*models.GalleryResponse, error) {
+				log.Printf("📷 Processing gallery request from %s", peerID)
+				return p.handleGetGalleryRequest(peerID, *request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetGalleryImage, models.MessageTypeGetGalleryImageResp, 30*time.Second,
+			func(peerID peer.ID, request *models.GalleryImageRequest) (*models.GalleryImageResponse, error) {
+				log.Printf("📷 Processing gallery image request from %s", peerID)
+				return p.handleGetGalleryImageRequest(peerID, *request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetMediaGalleries, models.MessageTypeGetMediaGalleriesResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.MediaGalleriesRequest) (*models.MediaGalleriesResponse, error) {
+				log.Printf("🗂️ Processing media galleries request from %s", peerID)
+				return p.handleGetMediaGalleriesRequest(peerID, *request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetMediaGallery, models.MessageTypeGetMediaGalleryResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.MediaGalleryRequest) (*models.MediaGalleryResponse, error) {
+				log.Printf("🗂️ Processing media gallery request from %s", peerID)
+				return p.handleGetMediaGalleryRequest(peerID, *request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetMediaFile, models.MessageTypeGetMediaFileResp, 2*time.Minute,
+			func(peerID peer.ID, request *models.MediaFileRequest) (*models.MediaFileResponse, error) {
+				log.Printf("🗂️ Processing media file request from %s", peerID)
+				return p.handleGetMediaFileRequest(peerID, *request), nil
+			}),
+
+		NewMessageHandler(models.MessageTypeGetFriends, models.MessageTypeGetFriendsResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.FriendsRequest) (*models.FriendsResponse, error) {
+				log.Printf("👥 Processing friends request from %s", peerID)
+				return p.handleGetFriendsRequest(peerID), nil
+			}),
+	}
+}
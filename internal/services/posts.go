@@ -0,0 +1,394 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+const (
+	// DefaultPostPageSize is the number of posts returned per page unless asked otherwise
+	DefaultPostPageSize = 20
+
+	// MaxPostPageSize bounds the number of posts returned per page
+	MaxPostPageSize = 100
+
+	// postBackfillBatch is how many posts a friend returns per backfill request
+	postBackfillBatch = 100
+
+	// maxPostClockSkew is how far in the future a friend's post may be dated before we date it
+	// when we received it, so a friend's wrong clock can't hold back later backfills
+	maxPostClockSkew = 5 * time.Minute
+)
+
+func init() {
+	registerMessageHandlers(postMessageHandlers)
+}
+
+// postMessageHandlers returns the handlers for announced posts and backfill requests
+func postMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypePostAnnounce, models.MessageTypePostAnnounceResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.PostPayload) (*models.PostAnnounceAck, error) {
+				postService := p.container.GetPostService()
+				if postService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "post service not available")
+				}
+				return postService.handlePostAnnounce(peerID, request)
+			}),
+
+		NewMessageHandler(models.MessageTypeGetPosts, models.MessageTypeGetPostsResp, 30*time.Second,
+			func(peerID peer.ID, request *models.GetPostsRequest) (*models.GetPostsResponse, error) {
+				postService := p.container.GetPostService()
+				if postService == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "post service not available")
+				}
+				return postService.handleGetPosts(peerID, request)
+			}),
+	}
+}
+
+// PostService manages our posts and the friends timeline. New posts are announced to connected
+// friends, and friends that were offline pull the posts they missed when they reconnect.
+type PostService struct {
+	database   interfaces.DatabaseService
+	p2pService *P2PService
+	events     interfaces.EventPublisher
+}
+
+// NewPostService creates a new post service
+func NewPostService(database interfaces.DatabaseService, p2pService *P2PService, events interfaces.EventPublisher) *PostService {
+	return &PostService{
+		database:   database,
+		p2pService: p2pService,
+		events:     events,
+	}
+}
+
+// WatchFriends backfills a friend's posts whenever the friend connects
+func (ps *PostService) WatchFriends(events *EventBus) {
+	events.SubscribeEvents(models.EventPeerValidated, func(event models.Event) {
+		peerEvent, ok := event.Data.(models.PeerEvent)
+		if !ok {
+			return
+		}
+
+		isFriend, err := ps.database.IsFriend(peerEvent.PeerID)
+		if err != nil || !isFriend {
+			return
+		}
+
+		if err := ps.BackfillFriendPosts(peerEvent.PeerID); err != nil {
+			log.Printf("⚠️ Failed to backfill posts from %s: %v", peerEvent.PeerID, err)
+		}
+	})
+}
+
+// CreatePost stores a new post and announces it to the friends that are online
+func (ps *PostService) CreatePost(body string, media []models.PostMedia) (*models.Post, error) {
+	body = strings.TrimSpace(body)
+	if body == "" && len(media) == 0 {
+		return nil, utils.NewValidationError("body", "post is empty")
+	}
+	if len(body) > models.MaxPostLength {
+		return nil, utils.NewValidationError("body", fmt.Sprintf("post is longer than %d bytes", models.MaxPostLength))
+	}
+	if err := validatePostMedia(media); err != nil {
+		return nil, err
+	}
+
+	postID, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	authorName, _ := ps.database.GetSetting("name")
+	post := &models.Post{
+		PostID:     postID,
+		AuthorID:   ps.selfID(),
+		AuthorName: authorName,
+		Body:       body,
+		Media:      media,
+		CreatedAt:  time.UnixMilli(time.Now().UnixMilli()),
+		Own:        true,
+	}
+	if post.Media == nil {
+		post.Media = []models.PostMedia{}
+	}
+
+	if _, err := ps.database.SavePost(post); err != nil {
+		return nil, err
+	}
+
+	log.Printf("📝 Created post %s", postID)
+	publishEvent(ps.events, models.EventPostCreated, models.PostEvent{PostID: postID, AuthorID: post.AuthorID, AuthorName: authorName})
+
+	go ps.announcePost(post)
+	return post, nil
+}
+
+// GetOwnPosts returns a page of our own posts, newest first
+func (ps *PostService) GetOwnPosts(before time.Time, limit int) (*models.PostsResponse, error) {
+	limit = postPageSize(limit)
+	posts, err := ps.database.GetPosts(ps.selfID(), before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return ps.postsPage(posts, limit), nil
+}
+
+// GetTimeline returns a page of our posts merged with our friends' posts, newest first
+func (ps *PostService) GetTimeline(before time.Time, limit int) (*models.PostsResponse, error) {
+	limit = postPageSize(limit)
+	posts, err := ps.database.GetTimeline(ps.selfID(), before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return ps.postsPage(posts, limit), nil
+}
+
+// BackfillFriendPosts pulls the posts a friend made since the newest one we have
+func (ps *PostService) BackfillFriendPosts(peerID string) error {
+	if ps.p2pService == nil {
+		return fmt.Errorf("P2P service not available")
+	}
+
+	since, err := ps.database.GetLatestPostTime(peerID)
+	if err != nil {
+		return err
+	}
+
+	// Posts are paged by creation time and post ID, so posts created in the same millisecond
+	// are not lost at a page boundary. The first page repeats the posts created at since.
+	var sinceMillis int64
+	if !since.IsZero() {
+		sinceMillis = since.UnixMilli()
+	}
+	sincePostID := ""
+
+	received := 0
+	for {
+		response, err := requestPeerByID[models.GetPostsResponse](ps.p2pService, peerID, models.MessageTypeGetPosts,
+			models.GetPostsRequest{Since: sinceMillis, SincePostID: sincePostID, Limit: postBackfillBatch})
+		if err != nil {
+			return err
+		}
+
+		for i := range response.Posts {
+			if ps.storeFriendPost(peerID, &response.Posts[i]) {
+				received++
+			}
+		}
+
+		if !response.HasMore || len(response.Posts) == 0 {
+			break
+		}
+
+		// Stop if the friend doesn't move past the previous page
+		last := response.Posts[len(response.Posts)-1]
+		if last.CreatedAt < sinceMillis || (last.CreatedAt == sinceMillis && last.PostID <= sincePostID) {
+			break
+		}
+		sinceMillis, sincePostID = last.CreatedAt, last.PostID
+	}
+
+	if received > 0 {
+		log.Printf("📰 Backfilled %d post(s) from %s", received, peerID)
+	}
+	return nil
+}
+
+// announcePost pushes a new post to every connected friend
+func (ps *PostService) announcePost(post *models.Post) {
+	if ps.p2pService == nil {
+		return
+	}
+
+	payload := postPayload(*post)
+	for _, peerID := range ps.p2pService.GetConnectedPeers() {
+		isFriend, err := ps.database.IsFriend(peerID.String())
+		if err != nil || !isFriend {
+			continue
+		}
+
+		if _, err := requestPeer[models.PostAnnounceAck](ps.p2pService, peerID, models.MessageTypePostAnnounce, payload); err != nil {
+			// The friend pulls the post with its next backfill
+			log.Printf("📭 Failed to announce post %s to %s: %v", post.PostID, peerID, err)
+		}
+	}
+}
+
+// handlePostAnnounce stores a post a friend announced
+func (ps *PostService) handlePostAnnounce(peerID peer.ID, payload *models.PostPayload) (*models.PostAnnounceAck, error) {
+	isFriend, err := ps.database.IsFriend(peerID.String())
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, wire.NewError(wire.ErrCodeForbidden, "only friends can announce posts")
+	}
+
+	if payload.PostID == "" || len(payload.Body) > models.MaxPostLength || validatePostMedia(payload.Media) != nil {
+		return nil, wire.NewError(wire.ErrCodeBadRequest, "invalid post")
+	}
+
+	ps.storeFriendPost(peerID.String(), payload)
+	return &models.PostAnnounceAck{Success: true}, nil
+}
+
+// handleGetPosts returns our posts after the requested time to a friend backfilling its timeline
+func (ps *PostService) handleGetPosts(peerID peer.ID, request *models.GetPostsRequest) (*models.GetPostsResponse, error) {
+	isFriend, err := ps.database.IsFriend(peerID.String())
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, wire.NewError(wire.ErrCodeForbidden, "only friends can read posts")
+	}
+
+	limit := request.Limit
+	if limit <= 0 || limit > postBackfillBatch {
+		limit = postBackfillBatch
+	}
+
+	var since time.Time
+	if request.Since > 0 {
+		since = time.UnixMilli(request.Since)
+	}
+
+	posts, err := ps.database.GetPostsSince(ps.selfID(), since, request.SincePostID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.GetPostsResponse{Posts: []models.PostPayload{}}
+	if len(posts) > limit {
+		posts = posts[:limit]
+		response.HasMore = true
+	}
+	for _, post := range posts {
+		response.Posts = append(response.Posts, postPayload(post))
+	}
+
+	log.Printf("📰 Sending %d post(s) to %s", len(response.Posts), peerID)
+	return response, nil
+}
+
+// storeFriendPost stores a friend's post, returning true if we didn't have it yet
+func (ps *PostService) storeFriendPost(peerID string, payload *models.PostPayload) bool {
+	if payload.PostID == "" || len(payload.Body) > models.MaxPostLength || validatePostMedia(payload.Media) != nil {
+		log.Printf("⚠️ Ignoring invalid post %q from %s", payload.PostID, peerID)
+		return false
+	}
+
+	post := &models.Post{
+		PostID:     payload.PostID,
+		AuthorID:   peerID,
+		AuthorName: payload.AuthorName,
+		Body:       payload.Body,
+		Media:      payload.Media,
+		CreatedAt:  time.UnixMilli(payload.CreatedAt),
+	}
+	if now := time.Now(); post.CreatedAt.After(now.Add(maxPostClockSkew)) {
+		log.Printf("⏰ Post %s from %s is dated %s in the future, dating it now", payload.PostID, peerID, post.CreatedAt.Sub(now).Round(time.Second))
+		post.CreatedAt = time.UnixMilli(now.UnixMilli())
+	}
+
+	stored, err := ps.database.SavePost(post)
+	if err != nil {
+		log.Printf("⚠️ Failed to store post %s from %s: %v", payload.PostID, peerID, err)
+		return false
+	}
+
+	if stored {
+		publishEvent(ps.events, models.EventPostReceived, models.PostEvent{PostID: post.PostID, AuthorID: peerID, AuthorName: post.AuthorName})
+	}
+	return stored
+}
+
+// postsPage trims a query result fetched with one extra post and marks our own posts
+func (ps *PostService) postsPage(posts []models.Post, limit int) *models.PostsResponse {
+	hasMore := len(posts) > limit
+	if hasMore {
+		posts = posts[:limit]
+	}
+
+	selfID := ps.selfID()
+	for i := range posts {
+		posts[i].Own = posts[i].AuthorID == selfID
+	}
+
+	return &models.PostsResponse{
+		Posts:   posts,
+		Count:   len(posts),
+		HasMore: hasMore,
+	}
+}
+
+// selfID returns our peer ID in string form
+func (ps *PostService) selfID() string {
+	if ps.p2pService != nil {
+		return ps.p2pService.host.ID().String()
+	}
+	if nodeID, err := ps.database.GetNodeID(); err == nil {
+		return nodeID.String()
+	}
+	return ""
+}
+
+// postPageSize applies the default and maximum page sizes
+func postPageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPostPageSize
+	}
+	if limit > MaxPostPageSize {
+		return MaxPostPageSize
+	}
+	return limit
+}
+
+// postPayload converts a stored post to its wire form
+func postPayload(post models.Post) models.PostPayload {
+	return models.PostPayload{
+		PostID:     post.PostID,
+		AuthorName: post.AuthorName,
+		Body:       post.Body,
+		Media:      post.Media,
+		CreatedAt:  post.CreatedAt.UnixMilli(),
+	}
+}
+
+// validatePostMedia checks that media references name a known media type and safe gallery and file names
+func validatePostMedia(media []models.PostMedia) error {
+	if len(media) > models.MaxPostMedia {
+		return utils.NewValidationError("media", fmt.Sprintf("a post can reference at most %d media files", models.MaxPostMedia))
+	}
+
+	validator := &utils.PathValidator{}
+	for _, item := range media {
+		switch item.MediaType {
+		case models.MediaTypeImage, models.MediaTypeAudio, models.MediaTypeVideo, models.MediaTypeDocs:
+		default:
+			return utils.NewValidationError("media_type", fmt.Sprintf("unknown media type %q", item.MediaType))
+		}
+
+		if item.File == "" {
+			return utils.NewValidationError("file", "media file name is required")
+		}
+		if err := validator.ValidateGalleryName(item.Gallery); err != nil {
+			return err
+		}
+		if err := validator.ValidateFilename(item.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"old-school/internal/models"
+	"old-school/internal/utils"
+)
+
+// privacySettingPrefix prefixes the settings keys holding the audience of each identity item
+const privacySettingPrefix = "privacy_"
+
+// GetPrivacySettings returns who may learn each identity item. Items without a setting are
+// disclosed to anyone, as they were before privacy settings existed.
+func (as *AccessService) GetPrivacySettings() *models.PrivacySettings {
+	return &models.PrivacySettings{
+		FriendsList: as.privacyAudience(models.PrivacyItemFriendsList),
+		Avatar:      as.privacyAudience(models.PrivacyItemAvatar),
+		Name:        as.privacyAudience(models.PrivacyItemName),
+		Files:       as.privacyAudience(models.PrivacyItemFiles),
+	}
+}
+
+// UpdatePrivacySettings changes the audience of the identity items, leaving items without
+// an audience in the request unchanged
+func (as *AccessService) UpdatePrivacySettings(settings models.PrivacySettings) (*models.PrivacySettings, error) {
+	items := []struct {
+		item     string
+		audience string
+	}{
+		{models.PrivacyItemFriendsList, settings.FriendsList},
+		{models.PrivacyItemAvatar, settings.Avatar},
+		{models.PrivacyItemName, settings.Name},
+		{models.PrivacyItemFiles, settings.Files},
+	}
+
+	for _, item := range items {
+		if item.audience != "" && !isPrivacyAudience(item.audience) {
+			return nil, utils.NewValidationError(item.item, fmt.Sprintf("unknown audience %q, expected anyone, friends or nobody", item.audience))
+		}
+	}
+
+	for _, item := range items {
+		if item.audience == "" {
+			continue
+		}
+		if err := as.database.SetSetting(privacySettingPrefix+item.item, item.audience); err != nil {
+			return nil, err
+		}
+		log.Printf("🕶️ Privacy of %s set to %s", item.item, item.audience)
+	}
+
+	return as.GetPrivacySettings(), nil
+}
+
+// privacyAudience returns the audience of an identity item, anyone unless configured
+func (as *AccessService) privacyAudience(item string) string {
+	value, err := as.database.GetSetting(privacySettingPrefix + item)
+	if err != nil || !isPrivacyAudience(value) {
+		return models.PrivacyAnyone
+	}
+	return value
+}
+
+// Discloses reports whether an identity item may be sent to the peer
+func (ca *ContentAccess) Discloses(item string) bool {
+	if !ca.loaded {
+		return false
+	}
+
+	switch ca.service.privacyAudience(item) {
+	case models.PrivacyAnyone:
+		return true
+	case models.PrivacyFriends:
+		return ca.isFriend
+	default:
+		return false
+	}
+}
+
+// isAvatarPath reports whether a path relative to the space184 directory is inside our avatar gallery,
+// which the avatar privacy setting covers on top of the visibility rules
+func (ca *ContentAccess) isAvatarPath(contentPath string) bool {
+	avatarPath, ok := ca.service.relativePath(ca.service.pathManager.GetAvatarPath())
+	if !ok {
+		return false
+	}
+	return contentPath == avatarPath || strings.HasPrefix(contentPath, avatarPath+"/")
+}
+
+// isPrivacyAudience reports whether an audience is known
+func isPrivacyAudience(audience string) bool {
+	switch audience {
+	case models.PrivacyAnyone, models.PrivacyFriends, models.PrivacyNobody:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/models"
+	"old-school/internal/utils"
+	"old-school/internal/wire"
+)
+
+const (
+	// profileRecordSetting is the settings key holding our last signed profile record as JSON
+	profileRecordSetting = "profile_record"
+
+	// profileBioSetting is the settings key holding our bio
+	profileBioSetting = "bio"
+
+	// profileLinksSetting is the settings key holding our profile links as JSON
+	profileLinksSetting = "profile_links"
+
+	// profileLocationSetting is the settings key holding our location text
+	profileLocationSetting = "profile_location"
+
+	// profileAvatarSetting is the settings key holding the filename of our chosen avatar image
+	profileAvatarSetting = "profile_avatar"
+
+	// profileNameSetSetting is set once a name was saved through the profile, which then takes
+	// priority over the node name from the configuration
+	profileNameSetSetting = "profile_name_set"
+)
+
+func init() {
+	registerMessageHandlers(profileMessageHandlers)
+}
+
+// profileMessageHandlers returns the handler for profiles pushed by connected peers
+func profileMessageHandlers(p *P2PService) []interfaces.MessageHandler {
+	return []interfaces.MessageHandler{
+		NewMessageHandler(models.MessageTypeProfileUpdate, models.MessageTypeProfileUpdateResp, DefaultMessageTimeout,
+			func(peerID peer.ID, request *models.ProfileUpdatePayload) (*models.ProfileUpdateAck, error) {
+				if p.container.GetProfileService() == nil {
+					return nil, wire.NewError(wire.ErrCodeInternal, "profile service not available")
+				}
+				return p.handleProfileUpdate(peerID, request)
+			}),
+	}
+}
+
+// applyConfiguredNodeName stores the node name from the configuration, unless a name saved
+// through the profile takes priority
+func applyConfiguredNodeName(settings interfaces.SettingsRepository, name, source string) error {
+	if name == "" {
+		return nil
+	}
+
+	if profileNameSet, _ := settings.GetSetting(profileNameSetSetting); profileNameSet == "true" {
+		stored, _ := settings.GetSetting("name")
+		log.Printf("🏷️ Node name from the profile: %s (ignoring %s from %s)", stored, name, source)
+		return nil
+	}
+
+	if err := settings.SetSetting("name", name); err != nil {
+		return fmt.Errorf("failed to apply configured node name: %w", err)
+	}
+	log.Printf("🏷️ Node name set from %s: %s", source, name)
+	return nil
+}
+
+// ProfileService manages our editable profile, signs it into a profile record, pushes changes to
+// connected peers and verifies and keeps the newest profile records of other peers
+type ProfileService struct {
+	database         interfaces.DatabaseService
+	directoryService DirectoryServiceInterface
+	p2pService       *P2PService
+	events           interfaces.EventPublisher
+	mutex            sync.Mutex
+}
+
+// NewProfileService creates a new profile service
+func NewProfileService(database interfaces.DatabaseService, directoryService DirectoryServiceInterface, events interfaces.EventPublisher) *ProfileService {
+	return &ProfileService{
+		database:         database,
+		directoryService: directoryService,
+		events:           events,
+	}
+}
+
+// SetP2PService sets the P2P service profile changes are pushed through, which is created after
+// the profile service because identification already needs our profile record
+func (ps *ProfileService) SetP2PService(p2pService *P2PService) {
+	ps.p2pService = p2pService
+}
+
+// GetProfile returns our editable profile
+func (ps *ProfileService) GetProfile() (*models.Profile, error) {
+	record, err := ps.OwnProfile()
+	if err != nil {
+		return nil, err
+	}
+	return ps.profileFromRecord(record), nil
+}
+
+// UpdateProfile validates and stores the fields set in the request, signs a new profile record
+// and pushes it to connected peers
+func (ps *ProfileService) UpdateProfile(request models.UpdateProfileRequest) (*models.Profile, error) {
+	updates := make(map[string]string)
+
+	if request.Name != nil {
+		name, err := validateProfileText("name", *request.Name, models.MaxProfileNameLength, false)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, utils.NewValidationError("name", "name is required")
+		}
+		updates["name"] = name
+		updates[profileNameSetSetting] = "true"
+	}
+	if request.Bio != nil {
+		bio, err := validateProfileText("bio", *request.Bio, models.MaxProfileBioLength, true)
+		if err != nil {
+			return nil, err
+		}
+		updates[profileBioSetting] = bio
+	}
+	if request.Location != nil {
+		location, err := validateProfileText("location", *request.Location, models.MaxProfileLocationLength, false)
+		if err != nil {
+			return nil, err
+		}
+		updates[profileLocationSetting] = location
+	}
+	if request.Links != nil {
+		links, err := validateProfileLinks(*request.Links)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(links)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode profile links: %w", err)
+		}
+		updates[profileLinksSetting] = string(encoded)
+	}
+	if request.Avatar != nil {
+		avatar := strings.TrimSpace(*request.Avatar)
+		if avatar != "" && !ps.hasAvatarImage(avatar) {
+			return nil, utils.NewValidationError("avatar", fmt.Sprintf("no avatar image named %q", avatar))
+		}
+		updates[profileAvatarSetting] = avatar
+	}
+
+	ps.mutex.Lock()
+	previous := ps.storedOwnProfile()
+	for key, value := range updates {
+		if err := ps.database.SetSetting(key, value); err != nil {
+			ps.mutex.Unlock()
+			return nil, err
+		}
+	}
+	record, err := ps.signOwnProfile()
+	ps.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if previous == nil || record.Seq != previous.Seq {
+		log.Printf("🪪 Profile updated (name: %s)", record.Name)
+		publishEvent(ps.events, models.EventProfileUpdated, models.PeerEvent{PeerID: record.PeerID, Name: record.Name})
+		go ps.pushProfile(record)
+	}
+
+	return ps.profileFromRecord(record), nil
+}
+
+// OwnProfile returns our signed profile record. When a profile field or the avatar changed since
+// the last record it is signed again with a higher sequence number.
+func (ps *ProfileService) OwnProfile() (*models.ProfileRecord, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	return ps.signOwnProfile()
+}
+
+// signOwnProfile returns our last profile record if it still matches our profile, otherwise it
+// signs a new one. The caller holds the mutex.
+func (ps *ProfileService) signOwnProfile() (*models.ProfileRecord, error) {
+	// After a key rotation the running host keeps its peer ID until restart
+	nodeID, err := ps.database.GetNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %w", err)
+	}
+	if ps.p2pService != nil && ps.p2pService.host != nil {
+		nodeID = ps.p2pService.host.ID()
+	}
+
+	name, _ := ps.database.GetSetting("name")
+	bio, _ := ps.database.GetSetting(profileBioSetting)
+	location, _ := ps.database.GetSetting(profileLocationSetting)
+	links := ps.ownProfileLinks()
+	avatarHash := ps.ownAvatarHash()
+
+	// A record that no longer verifies, say one signed under an older format, is replaced as well
+	previous := ps.storedOwnProfile()
+	if previous != nil && previous.Name == name && previous.AvatarHash == avatarHash && previous.Bio == bio &&
+		previous.Location == location && slices.Equal(previous.Links, links) &&
+		verifyProfileRecord(previous, nodeID.String()) == nil {
+		return previous, nil
+	}
+
+	now := time.Now().UTC()
+	record := &models.ProfileRecord{
+		PeerID:     nodeID.String(),
+		Name:       name,
+		AvatarHash: avatarHash,
+		Bio:        bio,
+		Links:      links,
+		Location:   location,
+		Seq:        nextProfileSeq(previous, now),
+		UpdatedAt:  now,
+	}
+
+	record.PublicKey, record.Signature, err = signWithNodeKey(runningNodeKey(ps.p2pService, ps.database), profileSigningBytes(record))
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode profile record: %w", err)
+	}
+	if err := ps.database.SetSetting(profileRecordSetting, string(encoded)); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🪪 Signed profile record #%d", record.Seq)
+	return record, nil
+}
+
+// GetPeerProfile returns the newest verified profile record we have of a peer
+func (ps *ProfileService) GetPeerProfile(peerID string) (*models.ProfileRecord, error) {
+	record, err := ps.database.GetPeerProfile(peerID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, utils.NewNotFoundError("profile", peerID)
+	}
+	return record, nil
+}
+
+// ReceiveProfile verifies a profile record claimed to belong to peerID, whether the peer sent it
+// itself or a friend relayed it, and stores it if it is newer than the one we have.
+// It returns the newest record we know of the peer.
+func (ps *ProfileService) ReceiveProfile(record *models.ProfileRecord, peerID string) (*models.ProfileRecord, error) {
+	if err := verifyProfileRecord(record, peerID); err != nil {
+		return nil, err
+	}
+
+	stored, err := ps.database.SavePeerProfile(record)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		newest, err := ps.database.GetPeerProfile(peerID)
+		if err != nil || newest == nil {
+			return record, err
+		}
+		return newest, nil
+	}
+
+	log.Printf("🪪 Stored profile record #%d of %s (name: %s)", record.Seq, peerID, record.Name)
+	publishEvent(ps.events, models.EventProfileUpdated, models.PeerEvent{PeerID: peerID, Name: record.Name})
+	return record, nil
+}
+
+// pushProfile sends a new profile record to the connected peers that accept pushed profiles and
+// may learn our name, along with the avatar if our privacy settings disclose it to them
+func (ps *ProfileService) pushProfile(record *models.ProfileRecord) {
+	if ps.p2pService == nil {
+		return
+	}
+
+	pushed := 0
+	for _, peerID := range ps.p2pService.GetConnectedPeers() {
+		if !ps.p2pService.GetPeerProtocol(peerID).Supports(wire.CapabilityProfileUpdates) {
+			continue
+		}
+
+		access := ps.p2pService.contentAccess(peerID)
+		if !access.Discloses(models.PrivacyItemName) {
+			continue
+		}
+
+		payload := &models.ProfileUpdatePayload{Profile: record}
+		if access.Discloses(models.PrivacyItemAvatar) {
+			payload.Avatar = ps.p2pService.prepareAvatarData()
+		}
+
+		if _, err := requestPeer[models.ProfileUpdateAck](ps.p2pService, peerID, models.MessageTypeProfileUpdate, payload); err != nil {
+			// The peer gets the record with the next identify exchange
+			log.Printf("📭 Failed to push profile to %s: %v", peerID, err)
+			continue
+		}
+		pushed++
+	}
+
+	if pushed > 0 {
+		log.Printf("📣 Pushed profile record #%d to %d peer(s)", record.Seq, pushed)
+	}
+}
+
+// profileFromRecord combines our signed record with the avatar choice
+func (ps *ProfileService) profileFromRecord(record *models.ProfileRecord) *models.Profile {
+	profile := &models.Profile{
+		PeerID:   record.PeerID,
+		Name:     record.Name,
+		Bio:      record.Bio,
+		Links:    record.Links,
+		Location: record.Location,
+		Avatar:   primaryAvatarImage(ps.database, ps.directoryService),
+		Avatars:  []string{},
+		Record:   record,
+	}
+	if profile.Links == nil {
+		profile.Links = []string{}
+	}
+	if ps.directoryService != nil {
+		if avatars, err := ps.directoryService.GetAvatarImages(); err == nil && avatars != nil {
+			profile.Avatars = avatars
+		}
+	}
+	return profile
+}
+
+// hasAvatarImage reports whether our avatar gallery holds an image
+func (ps *ProfileService) hasAvatarImage(filename string) bool {
+	if ps.directoryService == nil {
+		return false
+	}
+	avatars, err := ps.directoryService.GetAvatarImages()
+	return err == nil && slices.Contains(avatars, filename)
+}
+
+// ownProfileLinks returns our stored profile links
+func (ps *ProfileService) ownProfileLinks() []string {
+	value, err := ps.database.GetSetting(profileLinksSetting)
+	if err != nil || value == "" {
+		return nil
+	}
+
+	var links []string
+	if err := json.Unmarshal([]byte(value), &links); err != nil {
+		log.Printf("⚠️ Ignoring unreadable profile links: %v", err)
+		return nil
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return links
+}
+
+// storedOwnProfile returns the last profile record we signed, or nil if there is none
+func (ps *ProfileService) storedOwnProfile() *models.ProfileRecord {
+	value, err := ps.database.GetSetting(profileRecordSetting)
+	if err != nil || value == "" {
+		return nil
+	}
+
+	var record models.ProfileRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		log.Printf("⚠️ Ignoring unreadable stored profile record: %v", err)
+		return nil
+	}
+	return &record
+}
+
+// ownAvatarHash returns the hash of our primary avatar image, empty without an avatar
+func (ps *ProfileService) ownAvatarHash() string {
+	avatar := primaryAvatarImage(ps.database, ps.directoryService)
+	if avatar == "" {
+		return ""
+	}
+
+	hash, err := utils.DefaultHashService.ComputeFileHash(filepath.Join(ps.directoryService.GetAvatarDirectory(), avatar))
+	if err != nil {
+		log.Printf("⚠️ Failed to hash avatar %s: %v", avatar, err)
+		return ""
+	}
+	return hash
+}
+
+// primaryAvatarImage returns the avatar image we present to peers: the chosen one while it is
+// still in the avatar gallery, otherwise the first image. It is empty without avatar images.
+func primaryAvatarImage(settings interfaces.SettingsRepository, directoryService DirectoryServiceInterface) string {
+	if directoryService == nil {
+		return ""
+	}
+
+	avatarImages, err := directoryService.GetAvatarImages()
+	if err != nil || len(avatarImages) == 0 {
+		return ""
+	}
+
+	if settings != nil {
+		if chosen, err := settings.GetSetting(profileAvatarSetting); err == nil && slices.Contains(avatarImages, chosen) {
+			return chosen
+		}
+	}
+	return avatarImages[0]
+}
+
+// nextProfileSeq returns the sequence number of a new profile record. It follows the clock so a
+// node restored from a backup without its last record still outranks the records peers kept.
+func nextProfileSeq(previous *models.ProfileRecord, now time.Time) uint64 {
+	seq := uint64(now.UnixMilli())
+	if previous != nil && previous.Seq >= seq {
+		seq = previous.Seq + 1
+	}
+	return seq
+}
+
+// verifyProfileRecord checks that a profile record belongs to peerID, is signed by its key and
+// stays within the field limits
+func verifyProfileRecord(record *models.ProfileRecord, peerID string) error {
+	if record == nil {
+		return fmt.Errorf("no profile record")
+	}
+	if record.PeerID != peerID {
+		return fmt.Errorf("profile record of %s presented as %s", record.PeerID, peerID)
+	}
+	if len(record.Name) > models.MaxProfileNameLength || !utf8.ValidString(record.Name) {
+		return fmt.Errorf("invalid profile name")
+	}
+	if len(record.Bio) > models.MaxProfileBioLength || !utf8.ValidString(record.Bio) {
+		return fmt.Errorf("invalid profile bio")
+	}
+	if len(record.Location) > models.MaxProfileLocationLength || !utf8.ValidString(record.Location) {
+		return fmt.Errorf("invalid profile location")
+	}
+	if _, err := validateProfileLinks(record.Links); err != nil {
+		return fmt.Errorf("invalid profile links: %w", err)
+	}
+
+	if err := verifyPeerSignature(record.PeerID, record.PublicKey, profileSigningBytes(record), record.Signature); err != nil {
+		return fmt.Errorf("invalid profile signature: %w", err)
+	}
+	return nil
+}
+
+// validateProfileText trims a profile text field and checks its length. Only multiline fields may
+// contain line breaks, no field may contain other control characters.
+func validateProfileText(field, value string, maxLength int, multiline bool) (string, error) {
+	value = strings.TrimSpace(value)
+	if !utf8.ValidString(value) {
+		return "", utils.NewValidationError(field, "must be valid UTF-8")
+	}
+	if len(value) > maxLength {
+		return "", utils.NewValidationError(field, fmt.Sprintf("must be at most %d bytes", maxLength))
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) && !(multiline && (r == '\n' || r == '\r' || r == '\t')) {
+			return "", utils.NewValidationError(field, "must not contain control characters")
+		}
+	}
+	return value, nil
+}
+
+// validateProfileLinks trims the links, drops empty ones and checks that each is an http or https URL
+func validateProfileLinks(links []string) ([]string, error) {
+	var valid []string
+	for _, link := range links {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			continue
+		}
+		if len(link) > models.MaxProfileLinkLength {
+			return nil, utils.NewValidationError("links", fmt.Sprintf("links must be at most %d bytes", models.MaxProfileLinkLength))
+		}
+
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, utils.NewValidationError("links", fmt.Sprintf("%q is not an http or https URL", link))
+		}
+		valid = append(valid, link)
+	}
+
+	if len(valid) > models.MaxProfileLinks {
+		return nil, utils.NewValidationError("links", fmt.Sprintf("at most %d links are allowed", models.MaxProfileLinks))
+	}
+	return valid, nil
+}
+
+// profileSigningBytes returns the bytes a profile record signature covers
+func profileSigningBytes(record *models.ProfileRecord) []byte {
+	fields := []string{record.PeerID, record.Name, record.AvatarHash, record.Bio, record.Location,
+		strconv.Itoa(len(record.Links))}
+	fields = append(fields, record.Links...)
+	fields = append(fields, strconv.FormatUint(record.Seq, 10), strconv.FormatInt(record.UpdatedAt.UnixMilli(), 10))
+	return signingBytes("old-school/profile/v2", fields...)
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
+	"github.com/multiformats/go-multiaddr"
+
+	"old-school/internal/models"
+)
+
+const (
+	// reconnectCheckInterval is how often the supervisor looks for friends due for a reconnect
+	reconnectCheckInterval = 10 * time.Second
+
+	// reconnectAfterDisconnect is the delay before the first attempt after a friend disconnects
+	reconnectAfterDisconnect = 5 * time.Second
+
+	// reconnectInitialBackoff and reconnectMaxBackoff bound the delay after failed attempts,
+	// which doubles with each failure
+	reconnectInitialBackoff = 30 * time.Second
+	reconnectMaxBackoff     = 30 * time.Minute
+
+	// reconnectDialTimeout bounds each way of reaching a friend
+	reconnectDialTimeout = 20 * time.Second
+
+	// maxConcurrentReconnects bounds the friends dialed at the same time
+	maxConcurrentReconnects = 4
+)
+
+// StartReconnectSupervisor keeps dialing offline friends until the node shuts down,
+// backing off exponentially for each friend that stays unreachable
+func (fs *FriendService) StartReconnectSupervisor() {
+	if fs.database == nil || fs.p2pService == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(reconnectCheckInterval)
+		defer ticker.Stop()
+
+		slots := make(chan struct{}, maxConcurrentReconnects)
+		for {
+			select {
+			case <-fs.p2pService.ctx.Done():
+				return
+			case <-ticker.C:
+				fs.superviseReconnects(slots)
+			}
+		}
+	}()
+
+	log.Printf("🔄 Friend reconnect supervisor started")
+}
+
+// superviseReconnects starts a reconnect for every offline friend whose backoff has passed
+func (fs *FriendService) superviseReconnects(slots chan struct{}) {
+	friends, err := fs.database.GetFriends()
+	if err != nil {
+		log.Printf("⚠️ Reconnect supervisor failed to get friends: %v", err)
+		return
+	}
+
+	fs.forgetRemovedFriends(friends)
+
+	now := time.Now()
+	for _, friend := range friends {
+		if !fs.reconnectDue(friend.PeerID, now) {
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-fs.p2pService.ctx.Done():
+			return
+		}
+
+		go func(friend models.Friend) {
+			defer func() { <-slots }()
+			fs.reconnectFriend(friend)
+		}(friend)
+	}
+}
+
+// reconnectFriend dials an offline friend through every way known and records the outcome.
+// The caller claims the attempt with reconnectDue first, so a friend is never dialed twice at once.
+func (fs *FriendService) reconnectFriend(friend models.Friend) error {
+	pid, err := peer.Decode(friend.PeerID)
+	if err != nil {
+		return fmt.Errorf("invalid peer ID %s: %w", friend.PeerID, err)
+	}
+	if fs.p2pService.host.Network().Connectedness(pid) == network.Connected {
+		fs.markReconnected(friend.PeerID, "")
+		return nil
+	}
+
+	log.Printf("🔄 Attempting to reconnect to friend %s (%s)", friend.PeerName, friend.PeerID)
+
+	addrs := fs.knownFriendAddresses(pid)
+	via, err := fs.p2pService.dialPeer(pid, addrs)
+	if err != nil {
+		fs.recordAddressFailures(friend.PeerID, addrs)
+	} else {
+		_, err = fs.p2pService.validateConnectedPeer(pid)
+	}
+	if err != nil {
+		backoff := fs.recordReconnectFailure(friend.PeerID, err)
+		log.Printf("❌ Failed to reconnect to friend %s, retrying in %s: %v", friend.PeerName, backoff, err)
+		return fmt.Errorf("failed to reconnect to friend %s: %w", friend.PeerName, err)
+	}
+
+	fs.markReconnected(friend.PeerID, via)
+	log.Printf("✅ Successfully reconnected to friend %s via %s", friend.PeerName, via)
+	return nil
+}
+
+// knownFriendAddresses returns every address we observed for a friend: those in its address book,
+// best first, then those in the connection history and those libp2p learned
+func (fs *FriendService) knownFriendAddresses(pid peer.ID) []multiaddr.Multiaddr {
+	seen := make(map[string]bool)
+	var addrs []multiaddr.Multiaddr
+	add := func(addr multiaddr.Multiaddr) {
+		if transport, _ := peer.SplitAddr(addr); transport != nil && !seen[transport.String()] {
+			seen[transport.String()] = true
+			addrs = append(addrs, transport)
+		}
+	}
+
+	for _, addr := range fs.p2pService.storedPeerAddresses(pid) {
+		add(addr)
+	}
+
+	history, err := fs.database.GetConnectionHistory()
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to get connection history: %v", err)
+	}
+	for _, record := range history {
+		if record.PeerID != pid.String() {
+			continue
+		}
+		if addr, err := multiaddr.NewMultiaddr(record.Address); err == nil {
+			add(addr)
+		}
+	}
+
+	for _, addr := range fs.p2pService.host.Peerstore().Addrs(pid) {
+		add(addr)
+	}
+	return addrs
+}
+
+// recordAddressFailures counts a failed dial for the addresses of a friend that could not be reached
+func (fs *FriendService) recordAddressFailures(peerID string, addrs []multiaddr.Multiaddr) {
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.String()
+	}
+	if err := fs.database.RecordPeerAddressFailures(peerID, addresses); err != nil {
+		log.Printf("⚠️ Warning: Failed to record address failures of friend %s: %v", peerID, err)
+	}
+}
+
+// GetReconnectStatus returns the reconnect state of a friend, nil before the supervisor saw it
+func (fs *FriendService) GetReconnectStatus(peerID string) *models.ReconnectStatus {
+	fs.reconnectMutex.Lock()
+	defer fs.reconnectMutex.Unlock()
+
+	status, exists := fs.reconnects[peerID]
+	if !exists {
+		return nil
+	}
+	statusCopy := *status
+	return &statusCopy
+}
+
+// AttachReconnectStatus fills in the reconnect state of each friend
+func (fs *FriendService) AttachReconnectStatus(friends []models.Friend) {
+	for i := range friends {
+		friends[i].Reconnect = fs.GetReconnectStatus(friends[i].PeerID)
+	}
+}
+
+// reconnectDue reports whether an offline friend should be dialed now and marks it as dialing
+func (fs *FriendService) reconnectDue(peerID string, now time.Time) bool {
+	pid, err := peer.Decode(peerID)
+	if err != nil {
+		return false
+	}
+	if fs.p2pService.host.Network().Connectedness(pid) == network.Connected {
+		fs.markReconnected(peerID, "")
+		return false
+	}
+
+	fs.reconnectMutex.Lock()
+	defer fs.reconnectMutex.Unlock()
+
+	status := fs.reconnectStatus(peerID)
+	if status.State == models.ReconnectDialing || (status.NextAttempt != nil && now.Before(*status.NextAttempt)) {
+		return false
+	}
+	status.State = models.ReconnectDialing
+	return true
+}
+
+// scheduleReconnect resets the backoff of a friend and schedules its next attempt.
+// An attempt in progress is left running and its outcome decides the next one.
+func (fs *FriendService) scheduleReconnect(peerID string, delay time.Duration) {
+	fs.reconnectMutex.Lock()
+	defer fs.reconnectMutex.Unlock()
+
+	next := time.Now().Add(delay)
+	status := fs.reconnectStatus(peerID)
+	if status.State != models.ReconnectDialing {
+		status.State = models.ReconnectWaiting
+	}
+	status.Attempts = 0
+	status.NextAttempt = &next
+	status.ConnectedVia = ""
+}
+
+// markReconnected records that a friend is connected, clearing its backoff
+func (fs *FriendService) markReconnected(peerID, via string) {
+	fs.reconnectMutex.Lock()
+	defer fs.reconnectMutex.Unlock()
+
+	status := fs.reconnectStatus(peerID)
+	status.State = models.ReconnectConnected
+	status.Attempts = 0
+	status.NextAttempt = nil
+	status.LastError = ""
+	if via != "" {
+		now := time.Now()
+		status.LastAttempt = &now
+		status.ConnectedVia = via
+	}
+}
+
+// recordReconnectFailure records a failed attempt and schedules the next one, returning the backoff
+func (fs *FriendService) recordReconnectFailure(peerID string, err error) time.Duration {
+	fs.reconnectMutex.Lock()
+	defer fs.reconnectMutex.Unlock()
+
+	status := fs.reconnectStatus(peerID)
+	status.Attempts++
+
+	backoff := reconnectInitialBackoff
+	for i := 1; i < status.Attempts && backoff < reconnectMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > reconnectMaxBackoff {
+		backoff = reconnectMaxBackoff
+	}
+
+	now := time.Now()
+	next := now.Add(backoff)
+	status.State = models.ReconnectWaiting
+	status.LastAttempt = &now
+	status.NextAttempt = &next
+	status.LastError = err.Error()
+	return backoff
+}
+
+// reconnectStatus returns the reconnect state of a friend, creating it. The caller holds reconnectMutex.
+func (fs *FriendService) reconnectStatus(peerID string) *models.ReconnectStatus {
+	status, exists := fs.reconnects[peerID]
+	if !exists {
+		status = &models.ReconnectStatus{State: models.ReconnectWaiting}
+		fs.reconnects[peerID] = status
+	}
+	return status
+}
+
+// forgetRemovedFriends drops the reconnect state of peers that are no longer friends
+func (fs *FriendService) forgetRemovedFriends(friends []models.Friend) {
+	current := make(map[string]bool, len(friends))
+	for _, friend := range friends {
+		current[friend.PeerID] = true
+	}
+
+	fs.reconnectMutex.Lock()
+	defer fs.reconnectMutex.Unlock()
+	for peerID := range fs.reconnects {
+		if !current[peerID] {
+			delete(fs.reconnects, peerID)
+		}
+	}
+}
+
+// dialPeer connects to a peer at the given addresses, then at those a DHT lookup finds and
+// finally through circuits of connected relays. It returns which of them reached the peer.
+func (p *P2PService) dialPeer(pid peer.ID, addrs []multiaddr.Multiaddr) (string, error) {
+	var failures []string
+
+	if len(addrs) > 0 {
+		err := p.connectWithTimeout(peer.AddrInfo{ID: pid, Addrs: addrs})
+		if err == nil {
+			return models.ReconnectViaAddresses, nil
+		}
+		failures = append(failures, fmt.Sprintf("%d known address(es): %v", len(addrs), err))
+	} else {
+		failures = append(failures, "no known address")
+	}
+
+	if p.dht != nil {
+		ctx, cancel := context.WithTimeout(p.ctx, reconnectDialTimeout)
+		peerInfo, err := p.dht.FindPeer(ctx, pid)
+		cancel()
+
+		if err == nil && len(peerInfo.Addrs) > 0 {
+			if err = p.connectWithTimeout(peerInfo); err == nil {
+				return models.ReconnectViaDHT, nil
+			}
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("DHT lookup: %v", err))
+		}
+	}
+
+	if relayAddrs := p.relayAddresses(pid); len(relayAddrs) > 0 {
+		err := p.connectWithTimeout(peer.AddrInfo{ID: pid, Addrs: relayAddrs})
+		if err == nil {
+			return models.ReconnectViaRelay, nil
+		}
+		failures = append(failures, fmt.Sprintf("%d relay(s): %v", len(relayAddrs), err))
+	}
+
+	return "", fmt.Errorf("%s", strings.Join(failures, "; "))
+}
+
+// connectWithTimeout connects to a peer, giving up after reconnectDialTimeout
+func (p *P2PService) connectWithTimeout(peerInfo peer.AddrInfo) error {
+	ctx, cancel := context.WithTimeout(p.ctx, reconnectDialTimeout)
+	defer cancel()
+	return p.host.Connect(ctx, peerInfo)
+}
+
+// relayAddresses returns circuit addresses for a peer through the connected peers serving as relays
+func (p *P2PService) relayAddresses(target peer.ID) []multiaddr.Multiaddr {
+	var addrs []multiaddr.Multiaddr
+	for _, relayID := range p.host.Network().Peers() {
+		if relayID == target {
+			continue
+		}
+		protocols, err := p.host.Peerstore().SupportsProtocols(relayID, proto.ProtoIDv2Hop)
+		if err != nil || len(protocols) == 0 {
+			continue
+		}
+
+		addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/p2p/%s/p2p-circuit", relayID))
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
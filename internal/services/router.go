@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+	"old-school/internal/wire"
+)
+
+// DefaultMessageTimeout bounds a request/response exchange unless a handler sets its own
+const DefaultMessageTimeout = 10 * time.Second
+
+// MessageRouter routes incoming P2P requests to the handler registered for their message type
+type MessageRouter struct {
+	handlers map[string]interfaces.MessageHandler
+	mutex    sync.RWMutex
+}
+
+// NewMessageRouter creates an empty message router
+func NewMessageRouter() *MessageRouter {
+	return &MessageRouter{
+		handlers: make(map[string]interfaces.MessageHandler),
+	}
+}
+
+// RegisterHandler registers the handler serving a message type
+func (r *MessageRouter) RegisterHandler(messageType string, handler interfaces.MessageHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.handlers[messageType]; exists {
+		log.Printf("⚠️ Replacing handler for message type %s", messageType)
+	}
+	r.handlers[messageType] = handler
+}
+
+// GetHandler returns the handler registered for a message type
+func (r *MessageRouter) GetHandler(messageType string) (interfaces.MessageHandler, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	handler, exists := r.handlers[messageType]
+	return handler, exists
+}
+
+// RouteMessage decodes a request with decode, runs its handler and returns the response type and payload.
+// Errors are always *wire.Error so they can be reported back to the peer.
+func (r *MessageRouter) RouteMessage(messageType string, decode func(v interface{}) error, peerID peer.ID) (string, interface{}, error) {
+	handler, exists := r.GetHandler(messageType)
+	if !exists {
+		log.Printf("Unknown message type: %s", messageType)
+		return "", nil, wire.NewError(wire.ErrCodeUnknownType, "unknown message type %q", messageType)
+	}
+
+	request := handler.NewRequest()
+	if request != nil {
+		if err := decode(request); err != nil {
+			return "", nil, wire.NewError(wire.ErrCodeBadRequest, "invalid %s request: %v", messageType, err)
+		}
+	}
+
+	response, err := handler.Handle(request, peerID)
+	if err != nil {
+		var wireErr *wire.Error
+		if errors.As(err, &wireErr) {
+			return "", nil, wireErr
+		}
+		log.Printf("Failed to handle %s from %s: %v", messageType, peerID, err)
+		return "", nil, wire.NewError(wire.ErrCodeInternal, "%v", err)
+	}
+
+	return handler.ResponseType(), response, nil
+}
+
+// typedMessageHandler adapts a typed handler function to interfaces.MessageHandler
+type typedMessageHandler[Req any, Resp any] struct {
+	messageType  string
+	responseType string
+	timeout      time.Duration
+	handle       func(peerID peer.ID, request *Req) (Resp, error)
+}
+
+// NewMessageHandler creates a handler for messageType requests decoded into Req and answered with a Resp of responseType
+func NewMessageHandler[Req any, Resp any](messageType, responseType string, timeout time.Duration, handle func(peerID peer.ID, request *Req) (Resp, error)) interfaces.MessageHandler {
+	if timeout <= 0 {
+		timeout = DefaultMessageTimeout
+	}
+
+	return &typedMessageHandler[Req, Resp]{
+		messageType:  messageType,
+		responseType: responseType,
+		timeout:      timeout,
+		handle:       handle,
+	}
+}
+
+// MessageType returns the request type served by the handler
+func (h *typedMessageHandler[Req, Resp]) MessageType() string {
+	return h.messageType
+}
+
+// ResponseType returns the type of the response sent back
+func (h *typedMessageHandler[Req, Resp]) ResponseType() string {
+	return h.responseType
+}
+
+// Timeout returns how long a request/response exchange of this type may take
+func (h *typedMessageHandler[Req, Resp]) Timeout() time.Duration {
+	return h.timeout
+}
+
+// NewRequest returns an empty request for the payload to be decoded into
+func (h *typedMessageHandler[Req, Resp]) NewRequest() interface{} {
+	return new(Req)
+}
+
+// Handle runs the typed handler function
+func (h *typedMessageHandler[Req, Resp]) Handle(request interface{}, peerID peer.ID) (interface{}, error) {
+	typedRequest, ok := request.(*Req)
+	if !ok {
+		return nil, fmt.Errorf("unexpected request type %T for %s", request, h.messageType)
+	}
+	return h.handle(peerID, typedRequest)
+}
+
+// messageHandlerFactories build the handlers of every feature for a P2P service
+var messageHandlerFactories []func(p *P2PService) []interfaces.MessageHandler
+
+// registerMessageHandlers adds the handlers of a feature. It is called from the init function
+// of the file implementing the feature, so new message types don't need changes in p2p.go.
+func registerMessageHandlers(factory func(p *P2PService) []interfaces.MessageHandler) {
+	messageHandlerFactories = append(messageHandlerFactories, factory)
+}
+
+// setupMessageRouter creates the router and registers the handlers of all features
+func (p *P2PService) setupMessageRouter() {
+	p.router = NewMessageRouter()
+
+	for _, factory := range messageHandlerFactories {
+		for _, handler := range factory(p) {
+			p.router.RegisterHandler(handler.MessageType(), handler)
+		}
+	}
+
+	log.Printf("📨 Registered %d P2P message handlers", len(p.router.handlers))
+}
+
+// GetMessageRouter returns the router serving incoming P2P requests
+func (p *P2PService) GetMessageRouter() interfaces.MessageRouter {
+	return p.router
+}
+
+// messageTimeout returns the timeout registered for a message type
+func (p *P2PService) messageTimeout(msgType string) time.Duration {
+	if handler, exists := p.router.GetHandler(msgType); exists {
+		return handler.Timeout()
+	}
+	return DefaultMessageTimeout
+}
+
+// requestPeer sends a request of a registered message type to a peer and returns the decoded response
+func requestPeer[Resp any](p *P2PService, peerID peer.ID, msgType string, request interface{}) (*Resp, error) {
+	handler, exists := p.router.GetHandler(msgType)
+	if !exists {
+		return nil, fmt.Errorf("message type %s is not registered", msgType)
+	}
+
+	var response Resp
+	if err := p.sendMessage(peerID, msgType, handler.ResponseType(), request, &response, handler.Timeout()); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// requestPeerByID is requestPeer for a peer ID in string form
+func requestPeerByID[Resp any](p *P2PService, peerID string, msgType string, request interface{}) (*Resp, error) {
+	pid, err := peer.Decode(peerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ID: %w", err)
+	}
+
+	return requestPeer[Resp](p, pid, msgType, request)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"old-school/internal/config"
 	"old-school/internal/interfaces"
 	"old-school/internal/repository"
 	"old-school/internal/utils"
@@ -14,23 +15,41 @@ type ServiceContainer struct {
 	// Core repositories
 	database interfaces.DatabaseService
 
+	// Event bus connecting the services
+	events *EventBus
+
 	// Core services
-	directoryService  DirectoryServiceInterface
-	fileSystemService interfaces.FileSystemService
-	templateService   *TemplateService
-	friendService     *FriendService
+	directoryService    DirectoryServiceInterface
+	fileSystemService   interfaces.FileSystemService
+	accessService       *AccessService
+	profileService      *ProfileService
+	identityService     *IdentityService
+	templateService     *TemplateService
+	friendService       *FriendService
+	chatService         *ChatService
+	postService         *PostService
+	commentService      *CommentService
+	notificationService *NotificationService
+	blockService        *BlockService
+	inviteService       *InviteService
 	// portsService       *PortsService  // Commented out - not essential
 	monitorService *MonitorService
 	p2pService     *P2PService
 
 	// Utilities
+	config      *config.Config
 	pathManager *utils.PathManager
 }
 
 // NewServiceContainer creates and initializes all services
-func NewServiceContainer() (*ServiceContainer, error) {
+func NewServiceContainer(cfg *config.Config) (*ServiceContainer, error) {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
 	container := &ServiceContainer{
-		pathManager: utils.DefaultPathManager,
+		config:      cfg,
+		pathManager: utils.NewPathManagerWithRoot(cfg.DataDir),
 	}
 
 	if err := container.initializeServices(); err != nil {
@@ -42,8 +61,11 @@ func NewServiceContainer() (*ServiceContainer, error) {
 
 // initializeServices initializes all services in the correct order
 func (sc *ServiceContainer) initializeServices() error {
-	// Initialize directory service first
-	sc.directoryService = NewDirectoryService()
+	// Initialize the event bus first so every service can publish to it
+	sc.events = NewEventBus()
+
+	// Initialize directory service
+	sc.directoryService = NewDirectoryService(sc.pathManager)
 
 	// Initialize database
 	dbPath := sc.pathManager.GetDatabasePath()
@@ -53,8 +75,24 @@ func (sc *ServiceContainer) initializeServices() error {
 	}
 	sc.database = database
 
+	// Apply the configured node name, keeping the stored one when none is configured
+	// or a name was saved through the profile
+	if err := applyConfiguredNodeName(database, sc.config.NodeName, sc.config.Source("node_name")); err != nil {
+		return err
+	}
+
 	// Initialize file system service
-	sc.fileSystemService = NewFileScannerService(database)
+	sc.fileSystemService = NewFileScannerService(database, sc.pathManager, sc.events)
+
+	// Initialize access service, deciding what peers may see of our content
+	sc.accessService = NewAccessService(database, sc.directoryService, sc.pathManager)
+
+	// Initialize profile service, signing our profile record and keeping the newest records of peers
+	sc.profileService = NewProfileService(database, sc.directoryService, sc.events)
+
+	// Initialize identity service, exporting and restoring encrypted identity backups
+	sc.identityService = NewIdentityService(database, sc.pathManager, sc.events)
+	sc.identityService.MigrateOwnKeyRotations()
 
 	// Initialize utility services
 	var err2 error
@@ -80,21 +118,40 @@ func (sc *ServiceContainer) initializeServices() error {
 		})
 	}
 
+	// Profile changes are pushed to connected peers through the P2P service
+	sc.profileService.SetP2PService(sc.p2pService)
+
 	// Initialize Friend service
-	sc.friendService = NewFriendService(database, sc.p2pService)
+	sc.friendService = NewFriendService(database, sc.p2pService, sc.events)
+	sc.friendService.WatchFriendPresence(sc.events)
 
-	// Monitor service will be initialized later when AppService is available
+	// Initialize chat service, queued messages are delivered when friends reconnect
+	sc.chatService = NewChatService(database, sc.p2pService, sc.friendService, sc.events)
+	sc.chatService.WatchOutbox(sc.events)
 
-	return nil
-}
+	// Initialize post service, friends' missed posts are backfilled when they reconnect
+	sc.postService = NewPostService(database, sc.p2pService, sc.events)
+	sc.postService.WatchFriends(sc.events)
+
+	// Initialize comment service, comments on our content are stored here and served with it
+	sc.commentService = NewCommentService(database, sc.p2pService, sc.directoryService, sc.pathManager, sc.events)
+
+	// Initialize notification service, fed by the events the other services publish
+	sc.notificationService = NewNotificationService(database, sc.p2pService, sc.events)
+	sc.notificationService.WatchEvents(sc.events)
+
+	// Initialize block service, blocked peers are refused by the P2P host's connection gater
+	sc.blockService = NewBlockService(database, sc.p2pService, sc.pathManager, sc.events)
+
+	// Initialize invite service, redeemed friendship invites skip the friend request
+	sc.inviteService = NewInviteService(database, sc.p2pService, sc.friendService, sc.events)
 
-// InitializeMonitorService initializes the monitor service with an AppService
-func (sc *ServiceContainer) InitializeMonitorService(appService *AppService) error {
-	var err error
-	sc.monitorService, err = NewMonitorService(sc.directoryService, appService)
+	// Initialize monitor service, scan results are published on the event bus
+	sc.monitorService, err = NewMonitorService(sc.directoryService, sc.events)
 	if err != nil {
-		return fmt.Errorf("failed to create monitor service: %w", err)
+		log.Printf("⚠️ Warning: failed to initialize monitor service: %v", err)
 	}
+
 	return nil
 }
 
@@ -121,6 +178,9 @@ func (sc *ServiceContainer) PerformStartupTasks() error {
 		if err := sc.friendService.SyncFriendFilesMetadata(); err != nil {
 			log.Printf("⚠️ Warning: failed to sync friend files metadata: %v", err)
 		}
+
+		// Friends still offline are retried with backoff from now on
+		sc.friendService.StartReconnectSupervisor()
 	}
 
 	log.Printf("✅ Startup tasks completed")
@@ -170,11 +230,66 @@ func (sc *ServiceContainer) GetFriendService() *FriendService {
 	return sc.friendService
 }
 
+// GetChatService returns the chat service
+func (sc *ServiceContainer) GetChatService() *ChatService {
+	return sc.chatService
+}
+
+// GetPostService returns the post service
+func (sc *ServiceContainer) GetPostService() *PostService {
+	return sc.postService
+}
+
+// GetAccessService returns the access service
+func (sc *ServiceContainer) GetAccessService() *AccessService {
+	return sc.accessService
+}
+
+// GetCommentService returns the comment service
+func (sc *ServiceContainer) GetCommentService() *CommentService {
+	return sc.commentService
+}
+
+// GetNotificationService returns the notification service
+func (sc *ServiceContainer) GetNotificationService() *NotificationService {
+	return sc.notificationService
+}
+
+// GetProfileService returns the profile service
+func (sc *ServiceContainer) GetProfileService() *ProfileService {
+	return sc.profileService
+}
+
+// GetIdentityService returns the identity backup service
+func (sc *ServiceContainer) GetIdentityService() *IdentityService {
+	return sc.identityService
+}
+
+// GetBlockService returns the block service
+func (sc *ServiceContainer) GetBlockService() *BlockService {
+	return sc.blockService
+}
+
+// GetInviteService returns the invite service
+func (sc *ServiceContainer) GetInviteService() *InviteService {
+	return sc.inviteService
+}
+
+// GetEventBus returns the event bus services publish to and subscribe on
+func (sc *ServiceContainer) GetEventBus() *EventBus {
+	return sc.events
+}
+
 // GetPathManager returns the path manager
 func (sc *ServiceContainer) GetPathManager() *utils.PathManager {
 	return sc.pathManager
 }
 
+// GetConfig returns the effective node configuration
+func (sc *ServiceContainer) GetConfig() *config.Config {
+	return sc.config
+}
+
 // GetPortsService returns the ports service
 // func (sc *ServiceContainer) GetPortsService() *PortsService {
 //	return sc.portsService
@@ -194,6 +309,10 @@ func (sc *ServiceContainer) Close() error {
 		}
 	}
 
+	if sc.events != nil {
+		sc.events.Close()
+	}
+
 	if sc.database != nil {
 		if err := sc.database.Close(); err != nil {
 			log.Printf("Error closing database: %v", err)
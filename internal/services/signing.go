@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"old-school/internal/interfaces"
+)
+
+// nodeKeySource provides the private key our records are signed with: the stored node key, or the
+// key of the running host, which keeps its key until restart when the stored one is rotated
+type nodeKeySource interface {
+	GetNodePrivateKey() (crypto.PrivKey, error)
+}
+
+// runningNodeKey returns the key source matching the peer ID peers know us by: the running host's
+// key when there is a host, otherwise the stored node key
+func runningNodeKey(p2pService *P2PService, settings interfaces.SettingsRepository) nodeKeySource {
+	if p2pService != nil && p2pService.host != nil {
+		return p2pService
+	}
+	return settings
+}
+
+// signWithNodeKey signs data with our node key and returns the marshalled public key with the signature
+func signWithNodeKey(keys nodeKeySource, data []byte) ([]byte, []byte, error) {
+	privateKey, err := keys.GetNodePrivateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get node private key: %w", err)
+	}
+
+	publicKey, err := crypto.MarshalPublicKey(privateKey.GetPublic())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	signature, err := privateKey.Sign(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	return publicKey, signature, nil
+}
+
+// nodePublicKey returns our marshalled node public key
+func nodePublicKey(keys nodeKeySource) ([]byte, error) {
+	privateKey, err := keys.GetNodePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node private key: %w", err)
+	}
+
+	publicKey, err := crypto.MarshalPublicKey(privateKey.GetPublic())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// verifyPeerSignature checks that data was signed by the key peerID is derived from
+func verifyPeerSignature(peerID string, marshalledKey, data, signature []byte) error {
+	publicKey, err := crypto.UnmarshalPublicKey(marshalledKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	keyID, err := peer.IDFromPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if keyID.String() != peerID {
+		return fmt.Errorf("public key belongs to %s, not %s", keyID, peerID)
+	}
+
+	valid, err := publicKey.Verify(data, signature)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature: %w", err)
+	}
+	if !valid {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+// signingBytes encodes the fields a signature covers. The domain keeps a signature for one kind
+// of record from being valid for another, and length prefixes keep field boundaries unambiguous.
+func signingBytes(domain string, fields ...string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(domain)
+	buffer.WriteByte('\n')
+	for _, field := range fields {
+		buffer.WriteString(strconv.Itoa(len(field)))
+		buffer.WriteByte(':')
+		buffer.WriteString(field)
+	}
+	return buffer.Bytes()
+}
@@ -21,11 +21,13 @@ type WebViewUI struct {
 	appService      *services.AppService
 	templateService *services.TemplateService
 	handler         *handlers.Handler
+	host            string
 	port            int
 }
 
-// NewWebViewUI creates a new WebView UI manager with automatic port discovery
-func NewWebViewUI(appService *services.AppService, preferredPort int) (*WebViewUI, error) {
+// NewWebViewUI creates a new WebView UI manager with automatic port discovery.
+// An empty host binds the HTTP server on all interfaces.
+func NewWebViewUI(appService *services.AppService, host string, preferredPort int) (*WebViewUI, error) {
 	// Find an available port starting from the preferred port
 	availablePort, err := services.FindAvailablePort(preferredPort)
 	if err != nil {
@@ -41,6 +43,7 @@ func NewWebViewUI(appService *services.AppService, preferredPort int) (*WebViewU
 		appService:      appService,
 		templateService: templateService,
 		handler:         handlers.NewHandler(appService, templateService),
+		host:            host,
 		port:            availablePort,
 	}, nil
 }
@@ -84,8 +87,9 @@ func (w *WebViewUI) StartServer() {
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 
 	go func() {
-		log.Printf("Starting web server on port %d", w.port)
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", w.port), nil); err != nil {
+		address := fmt.Sprintf("%s:%d", w.host, w.port)
+		log.Printf("Starting web server on %s", address)
+		if err := http.ListenAndServe(address, nil); err != nil {
 			log.Printf("Server error: %v", err)
 		}
 	}()
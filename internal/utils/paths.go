@@ -8,22 +8,27 @@ import (
 
 // PathManager handles common path operations
 type PathManager struct {
-	homeDir string
+	rootDir string
 }
 
-// NewPathManager creates a new path manager
+// NewPathManager creates a new path manager rooted at ~/space184
 func NewPathManager() (*PathManager, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user home directory: %w", err)
 	}
 
-	return &PathManager{homeDir: homeDir}, nil
+	return &PathManager{rootDir: filepath.Join(homeDir, "space184")}, nil
 }
 
-// GetSpace184Path returns the space184 directory path
+// NewPathManagerWithRoot creates a path manager rooted at the given data directory
+func NewPathManagerWithRoot(rootDir string) *PathManager {
+	return &PathManager{rootDir: rootDir}
+}
+
+// GetSpace184Path returns the space184 (data) directory path
 func (pm *PathManager) GetSpace184Path() string {
-	return filepath.Join(pm.homeDir, "space184")
+	return pm.rootDir
 }
 
 // GetDocsPath returns the docs directory path
@@ -106,7 +111,7 @@ func (pm *PathManager) GetDatabasePath() string {
 	return filepath.Join(pm.GetSpace184Path(), "node.db")
 }
 
-// GetRelativePath computes relative path from home directory
+// GetRelativePath computes relative path from the space184 directory
 func (pm *PathManager) GetRelativePath(absolutePath string) (string, error) {
 	relPath, err := filepath.Rel(pm.GetSpace184Path(), absolutePath)
 	if err != nil {
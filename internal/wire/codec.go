@@ -0,0 +1,105 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Encoder writes values to a stream
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Decoder reads values from a stream
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// Codec encodes envelopes and their payloads in one wire encoding
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// CodecFor returns the codec for an encoding name
+func CodecFor(encoding string) (Codec, error) {
+	switch encoding {
+	case EncodingCBOR:
+		return cborCodec, nil
+	case EncodingJSON:
+		return jsonCodec, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// JSONCodec encodes envelopes as newline delimited JSON
+type JSONCodec struct{}
+
+var jsonCodec = &JSONCodec{}
+
+// Name returns the encoding name
+func (c *JSONCodec) Name() string { return EncodingJSON }
+
+// Marshal encodes a value as JSON
+func (c *JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes a JSON value
+func (c *JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// NewEncoder returns a JSON stream encoder
+func (c *JSONCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+
+// NewDecoder returns a JSON stream decoder
+func (c *JSONCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// CBORCodec encodes envelopes as CBOR (RFC 8949)
+type CBORCodec struct {
+	encMode cbor.EncMode
+	decMode cbor.DecMode
+}
+
+var cborCodec = newCBORCodec()
+
+// Generic maps decode with string keys so they behave like their JSON counterparts
+var mapStringInterfaceType = reflect.TypeOf(map[string]interface{}(nil))
+
+func newCBORCodec() *CBORCodec {
+	encMode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(fmt.Sprintf("invalid CBOR encoding options: %v", err))
+	}
+
+	decMode, err := cbor.DecOptions{
+		DefaultMapType:   mapStringInterfaceType,
+		MaxArrayElements: 1 << 20,
+		MaxMapPairs:      1 << 20,
+	}.DecMode()
+	if err != nil {
+		panic(fmt.Sprintf("invalid CBOR decoding options: %v", err))
+	}
+
+	return &CBORCodec{encMode: encMode, decMode: decMode}
+}
+
+// Name returns the encoding name
+func (c *CBORCodec) Name() string { return EncodingCBOR }
+
+// Marshal encodes a value as CBOR
+func (c *CBORCodec) Marshal(v interface{}) ([]byte, error) { return c.encMode.Marshal(v) }
+
+// Unmarshal decodes a CBOR value
+func (c *CBORCodec) Unmarshal(data []byte, v interface{}) error { return c.decMode.Unmarshal(data, v) }
+
+// NewEncoder returns a CBOR stream encoder
+func (c *CBORCodec) NewEncoder(w io.Writer) Encoder { return c.encMode.NewEncoder(w) }
+
+// NewDecoder returns a CBOR stream decoder
+func (c *CBORCodec) NewDecoder(r io.Reader) Decoder { return c.decMode.NewDecoder(r) }
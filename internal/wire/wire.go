@@ -0,0 +1,134 @@
+package wire
+
+import (
+	"fmt"
+)
+
+const (
+	// LegacyProtocolVersion is the untyped JSON P2PMessage protocol spoken by older nodes
+	LegacyProtocolVersion = 1
+
+	// ProtocolVersion is the typed envelope protocol spoken by this build
+	ProtocolVersion = 2
+
+	// MaxRequestSize limits how much a node reads for a single incoming request envelope
+	MaxRequestSize = 16 * 1024 * 1024
+)
+
+// Envelope encodings, advertised during identification
+const (
+	EncodingCBOR = "cbor"
+	EncodingJSON = "json"
+)
+
+// SupportedEncodings lists the envelope encodings of this build in order of preference
+var SupportedEncodings = []string{EncodingCBOR, EncodingJSON}
+
+// Optional features a node advertises during identification
+const (
+	CapabilityMediaGalleries = "media-galleries"
+	CapabilityBlob           = "blob"
+	CapabilityProfileUpdates = "profile-updates"
+	CapabilityInvites        = "invites"
+)
+
+// Error codes carried in a response envelope
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeInternal           = "internal"
+)
+
+// Envelope is the typed, versioned message frame of protocol version 2.
+// A request and its response share the same RequestID.
+type Envelope struct {
+	Version   int        `json:"v"`
+	RequestID uint64     `json:"id"`
+	Type      string     `json:"type"`
+	Error     *Error     `json:"error,omitempty"`
+	Payload   RawPayload `json:"payload,omitempty"`
+}
+
+// Error is a protocol level error reported by the remote peer
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewError creates a protocol error with a formatted message
+func NewError(code, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("peer returned %s: %s", e.Code, e.Message)
+}
+
+// RawPayload holds a payload already encoded with the envelope's codec.
+// In JSON it is embedded as-is, in CBOR it travels as a byte string.
+type RawPayload []byte
+
+// MarshalJSON embeds the raw JSON payload
+func (r RawPayload) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// UnmarshalJSON keeps a copy of the raw JSON payload
+func (r *RawPayload) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*r = nil
+		return nil
+	}
+	*r = append((*r)[0:0], data...)
+	return nil
+}
+
+// PeerProtocol describes what was negotiated with a peer during identification
+type PeerProtocol struct {
+	AppVersion   string   `json:"app_version"`
+	Version      int      `json:"protocol_version"`
+	Encoding     string   `json:"encoding"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Supports reports whether the peer advertised a capability
+func (pp *PeerProtocol) Supports(capability string) bool {
+	if pp == nil {
+		return false
+	}
+	for _, c := range pp.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate picks the protocol version and encoding to use with a peer from what it advertised.
+// Peers that advertise nothing are legacy nodes and get the JSON P2PMessage protocol.
+func Negotiate(remoteVersion int, remoteEncodings []string) (int, string) {
+	if remoteVersion < ProtocolVersion {
+		return LegacyProtocolVersion, EncodingJSON
+	}
+
+	for _, local := range SupportedEncodings {
+		for _, remote := range remoteEncodings {
+			if local == remote {
+				return ProtocolVersion, local
+			}
+		}
+	}
+
+	// A newer peer always understands JSON envelopes
+	return ProtocolVersion, EncodingJSON
+}
@@ -10,7 +10,32 @@ function initializeFriendsPage() {
         return;
     }
 
-    loadFriends();
+    loadFriendGroups();
+    loadFriendRequests();
+    loadBlockedPeers();
+
+    // Reload the list when friends are added, removed, come online or move to a new peer ID
+    sharedApp.setLiveEventHandler('friends-page', ['friend.added', 'friend.removed', 'friend.online', 'friend.offline', 'peer.key_rotated'], () => {
+        if (document.getElementById('friendsContent')) {
+            loadFriends();
+        }
+    });
+
+    // Reload pending requests when one arrives or is answered
+    sharedApp.setLiveEventHandler('friend-requests', ['friend.request.received', 'friend.request.accepted', 'friend.request.declined', 'friend.request.cancelled', 'friend.added'], () => {
+        if (document.getElementById('friendRequestsContent')) {
+            loadFriendRequests();
+        }
+    });
+
+    // Reload the block list when a peer is blocked or unblocked
+    sharedApp.setLiveEventHandler('blocked-peers', ['peer.blocked', 'peer.unblocked'], () => {
+        if (document.getElementById('blockedPeersContent')) {
+            loadBlockedPeers();
+            loadFriendGroups();
+        }
+    });
+
     // Show connection status initially
     if (typeof sharedApp !== 'undefined') {
         sharedApp.showStatus('connectionStatus', '', false);
@@ -26,14 +51,59 @@ document.addEventListener('DOMContentLoaded', function() {
 
 // Make functions globally accessible for SPA navigation
 window.loadFriends = loadFriends;
+window.loadFriendRequests = loadFriendRequests;
+window.loadFriendGroups = loadFriendGroups;
+window.loadBlockedPeers = loadBlockedPeers;
 window.initializeFriendsPage = initializeFriendsPage;
 
+// Friend groups known to the page, used for the filter and the per-friend group pickers
+let friendGroups = [];
+
+// Name of the group the friends list is filtered by, empty for all friends
+function selectedFriendGroup() {
+    const filter = document.getElementById('friendGroupFilter');
+    return filter ? filter.value : '';
+}
+
+// Load friend groups, refresh the group filter and then the friends list
+async function loadFriendGroups() {
+    try {
+        const data = await sharedApp.fetchAPI('/api/friend-groups');
+        friendGroups = data.groups || [];
+    } catch (error) {
+        console.error('Error loading friend groups:', error);
+        friendGroups = [];
+    }
+
+    const filter = document.getElementById('friendGroupFilter');
+    if (filter) {
+        const selected = filter.value;
+        filter.innerHTML = '<option value="">All friends</option>' + friendGroups.map(group => {
+            const name = sharedApp.escapeHtml(group.name);
+            return `<option value="${name}">${name} (${group.members.length})</option>`;
+        }).join('');
+        filter.value = friendGroups.some(group => group.name === selected) ? selected : '';
+    }
+
+    onFriendGroupFilterChange();
+}
+
+// Show the group actions when a group is selected and reload the friends list
+function onFriendGroupFilterChange() {
+    const actions = document.getElementById('friendGroupActions');
+    if (actions) {
+        actions.style.display = selectedFriendGroup() ? 'inline-flex' : 'none';
+    }
+    loadFriends();
+}
+
 // Load friends from the server
 async function loadFriends() {
     try {
         sharedApp.showStatus('friendsStatus', 'Loading friends...', false);
         
-        const data = await sharedApp.fetchAPI('/api/friends');
+        const group = selectedFriendGroup();
+        const data = await sharedApp.fetchAPI(group ? `/api/friends?group=${encodeURIComponent(group)}` : '/api/friends');
         
         displayFriends(data.friends || []);
         sharedApp.hideStatus('friendsStatus');
@@ -44,6 +114,89 @@ async function loadFriends() {
     }
 }
 
+// Load pending friend requests from the server
+async function loadFriendRequests() {
+    try {
+        const data = await sharedApp.fetchAPI('/api/friend-requests?status=pending');
+        displayFriendRequests(data.requests || []);
+    } catch (error) {
+        console.error('Error loading friend requests:', error);
+    }
+}
+
+// Display pending incoming and outgoing friend requests
+function displayFriendRequests(requests) {
+    const section = document.getElementById('friendRequestsSection');
+    const content = document.getElementById('friendRequestsContent');
+    if (!section || !content) {
+        return;
+    }
+
+    if (requests.length === 0) {
+        section.style.display = 'none';
+        content.innerHTML = '';
+        return;
+    }
+
+    content.innerHTML = requests.map(request => {
+        const name = sharedApp.escapeHtml(request.peer_name || request.peer_id);
+        const sentDate = new Date(request.created_at).toLocaleString();
+        const messageHtml = request.message
+            ? `<br><em>"${sharedApp.escapeHtml(request.message)}"</em>`
+            : '';
+
+        let description;
+        let buttons;
+        if (request.direction === 'incoming') {
+            description = `${name} wants to be your friend`;
+            buttons = `
+                <button class="button" onclick="answerFriendRequest('${request.peer_id}', 'accept')">Accept</button>
+                <button class="button" onclick="answerFriendRequest('${request.peer_id}', 'decline')" style="background-color: #dc3545;">Decline</button>
+            `;
+        } else {
+            const delivery = request.delivered ? 'waiting for an answer' : 'will be delivered when they come online';
+            description = `Request sent to ${name}, ${delivery}`;
+            buttons = `
+                <button class="button" onclick="answerFriendRequest('${request.peer_id}', 'cancel')" style="background-color: #6c757d;">Cancel</button>
+            `;
+        }
+
+        return `
+            <div style="border: 1px solid #ddd; border-radius: 5px; padding: 15px; margin-bottom: 10px; background: #fffbea;">
+                <div style="display: flex; justify-content: space-between; align-items: center;">
+                    <div>
+                        <strong>${description}</strong>
+                        <br>
+                        <small style="color: #666;">${sentDate}</small>
+                        ${messageHtml}
+                    </div>
+                    <div style="display: flex; gap: 10px;">${buttons}</div>
+                </div>
+            </div>
+        `;
+    }).join('');
+    section.style.display = 'block';
+}
+
+// Accept, decline or cancel a pending friend request
+async function answerFriendRequest(peerID, action) {
+    try {
+        const response = await fetch(`/api/friend-requests/${encodeURIComponent(peerID)}/${action}`, {
+            method: 'POST'
+        });
+
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+
+        loadFriendRequests();
+        loadFriends();
+    } catch (error) {
+        console.error(`Error trying to ${action} friend request:`, error);
+        sharedApp.showStatus('friendsStatus', `❌ Failed to ${action} friend request: ${error.message}`, true);
+    }
+}
+
 // Display friends in the list
 function displayFriends(friends) {
     const friendsContent = document.getElementById('friendsContent');
@@ -66,6 +219,7 @@ function displayFriends(friends) {
         // Load friend's avatar
         const avatarInfo = await sharedApp.getPeerAvatar(friend.peer_id);
         const avatarHtml = sharedApp.createPeerAvatarElement(friend.peer_id, avatarInfo, '50px');
+        const groupsHtml = friendGroupsHtml(friend);
 
         const friendCard = `
             <div style="border: 1px solid #ddd; border-radius: 5px; padding: 15px; background: #f8f9fa; cursor: pointer;" onclick="viewFriendProfile('${friend.peer_id}')">
@@ -74,17 +228,21 @@ function displayFriends(friends) {
                         ${avatarHtml}
                         <div style="margin-left: 15px;">
                             <strong style="font-size: 18px;">${sharedApp.escapeHtml(friend.peer_name)}</strong>
+                            ${friend.mutual ? '' : '<small style="color: #856404;">(not confirmed yet)</small>'}
                             <br>
                             <small style="color: #666;">
                                 Added: ${addedDate} • Last seen: ${lastSeenText}
                                 <br>
                                 Status: <span style="color: ${statusColor}; font-weight: bold;">${onlineStatus}</span>
+                                ${reconnectStatusHtml(friend)}
                             </small>
+                            ${groupsHtml}
                         </div>
                     </div>
                     <div style="display: flex; gap: 10px;">
                         <button class="button" onclick="event.stopPropagation(); viewFriendProfile('${friend.peer_id}')">View Profile</button>
                         <button class="button" onclick="event.stopPropagation(); removeFriend('${friend.peer_id}', '${sharedApp.escapeHtml(friend.peer_name)}')" style="background-color: #dc3545;">Remove</button>
+                        <button class="button" onclick="event.stopPropagation(); blockPeer('${friend.peer_id}', '${sharedApp.escapeHtml(friend.peer_name)}')" style="background-color: #343a40;">Block</button>
                     </div>
                 </div>
             </div>
@@ -100,6 +258,174 @@ function displayFriends(friends) {
     });
 }
 
+// Describe what the reconnect supervisor is doing for an offline friend
+function reconnectStatusHtml(friend) {
+    const reconnect = friend.reconnect;
+    if (friend.is_online || !reconnect || reconnect.state === 'connected') {
+        return '';
+    }
+
+    let text = reconnect.state === 'dialing' ? '🔄 Reconnecting now' : '⏳ Waiting to reconnect';
+    if (reconnect.attempts > 0) {
+        text += ` • ${reconnect.attempts} failed attempt(s)`;
+    }
+    if (reconnect.state === 'waiting' && reconnect.next_attempt) {
+        text += ` • next try ${new Date(reconnect.next_attempt).toLocaleTimeString()}`;
+    }
+
+    const title = reconnect.last_error ? ` title="${sharedApp.escapeHtml(reconnect.last_error)}"` : '';
+    return `<br><span${title}>${text}</span>`;
+}
+
+// Render a friend's group chips and a picker to add the friend to another group
+function friendGroupsHtml(friend) {
+    const memberOf = friend.groups || [];
+    const chips = memberOf.map(group => {
+        const name = sharedApp.escapeHtml(group);
+        return `<span style="display: inline-block; background: #e2e6ea; border-radius: 10px; padding: 2px 8px; margin: 4px 4px 0 0; font-size: 12px;">
+            ${name} <a href="#" title="Remove from group" onclick="event.stopPropagation(); event.preventDefault(); removeFriendFromGroup('${name}', '${friend.peer_id}')">×</a>
+        </span>`;
+    }).join('');
+
+    const available = friendGroups.filter(group => !memberOf.includes(group.name));
+    const picker = available.length === 0 ? '' : `
+        <select class="input" style="font-size: 12px; padding: 2px; margin-top: 4px;" onclick="event.stopPropagation()" onchange="addFriendToGroup(this.value, '${friend.peer_id}')">
+            <option value="">+ Add to group</option>
+            ${available.map(group => `<option value="${sharedApp.escapeHtml(group.name)}">${sharedApp.escapeHtml(group.name)}</option>`).join('')}
+        </select>`;
+
+    return `<div>${chips}${picker}</div>`;
+}
+
+// Send a friend group request and reload groups and friends on success
+async function friendGroupRequest(url, options, successMessage) {
+    try {
+        const response = await fetch(url, options);
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+
+        if (successMessage) {
+            sharedApp.showStatus('friendGroupsStatus', successMessage, false);
+            setTimeout(() => sharedApp.hideStatus('friendGroupsStatus'), 3000);
+        }
+        await loadFriendGroups();
+        return true;
+    } catch (error) {
+        console.error('Friend group request failed:', error);
+        sharedApp.showStatus('friendGroupsStatus', '❌ ' + error.message, true);
+        return false;
+    }
+}
+
+// Create a friend group from the name input
+async function createFriendGroup() {
+    const input = document.getElementById('newFriendGroupInput');
+    const name = input.value.trim();
+    if (!name) {
+        sharedApp.showStatus('friendGroupsStatus', 'Please enter a group name', true);
+        return;
+    }
+
+    const created = await friendGroupRequest('/api/friend-groups', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ name: name })
+    }, `✅ Created group ${name}`);
+    if (created) {
+        input.value = '';
+    }
+}
+
+// Add a friend to a group
+async function addFriendToGroup(group, peerID) {
+    if (!group) {
+        return;
+    }
+    await friendGroupRequest(`/api/friend-groups/${encodeURIComponent(group)}/members`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ peer_id: peerID })
+    });
+}
+
+// Remove a friend from a group
+async function removeFriendFromGroup(group, peerID) {
+    await friendGroupRequest(`/api/friend-groups/${encodeURIComponent(group)}/members/${encodeURIComponent(peerID)}`, {
+        method: 'DELETE'
+    });
+}
+
+// Rename the selected group
+async function renameSelectedFriendGroup() {
+    const group = selectedFriendGroup();
+    const newName = group ? prompt(`Rename group ${group} to:`, group) : null;
+    if (!newName || newName.trim() === group) {
+        return;
+    }
+
+    const renamed = await friendGroupRequest(`/api/friend-groups/${encodeURIComponent(group)}`, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ name: newName.trim() })
+    }, `✅ Renamed group ${group} to ${newName.trim()}`);
+    if (renamed) {
+        document.getElementById('friendGroupFilter').value = newName.trim();
+        onFriendGroupFilterChange();
+    }
+}
+
+// Delete the selected group
+async function deleteSelectedFriendGroup() {
+    const group = selectedFriendGroup();
+    if (!group || !confirm(`Delete the group ${group}? Content shared only with this group will be shared with nobody.`)) {
+        return;
+    }
+
+    await friendGroupRequest(`/api/friend-groups/${encodeURIComponent(group)}`, {
+        method: 'DELETE'
+    }, `✅ Deleted group ${group}`);
+}
+
+// Sync the files metadata of the friends in the selected group
+async function syncSelectedFriendGroup() {
+    const group = selectedFriendGroup();
+    if (!group) {
+        return;
+    }
+
+    try {
+        sharedApp.showStatus('friendsStatus', `Syncing files of ${group}...`, false);
+        const response = await fetch(`/api/sync-friend-files?group=${encodeURIComponent(group)}`, { method: 'POST' });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+        sharedApp.showStatus('friendsStatus', `✅ Synced files of ${group}`, false);
+    } catch (error) {
+        sharedApp.showStatus('friendsStatus', `❌ Failed to sync ${group}: ${error.message}`, true);
+    }
+}
+
+// Reconnect to the friends in the selected group
+async function reconnectSelectedFriendGroup() {
+    const group = selectedFriendGroup();
+    if (!group) {
+        return;
+    }
+
+    try {
+        sharedApp.showStatus('friendsStatus', `Reconnecting to ${group}...`, false);
+        const response = await fetch(`/api/friend-groups/${encodeURIComponent(group)}/reconnect`, { method: 'POST' });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+        sharedApp.showStatus('friendsStatus', `✅ Reconnection to ${group} finished`, false);
+        loadFriends();
+    } catch (error) {
+        sharedApp.showStatus('friendsStatus', `❌ Failed to reconnect to ${group}: ${error.message}`, true);
+    }
+}
+
 // Display empty state
 function displayEmptyState(message) {
     const friendsContent = document.getElementById('friendsContent');
@@ -144,6 +470,76 @@ async function removeFriend(peerID, friendName) {
     }
 }
 
+// Load the block list, the section stays hidden while it is empty
+async function loadBlockedPeers() {
+    const section = document.getElementById('blockedPeersSection');
+    const content = document.getElementById('blockedPeersContent');
+    if (!section || !content) {
+        return;
+    }
+
+    try {
+        const data = await sharedApp.fetchAPI('/api/blocked-peers');
+        const blocked = data.blocked || [];
+        section.style.display = blocked.length > 0 ? 'block' : 'none';
+
+        content.innerHTML = blocked.map(peer => {
+            const name = sharedApp.escapeHtml(peer.peer_name || peer.peer_id.substring(0, 12) + '...');
+            const reason = peer.reason ? ` • ${sharedApp.escapeHtml(peer.reason)}` : '';
+            return `
+                <div style="display: flex; justify-content: space-between; align-items: center; border: 1px solid #ddd; border-radius: 5px; padding: 10px; margin-bottom: 10px;">
+                    <div>
+                        <strong>${name}</strong>
+                        <br>
+                        <small style="color: #666;">Blocked ${new Date(peer.blocked_at).toLocaleString()}${reason}</small>
+                    </div>
+                    <button class="button" onclick="unblockPeer('${peer.peer_id}')">Unblock</button>
+                </div>
+            `;
+        }).join('');
+    } catch (error) {
+        console.error('Error loading blocked peers:', error);
+    }
+}
+
+// Block a peer, optionally deleting everything stored about it
+async function blockPeer(peerID, peerName) {
+    if (!confirm(`Block ${peerName}? They will not be able to connect to you or see your files.`)) {
+        return;
+    }
+    const purge = confirm(`Also delete everything stored about ${peerName}: downloaded files, their friends list, connection history and file records?`);
+
+    try {
+        const response = await fetch('/api/blocked-peers', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ peer_id: peerID, peer_name: peerName, purge: purge })
+        });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+
+        sharedApp.showStatus('friendsStatus', `🚫 ${peerName} blocked`, false);
+        loadBlockedPeers();
+        loadFriendGroups();
+    } catch (error) {
+        sharedApp.showStatus('friendsStatus', `❌ Failed to block ${peerName}: ${error.message}`, true);
+    }
+}
+
+// Remove a peer from the block list
+async function unblockPeer(peerID) {
+    try {
+        const response = await fetch(`/api/blocked-peers/${encodeURIComponent(peerID)}`, { method: 'DELETE' });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+        loadBlockedPeers();
+    } catch (error) {
+        sharedApp.showStatus('blockedPeersStatus', `❌ Failed to unblock peer: ${error.message}`, true);
+    }
+}
+
 // Navigate to friend profile page using SPA navigation
 function viewFriendProfile(peerID) {
     if (typeof sharedApp !== 'undefined' && sharedApp.loadPage) {
@@ -153,73 +549,68 @@ function viewFriendProfile(peerID) {
     }
 }
 
-// Connect to a peer using connection string and add to friends
-async function connectAndAddFriend() {
-    const connectionString = document.getElementById('connectionStringInput').value;
-    
-    if (!connectionString) {
-        sharedApp.showStatus('connectionStatus', 'Please enter a connection string', true);
-        return;
-    }
+// Redeem an invite code: connect to the inviting node and become its friend
+async function redeemInviteCode() {
+    const code = document.getElementById('inviteCodeInput').value.trim();
 
-    // Parse connection string (format: IP:PORT:PEER_ID)
-    const parts = connectionString.split(':');
-    if (parts.length < 3) {
-        sharedApp.showStatus('connectionStatus', 'Invalid connection string format. Use IP:PORT:PEER_ID', true);
+    if (!code) {
+        sharedApp.showStatus('connectionStatus', 'Please paste an invite code', true);
         return;
     }
 
-    const ip = parts[0];
-    const port = parts[1];
-    const peerId = parts.slice(2).join(':'); // In case peer ID contains colons
-
     try {
-        sharedApp.showStatus('connectionStatus', 'Connecting to peer...', false);
-        
-        const response = await fetch('/api/connect-ip', {
+        sharedApp.showStatus('connectionStatus', 'Connecting to the inviting node...', false);
+
+        const response = await fetch('/api/invites/redeem', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ 
-                ip: ip,
-                port: parseInt(port),
-                peerId: peerId 
-            })
+            body: JSON.stringify({ code: code })
         });
-        
+
         if (!response.ok) {
-            throw new Error('Network response was not ok');
+            throw new Error(await response.text());
         }
-        
-        const connectionData = await response.json();
-        sharedApp.showStatus('connectionStatus', 'Successfully connected! Adding to friends...', false);
-        
-        // Now add the peer to friends
-        const friendResponse = await fetch('/api/friends', {
-            method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ 
-                peer_id: peerId,
-                peer_name: connectionData.peer_name || 'Unknown'
-            })
-        });
-        
-        if (!friendResponse.ok) {
-            throw new Error('Failed to add to friends');
+
+        const result = await response.json();
+        const name = result.peer_name || 'peer';
+        if (result.friend_request && result.friend_request.status === 'accepted') {
+            sharedApp.showStatus('connectionStatus', `✅ Connected to ${name}, you are now friends!`, false);
+        } else {
+            sharedApp.showStatus('connectionStatus', `✅ Connected to ${name}, friend request sent!`, false);
         }
-        
-        const friendData = await friendResponse.json();
-        sharedApp.showStatus('connectionStatus', `✅ Successfully connected and added ${connectionData.peer_name || 'peer'} to friends!`, false);
-        
+
         // Clear the input field
-        document.getElementById('connectionStringInput').value = '';
-        
+        document.getElementById('inviteCodeInput').value = '';
+
         // Reload friends list to show the new friend
         setTimeout(() => {
             loadFriends();
+            loadFriendRequests();
             sharedApp.hideStatus('connectionStatus');
         }, 2000);
-        
+
+    } catch (error) {
+        sharedApp.showStatus('connectionStatus', 'Error redeeming invite: ' + error.message, true);
+    }
+}
+
+// Create an invite code to share with a friend
+async function createInviteCode() {
+    try {
+        const response = await fetch('/api/invites', { method: 'POST' });
+
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+
+        const result = await response.json();
+        document.getElementById('inviteCodeOutput').value = result.code;
+        document.getElementById('inviteCodeExpiry').textContent =
+            `Valid until ${new Date(result.invite.expires_at).toLocaleString()}, can be redeemed once.`;
+        document.getElementById('inviteCodeResult').style.display = 'block';
+        document.getElementById('inviteCodeOutput').select();
+
     } catch (error) {
-        sharedApp.showStatus('connectionStatus', 'Error connecting or adding friend: ' + error.message, true);
+        sharedApp.showStatus('connectionStatus', 'Error creating invite: ' + error.message, true);
     }
 }
\ No newline at end of file
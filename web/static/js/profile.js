@@ -1,4 +1,5 @@
 let userInfo = null;
+let ownProfile = null;
 let currentFriend = null;
 let isViewingFriend = false;
 
@@ -24,7 +25,26 @@ function initializeProfilePage() {
         window.isViewingFriend = false;
         loadUserInfo();
         loadDocs();
+        loadPrivacySettings();
+        document.getElementById('identitySection').style.display = 'block';
+        loadKeyRotations();
     }
+
+    // Keep the friends tab current while it is open
+    sharedApp.setLiveEventHandler('profile-friends', ['friend.added', 'friend.removed', 'friend.online', 'friend.offline'], () => {
+        if (friendsLoaded && !isViewingFriend) {
+            loadProfileFriends();
+        }
+    });
+
+    // Show a friend's profile changes as soon as the friend pushes them
+    sharedApp.setLiveEventHandler('profile-record', ['profile.updated'], (event) => {
+        const data = event.data || {};
+        if (isViewingFriend && currentFriend && data.peer_id === currentFriend.peer_id) {
+            document.getElementById('profileName').textContent = currentFriend.peer_name;
+            loadPeerProfileRecord(currentFriend.peer_id);
+        }
+    });
 }
 
 // Load initial data when page loads (for direct page access only)
@@ -71,6 +91,7 @@ async function loadFriendProfile() {
         // Update profile display
         document.getElementById('profileName').textContent = friendInfo.peer_name;
         document.getElementById('profileId').textContent = `Peer ID: ${peerID}`;
+        await loadPeerProfileRecord(peerID);
 
         // Load friend's avatar
         const avatarInfo = await sharedApp.getPeerAvatar(peerID);
@@ -89,6 +110,11 @@ async function loadFriendProfile() {
         document.getElementById('downloadSection').style.display = 'block';
         document.getElementById('tabNavigation').style.display = 'block';
         
+        // Hide our own profile settings on friend profiles
+        document.getElementById('profileEditSection').style.display = 'none';
+        document.getElementById('privacySection').style.display = 'none';
+        document.getElementById('identitySection').style.display = 'none';
+
         // Hide upload buttons for friend profiles
         document.getElementById('addDocsBtn').style.display = 'none';
         document.getElementById('addPhotosBtn').style.display = 'none';
@@ -104,6 +130,42 @@ async function loadFriendProfile() {
     }
 }
 
+// Show the bio, location and links of a profile in the profile header
+function showProfileDetails(profile) {
+    const bio = document.getElementById('profileBio');
+    bio.textContent = profile.bio || '';
+    bio.style.display = profile.bio ? 'block' : 'none';
+
+    const locationText = document.getElementById('profileLocation');
+    locationText.textContent = profile.location ? `📍 ${profile.location}` : '';
+    locationText.style.display = profile.location ? 'block' : 'none';
+
+    const links = document.getElementById('profileLinks');
+    const profileLinks = profile.links || [];
+    links.innerHTML = profileLinks.map(link => {
+        const escaped = sharedApp.escapeHtml(link);
+        return `<a href="${escaped}" target="_blank" rel="noopener noreferrer">🔗 ${escaped}</a>`;
+    }).join('');
+    links.style.display = profileLinks.length > 0 ? 'block' : 'none';
+}
+
+// Show the name, bio, location and links from the friend's signed profile record, if we have one
+async function loadPeerProfileRecord(peerID) {
+    showProfileDetails({});
+
+    const response = await fetch(`/api/peer-profiles/${encodeURIComponent(peerID)}`);
+    if (!response.ok) {
+        return;
+    }
+
+    const profile = await response.json();
+    const profileName = document.getElementById('profileName');
+    profileName.textContent = profile.name || profileName.textContent;
+    profileName.insertAdjacentHTML('beforeend', '<span class="profile-verified" title="Signed by the peer\'s key">✔ verified</span>');
+
+    showProfileDetails(profile);
+}
+
 // Load friend info from API
 async function loadFriendInfo(peerID) {
     try {
@@ -133,8 +195,11 @@ async function loadUserInfo() {
             document.getElementById('profileId').textContent = `Peer ID: ${nodeId}`;
         }
 
-        // Try to get user name from database/settings
-        // For now, we'll use a default name
+        // Use the name from our profile
+        ownProfile = await loadOwnProfile();
+        if (ownProfile && ownProfile.name) {
+            name = ownProfile.name;
+        }
         document.getElementById('profileName').textContent = name;
 
         // Load avatar
@@ -151,13 +216,216 @@ async function loadUserInfo() {
     }
 }
 
+// Load our profile, show its details and fill in the edit form
+async function loadOwnProfile() {
+    try {
+        const profile = await sharedApp.fetchAPI('/api/profile');
+        showProfileDetails(profile);
+
+        document.getElementById('editProfileName').value = profile.name || '';
+        document.getElementById('editProfileBio').value = profile.bio || '';
+        document.getElementById('editProfileLocation').value = profile.location || '';
+        document.getElementById('editProfileLinks').value = (profile.links || []).join('\n');
+
+        const avatarSelect = document.getElementById('editProfileAvatar');
+        const avatars = profile.avatars || [];
+        avatarSelect.innerHTML = avatars.length > 0
+            ? avatars.map(avatar => `<option value="${sharedApp.escapeHtml(avatar)}">${sharedApp.escapeHtml(avatar)}</option>`).join('')
+            : '<option value="">No avatar images</option>';
+        avatarSelect.value = profile.avatar || '';
+
+        document.getElementById('profileEditSection').style.display = 'block';
+        return profile;
+    } catch (error) {
+        console.error('Error loading profile:', error);
+        return null;
+    }
+}
+
+// Save the edit form as our profile
+async function saveProfile() {
+    const update = {
+        name: document.getElementById('editProfileName').value,
+        bio: document.getElementById('editProfileBio').value,
+        location: document.getElementById('editProfileLocation').value,
+        links: document.getElementById('editProfileLinks').value.split('\n').map(link => link.trim()).filter(link => link !== ''),
+        avatar: document.getElementById('editProfileAvatar').value
+    };
+
+    try {
+        const response = await fetch('/api/profile', {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(update)
+        });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+
+        ownProfile = await response.json();
+        document.getElementById('profileName').textContent = ownProfile.name;
+        showProfileDetails(ownProfile);
+        await loadAvatar();
+        sharedApp.showStatus('profileEditStatus', '✅ Profile saved and sent to connected peers', false);
+    } catch (error) {
+        sharedApp.showStatus('profileEditStatus', '❌ Failed to save profile: ' + error.message, true);
+    }
+}
+
+// Audiences offered for each privacy setting
+const privacyAudiences = [
+    { value: 'anyone', label: '🌍 Anyone' },
+    { value: 'friends', label: '👥 Friends only' },
+    { value: 'nobody', label: '🔒 Nobody' }
+];
+
+// Load the privacy settings into the privacy section of our own profile
+async function loadPrivacySettings() {
+    const section = document.getElementById('privacySection');
+    if (!section) {
+        return;
+    }
+
+    try {
+        const settings = await sharedApp.fetchAPI('/api/privacy');
+        document.querySelectorAll('.privacy-select').forEach(select => {
+            select.innerHTML = privacyAudiences.map(audience => `<option value="${audience.value}">${audience.label}</option>`).join('');
+            select.value = settings[select.dataset.item] || 'anyone';
+        });
+        section.style.display = 'block';
+    } catch (error) {
+        console.error('Error loading privacy settings:', error);
+    }
+}
+
+// Save the audiences chosen in the privacy section
+async function savePrivacySettings() {
+    const settings = {};
+    document.querySelectorAll('.privacy-select').forEach(select => {
+        settings[select.dataset.item] = select.value;
+    });
+
+    try {
+        const response = await fetch('/api/privacy', {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(settings)
+        });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+        sharedApp.showStatus('privacyStatus', '✅ Privacy settings saved, they apply to the next identification with each peer', false);
+    } catch (error) {
+        sharedApp.showStatus('privacyStatus', '❌ Failed to save privacy settings: ' + error.message, true);
+    }
+}
+
+// Download our identity encrypted with the passphrase
+async function exportIdentity() {
+    const passphraseInput = document.getElementById('identityExportPassphrase');
+
+    try {
+        const response = await fetch('/api/identity/export', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ passphrase: passphraseInput.value })
+        });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+
+        const disposition = response.headers.get('Content-Disposition') || '';
+        const match = disposition.match(/filename="([^"]+)"/);
+        const url = URL.createObjectURL(await response.blob());
+        const link = document.createElement('a');
+        link.href = url;
+        link.download = match ? match[1] : 'old-school-identity.json';
+        document.body.appendChild(link);
+        link.click();
+        link.remove();
+        URL.revokeObjectURL(url);
+
+        passphraseInput.value = '';
+        sharedApp.showStatus('identityStatus', '✅ Identity backup downloaded', false);
+    } catch (error) {
+        sharedApp.showStatus('identityStatus', '❌ Failed to export identity: ' + error.message, true);
+    }
+}
+
+// Restore an identity backup chosen in the identity section
+async function importIdentity() {
+    const file = document.getElementById('identityImportFile').files[0];
+    if (!file) {
+        sharedApp.showStatus('identityStatus', '❌ Choose a backup file first', true);
+        return;
+    }
+
+    try {
+        const backup = JSON.parse(await file.text());
+        const response = await fetch('/api/identity/import', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                passphrase: document.getElementById('identityImportPassphrase').value,
+                backup: backup
+            })
+        });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+
+        const result = await response.json();
+        let message = `✅ Restored ${result.peer_id} with ${result.friends} friend(s) and ${result.groups} group(s)`;
+        if (result.restart_required) {
+            message += '. Restart the node to use the restored identity.';
+        }
+        sharedApp.showStatus('identityStatus', message, false);
+    } catch (error) {
+        sharedApp.showStatus('identityStatus', '❌ Failed to restore identity: ' + error.message, true);
+    }
+}
+
+// Show our key type and earlier peer IDs in the identity section
+async function loadKeyRotations() {
+    const info = document.getElementById('identityKeyInfo');
+
+    try {
+        const data = await sharedApp.fetchAPI('/api/identity/rotations');
+        const rotations = data.rotations || [];
+        info.textContent = `${data.key_type} key, peer ID ${data.peer_id}` +
+            (rotations.length > 0 ? ` (rotated ${rotations.length} time(s), last on ${new Date(rotations[rotations.length - 1].rotated_at).toLocaleString()})` : '');
+    } catch (error) {
+        info.textContent = 'Failed to load key information: ' + error.message;
+    }
+}
+
+// Replace our node key with a new Ed25519 key
+async function rotateIdentityKey() {
+    if (!confirm('Rotate the node key? Your peer ID changes after the next restart and friends follow you to it when you reconnect. Download a new identity backup afterwards.')) {
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/identity/rotations', { method: 'POST' });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || `HTTP ${response.status}`);
+        }
+
+        const result = await response.json();
+        sharedApp.showStatus('identityStatus', `✅ New peer ID ${result.rotation.new_peer_id}. Restart the node to use it.`, false);
+        loadKeyRotations();
+    } catch (error) {
+        sharedApp.showStatus('identityStatus', '❌ Failed to rotate key: ' + error.message, true);
+    }
+}
+
 // Load user avatar
 async function loadAvatar() {
     try {
         const data = await sharedApp.loadAvatarImages();
         
         if (avatarImages.length > 0) {
-            const primaryAvatar = data.primary || avatarImages[0];
+            const primaryAvatar = (ownProfile && ownProfile.avatar) || data.primary || avatarImages[0];
             const avatarUrl = `/api/media/image/galleries/avatar/${primaryAvatar}`;
             
             document.getElementById('profileAvatar').innerHTML = 
@@ -897,8 +1165,9 @@ async function loadFriendAudio(peerID) {
     try {
         sharedApp.showStatus('audioStatus', 'Loading friend\'s audio collections...', false);
         
-        // For now, just show empty state - P2P audio not implemented yet
-        displayFriendAudioEmptyState('Audio sharing via P2P not yet implemented');
+        const data = await sharedApp.fetchAPI(`/api/peer-galleries/${encodeURIComponent(peerID)}?type=audio`);
+        
+        displayAudioWithFilters(data.galleries || [], peerID);
         audioLoaded = true;
         sharedApp.hideStatus('audioStatus');
     } catch (error) {
@@ -908,12 +1177,16 @@ async function loadFriendAudio(peerID) {
     }
 }
 
-// Display audio with filter buttons and file list
-function displayAudioWithFilters(galleries) {
+// Display audio with filter buttons and file list (peerID is set for a friend's audio)
+function displayAudioWithFilters(galleries, peerID = null) {
     const audioContent = document.getElementById('audioContent');
     
     if (galleries.length === 0) {
-        displayAudioEmptyState('No audio found');
+        if (peerID) {
+            displayFriendAudioEmptyState('Your friend has not shared any audio yet');
+        } else {
+            displayAudioEmptyState('No audio found');
+        }
         return;
     }
 
@@ -952,7 +1225,7 @@ function displayAudioWithFilters(galleries) {
                         <div class="audio-name">${sharedApp.escapeHtml(audio.name.replace(/\.[^/.]+$/, ''))}</div>
                         <div class="audio-gallery">${sharedApp.escapeHtml(audio.galleryDisplayName)}</div>
                     </div>
-                    <button class="audio-play-btn" onclick="playAudioFromGallery('${audio.gallery}', '${sharedApp.escapeHtml(audio.name)}')">
+                    <button class="audio-play-btn" onclick="playAudioFromGallery('${audio.gallery}', '${sharedApp.escapeHtml(audio.name)}'${peerID ? `, '${peerID}'` : ''})">
                         ▶ Play
                     </button>
                 </div>`;
@@ -990,9 +1263,11 @@ function filterAudio(galleryName) {
     });
 }
 
-// Play audio from gallery
-function playAudioFromGallery(galleryName, audioName) {
-    const audioUrl = `/api/media/audio/galleries/${encodeURIComponent(galleryName)}/${encodeURIComponent(audioName)}`;
+// Play audio from gallery (peerID is set when playing a friend's audio over P2P)
+function playAudioFromGallery(galleryName, audioName, peerID = null) {
+    const audioUrl = peerID
+        ? `/api/peer-galleries/${encodeURIComponent(peerID)}/${encodeURIComponent(galleryName)}/${encodeURIComponent(audioName)}?type=audio`
+        : `/api/media/audio/galleries/${encodeURIComponent(galleryName)}/${encodeURIComponent(audioName)}`;
     
     // Update global audio player
     const globalAudio = document.getElementById('globalAudio');
@@ -1094,7 +1369,7 @@ function displayFriendAudioEmptyState(message) {
             <div class="empty-state-icon">🎵</div>
             <div>${message}</div>
             <div class="create-doc-hint">
-                📡 Audio files would be requested directly from your friend via P2P connection
+                📡 Audio files are requested directly from your friend via P2P connection
             </div>
         </div>
     `;
@@ -1123,8 +1398,9 @@ async function loadFriendVideo(peerID) {
     try {
         sharedApp.showStatus('videoStatus', 'Loading friend\'s video collections...', false);
         
-        // For now, just show empty state - P2P video not implemented yet
-        displayFriendVideoEmptyState('Video sharing via P2P not yet implemented');
+        const data = await sharedApp.fetchAPI(`/api/peer-galleries/${encodeURIComponent(peerID)}?type=video`);
+        
+        displayVideoWithFilters(data.galleries || [], peerID);
         videoLoaded = true;
         sharedApp.hideStatus('videoStatus');
     } catch (error) {
@@ -1134,12 +1410,16 @@ async function loadFriendVideo(peerID) {
     }
 }
 
-// Display video with filter buttons and file list
-function displayVideoWithFilters(galleries) {
+// Display video with filter buttons and file list (peerID is set for a friend's videos)
+function displayVideoWithFilters(galleries, peerID = null) {
     const videoContent = document.getElementById('videoContent');
     
     if (galleries.length === 0) {
-        displayVideoEmptyState('No videos found');
+        if (peerID) {
+            displayFriendVideoEmptyState('Your friend has not shared any videos yet');
+        } else {
+            displayVideoEmptyState('No videos found');
+        }
         return;
     }
 
@@ -1178,7 +1458,7 @@ function displayVideoWithFilters(galleries) {
                         <div class="video-name">${sharedApp.escapeHtml(video.name.replace(/\.[^/.]+$/, ''))}</div>
                         <div class="video-gallery">${sharedApp.escapeHtml(video.galleryDisplayName)}</div>
                     </div>
-                    <button class="video-play-btn" onclick="playVideoFromGallery('${video.gallery}', '${sharedApp.escapeHtml(video.name)}')">
+                    <button class="video-play-btn" onclick="playVideoFromGallery('${video.gallery}', '${sharedApp.escapeHtml(video.name)}'${peerID ? `, '${peerID}'` : ''})">
                         ▶ Play
                     </button>
                 </div>`;
@@ -1216,9 +1496,11 @@ function filterVideos(galleryName) {
     });
 }
 
-// Play video from gallery
-function playVideoFromGallery(galleryName, videoName) {
-    const videoUrl = `/api/media/video/galleries/${encodeURIComponent(galleryName)}/${encodeURIComponent(videoName)}`;
+// Play video from gallery (peerID is set when playing a friend's video over P2P)
+function playVideoFromGallery(galleryName, videoName, peerID = null) {
+    const videoUrl = peerID
+        ? `/api/peer-galleries/${encodeURIComponent(peerID)}/${encodeURIComponent(galleryName)}/${encodeURIComponent(videoName)}?type=video`
+        : `/api/media/video/galleries/${encodeURIComponent(galleryName)}/${encodeURIComponent(videoName)}`;
     
     // Open video player modal
     openSingleVideoPlayer(videoUrl, videoName);
@@ -1278,7 +1560,7 @@ function displayFriendVideoEmptyState(message) {
             <div class="empty-state-icon">🎬</div>
             <div>${message}</div>
             <div class="create-doc-hint">
-                📡 Video files would be requested directly from your friend via P2P connection
+                📡 Video files are requested directly from your friend via P2P connection
             </div>
         </div>
     `;
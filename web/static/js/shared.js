@@ -339,6 +339,180 @@ if (document.readyState === 'loading') {
     initializeSPANavigation();
 }
 
+// Live updates pushed by the node over Server-Sent Events
+let liveEventSource = null;
+const liveEventHandlers = {};
+const liveEventTypes = [
+    'peer.connected', 'peer.disconnected', 'peer.validated', 'peer.blocked', 'peer.unblocked', 'profile.updated', 'peer.key_rotated',
+    'friend.added', 'friend.removed', 'friend.online', 'friend.offline', 'friend.files.added',
+    'friend.request.received', 'friend.request.accepted', 'friend.request.declined', 'friend.request.cancelled',
+    'message.received', 'message.delivered', 'message.read',
+    'post.created', 'post.received',
+    'comment.received', 'comment.removed',
+    'notification.created', 'notifications.read',
+    'file.added', 'file.changed', 'file.removed',
+    'sync.started', 'sync.finished', 'download.progress', 'resync'
+];
+
+function connectLiveEvents() {
+    if (liveEventSource || typeof EventSource === 'undefined') {
+        return;
+    }
+
+    // EventSource reconnects by itself and sends Last-Event-ID, so missed events are replayed
+    liveEventSource = new EventSource('/api/events');
+    liveEventTypes.forEach(type => {
+        liveEventSource.addEventListener(type, (message) => {
+            let event = {type: type};
+            if (message.data) {
+                try {
+                    event = JSON.parse(message.data);
+                } catch (error) {
+                    console.error('Invalid live event:', error);
+                    return;
+                }
+            }
+            dispatchLiveEvent(event);
+        });
+    });
+}
+
+// Register a page's live event handler under a name, replacing the previous one
+// so pages reloaded by SPA navigation don't stack handlers
+function setLiveEventHandler(name, types, handler) {
+    liveEventHandlers[name] = {types: types, handler: handler};
+    connectLiveEvents();
+}
+
+function dispatchLiveEvent(event) {
+    Object.values(liveEventHandlers).forEach(({types, handler}) => {
+        // Pages reload their state when the stream says they missed events
+        if (event.type !== 'resync' && !types.includes(event.type)) {
+            return;
+        }
+        try {
+            handler(event);
+        } catch (error) {
+            console.error('Live event handler failed:', error);
+        }
+    });
+}
+
+// Show download progress in a small indicator
+function updateDownloadProgress(event) {
+    const data = event.data || {};
+    let indicator = document.getElementById('downloadProgress');
+    if (!indicator) {
+        indicator = document.createElement('div');
+        indicator.id = 'downloadProgress';
+        indicator.style.cssText = 'position: fixed; bottom: 20px; right: 20px; padding: 10px 15px; background: #333; color: #fff; border-radius: 5px; font-size: 14px; z-index: 2000; display: none;';
+        document.body.appendChild(indicator);
+    }
+
+    if (data.done) {
+        indicator.textContent = data.error ? `❌ ${data.file_name}: ${data.error}` : `✅ ${data.file_name} downloaded`;
+        setTimeout(() => { indicator.style.display = 'none'; }, 3000);
+    } else {
+        const percent = data.total > 0 ? Math.floor(data.received * 100 / data.total) : 0;
+        indicator.textContent = `⬇️ ${data.file_name} ${percent}%`;
+    }
+    indicator.style.display = 'block';
+}
+
+setLiveEventHandler('downloads', ['download.progress'], (event) => {
+    if (event.type === 'download.progress') {
+        updateDownloadProgress(event);
+    }
+});
+
+// Notification badge and inbox in the sidebar
+function updateNotificationBadge(unread) {
+    const badge = document.getElementById('notificationBadge');
+    if (!badge) {
+        return;
+    }
+    badge.textContent = unread > 99 ? '99+' : String(unread);
+    badge.style.display = unread > 0 ? 'inline-block' : 'none';
+}
+
+async function loadNotifications() {
+    try {
+        const response = await fetchAPI('/api/notifications?limit=30');
+        updateNotificationBadge(response.unread);
+        renderNotifications(response.notifications || []);
+    } catch (error) {
+        console.error('Error loading notifications:', error);
+    }
+}
+
+function renderNotifications(notifications) {
+    const list = document.getElementById('notificationList');
+    if (!list) {
+        return;
+    }
+
+    if (notifications.length === 0) {
+        list.innerHTML = '<div class="notification-empty">Nothing new</div>';
+        return;
+    }
+
+    list.innerHTML = notifications.map(notification => {
+        const name = escapeHtml(notification.peer_name || notification.peer_id.substring(0, 12) + '...');
+        const count = notification.count > 1 ? ` <span class="notification-count">×${notification.count}</span>` : '';
+        const time = new Date(notification.created_at).toLocaleString();
+        return `
+            <div class="notification-item${notification.read ? '' : ' unread'}" onclick="markNotificationRead(${notification.id})">
+                <div><strong>${name}</strong> ${escapeHtml(notification.body)}${count}</div>
+                <div class="notification-time">${escapeHtml(time)}</div>
+            </div>
+        `;
+    }).join('');
+}
+
+function toggleNotificationInbox() {
+    const inbox = document.getElementById('notificationInbox');
+    if (!inbox) {
+        return;
+    }
+    const opening = inbox.style.display === 'none';
+    inbox.style.display = opening ? 'block' : 'none';
+    if (opening) {
+        loadNotifications();
+    }
+}
+
+async function markNotificationRead(id) {
+    try {
+        await fetchAPI(`/api/notifications/${id}/read`, {method: 'POST'});
+        loadNotifications();
+    } catch (error) {
+        console.error('Error marking notification read:', error);
+    }
+}
+
+async function markAllNotificationsRead() {
+    try {
+        await fetchAPI('/api/notifications/read', {method: 'POST'});
+        loadNotifications();
+    } catch (error) {
+        console.error('Error marking notifications read:', error);
+    }
+}
+
+setLiveEventHandler('notifications', ['notification.created', 'notifications.read'], (event) => {
+    if (event.type === 'notifications.read' && event.data) {
+        updateNotificationBadge(event.data.unread);
+        return;
+    }
+    loadNotifications();
+});
+
+if (document.readyState === 'loading') {
+    document.addEventListener('DOMContentLoaded', loadNotifications);
+} else {
+    loadNotifications();
+}
+
 // Keyboard navigation
 document.addEventListener('keydown', function (event) {
     const imageGalleryModal = document.getElementById('imageGalleryModal');
@@ -929,6 +1103,10 @@ window.sharedApp = {
     createAvatarDirectory,
     openAvatarGallery,
 
+    // Live update functions
+    setLiveEventHandler,
+    loadNotifications,
+
     // Utility functions
     getPeerAvatar,
     createPeerAvatarElement,
@@ -949,3 +1127,8 @@ window.globalPreviousTrack = globalPreviousTrack;
 window.globalNextTrack = globalNextTrack;
 window.hideGlobalPlayer = hideGlobalPlayer;
 
+// Notification inbox functions used by the sidebar
+window.toggleNotificationInbox = toggleNotificationInbox;
+window.markNotificationRead = markNotificationRead;
+window.markAllNotificationsRead = markAllNotificationsRead;
+